@@ -0,0 +1,338 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"sort"
+	"strings"
+)
+
+// SLAVE_SCOPE is the affinity scope of the tasks on a slave. Other scopes
+// are HOSTNAME_FIELD and attribute names, i.e. rack.
+const SLAVE_SCOPE = "slave"
+
+/*
+AffinityRule places the tasks matching Selector with the tasks matching
+Target in the same Scope or, when Anti is set, apart from them. Selector
+and Target match the tasks having each of their labels, an empty value
+matching any value of the label. Targets must also have the values of the
+task for the labels in SameLabels:
+
+	// cache replicas never share a host
+	&AffinityRule{Selector: cache, Target: cache, Scope: HOSTNAME_FIELD, Anti: true}
+	// sidecars land on the slave of the primary of their app
+	&AffinityRule{Selector: sidecar, Target: primary, SameLabels: []string{"app"}, Scope: SLAVE_SCOPE}
+	// at most 2 web tasks per rack
+	&AffinityRule{Selector: web, Target: web, Scope: "rack", Anti: true, MaxPer: 2}
+
+An anti-affinity rule allows no target in the scope of the task or, when
+MaxPer is set, up to MaxPer targets counting the task itself. An affinity
+rule needs a target in the scope, unless the task is a target and none
+runs yet. Breaking a Soft rule only makes an offer less preferred.
+*/
+type AffinityRule struct {
+	Selector   map[string]string
+	Target     map[string]string
+	SameLabels []string
+	Scope      string
+	Anti       bool
+	MaxPer     int
+	Soft       bool
+}
+
+func (rule *AffinityRule) String() string {
+	kind := "affinity"
+	if rule.Anti {
+		kind = "anti-affinity"
+	}
+	if rule.Soft {
+		kind = "soft " + kind
+	}
+	text := fmt.Sprintf("%s of [%s] with [%s]", kind, formatLabelSelector(rule.Selector), formatLabelSelector(rule.Target))
+	if len(rule.SameLabels) > 0 {
+		text += " sharing " + strings.Join(rule.SameLabels, ",")
+	}
+	if rule.MaxPer > 0 {
+		text += fmt.Sprintf(" max %d", rule.MaxPer)
+	}
+	return text + " per " + rule.Scope
+}
+
+// targets reports whether other is a target of the rule for task.
+func (rule *AffinityRule) targets(task, other *mesos.TaskInfo) bool {
+	if !labelsMatch(other, rule.Target) {
+		return false
+	}
+	for _, key := range rule.SameLabels {
+		value, ok := TaskLabel(task, key)
+		otherValue, otherOk := TaskLabel(other, key)
+		if !ok || !otherOk || value != otherValue {
+			return false
+		}
+	}
+	return true
+}
+
+// labelsMatch reports whether task has each label of selector.
+func labelsMatch(task *mesos.TaskInfo, selector map[string]string) bool {
+	for key, value := range selector {
+		taskValue, ok := TaskLabel(task, key)
+		if !ok || (value != "" && taskValue != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatLabelSelector returns selector as key=value pairs sorted by key.
+func formatLabelSelector(selector map[string]string) string {
+	pairs := make([]string, 0, len(selector))
+	for key, value := range selector {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// AffinityViolation is a rule broken by placing Task on Offer, with Count
+// targets in the scope of the offer.
+type AffinityViolation struct {
+	Rule  *AffinityRule
+	Task  *mesos.TaskInfo
+	Offer *mesos.Offer
+	Count int
+}
+
+func (violation *AffinityViolation) Error() string {
+	return fmt.Sprintf("Task [%s] on offer [%s] breaks %s with %d targets.",
+		violation.Task.GetTaskId().GetValue(), violation.Offer.GetId().GetValue(), violation.Rule, violation.Count)
+}
+
+/*
+AffinityEvaluator checks the placement of tasks against affinity rules,
+given the tasks running on each slave. Scopes other than SLAVE_SCOPE need
+the hostname and attributes of slaves, which are learned from their offers:
+
+	evaluator := NewAffinityEvaluator(rules...)
+	evaluator.AddTasks(driver.Tasks)
+	evaluator.AddSlave(offer)
+	violations := evaluator.Violations(offer, task)
+
+Tasks are not removed, a new evaluator is expected for each offer cycle.
+The targets of each rule are counted per scope value on the first call
+to Violations after a slave is added, and kept up to date by AddTask.
+*/
+type AffinityEvaluator struct {
+	Rules []*AffinityRule
+
+	slaves map[string]*mesos.Offer
+	tasks  map[string][]*mesos.TaskInfo
+	byId   map[string][]*mesos.TaskInfo
+	counts map[*AffinityRule]*targetCounts
+}
+
+// targetCounts are the targets of a rule by the values of its SameLabels,
+// in total and per scope value.
+type targetCounts struct {
+	totals map[string]int
+	scopes map[string]map[string]int
+}
+
+func NewAffinityEvaluator(rules ...*AffinityRule) *AffinityEvaluator {
+	return &AffinityEvaluator{
+		Rules:  rules,
+		slaves: make(map[string]*mesos.Offer),
+		tasks:  make(map[string][]*mesos.TaskInfo),
+		byId:   make(map[string][]*mesos.TaskInfo),
+	}
+}
+
+// AddSlave records the hostname and attributes of the slave of offer.
+func (evaluator *AffinityEvaluator) AddSlave(offer *mesos.Offer) {
+	evaluator.slaves[offer.GetSlaveId().GetValue()] = offer
+	// the scope of tasks on the slave may change, counts are redone
+	evaluator.counts = nil
+}
+
+// AddTask records task as running on its slave.
+func (evaluator *AffinityEvaluator) AddTask(task *mesos.TaskInfo) {
+	slaveId := task.GetSlaveId().GetValue()
+	evaluator.tasks[slaveId] = append(evaluator.tasks[slaveId], task)
+	taskId := task.GetTaskId().GetValue()
+	evaluator.byId[taskId] = append(evaluator.byId[taskId], task)
+	for rule, counts := range evaluator.counts {
+		evaluator.count(counts, rule, task)
+	}
+}
+
+// AddTasks records the active tasks of registry.
+func (evaluator *AffinityEvaluator) AddTasks(registry *TaskRegistry) {
+	for _, record := range registry.Active() {
+		evaluator.AddTask(record.Info)
+	}
+}
+
+// Violations returns the rules broken by placing task on offer.
+func (evaluator *AffinityEvaluator) Violations(offer *mesos.Offer, task *mesos.TaskInfo) []*AffinityViolation {
+	var violations []*AffinityViolation
+	for _, rule := range evaluator.Rules {
+		if !labelsMatch(task, rule.Selector) {
+			continue
+		}
+		scope, ok := scopeValue(offer, rule.Scope)
+		count, total := 0, 0
+		if key, keyOk := sameLabelsKey(task, rule.SameLabels); keyOk {
+			counts := evaluator.targetCounts(rule)
+			total = counts.totals[key]
+			if ok {
+				count = counts.scopes[key][scope]
+			}
+			// the task itself is not counted
+			for _, other := range evaluator.byId[task.GetTaskId().GetValue()] {
+				if !rule.targets(task, other) {
+					continue
+				}
+				total--
+				if otherScope, otherOk := evaluator.taskScope(other, rule.Scope); ok && otherOk && otherScope == scope {
+					count--
+				}
+			}
+		}
+
+		var broken bool
+		switch {
+		case rule.Anti && rule.MaxPer > 0:
+			if rule.targets(task, task) {
+				count++
+			}
+			broken = ok && count > rule.MaxPer
+		case rule.Anti:
+			broken = ok && count > 0
+		default:
+			broken = count == 0 && (total > 0 || !rule.targets(task, task))
+		}
+		if broken {
+			violations = append(violations, &AffinityViolation{Rule: rule, Task: task, Offer: offer, Count: count})
+		}
+	}
+	return violations
+}
+
+// targetCounts returns the targets of rule among the tasks, counting them
+// unless done since the last slave was added.
+func (evaluator *AffinityEvaluator) targetCounts(rule *AffinityRule) *targetCounts {
+	if counts, ok := evaluator.counts[rule]; ok {
+		return counts
+	}
+	if evaluator.counts == nil {
+		evaluator.counts = make(map[*AffinityRule]*targetCounts)
+	}
+	counts := &targetCounts{totals: make(map[string]int), scopes: make(map[string]map[string]int)}
+	for _, tasks := range evaluator.tasks {
+		for _, task := range tasks {
+			evaluator.count(counts, rule, task)
+		}
+	}
+	evaluator.counts[rule] = counts
+	return counts
+}
+
+// count adds task to counts when it is a target of rule.
+func (evaluator *AffinityEvaluator) count(counts *targetCounts, rule *AffinityRule, task *mesos.TaskInfo) {
+	if !labelsMatch(task, rule.Target) {
+		return
+	}
+	key, ok := sameLabelsKey(task, rule.SameLabels)
+	if !ok {
+		return
+	}
+	counts.totals[key]++
+	if scope, ok := evaluator.taskScope(task, rule.Scope); ok {
+		if counts.scopes[key] == nil {
+			counts.scopes[key] = make(map[string]int)
+		}
+		counts.scopes[key][scope]++
+	}
+}
+
+// taskScope returns the scope value of the slave of task. The scope of
+// slaves without known offers is unknown.
+func (evaluator *AffinityEvaluator) taskScope(task *mesos.TaskInfo, scope string) (string, bool) {
+	slaveId := task.GetSlaveId().GetValue()
+	if scope == SLAVE_SCOPE {
+		return slaveId, true
+	}
+	slave, ok := evaluator.slaves[slaveId]
+	if !ok {
+		return "", false
+	}
+	return scopeValue(slave, scope)
+}
+
+// sameLabelsKey joins the values of task for labels. Tasks are targets of
+// each other for labels only when both have them all with the same key.
+func sameLabelsKey(task *mesos.TaskInfo, labels []string) (string, bool) {
+	values := make([]string, len(labels))
+	for i, label := range labels {
+		value, ok := TaskLabel(task, label)
+		if !ok {
+			return "", false
+		}
+		values[i] = value
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+// scopeValue returns the slave id, hostname or attribute of offer for scope.
+func scopeValue(offer *mesos.Offer, scope string) (string, bool) {
+	if scope == SLAVE_SCOPE {
+		return offer.GetSlaveId().GetValue(), true
+	}
+	attribute := offerField(offer, scope)
+	if attribute == nil {
+		return "", false
+	}
+	return attributeString(attribute), true
+}
+
+// clone returns a copy of the evaluator that tasks can be added to.
+func (evaluator *AffinityEvaluator) clone() *AffinityEvaluator {
+	clone := NewAffinityEvaluator(evaluator.Rules...)
+	for slaveId, offer := range evaluator.slaves {
+		clone.slaves[slaveId] = offer
+	}
+	for slaveId, tasks := range evaluator.tasks {
+		clone.tasks[slaveId] = append([]*mesos.TaskInfo{}, tasks...)
+	}
+	for taskId, tasks := range evaluator.byId {
+		clone.byId[taskId] = append([]*mesos.TaskInfo{}, tasks...)
+	}
+	return clone
+}
+
+// filter returns the candidates breaking no hard rule and the fewest soft
+// rules for task, with these soft rules set as their Violations.
+func (evaluator *AffinityEvaluator) filter(task *mesos.TaskInfo, fits []*Candidate) []*Candidate {
+	kept := fits[:0]
+	least := -1
+	for _, candidate := range fits {
+		candidate.Violations = nil
+		hard := false
+		for _, violation := range evaluator.Violations(candidate.Offer, task) {
+			if !violation.Rule.Soft {
+				hard = true
+				break
+			}
+			candidate.Violations = append(candidate.Violations, violation)
+		}
+		switch {
+		case hard || (least >= 0 && len(candidate.Violations) > least):
+			continue
+		case len(candidate.Violations) < least:
+			kept = kept[:0]
+		}
+		least = len(candidate.Violations)
+		kept = append(kept, candidate)
+	}
+	return kept
+}
@@ -0,0 +1,158 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"testing"
+)
+
+// makeLabeledTask returns a task on slave labeled with key=value pairs.
+func makeLabeledTask(id, slave string, labels ...string) *mesos.TaskInfo {
+	task := makeLaunchTask(id, slave, 1, 128)
+	task.Labels = &mesos.Labels{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		task.Labels.Labels = append(task.Labels.Labels, NewLabel(labels[i], labels[i+1]))
+	}
+	return task
+}
+
+func TestAffinityEvaluator_AntiAffinity(t *testing.T) {
+	cache := map[string]string{"role": "cache"}
+	rule := &AffinityRule{Selector: cache, Target: cache, Scope: HOSTNAME_FIELD, Anti: true}
+	evaluator := NewAffinityEvaluator(rule)
+	host1 := makeAttributeOffer(t, "offer-1", "host-1", "")
+	host2 := makeAttributeOffer(t, "offer-2", "host-2", "")
+	evaluator.AddSlave(host1)
+	evaluator.AddTask(makeLabeledTask("cache-1", host1.GetSlaveId().GetValue(), "role", "cache"))
+
+	violations := evaluator.Violations(host1, makeLabeledTask("cache-2", "", "role", "cache"))
+	if len(violations) != 1 || violations[0].Rule != rule || violations[0].Count != 1 {
+		t.Fatal("Expected cache-2 on host-1 to break the anti-affinity rule, but got", violations)
+	}
+	if violations[0].Error() != "Task [cache-2] on offer [offer-1] breaks anti-affinity of [role=cache] with [role=cache] per hostname with 1 targets." {
+		t.Fatal("Unexpected violation message", violations[0].Error())
+	}
+	if violations = evaluator.Violations(host2, makeLabeledTask("cache-2", "", "role", "cache")); len(violations) != 0 {
+		t.Fatal("Expected cache-2 on host-2 to break no rule, but got", violations)
+	}
+	if violations = evaluator.Violations(host1, makeLabeledTask("web-1", "", "role", "web")); len(violations) != 0 {
+		t.Fatal("Expected rule to ignore tasks not selected, but got", violations)
+	}
+	// the task itself is not counted
+	if violations = evaluator.Violations(host1, makeLabeledTask("cache-1", "", "role", "cache")); len(violations) != 0 {
+		t.Fatal("Expected cache-1 not to be its own target, but got", violations)
+	}
+}
+
+func TestAffinityEvaluator_Affinity(t *testing.T) {
+	rule := &AffinityRule{
+		Selector:   map[string]string{"role": "sidecar"},
+		Target:     map[string]string{"role": "primary"},
+		SameLabels: []string{"app"},
+		Scope:      SLAVE_SCOPE,
+	}
+	evaluator := NewAffinityEvaluator(rule)
+	slave1 := makeLaunchOffer("offer-1", "slave-1", 4, 4096)
+	slave2 := makeLaunchOffer("offer-2", "slave-2", 4, 4096)
+
+	registry := NewTaskRegistry()
+	registry.Add(makeLabeledTask("web-1", "slave-1", "role", "primary", "app", "web"), nil)
+	registry.Add(makeLabeledTask("db-1", "slave-2", "role", "primary", "app", "db"), nil)
+	registry.Update(makeTaskUpdate("db-1", mesos.TaskState_TASK_FINISHED))
+	evaluator.AddTasks(registry)
+
+	sidecar := makeLabeledTask("web-sidecar", "", "role", "sidecar", "app", "web")
+	if violations := evaluator.Violations(slave1, sidecar); len(violations) != 0 {
+		t.Fatal("Expected web sidecar next to its primary to break no rule, but got", violations)
+	}
+	if violations := evaluator.Violations(slave2, sidecar); len(violations) != 1 {
+		t.Fatal("Expected web sidecar away from its primary to break the rule, but got", violations)
+	}
+	sidecar = makeLabeledTask("db-sidecar", "", "role", "sidecar", "app", "db")
+	if violations := evaluator.Violations(slave2, sidecar); len(violations) != 1 {
+		t.Fatal("Expected db sidecar without a running primary to break the rule, but got", violations)
+	}
+
+	// tasks clustered with each other may start anywhere
+	web := map[string]string{"app": "web"}
+	evaluator = NewAffinityEvaluator(&AffinityRule{Selector: web, Target: web, Scope: SLAVE_SCOPE})
+	if violations := evaluator.Violations(slave2, makeLabeledTask("web-1", "", "app", "web")); len(violations) != 0 {
+		t.Fatal("Expected first clustered task to break no rule, but got", violations)
+	}
+	evaluator.AddTask(makeLabeledTask("web-1", "slave-1", "app", "web"))
+	if violations := evaluator.Violations(slave2, makeLabeledTask("web-2", "", "app", "web")); len(violations) != 1 {
+		t.Fatal("Expected second clustered task away from the first to break the rule, but got", violations)
+	}
+}
+
+func TestAffinityEvaluator_MaxPer(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	evaluator := NewAffinityEvaluator(&AffinityRule{Selector: web, Target: web, Scope: "rack", Anti: true, MaxPer: 2})
+	r1a := makeAttributeOffer(t, "offer-1", "host-1", "rack:r1")
+	r1b := makeAttributeOffer(t, "offer-2", "host-2", "rack:r1")
+	r2 := makeAttributeOffer(t, "offer-3", "host-3", "rack:r2")
+	norack := makeAttributeOffer(t, "offer-4", "host-4", "")
+	evaluator.AddSlave(r1a)
+	evaluator.AddSlave(r1b)
+	evaluator.AddTask(makeLabeledTask("web-1", "slave-host-1", "app", "web"))
+	evaluator.AddTask(makeLabeledTask("web-2", "slave-host-2", "app", "web"))
+	// slave without known offer is out of every rack
+	evaluator.AddTask(makeLabeledTask("web-3", "slave-host-9", "app", "web"))
+
+	task := makeLabeledTask("web-4", "", "app", "web")
+	if violations := evaluator.Violations(r1a, task); len(violations) != 1 || violations[0].Count != 3 {
+		t.Fatal("Expected third web task on rack r1 to break the rule, but got", violations)
+	}
+	if violations := evaluator.Violations(r2, task); len(violations) != 0 {
+		t.Fatal("Expected web task on rack r2 to break no rule, but got", violations)
+	}
+	if violations := evaluator.Violations(norack, task); len(violations) != 0 {
+		t.Fatal("Expected web task on slave without rack to break no rule, but got", violations)
+	}
+
+	// counts follow slaves and tasks added after the first evaluation
+	evaluator.AddSlave(r2)
+	evaluator.AddSlave(makeAttributeOffer(t, "offer-9", "host-9", "rack:r2"))
+	evaluator.AddTask(makeLabeledTask("web-5", "slave-host-3", "app", "web"))
+	if violations := evaluator.Violations(r2, task); len(violations) != 1 || violations[0].Count != 3 {
+		t.Fatal("Expected third web task on rack r2 to break the rule, but got", violations)
+	}
+	if violations := evaluator.Violations(r2, makeLabeledTask("web-5", "", "app", "web")); len(violations) != 0 {
+		t.Fatal("Expected web-5 not to be its own target, but got", violations)
+	}
+}
+
+func TestPlanner_Affinity(t *testing.T) {
+	offers := []*mesos.Offer{
+		makeAttributeOffer(t, "offer-1", "host-1", "rack:r1"),
+		makeAttributeOffer(t, "offer-2", "host-2", "rack:r1"),
+		makeAttributeOffer(t, "offer-3", "host-3", "rack:r2"),
+	}
+	cache := map[string]string{"role": "cache"}
+	planner := NewPlanner(FirstFit)
+	planner.Affinity = NewAffinityEvaluator(
+		&AffinityRule{Selector: cache, Target: cache, Scope: HOSTNAME_FIELD, Anti: true},
+		&AffinityRule{Selector: cache, Target: cache, Scope: "rack", Anti: true, Soft: true},
+	)
+	planner.Affinity.AddTask(makeLabeledTask("cache-0", "slave-host-1", "role", "cache"))
+
+	demands := []*Demand{
+		NewDemand(makeLabeledTask("cache-1", "", "role", "cache")),
+		NewDemand(makeLabeledTask("cache-2", "", "role", "cache")),
+		NewDemand(makeLabeledTask("cache-3", "", "role", "cache")),
+	}
+	plan := planner.Plan(offers, demands)
+	summary := planSummary(plan)
+	// rack r2 is preferred, then host-2 breaks the soft rack rule only
+	if summary["cache-1"] != "offer-3" || summary["cache-2"] != "offer-2" || len(plan.Unplaced) != 1 {
+		t.Fatal("Expected cache replicas on separate hosts, but got", summary)
+	}
+	if len(plan.Violations) != 1 || plan.Violations[0].Task.GetTaskId().GetValue() != "cache-2" || !plan.Violations[0].Rule.Soft {
+		t.Fatal("Expected cache-2 to break the soft rack rule, but got", plan.Violations)
+	}
+
+	// planned tasks are not kept by the planner's evaluator
+	plan = planner.Plan(offers, demands[:1])
+	if summary = planSummary(plan); summary["cache-1"] != "offer-3" {
+		t.Fatal("Expected cache-1 on offer-3 again, but got", summary)
+	}
+}
@@ -0,0 +1,273 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+)
+
+// CommandBuilder builds a CommandInfo. Validation happens in Build.
+type CommandBuilder struct {
+	command *mesos.CommandInfo
+}
+
+// NewCommand starts a CommandInfo running value with the shell.
+func NewCommand(value string) *CommandBuilder {
+	return &CommandBuilder{command: &mesos.CommandInfo{Value: proto.String(value)}}
+}
+
+// WithURI adds a URI fetched into the sandbox before the command runs.
+func (b *CommandBuilder) WithURI(uri string, executable bool) *CommandBuilder {
+	b.command.Uris = append(b.command.Uris, &mesos.CommandInfo_URI{
+		Value:      proto.String(uri),
+		Executable: proto.Bool(executable),
+	})
+	return b
+}
+
+// WithEnv sets an environment variable of the command.
+func (b *CommandBuilder) WithEnv(name, value string) *CommandBuilder {
+	if b.command.Environment == nil {
+		b.command.Environment = &mesos.Environment{}
+	}
+	b.command.Environment.Variables = append(b.command.Environment.Variables, &mesos.Environment_Variable{
+		Name:  proto.String(name),
+		Value: proto.String(value),
+	})
+	return b
+}
+
+func (b *CommandBuilder) Build() (*mesos.CommandInfo, error) {
+	if b.command.GetValue() == "" {
+		return nil, fmt.Errorf("Command is missing a value.")
+	}
+	for _, uri := range b.command.GetUris() {
+		if uri.GetValue() == "" {
+			return nil, fmt.Errorf("Command [%s] has an empty URI.", b.command.GetValue())
+		}
+	}
+	names := make(map[string]bool)
+	for _, variable := range b.command.GetEnvironment().GetVariables() {
+		if variable.GetName() == "" || names[variable.GetName()] {
+			return nil, fmt.Errorf("Command [%s] has empty or duplicate environment variable [%s].",
+				b.command.GetValue(), variable.GetName())
+		}
+		names[variable.GetName()] = true
+	}
+	return proto.Clone(b.command).(*mesos.CommandInfo), nil
+}
+
+// withValue sets the command value, keeping the URIs and environment
+// added so far.
+func (b *CommandBuilder) withValue(value string) *CommandBuilder {
+	b.command.Value = proto.String(value)
+	return b
+}
+
+// ExecutorBuilder builds an ExecutorInfo. Validation happens in Build.
+type ExecutorBuilder struct {
+	executor *mesos.ExecutorInfo
+	command  *CommandBuilder
+}
+
+func NewExecutor(id string) *ExecutorBuilder {
+	return &ExecutorBuilder{executor: &mesos.ExecutorInfo{ExecutorId: NewExecutorID(id)}}
+}
+
+func (b *ExecutorBuilder) WithName(name string) *ExecutorBuilder {
+	b.executor.Name = proto.String(name)
+	return b
+}
+
+func (b *ExecutorBuilder) WithSource(source string) *ExecutorBuilder {
+	b.executor.Source = proto.String(source)
+	return b
+}
+
+func (b *ExecutorBuilder) WithFrameworkId(id *mesos.FrameworkID) *ExecutorBuilder {
+	b.executor.FrameworkId = id
+	return b
+}
+
+// WithCommand sets the command launching the executor.
+func (b *ExecutorBuilder) WithCommand(value string) *ExecutorBuilder {
+	b.commandBuilder().withValue(value)
+	return b
+}
+
+// WithURI adds a URI to the executor command.
+func (b *ExecutorBuilder) WithURI(uri string, executable bool) *ExecutorBuilder {
+	b.commandBuilder().WithURI(uri, executable)
+	return b
+}
+
+// WithEnv sets an environment variable of the executor command.
+func (b *ExecutorBuilder) WithEnv(name, value string) *ExecutorBuilder {
+	b.commandBuilder().WithEnv(name, value)
+	return b
+}
+
+func (b *ExecutorBuilder) WithResources(resources ...*mesos.Resource) *ExecutorBuilder {
+	b.executor.Resources = append(b.executor.Resources, resources...)
+	return b
+}
+
+func (b *ExecutorBuilder) WithData(data []byte) *ExecutorBuilder {
+	b.executor.Data = data
+	return b
+}
+
+func (b *ExecutorBuilder) commandBuilder() *CommandBuilder {
+	if b.command == nil {
+		b.command = NewCommand("")
+	}
+	return b.command
+}
+
+func (b *ExecutorBuilder) Build() (*mesos.ExecutorInfo, error) {
+	id := b.executor.GetExecutorId().GetValue()
+	if id == "" {
+		return nil, fmt.Errorf("Executor is missing an id.")
+	}
+	if b.command == nil {
+		return nil, fmt.Errorf("Executor %s is missing a command.", id)
+	}
+	command, err := b.command.Build()
+	if err != nil {
+		return nil, fmt.Errorf("Executor %s: %s", id, err)
+	}
+	if err := Resources(b.executor.GetResources()).Validate(); err != nil {
+		return nil, fmt.Errorf("Executor %s: %s", id, err)
+	}
+	executor := proto.Clone(b.executor).(*mesos.ExecutorInfo)
+	executor.Command = command
+	return executor, nil
+}
+
+/*
+TaskBuilder builds a TaskInfo, i.e.
+
+	task, err := NewTask("web").
+		WithId("web-1").
+		FromOffer(offer).
+		WithResources(NewScalarResource("cpus", 1)).
+		WithCommand("./server").
+		WithURI("http://repo/server.tgz", false).
+		WithEnv("PORT", "8080").
+		Build()
+
+Build validates the required fields, and that exactly one of an executor
+or a command is set.
+*/
+type TaskBuilder struct {
+	task     *mesos.TaskInfo
+	command  *CommandBuilder
+	executor *ExecutorBuilder
+}
+
+func NewTask(name string) *TaskBuilder {
+	return &TaskBuilder{task: &mesos.TaskInfo{Name: proto.String(name)}}
+}
+
+func (b *TaskBuilder) WithId(id string) *TaskBuilder {
+	b.task.TaskId = NewTaskID(id)
+	return b
+}
+
+func (b *TaskBuilder) WithSlaveId(id *mesos.SlaveID) *TaskBuilder {
+	b.task.SlaveId = id
+	return b
+}
+
+// FromOffer places the task on the slave of offer.
+func (b *TaskBuilder) FromOffer(offer *mesos.Offer) *TaskBuilder {
+	b.task.SlaveId = offer.GetSlaveId()
+	return b
+}
+
+func (b *TaskBuilder) WithResources(resources ...*mesos.Resource) *TaskBuilder {
+	b.task.Resources = append(b.task.Resources, resources...)
+	return b
+}
+
+// WithCommand runs the task with the command executor.
+func (b *TaskBuilder) WithCommand(value string) *TaskBuilder {
+	b.commandBuilder().withValue(value)
+	return b
+}
+
+// WithURI adds a URI to the task command.
+func (b *TaskBuilder) WithURI(uri string, executable bool) *TaskBuilder {
+	b.commandBuilder().WithURI(uri, executable)
+	return b
+}
+
+// WithEnv sets an environment variable of the task command.
+func (b *TaskBuilder) WithEnv(name, value string) *TaskBuilder {
+	b.commandBuilder().WithEnv(name, value)
+	return b
+}
+
+// WithExecutor runs the task with a custom executor.
+func (b *TaskBuilder) WithExecutor(executor *ExecutorBuilder) *TaskBuilder {
+	b.executor = executor
+	return b
+}
+
+func (b *TaskBuilder) WithData(data []byte) *TaskBuilder {
+	b.task.Data = data
+	return b
+}
+
+// WithLabel tags the task with label key=value.
+func (b *TaskBuilder) WithLabel(key, value string) *TaskBuilder {
+	if b.task.Labels == nil {
+		b.task.Labels = &mesos.Labels{}
+	}
+	b.task.Labels.Labels = append(b.task.Labels.Labels, NewLabel(key, value))
+	return b
+}
+
+func (b *TaskBuilder) commandBuilder() *CommandBuilder {
+	if b.command == nil {
+		b.command = NewCommand("")
+	}
+	return b.command
+}
+
+func (b *TaskBuilder) Build() (*mesos.TaskInfo, error) {
+	name := b.task.GetName()
+	if name == "" {
+		return nil, fmt.Errorf("Task is missing a name.")
+	}
+	if b.task.GetTaskId().GetValue() == "" {
+		return nil, fmt.Errorf("Task %s is missing a task id.", name)
+	}
+	if b.task.GetSlaveId().GetValue() == "" {
+		return nil, fmt.Errorf("Task %s is missing a slave id.", name)
+	}
+	if (b.command == nil) == (b.executor == nil) {
+		return nil, fmt.Errorf("Task %s must have exactly one of an executor or a command.", name)
+	}
+	if err := Resources(b.task.GetResources()).Validate(); err != nil {
+		return nil, fmt.Errorf("Task %s: %s", name, err)
+	}
+
+	// each build returns a new TaskInfo, later changes to the builder do
+	// not affect it
+	task := proto.Clone(b.task).(*mesos.TaskInfo)
+	if b.command != nil {
+		command, err := b.command.Build()
+		if err != nil {
+			return nil, fmt.Errorf("Task %s: %s", name, err)
+		}
+		task.Command = command
+	} else {
+		executor, err := b.executor.Build()
+		if err != nil {
+			return nil, fmt.Errorf("Task %s: %s", name, err)
+		}
+		task.Executor = executor
+	}
+	return task, nil
+}
@@ -0,0 +1,130 @@
+package gomes
+
+import (
+	"testing"
+)
+
+func TestTaskBuilder_Command(t *testing.T) {
+	offer := NewOffer(NewOfferID("offer-1"), NewFrameworkID("framework-1"), NewSlaveID("slave-1"), "host-a")
+	task, err := NewTask("web").
+		WithId("web-1").
+		FromOffer(offer).
+		WithResources(NewScalarResource("cpus", 1), NewScalarResource("mem", 128)).
+		WithCommand("./server").
+		WithURI("http://repo/server.tgz", false).
+		WithEnv("PORT", "8080").
+		WithData([]byte("payload")).
+		WithLabel("role", "frontend").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.GetName() != "web" || task.GetTaskId().GetValue() != "web-1" || task.GetSlaveId().GetValue() != "slave-1" {
+		t.Fatal("TaskInfo missing name, id or slave id:", task)
+	}
+	command := task.GetCommand()
+	if command.GetValue() != "./server" || command.GetUris()[0].GetValue() != "http://repo/server.tgz" {
+		t.Fatal("TaskInfo.Command missing value or URI:", command)
+	}
+	variables := command.GetEnvironment().GetVariables()
+	if len(variables) != 1 || variables[0].GetName() != "PORT" || variables[0].GetValue() != "8080" {
+		t.Fatal("TaskInfo.Command missing environment:", command)
+	}
+	if task.Executor != nil || string(task.GetData()) != "payload" || len(task.GetResources()) != 2 {
+		t.Fatal("Unexpected TaskInfo", task)
+	}
+	if role, ok := TaskLabel(task, "role"); !ok || role != "frontend" {
+		t.Fatal("TaskInfo missing label role=frontend:", task.GetLabels())
+	}
+	if _, ok := TaskLabel(task, "tier"); ok {
+		t.Fatal("TaskInfo has unexpected label tier.")
+	}
+}
+
+func TestTaskBuilder_Executor(t *testing.T) {
+	task, err := NewTask("worker").
+		WithId("worker-1").
+		WithSlaveId(NewSlaveID("slave-1")).
+		WithExecutor(NewExecutor("exec-1").
+			WithName("worker executor").
+			WithCommand("./executor").
+			WithURI("http://repo/executor", true).
+			WithResources(NewScalarResource("cpus", 0.1))).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := task.GetExecutor()
+	if task.Command != nil || executor.GetExecutorId().GetValue() != "exec-1" || executor.GetCommand().GetValue() != "./executor" {
+		t.Fatal("Unexpected TaskInfo.Executor", executor)
+	}
+	if !executor.GetCommand().GetUris()[0].GetExecutable() {
+		t.Fatal("Executor URI expected to be executable.")
+	}
+}
+
+func TestTaskBuilder_CommandAfterURI(t *testing.T) {
+	builder := NewTask("web").
+		WithId("web-1").
+		WithSlaveId(NewSlaveID("slave-1")).
+		WithURI("http://repo/server.tgz", false).
+		WithEnv("PORT", "8080").
+		WithCommand("./server")
+	task, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := task.GetCommand()
+	if command.GetValue() != "./server" || len(command.GetUris()) != 1 || len(command.GetEnvironment().GetVariables()) != 1 {
+		t.Fatal("Expected URI and environment kept by WithCommand, but got", command)
+	}
+
+	// built tasks are not changed by later use of the builder
+	builder.WithId("web-2").WithURI("http://repo/config.tgz", false)
+	again, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.GetTaskId().GetValue() != "web-1" || len(task.GetCommand().GetUris()) != 1 {
+		t.Fatal("Expected built task unchanged, but got", task)
+	}
+	if again.GetTaskId().GetValue() != "web-2" || len(again.GetCommand().GetUris()) != 2 {
+		t.Fatal("Expected second build with new id and URI, but got", again)
+	}
+}
+
+func TestTaskBuilder_Validation(t *testing.T) {
+	builders := map[string]*TaskBuilder{
+		"no name":     NewTask("").WithId("t").WithSlaveId(NewSlaveID("s")).WithCommand("ls"),
+		"no id":       NewTask("t").WithSlaveId(NewSlaveID("s")).WithCommand("ls"),
+		"no slave":    NewTask("t").WithId("t").WithCommand("ls"),
+		"neither":     NewTask("t").WithId("t").WithSlaveId(NewSlaveID("s")),
+		"both":        NewTask("t").WithId("t").WithSlaveId(NewSlaveID("s")).WithCommand("ls").WithExecutor(NewExecutor("e").WithCommand("ls")),
+		"uri only":    NewTask("t").WithId("t").WithSlaveId(NewSlaveID("s")).WithURI("http://a", false),
+		"bad env":     NewTask("t").WithId("t").WithSlaveId(NewSlaveID("s")).WithCommand("ls").WithEnv("A", "1").WithEnv("A", "2"),
+		"bad cpus":    NewTask("t").WithId("t").WithSlaveId(NewSlaveID("s")).WithCommand("ls").WithResources(NewScalarResource("cpus", -1)),
+		"no exec cmd": NewTask("t").WithId("t").WithSlaveId(NewSlaveID("s")).WithExecutor(NewExecutor("e")),
+		"no exec id":  NewTask("t").WithId("t").WithSlaveId(NewSlaveID("s")).WithExecutor(NewExecutor("").WithCommand("ls")),
+	}
+	for name, builder := range builders {
+		if _, err := builder.Build(); err == nil {
+			t.Fatal("Expected validation error for task with", name)
+		}
+	}
+}
+
+func TestCommandBuilder(t *testing.T) {
+	command, err := NewCommand("echo $GREETING").WithEnv("GREETING", "hello").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command.GetEnvironment().GetVariables()[0].GetValue() != "hello" {
+		t.Fatal("CommandInfo missing environment variable.")
+	}
+	if _, err := NewCommand("ls").WithURI("", false).Build(); err == nil {
+		t.Fatal("Expected error for empty URI.")
+	}
+	if _, err := NewCommand("").Build(); err == nil {
+		t.Fatal("Expected error for empty command.")
+	}
+}
@@ -4,15 +4,46 @@ const (
 	MESOS_INTERNAL_PREFIX  = "mesos.internal."
 	MESOS_SCHEDULER_PREFIX = "scheduler"
 	HTTP_SCHEME            = "http"
+	HTTPS_SCHEME           = "https"
 	HTTP_POST_METHOD       = "POST"
 	HTTP_MASTER_PREFIX     = "master"
 	HTTP_LIBPROC_PREFIX    = "libprocess/"
 	HTTP_CONTENT_TYPE      = "application/x-protobuf"
+	HTTP_API_SCHEDULER     = "/api/v1/scheduler"
+	HTTP_API_EXECUTOR      = "/api/v1/executor"
+	HTTP_STREAM_ID_HEADER  = "Mesos-Stream-Id"
+)
+
+// Environment variables used to configure the scheduler process address
+const (
+	LIBPROCESS_IP_ENV             = "LIBPROCESS_IP"
+	LIBPROCESS_PORT_ENV           = "LIBPROCESS_PORT"
+	LIBPROCESS_ADVERTISE_IP_ENV   = "LIBPROCESS_ADVERTISE_IP"
+	LIBPROCESS_ADVERTISE_PORT_ENV = "LIBPROCESS_ADVERTISE_PORT"
+)
+
+// Environment variables used to configure TLS (same names as libprocess)
+const (
+	LIBPROCESS_SSL_ENABLED_ENV           = "LIBPROCESS_SSL_ENABLED"
+	LIBPROCESS_SSL_CERT_FILE_ENV         = "LIBPROCESS_SSL_CERT_FILE"
+	LIBPROCESS_SSL_KEY_FILE_ENV          = "LIBPROCESS_SSL_KEY_FILE"
+	LIBPROCESS_SSL_CA_FILE_ENV           = "LIBPROCESS_SSL_CA_FILE"
+	LIBPROCESS_SSL_VERIFY_CERT_ENV       = "LIBPROCESS_SSL_VERIFY_CERT"
+	LIBPROCESS_SSL_REQUIRE_CERT_ENV      = "LIBPROCESS_SSL_REQUIRE_CERT"
+	LIBPROCESS_SSL_SUPPORT_DOWNGRADE_ENV = "LIBPROCESS_SSL_SUPPORT_DOWNGRADE"
+)
+
+// Environment variables set by the agent for executors using the HTTP API
+const (
+	MESOS_AGENT_ENDPOINT_ENV = "MESOS_AGENT_ENDPOINT"
+	MESOS_FRAMEWORK_ID_ENV   = "MESOS_FRAMEWORK_ID"
+	MESOS_EXECUTOR_ID_ENV    = "MESOS_EXECUTOR_ID"
 )
 
 // calls from sched to master
 const (
 	REGISTER_FRAMEWORK_CALL   = "RegisterFrameworkMessage"
+	REREGISTER_FRAMEWORK_CALL = "ReregisterFrameworkMessage"
 	UNREGISTER_FRAMEWORK_CALL = "UnregisterFrameworkMessage"
 	DEACTIVATE_FRAMEWORK_CALL = "DeactivateFrameworkMessage"
 	KILL_TASK_CALL            = "KillTaskMessage"
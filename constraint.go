@@ -0,0 +1,379 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Constraint operators
+const (
+	UNIQUE_OPERATOR   = "UNIQUE"
+	CLUSTER_OPERATOR  = "CLUSTER"
+	GROUP_BY_OPERATOR = "GROUP_BY"
+	LIKE_OPERATOR     = "LIKE"
+	UNLIKE_OPERATOR   = "UNLIKE"
+	MAX_PER_OPERATOR  = "MAX_PER"
+)
+
+// HOSTNAME_FIELD is the constraint field matching the hostname of offers,
+// every other field names an attribute.
+const HOSTNAME_FIELD = "hostname"
+
+/*
+Constraint restricts the offers a task may be placed on, based on the
+hostname or an attribute of the offers and on the offers the tasks of the
+same group were placed on:
+
+	hostname UNIQUE      at most one task per hostname
+	rack_id CLUSTER r1   only on offers with rack_id r1
+	rack_id CLUSTER      all tasks on the same rack_id
+	zone GROUP_BY 3      evenly spread across 3 zones
+	instance LIKE m4.*   only where instance matches m4.*
+	gpu UNLIKE true      only where gpu does not match true
+	rack_id MAX_PER 2    at most 2 tasks per rack_id
+
+LIKE and UNLIKE patterns match the whole value; a SET attribute matches
+when any of its items does. Other operators compare values as parsed by
+ParseAttributes, so ranges and sets compare regardless of their order.
+Offers without the attribute only satisfy UNLIKE.
+*/
+type Constraint struct {
+	Field    string
+	Operator string
+	Value    string
+
+	checked bool
+	pattern *regexp.Regexp
+	limit   int
+}
+
+// NewConstraint validates the operator and value of a constraint.
+func NewConstraint(field, operator, value string) (*Constraint, error) {
+	constraint := &Constraint{Field: field, Operator: operator, Value: value, checked: true}
+	if field == "" {
+		return nil, fmt.Errorf("Invalid constraint [%s]: missing field.", constraint)
+	}
+	var err error
+	switch operator {
+	case UNIQUE_OPERATOR:
+		if value != "" {
+			err = fmt.Errorf("unexpected value.")
+		}
+	case CLUSTER_OPERATOR:
+	case GROUP_BY_OPERATOR, MAX_PER_OPERATOR:
+		if value == "" && operator == GROUP_BY_OPERATOR {
+			break
+		}
+		if constraint.limit, err = strconv.Atoi(value); err != nil || constraint.limit < 1 {
+			err = fmt.Errorf("expected a positive count.")
+		}
+	case LIKE_OPERATOR, UNLIKE_OPERATOR:
+		if value == "" {
+			err = fmt.Errorf("missing pattern.")
+		} else if constraint.pattern, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			err = fmt.Errorf("invalid pattern: %s.", err)
+		}
+	default:
+		err = fmt.Errorf("unknown operator.")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid constraint [%s]: %s", constraint, err)
+	}
+	return constraint, nil
+}
+
+// ParseConstraint parses a constraint written as field OPERATOR [value].
+func ParseConstraint(text string) (*Constraint, error) {
+	tokens := strings.Fields(text)
+	if len(tokens) < 2 {
+		return nil, fmt.Errorf("Invalid constraint [%s]: expected field OPERATOR [value].", text)
+	}
+	return NewConstraint(tokens[0], tokens[1], strings.Join(tokens[2:], " "))
+}
+
+func (constraint *Constraint) String() string {
+	if constraint.Value == "" {
+		return constraint.Field + " " + constraint.Operator
+	}
+	return constraint.Field + " " + constraint.Operator + " " + constraint.Value
+}
+
+// compiled returns the constraint as built by NewConstraint. Constraints
+// not validated yet are compiled into a copy, leaving constraint unchanged
+// so that evaluation is safe while constraints are shared.
+func (constraint *Constraint) compiled() (*Constraint, error) {
+	if constraint.checked {
+		return constraint, nil
+	}
+	return NewConstraint(constraint.Field, constraint.Operator, constraint.Value)
+}
+
+// matches reports whether the attribute matches the pattern of a LIKE or
+// UNLIKE constraint.
+func (constraint *Constraint) matches(attribute *mesos.Attribute) bool {
+	if attribute.GetType() == mesos.Value_SET {
+		for _, item := range attribute.GetSet().GetItem() {
+			if constraint.pattern.MatchString(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return constraint.pattern.MatchString(attributeString(attribute))
+}
+
+// Constraints are satisfied when each of them is.
+type Constraints []*Constraint
+
+// ParseConstraints parses semicolon separated constraints, i.e.
+// hostname UNIQUE;rack_id CLUSTER r1
+func ParseConstraints(text string) (Constraints, error) {
+	constraints := Constraints{}
+	for _, token := range strings.Split(text, ";") {
+		if strings.TrimSpace(token) == "" {
+			continue
+		}
+		constraint, err := ParseConstraint(token)
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, constraint)
+	}
+	return constraints, nil
+}
+
+// Validate checks constraints not built by NewConstraint, i.e. written as
+// literals, and keeps their compiled form so that they are not compiled
+// again on each evaluation. It must be called before the constraints are
+// shared between goroutines.
+func (constraints Constraints) Validate() error {
+	compiled, err := constraints.compile()
+	if err != nil {
+		return err
+	}
+	copy(constraints, compiled)
+	return nil
+}
+
+// compile returns the constraints as Validate leaves them, in a new slice,
+// so constraints shared between goroutines are not written to.
+func (constraints Constraints) compile() (Constraints, error) {
+	compiled := make(Constraints, len(constraints))
+	for i, constraint := range constraints {
+		checked, err := constraint.compiled()
+		if err != nil {
+			return nil, err
+		}
+		compiled[i] = checked
+	}
+	return compiled, nil
+}
+
+func (constraints Constraints) String() string {
+	tokens := make([]string, len(constraints))
+	for i, constraint := range constraints {
+		tokens[i] = constraint.String()
+	}
+	return strings.Join(tokens, ";")
+}
+
+// fieldTally counts the tasks placed per value of a field.
+type fieldTally struct {
+	counts map[string]int
+	first  string
+}
+
+/*
+ConstraintEvaluator checks offers against constraints, given the offers
+the tasks of a group were placed on. One entry of placed is expected per
+task. Place records the offers of new tasks as they are planned:
+
+	evaluator := NewConstraintEvaluator(placed)
+	if evaluator.Satisfied(offer, constraints) {
+		evaluator.Place(offer)
+	}
+*/
+type ConstraintEvaluator struct {
+	placed  []*mesos.Offer
+	tallies map[string]*fieldTally
+}
+
+func NewConstraintEvaluator(placed []*mesos.Offer) *ConstraintEvaluator {
+	return &ConstraintEvaluator{
+		placed:  append([]*mesos.Offer{}, placed...),
+		tallies: make(map[string]*fieldTally),
+	}
+}
+
+// Place records a task placed on offer.
+func (evaluator *ConstraintEvaluator) Place(offer *mesos.Offer) {
+	evaluator.placed = append(evaluator.placed, offer)
+	for field, tally := range evaluator.tallies {
+		tally.add(field, offer)
+	}
+}
+
+// Placed returns the offers recorded for the tasks of the group.
+func (evaluator *ConstraintEvaluator) Placed() []*mesos.Offer {
+	return evaluator.placed
+}
+
+// Satisfied reports whether a task may be placed on offer.
+func (evaluator *ConstraintEvaluator) Satisfied(offer *mesos.Offer, constraints Constraints) bool {
+	for _, constraint := range constraints {
+		if !evaluator.satisfied(offer, constraint) {
+			return false
+		}
+	}
+	return true
+}
+
+// Violated returns the constraints that placing a task on offer breaks.
+func (evaluator *ConstraintEvaluator) Violated(offer *mesos.Offer, constraints Constraints) Constraints {
+	var violated Constraints
+	for _, constraint := range constraints {
+		if !evaluator.satisfied(offer, constraint) {
+			violated = append(violated, constraint)
+		}
+	}
+	return violated
+}
+
+func (evaluator *ConstraintEvaluator) satisfied(offer *mesos.Offer, constraint *Constraint) bool {
+	constraint, err := constraint.compiled()
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	attribute := offerField(offer, constraint.Field)
+	if attribute == nil {
+		return constraint.Operator == UNLIKE_OPERATOR
+	}
+
+	switch constraint.Operator {
+	case LIKE_OPERATOR:
+		return constraint.matches(attribute)
+	case UNLIKE_OPERATOR:
+		return !constraint.matches(attribute)
+	case CLUSTER_OPERATOR:
+		if constraint.Value != "" {
+			return attributeEquals(attribute, constraint.Value)
+		}
+	}
+
+	tally := evaluator.tally(constraint.Field)
+	key := attributeString(attribute)
+	switch constraint.Operator {
+	case UNIQUE_OPERATOR:
+		return tally.counts[key] == 0
+	case CLUSTER_OPERATOR:
+		return len(tally.counts) == 0 || key == tally.first
+	case MAX_PER_OPERATOR:
+		return tally.counts[key] < constraint.limit
+	case GROUP_BY_OPERATOR:
+		// same as Marathon: the offer must hold one of the least used
+		// values, any value is least used until the groups are all taken
+		least := 0
+		if len(tally.counts) > 0 && len(tally.counts) >= constraint.limit {
+			least = -1
+			for _, count := range tally.counts {
+				if least < 0 || count < least {
+					least = count
+				}
+			}
+		}
+		return tally.counts[key] <= least
+	}
+	return false
+}
+
+// tally returns the tasks placed per value of field, computed on first use.
+func (evaluator *ConstraintEvaluator) tally(field string) *fieldTally {
+	tally, ok := evaluator.tallies[field]
+	if !ok {
+		tally = &fieldTally{counts: make(map[string]int)}
+		for _, offer := range evaluator.placed {
+			tally.add(field, offer)
+		}
+		evaluator.tallies[field] = tally
+	}
+	return tally
+}
+
+func (tally *fieldTally) add(field string, offer *mesos.Offer) {
+	attribute := offerField(offer, field)
+	if attribute == nil {
+		return
+	}
+	key := attributeString(attribute)
+	if len(tally.counts) == 0 {
+		tally.first = key
+	}
+	tally.counts[key]++
+}
+
+// offerField returns the hostname or the named attribute of offer as an
+// attribute, nil if the offer has no such attribute.
+func offerField(offer *mesos.Offer, field string) *mesos.Attribute {
+	if field == HOSTNAME_FIELD {
+		return &mesos.Attribute{
+			Name: &field,
+			Type: mesos.Value_TEXT.Enum(),
+			Text: &mesos.Value_Text{Value: offer.Hostname},
+		}
+	}
+	for _, attribute := range offer.GetAttributes() {
+		if attribute.GetName() == field {
+			return attribute
+		}
+	}
+	return nil
+}
+
+// attributeString returns the value of attribute in the format read by
+// ParseAttributes, with ranges coalesced and set items sorted so equal
+// values have equal strings. Text is returned as is, never quoted.
+func attributeString(attribute *mesos.Attribute) string {
+	if attribute.GetType() == mesos.Value_TEXT {
+		return attribute.GetText().GetValue()
+	}
+	value := &mesos.Value{
+		Type:   attribute.Type,
+		Scalar: attribute.Scalar,
+		Ranges: attribute.Ranges,
+		Set:    attribute.Set,
+		Text:   attribute.Text,
+	}
+	switch attribute.GetType() {
+	case mesos.Value_RANGES:
+		value.Ranges = &mesos.Value_Ranges{Range: CoalesceRanges(attribute.GetRanges().GetRange())}
+	case mesos.Value_SET:
+		items := append([]string{}, attribute.GetSet().GetItem()...)
+		sort.Strings(items)
+		value.Set = &mesos.Value_Set{Item: items}
+	}
+	return formatValue(value)
+}
+
+// attributeEquals reports whether attribute holds the value of text, which
+// is parsed as the type of the attribute.
+func attributeEquals(attribute *mesos.Attribute, text string) bool {
+	if attribute.GetType() == mesos.Value_TEXT {
+		return attribute.GetText().GetValue() == text
+	}
+	value, err := parseValue(text, false)
+	if err != nil || value.GetType() != attribute.GetType() {
+		return false
+	}
+	return attributeString(attribute) == attributeString(&mesos.Attribute{
+		Type:   value.Type,
+		Scalar: value.Scalar,
+		Ranges: value.Ranges,
+		Set:    value.Set,
+	})
+}
@@ -0,0 +1,237 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"testing"
+)
+
+// makeAttributeOffer returns an offer on host with attributes in the format
+// read by ParseAttributes.
+func makeAttributeOffer(t *testing.T, id, host, attributes string) *mesos.Offer {
+	offer := makeLaunchOffer(id, "slave-"+host, 4, 4096)
+	offer.Hostname = proto.String(host)
+	var err error
+	if offer.Attributes, err = ParseAttributes(attributes); err != nil {
+		t.Fatal(err)
+	}
+	return offer
+}
+
+func mustParseConstraints(t *testing.T, text string) Constraints {
+	constraints, err := ParseConstraints(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return constraints
+}
+
+func TestParseConstraints(t *testing.T) {
+	constraints := mustParseConstraints(t, "hostname UNIQUE; rack_id CLUSTER r1;zone GROUP_BY 3;instance LIKE m4.*;gpu UNLIKE true;rack_id MAX_PER 2;zone GROUP_BY")
+	if len(constraints) != 7 {
+		t.Fatal("Expected 7 constraints, but got", len(constraints))
+	}
+	if constraints[1].Field != "rack_id" || constraints[1].Operator != CLUSTER_OPERATOR || constraints[1].Value != "r1" {
+		t.Fatal("Expected rack_id CLUSTER r1, but got", constraints[1])
+	}
+	if constraints.String() != "hostname UNIQUE;rack_id CLUSTER r1;zone GROUP_BY 3;instance LIKE m4.*;gpu UNLIKE true;rack_id MAX_PER 2;zone GROUP_BY" {
+		t.Fatal("Unexpected constraints string", constraints.String())
+	}
+
+	for _, text := range []string{"hostname", "hostname UNIQUE x", "zone GROUP_BY x", "rack MAX_PER", "rack MAX_PER 0", "instance LIKE", "instance LIKE m4.(", "rack NEAR r1"} {
+		if _, err := ParseConstraint(text); err == nil {
+			t.Fatal("Expected error for invalid constraint", text)
+		}
+	}
+}
+
+func TestConstraintEvaluator_Unique(t *testing.T) {
+	offer1 := makeAttributeOffer(t, "offer-1", "host-1", "rack_id:r1")
+	offer2 := makeAttributeOffer(t, "offer-2", "host-2", "rack_id:r1")
+	evaluator := NewConstraintEvaluator([]*mesos.Offer{offer1})
+
+	constraints := mustParseConstraints(t, "hostname UNIQUE")
+	if evaluator.Satisfied(offer1, constraints) || !evaluator.Satisfied(offer2, constraints) {
+		t.Fatal("Expected hostname UNIQUE to reject host-1 only.")
+	}
+	constraints = mustParseConstraints(t, "rack_id UNIQUE")
+	if evaluator.Satisfied(offer2, constraints) {
+		t.Fatal("Expected rack_id UNIQUE to reject rack r1.")
+	}
+	if evaluator.Satisfied(makeAttributeOffer(t, "offer-3", "host-3", ""), constraints) {
+		t.Fatal("Expected offer without rack_id to break rack_id UNIQUE.")
+	}
+
+	evaluator.Place(offer2)
+	if len(evaluator.Placed()) != 2 || evaluator.Satisfied(offer2, mustParseConstraints(t, "hostname UNIQUE")) {
+		t.Fatal("Expected placed host-2 to break hostname UNIQUE.")
+	}
+}
+
+func TestConstraintEvaluator_Cluster(t *testing.T) {
+	r1 := makeAttributeOffer(t, "offer-1", "host-1", "rack_id:r1;cores:8;ports:[2-3,1-1];tags:{ssd,gpu}")
+	r2 := makeAttributeOffer(t, "offer-2", "host-2", "rack_id:r2;cores:16;ports:[1-2];tags:{ssd}")
+	evaluator := NewConstraintEvaluator(nil)
+
+	for _, text := range []string{"rack_id CLUSTER r1", "cores CLUSTER 8.0", "ports CLUSTER [1-3]", "tags CLUSTER {gpu,ssd}"} {
+		constraints := mustParseConstraints(t, text)
+		if !evaluator.Satisfied(r1, constraints) || evaluator.Satisfied(r2, constraints) {
+			t.Fatal("Expected constraint to hold for offer-1 only:", text)
+		}
+	}
+
+	constraints := mustParseConstraints(t, "rack_id CLUSTER")
+	if !evaluator.Satisfied(r2, constraints) {
+		t.Fatal("Expected CLUSTER without value to accept the first task anywhere.")
+	}
+	evaluator.Place(r1)
+	if !evaluator.Satisfied(r1, constraints) || evaluator.Satisfied(r2, constraints) {
+		t.Fatal("Expected CLUSTER without value to follow the rack of the first task.")
+	}
+}
+
+func TestConstraintEvaluator_GroupBy(t *testing.T) {
+	zones := []*mesos.Offer{
+		makeAttributeOffer(t, "offer-a", "host-a", "zone:a"),
+		makeAttributeOffer(t, "offer-b", "host-b", "zone:b"),
+		makeAttributeOffer(t, "offer-c", "host-c", "zone:c"),
+	}
+	evaluator := NewConstraintEvaluator([]*mesos.Offer{zones[0]})
+	constraints := mustParseConstraints(t, "zone GROUP_BY 3")
+	if evaluator.Satisfied(zones[0], constraints) || !evaluator.Satisfied(zones[1], constraints) {
+		t.Fatal("Expected GROUP_BY 3 to reject zone a until 3 zones are used.")
+	}
+	evaluator.Place(zones[1])
+	evaluator.Place(zones[2])
+	for _, offer := range zones {
+		if !evaluator.Satisfied(offer, constraints) {
+			t.Fatal("Expected GROUP_BY 3 to accept any zone once all are even.")
+		}
+	}
+	evaluator.Place(zones[2])
+	if evaluator.Satisfied(zones[2], constraints) || !evaluator.Satisfied(zones[0], constraints) {
+		t.Fatal("Expected GROUP_BY 3 to reject the most used zone.")
+	}
+
+	evaluator = NewConstraintEvaluator([]*mesos.Offer{zones[0]})
+	constraints = mustParseConstraints(t, "zone GROUP_BY")
+	if !evaluator.Satisfied(zones[0], constraints) {
+		t.Fatal("Expected GROUP_BY without count to accept the single used zone.")
+	}
+}
+
+func TestConstraintEvaluator_MaxPer(t *testing.T) {
+	offer := makeAttributeOffer(t, "offer-1", "host-1", "rack_id:r1")
+	evaluator := NewConstraintEvaluator([]*mesos.Offer{offer})
+	constraints := Constraints{{Field: "rack_id", Operator: MAX_PER_OPERATOR, Value: "2"}}
+	if !evaluator.Satisfied(offer, constraints) {
+		t.Fatal("Expected MAX_PER 2 to accept a second task.")
+	}
+	evaluator.Place(offer)
+	if evaluator.Satisfied(offer, constraints) {
+		t.Fatal("Expected MAX_PER 2 to reject a third task.")
+	}
+	if evaluator.Satisfied(offer, Constraints{{Field: "rack_id", Operator: MAX_PER_OPERATOR}}) {
+		t.Fatal("Expected invalid constraint to be unsatisfied.")
+	}
+}
+
+func TestConstraints_Validate(t *testing.T) {
+	literal := &Constraint{Field: "rack_id", Operator: MAX_PER_OPERATOR, Value: "2"}
+	constraints := Constraints{literal}
+	if err := constraints.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if constraints[0].limit != 2 || literal.checked {
+		t.Fatal("Expected the compiled constraint kept in place of the literal, but got", constraints[0])
+	}
+	if err := (Constraints{{Field: "rack_id", Operator: "NEAR"}}).Validate(); err == nil {
+		t.Fatal("Expected unknown operator to be invalid.")
+	}
+
+	demand := NewDemand(makeLaunchTask("task-1", "", 1, 128))
+	demand.Constraints = Constraints{{Field: "rack_id", Operator: MAX_PER_OPERATOR}}
+	offer := makeAttributeOffer(t, "offer-1", "host-1", "rack_id:r1")
+	if plan := NewPlanner(FirstFit).Plan([]*mesos.Offer{offer}, []*Demand{demand}); len(plan.Unplaced) != 1 {
+		t.Fatal("Expected demand with invalid constraints unplaced, but got", plan.Launches)
+	}
+}
+
+func TestPlanner_ConstraintsNotWritten(t *testing.T) {
+	literal := &Constraint{Field: "rack_id", Operator: UNIQUE_OPERATOR}
+	shared := Constraints{literal}
+	offers := []*mesos.Offer{makeAttributeOffer(t, "offer-1", "host-1", "rack_id:r1")}
+
+	// constraints may be shared by demands planned concurrently
+	done := make(chan *Plan)
+	for i := 0; i < 2; i++ {
+		demand := NewDemand(makeLaunchTask("task-1", "", 1, 128))
+		demand.Constraints = shared
+		go func() {
+			done <- NewPlanner(FirstFit).Plan(offers, []*Demand{demand})
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if plan := <-done; len(plan.Launches) != 1 {
+			t.Fatal("Expected demand placed, but got", plan.Unplaced)
+		}
+	}
+	if shared[0] != literal || literal.checked {
+		t.Fatal("Expected Plan to leave the constraints of the demand unchanged.")
+	}
+}
+
+func TestConstraintEvaluator_Like(t *testing.T) {
+	m4 := makeAttributeOffer(t, "offer-1", "host-1", "instance:m4.large;gpu:true;tags:{ssd,fast};ports:[1-5];cores:8")
+	c5 := makeAttributeOffer(t, "offer-2", "host-2", "instance:c5.large;tags:{hdd}")
+	evaluator := NewConstraintEvaluator(nil)
+
+	for _, text := range []string{"instance LIKE m4.*", "tags LIKE ss.", "ports LIKE \\[1-5\\]", "cores LIKE [0-9]", "gpu LIKE true", "hostname LIKE host-1"} {
+		constraints := mustParseConstraints(t, text)
+		if !evaluator.Satisfied(m4, constraints) || evaluator.Satisfied(c5, constraints) {
+			t.Fatal("Expected LIKE to hold for offer-1 only:", text)
+		}
+	}
+	if evaluator.Satisfied(m4, mustParseConstraints(t, "instance LIKE m4")) {
+		t.Fatal("Expected LIKE to match the whole value.")
+	}
+
+	constraints := mustParseConstraints(t, "gpu UNLIKE true")
+	if evaluator.Satisfied(m4, constraints) || !evaluator.Satisfied(c5, constraints) {
+		t.Fatal("Expected gpu UNLIKE true to reject offer-1 only.")
+	}
+
+	constraints = mustParseConstraints(t, "gpu UNLIKE true;hostname UNIQUE;instance LIKE m4.*")
+	if violated := NewConstraintEvaluator([]*mesos.Offer{c5}).Violated(c5, constraints); violated.String() != "hostname UNIQUE;instance LIKE m4.*" {
+		t.Fatal("Unexpected violated constraints", violated)
+	}
+}
+
+func TestPlanner_Constraints(t *testing.T) {
+	offers := []*mesos.Offer{
+		makeAttributeOffer(t, "offer-1", "host-1", "rack_id:r1"),
+		makeAttributeOffer(t, "offer-2", "host-2", "rack_id:r1"),
+		makeAttributeOffer(t, "offer-3", "host-3", "rack_id:r2"),
+	}
+	demands := makeDemands(4, 1, 512)
+	for _, demand := range demands {
+		demand.Group = "web"
+		demand.Constraints = mustParseConstraints(t, "hostname UNIQUE")
+	}
+	planner := NewPlanner(FirstFit)
+	planner.Placed = map[string][]*mesos.Offer{"web": {offers[0]}}
+	plan := planner.Plan(offers, demands)
+	summary := planSummary(plan)
+	if summary["task-0"] != "offer-2" || summary["task-1"] != "offer-3" || len(plan.Unplaced) != 2 {
+		t.Fatal("Expected one web task per free host, but got", summary)
+	}
+
+	// other groups are not counted
+	other := makeDemands(1, 1, 512)
+	other[0].Task.TaskId = NewTaskID("task-other")
+	other[0].Constraints = mustParseConstraints(t, "hostname UNIQUE;rack_id CLUSTER r1")
+	plan = planner.Plan(offers, append(demands[:1], other...))
+	if summary = planSummary(plan); summary["task-0"] != "offer-2" || summary["task-other"] != "offer-1" {
+		t.Fatal("Expected ungrouped task on offer-1, but got", summary)
+	}
+}
@@ -0,0 +1,306 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeadlineStats counts the deadlines of an EDFQueue. Met and Missed count
+// the tasks finished before and after their deadline, Late the demands
+// found unable to finish in time while queued or running.
+type DeadlineStats struct {
+	Met    int
+	Missed int
+	Late   int
+}
+
+/*
+EDFQueue orders demands earliest deadline first. The slack of a demand is
+the time left before its deadline once its estimated runtime is taken
+off. Demands of equal deadline are ordered by increasing slack. Demands
+without slack come after the ones that can still finish in time, and
+demands without a deadline come last, in the order they were pushed.
+
+The queue follows the demands passed to Launched once their tasks run,
+which the Preemptor calls for the demands it launches. A launched task
+has no slack once its estimated runtime takes it past the deadline, or
+once it runs past the deadline. Deadlines are checked on a timer as well
+as on Push and Demands, and each demand found without slack is reported
+once to Late, on a goroutine of its own, so that Late may call back into
+the queue or the Preemptor holding it. The deadlines of launched tasks
+are counted as met or missed when StatusUpdate receives TASK_FINISHED:
+
+	queue := NewEDFQueue()
+	queue.Late = func(demand *Demand, slack time.Duration) { ... }
+	preemptor.Queue = queue
+
+	// in the StatusUpdate callback
+	queue.StatusUpdate(status)
+
+Unlike the PriorityQueue, an EDFQueue is safe for concurrent use.
+*/
+type EDFQueue struct {
+	// Late, when set, is called for demands that can no longer finish
+	// before their deadline. It is called on its own goroutine.
+	Late func(demand *Demand, slack time.Duration)
+	// Now returns the current time, time.Now when nil.
+	Now func() time.Time
+
+	lock     sync.Mutex
+	demands  []*Demand
+	launched map[string]*launchedDemand
+	timer    *time.Timer
+	stats    DeadlineStats
+}
+
+// launchedDemand is a demand whose task was launched at start.
+type launchedDemand struct {
+	demand *Demand
+	start  time.Time
+}
+
+// slack returns the time left before the deadline once the rest of the
+// estimated runtime is taken off. Tasks running longer than estimated are
+// expected to finish now.
+func (launched *launchedDemand) slack(now time.Time) time.Duration {
+	finish := launched.start.Add(launched.demand.Runtime)
+	if finish.Before(now) {
+		finish = now
+	}
+	return launched.demand.Deadline.Sub(finish)
+}
+
+// lateDemand is a demand to report to Late once the lock is released.
+type lateDemand struct {
+	demand *Demand
+	slack  time.Duration
+}
+
+func NewEDFQueue() *EDFQueue {
+	return &EDFQueue{
+		launched: make(map[string]*launchedDemand),
+	}
+}
+
+// Slack returns the time left to demand before it must start to finish
+// by its deadline, negative once it can no longer finish in time.
+func Slack(demand *Demand, now time.Time) time.Duration {
+	return demand.Deadline.Sub(now) - demand.Runtime
+}
+
+// Push queues demands. Demands pushed again are reported again once late.
+func (queue *EDFQueue) Push(demands ...*Demand) {
+	queue.lock.Lock()
+	for _, demand := range demands {
+		demand.late = false
+	}
+	queue.demands = append(queue.demands, demands...)
+	late := queue.checkLate(queue.now())
+	queue.lock.Unlock()
+	queue.flag(late)
+}
+
+// Remove drops the demand of a task, launched or not. Call Launched once
+// its task is launched.
+func (queue *EDFQueue) Remove(taskId string) *Demand {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	for i, demand := range queue.demands {
+		if demand.Task.GetTaskId().GetValue() == taskId {
+			queue.demands = append(queue.demands[:i], queue.demands[i+1:]...)
+			queue.schedule(queue.now())
+			return demand
+		}
+	}
+	return nil
+}
+
+// Launched follows the deadlines of demands whose tasks were launched,
+// until StatusUpdate receives their terminal state.
+func (queue *EDFQueue) Launched(demands ...*Demand) {
+	queue.lock.Lock()
+	now := queue.now()
+	for _, demand := range demands {
+		if !demand.Deadline.IsZero() {
+			queue.launched[demand.Task.GetTaskId().GetValue()] = &launchedDemand{demand: demand, start: now}
+		}
+	}
+	late := queue.checkLate(now)
+	queue.lock.Unlock()
+	queue.flag(late)
+}
+
+func (queue *EDFQueue) Demands() []*Demand {
+	queue.lock.Lock()
+	now := queue.now()
+	late := queue.checkLate(now)
+	ordered := &demandsByDeadline{demands: append([]*Demand{}, queue.demands...), now: now}
+	queue.lock.Unlock()
+
+	queue.flag(late)
+	sort.Stable(ordered)
+	return ordered.demands
+}
+
+func (queue *EDFQueue) Len() int {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return len(queue.demands)
+}
+
+// StatusUpdate counts the deadline of a finished task as met or missed.
+func (queue *EDFQueue) StatusUpdate(status *mesos.TaskStatus) {
+	if !IsTerminalState(status.GetState()) {
+		return
+	}
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	id := status.GetTaskId().GetValue()
+	launched, ok := queue.launched[id]
+	if !ok {
+		return
+	}
+	// tasks failing otherwise are expected to be pushed again
+	delete(queue.launched, id)
+	now := queue.now()
+	queue.schedule(now)
+	if status.GetState() != mesos.TaskState_TASK_FINISHED {
+		return
+	}
+	if now.After(launched.demand.Deadline) {
+		queue.stats.Missed++
+	} else {
+		queue.stats.Met++
+	}
+}
+
+// Stats returns the deadlines counted so far.
+func (queue *EDFQueue) Stats() DeadlineStats {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return queue.stats
+}
+
+// now must be called with the lock held.
+func (queue *EDFQueue) now() time.Time {
+	if queue.Now != nil {
+		return queue.Now()
+	}
+	return time.Now()
+}
+
+// checkLate returns the queued demands and launched tasks newly found
+// without slack, and arms the timer for the next ones. It must be called
+// with the lock held.
+func (queue *EDFQueue) checkLate(now time.Time) []lateDemand {
+	var late []lateDemand
+	for _, demand := range queue.demands {
+		if demand.Deadline.IsZero() || demand.late {
+			continue
+		}
+		if slack := Slack(demand, now); slack < 0 {
+			demand.late = true
+			queue.stats.Late++
+			late = append(late, lateDemand{demand: demand, slack: slack})
+		}
+	}
+	for _, launched := range queue.launched {
+		if launched.demand.late {
+			continue
+		}
+		if slack := launched.slack(now); slack < 0 {
+			launched.demand.late = true
+			queue.stats.Late++
+			late = append(late, lateDemand{demand: launched.demand, slack: slack})
+		}
+	}
+	queue.schedule(now)
+	return late
+}
+
+// schedule arms the timer for the next queued demand or launched task to
+// run out of slack. It must be called with the lock held.
+func (queue *EDFQueue) schedule(now time.Time) {
+	if queue.timer != nil {
+		queue.timer.Stop()
+		queue.timer = nil
+	}
+	var next time.Time
+	for _, demand := range queue.demands {
+		if demand.Deadline.IsZero() || demand.late {
+			continue
+		}
+		if at := demand.Deadline.Add(-demand.Runtime); next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	for _, launched := range queue.launched {
+		if launched.demand.late {
+			continue
+		}
+		if at := launched.demand.Deadline; next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	if next.IsZero() {
+		return
+	}
+	queue.timer = time.AfterFunc(next.Sub(now), queue.check)
+}
+
+// check reports the demands run out of slack when the timer fires.
+func (queue *EDFQueue) check() {
+	queue.lock.Lock()
+	late := queue.checkLate(queue.now())
+	queue.lock.Unlock()
+	queue.flag(late)
+}
+
+// flag must be called without the lock held. Push and Demands may be
+// called under the lock of a Preemptor, so Late is called on a goroutine.
+func (queue *EDFQueue) flag(late []lateDemand) {
+	if queue.Late == nil || len(late) == 0 {
+		return
+	}
+	callback := queue.Late
+	go func() {
+		for _, entry := range late {
+			callback(entry.demand, entry.slack)
+		}
+	}()
+}
+
+// demandsByDeadline sorts demands that can finish in time, then demands
+// without slack, both by deadline and slack, then demands without deadline.
+type demandsByDeadline struct {
+	demands []*Demand
+	now     time.Time
+}
+
+func (d *demandsByDeadline) Len() int      { return len(d.demands) }
+func (d *demandsByDeadline) Swap(i, j int) { d.demands[i], d.demands[j] = d.demands[j], d.demands[i] }
+
+func (d *demandsByDeadline) Less(i, j int) bool {
+	a, b := d.demands[i], d.demands[j]
+	if classA, classB := d.class(a), d.class(b); classA != classB {
+		return classA < classB
+	}
+	if !a.Deadline.Equal(b.Deadline) {
+		return a.Deadline.Before(b.Deadline)
+	}
+	return Slack(a, d.now) < Slack(b, d.now)
+}
+
+// class is 0 for demands that can finish in time, 1 for demands without
+// slack and 2 for demands without deadline.
+func (d *demandsByDeadline) class(demand *Demand) int {
+	switch {
+	case demand.Deadline.IsZero():
+		return 2
+	case Slack(demand, d.now) < 0:
+		return 1
+	}
+	return 0
+}
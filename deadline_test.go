@@ -0,0 +1,222 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"testing"
+	"time"
+)
+
+func makeDeadlineDemand(id string, deadline time.Time, runtime time.Duration) *Demand {
+	demand := NewDemand(makeLaunchTask(id, "", 1, 128))
+	demand.Deadline = deadline
+	demand.Runtime = runtime
+	return demand
+}
+
+// makeTestEDFQueue returns a queue with a clock set by the returned pointer.
+func makeTestEDFQueue() (*EDFQueue, *time.Time) {
+	now := time.Date(2014, 9, 1, 12, 0, 0, 0, time.UTC)
+	queue := NewEDFQueue()
+	queue.Now = func() time.Time { return now }
+	return queue, &now
+}
+
+func TestEDFQueue_Order(t *testing.T) {
+	queue, now := makeTestEDFQueue()
+	queue.Push(
+		NewDemand(makeLaunchTask("batch-1", "", 1, 128)),
+		makeDeadlineDemand("report", now.Add(time.Hour), 10*time.Minute),
+		makeDeadlineDemand("late", now.Add(5*time.Minute), 10*time.Minute),
+		makeDeadlineDemand("urgent", now.Add(20*time.Minute), 10*time.Minute),
+		makeDeadlineDemand("tight", now.Add(20*time.Minute), 15*time.Minute),
+		NewDemand(makeLaunchTask("batch-2", "", 1, 128)),
+	)
+	checkDemandOrder(t, queue.Demands(), "tight", "urgent", "report", "late", "batch-1", "batch-2")
+
+	*now = now.Add(10 * time.Minute)
+	checkDemandOrder(t, queue.Demands(), "urgent", "report", "late", "tight", "batch-1", "batch-2")
+
+	if queue.Remove("urgent") == nil || queue.Remove("urgent") != nil || queue.Len() != 5 {
+		t.Fatal("Expected 5 demands left, but got", queue.Len())
+	}
+}
+
+// expectLate returns the ids of the next demands reported to Late.
+func expectLate(t *testing.T, late chan lateDemand, count int) []string {
+	var ids []string
+	for len(ids) < count {
+		select {
+		case entry := <-late:
+			if entry.slack >= 0 {
+				t.Fatal("Expected negative slack, but got", entry.slack)
+			}
+			ids = append(ids, entry.demand.Task.GetTaskId().GetValue())
+		case <-time.After(3 * time.Second):
+			t.Fatal("EDFQueue.Late not called, got", ids)
+		}
+	}
+	select {
+	case entry := <-late:
+		t.Fatal("Expected no other late demand, but got", entry.demand.Task.GetTaskId().GetValue())
+	case <-time.After(20 * time.Millisecond):
+	}
+	return ids
+}
+
+func TestEDFQueue_Late(t *testing.T) {
+	queue, now := makeTestEDFQueue()
+	late := make(chan lateDemand, 4)
+	queue.Late = func(demand *Demand, slack time.Duration) {
+		late <- lateDemand{demand: demand, slack: slack}
+	}
+
+	queue.Push(
+		makeDeadlineDemand("late", now.Add(time.Minute), 2*time.Minute),
+		makeDeadlineDemand("tight", now.Add(10*time.Minute), 5*time.Minute),
+		NewDemand(makeLaunchTask("batch", "", 1, 128)),
+	)
+	if ids := expectLate(t, late, 1); ids[0] != "late" {
+		t.Fatal("Expected late flagged on push, but got", ids)
+	}
+
+	queue.Demands()
+	*now = now.Add(6 * time.Minute)
+	queue.Demands()
+	queue.Demands()
+	if ids := expectLate(t, late, 1); ids[0] != "tight" {
+		t.Fatal("Expected tight flagged once, but got", ids)
+	}
+	if stats := queue.Stats(); stats.Late != 2 {
+		t.Fatal("Expected 2 late demands, but got", stats.Late)
+	}
+}
+
+func TestEDFQueue_LateCleared(t *testing.T) {
+	queue, now := makeTestEDFQueue()
+	late := make(chan lateDemand, 4)
+	queue.Late = func(demand *Demand, slack time.Duration) {
+		late <- lateDemand{demand: demand, slack: slack}
+	}
+
+	queue.Push(makeDeadlineDemand("late-1", now.Add(time.Minute), 2*time.Minute))
+	expectLate(t, late, 1)
+	queue.Remove("late-1")
+	// a demand pushed again is reported again
+	queue.Push(makeDeadlineDemand("late-1", now.Add(time.Minute), 2*time.Minute))
+	expectLate(t, late, 1)
+	queue.Remove("late-1")
+
+	queue.Push(makeDeadlineDemand("late-2", now.Add(time.Minute), 2*time.Minute))
+	expectLate(t, late, 1)
+	queue.Push(makeDeadlineDemand("task-3", now.Add(time.Hour), 2*time.Minute))
+	queue.Remove("late-2")
+	queue.Remove("task-3")
+	queue.lock.Lock()
+	timer := queue.timer
+	queue.lock.Unlock()
+	if timer != nil {
+		t.Fatal("Expected no deadline checked once the demands are removed.")
+	}
+}
+
+func TestEDFQueue_LatePreemptor(t *testing.T) {
+	queue, now := makeTestEDFQueue()
+	preemptor, err := NewPreemptor(&SchedulerDriver{Tasks: NewTaskRegistry()}, PreemptionPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	preemptor.Queue = queue
+
+	// Late calls back into the preemptor, which holds its lock while pushing
+	late := make(chan lateDemand, 1)
+	queue.Late = func(demand *Demand, slack time.Duration) {
+		preemptor.Pending()
+		late <- lateDemand{demand: demand, slack: slack}
+	}
+	preemptor.Submit(makeDeadlineDemand("late", now.Add(time.Minute), 2*time.Minute))
+	if ids := expectLate(t, late, 1); ids[0] != "late" {
+		t.Fatal("Expected late flagged on submit, but got", ids)
+	}
+}
+
+func TestEDFQueue_StatusUpdate(t *testing.T) {
+	queue, now := makeTestEDFQueue()
+	queue.Push(
+		makeDeadlineDemand("task-1", now.Add(time.Hour), 10*time.Minute),
+		makeDeadlineDemand("task-2", now.Add(30*time.Minute), 10*time.Minute),
+		makeDeadlineDemand("task-3", now.Add(time.Hour), 10*time.Minute),
+		NewDemand(makeLaunchTask("task-4", "", 1, 128)),
+	)
+	for _, id := range []string{"task-1", "task-2", "task-3", "task-4"} {
+		queue.Launched(queue.Remove(id))
+	}
+	// demands removed without being launched are not counted
+	queue.Push(makeDeadlineDemand("task-5", now.Add(time.Hour), 10*time.Minute))
+	queue.Remove("task-5")
+
+	*now = now.Add(20 * time.Minute)
+	queue.StatusUpdate(makeTaskUpdate("task-1", mesos.TaskState_TASK_RUNNING))
+	queue.StatusUpdate(makeTaskUpdate("task-1", mesos.TaskState_TASK_FINISHED))
+	queue.StatusUpdate(makeTaskUpdate("task-3", mesos.TaskState_TASK_FAILED))
+	queue.StatusUpdate(makeTaskUpdate("task-4", mesos.TaskState_TASK_FINISHED))
+	queue.StatusUpdate(makeTaskUpdate("task-5", mesos.TaskState_TASK_FINISHED))
+
+	*now = now.Add(20 * time.Minute)
+	queue.StatusUpdate(makeTaskUpdate("task-2", mesos.TaskState_TASK_FINISHED))
+	queue.StatusUpdate(makeTaskUpdate("task-2", mesos.TaskState_TASK_FINISHED))
+
+	if stats := queue.Stats(); stats.Met != 1 || stats.Missed != 1 {
+		t.Fatal("Expected 1 deadline met and 1 missed, but got", stats)
+	}
+}
+
+func TestEDFQueue_LateTimer(t *testing.T) {
+	queue := NewEDFQueue()
+	late := make(chan lateDemand, 4)
+	queue.Late = func(demand *Demand, slack time.Duration) {
+		late <- lateDemand{demand: demand, slack: slack}
+	}
+
+	// queued demands are reported once they run out of slack
+	now := time.Now()
+	queue.Push(makeDeadlineDemand("queued", now.Add(80*time.Millisecond), 50*time.Millisecond))
+	if ids := expectLate(t, late, 1); ids[0] != "queued" {
+		t.Fatal("Expected queued flagged by the timer, but got", ids)
+	}
+
+	// launched tasks are reported once they run past their deadline, or
+	// at once when their runtime takes them past it
+	now = time.Now()
+	queue.Launched(
+		makeDeadlineDemand("running", now.Add(200*time.Millisecond), 10*time.Millisecond),
+		makeDeadlineDemand("slow", now.Add(time.Hour), 2*time.Hour),
+		queue.Remove("queued"),
+	)
+	if ids := expectLate(t, late, 1); ids[0] != "slow" {
+		t.Fatal("Expected slow flagged on launch, but got", ids)
+	}
+	if ids := expectLate(t, late, 1); ids[0] != "running" {
+		t.Fatal("Expected running flagged by the timer, but got", ids)
+	}
+	if stats := queue.Stats(); stats.Late != 3 {
+		t.Fatal("Expected 3 late demands, but got", stats.Late)
+	}
+}
+
+func TestEDFQueue_PreemptorLaunched(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+	driver.Tasks = NewTaskRegistry()
+	queue := NewEDFQueue()
+	preemptor, _ := NewPreemptor(driver, PreemptionPolicy{})
+	preemptor.Queue = queue
+
+	preemptor.Submit(makeDeadlineDemand("task-1", time.Now().Add(time.Hour), time.Minute))
+	preemptor.ResourceOffers([]*mesos.Offer{makeLaunchOffer("offer-1", "slave-1", 1, 1024)})
+	master.expectCall(t, mesos.Call_ACCEPT)
+	queue.StatusUpdate(makeTaskUpdate("task-1", mesos.TaskState_TASK_FINISHED))
+	if stats := queue.Stats(); stats.Met != 1 {
+		t.Fatal("Expected deadline of the launched task met, but got", stats)
+	}
+}
@@ -0,0 +1,429 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TENANT_LABEL is the task label read by TaskTenant.
+const TENANT_LABEL = "tenant"
+
+// TaskTenant returns the tenant label of task.
+func TaskTenant(task *mesos.TaskInfo) string {
+	tenant, _ := TaskLabel(task, TENANT_LABEL)
+	return tenant
+}
+
+/*
+DRFQueue orders demands by Dominant Resource Fairness between tenants.
+The dominant share of a tenant is the largest share it uses of any scalar
+resource, relative to the capacity of the slaves seen in offers, divided
+by its weight. Demands of the tenant with the lowest dominant share come
+first, each demand counting towards the share of its tenant for the
+demands after it. The demands of a tenant keep the order they were
+pushed in.
+
+Usage is taken from the active tasks of Tasks and capacity from
+ObserveOffers, which expects the offers as they arrive:
+
+	queue := NewDRFQueue(driver.Tasks)
+	queue.SetWeight("search", 2)
+	queue.SetQuota("batch", Resources{NewScalarResource("cpus", 64)})
+	preemptor.Queue = queue
+
+	// in the ResourceOffers callback
+	queue.ObserveOffers(offers)
+
+Demands that would take a tenant beyond its quota are held back. Like an
+EDFQueue, a DRFQueue is safe for concurrent use.
+*/
+type DRFQueue struct {
+	Tasks *TaskRegistry
+	// TenantOf returns the tenant of running tasks, TaskTenant when nil.
+	// Demands are accounted to their Tenant, or TenantOf their task.
+	TenantOf func(task *mesos.TaskInfo) string
+
+	lock     sync.Mutex
+	weights  map[string]float64
+	quotas   map[string]Resources
+	capacity map[string]map[string]float64
+	demands  []*Demand
+}
+
+// TenantShare is the fair share of a tenant, as reported by a DRFQueue.
+type TenantShare struct {
+	Tenant           string
+	Weight           float64
+	Usage            Resources
+	Quota            Resources
+	DominantResource string
+	// DominantShare is the share of the dominant resource, before weighting.
+	DominantShare float64
+	Pending       int
+	// Held counts the pending demands held back by the quota.
+	Held int
+}
+
+// FairnessReport lists the tenants by increasing weighted dominant share.
+type FairnessReport []TenantShare
+
+func NewDRFQueue(tasks *TaskRegistry) *DRFQueue {
+	return &DRFQueue{
+		Tasks:    tasks,
+		weights:  make(map[string]float64),
+		quotas:   make(map[string]Resources),
+		capacity: make(map[string]map[string]float64),
+	}
+}
+
+// SetWeight sets the weight of a tenant, 1 by default. A tenant of weight
+// 2 is entitled to twice the share of a tenant of weight 1.
+func (queue *DRFQueue) SetWeight(tenant string, weight float64) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	queue.weights[tenant] = weight
+}
+
+// SetQuota bounds the scalar resources used by a tenant. A nil quota
+// removes the bound.
+func (queue *DRFQueue) SetQuota(tenant string, quota Resources) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if quota == nil {
+		delete(queue.quotas, tenant)
+		return
+	}
+	queue.quotas[tenant] = quota
+}
+
+// ObserveOffers updates the capacity of the slaves of offers: the
+// resources offered plus the resources of the tasks running on them.
+// The capacity of a slave only grows, until the slave is removed.
+func (queue *DRFQueue) ObserveOffers(offers []*mesos.Offer) {
+	offered := make(map[string]map[string]float64)
+	for _, offer := range offers {
+		slaveId := offer.GetSlaveId().GetValue()
+		if offered[slaveId] == nil {
+			offered[slaveId] = make(map[string]float64)
+			if queue.Tasks != nil {
+				for _, record := range queue.Tasks.BySlave(offer.GetSlaveId()) {
+					if !IsTerminalState(record.State) {
+						addScalars(offered[slaveId], NewDemand(record.Info).Resources())
+					}
+				}
+			}
+		}
+		addScalars(offered[slaveId], Resources(offer.GetResources()))
+	}
+
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	for slaveId, amounts := range offered {
+		capacity, ok := queue.capacity[slaveId]
+		if !ok {
+			queue.capacity[slaveId] = amounts
+			continue
+		}
+		for name, amount := range amounts {
+			if amount > capacity[name] {
+				capacity[name] = amount
+			}
+		}
+	}
+}
+
+// RemoveSlave drops the capacity of a lost slave.
+func (queue *DRFQueue) RemoveSlave(slaveId *mesos.SlaveID) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	delete(queue.capacity, slaveId.GetValue())
+}
+
+// Capacity returns the scalar resources of the slaves seen in offers.
+func (queue *DRFQueue) Capacity() Resources {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return scalarsResources(queue.totalCapacity())
+}
+
+func (queue *DRFQueue) Push(demands ...*Demand) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	queue.demands = append(queue.demands, demands...)
+}
+
+func (queue *DRFQueue) Remove(taskId string) *Demand {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	for i, demand := range queue.demands {
+		if demand.Task.GetTaskId().GetValue() == taskId {
+			queue.demands = append(queue.demands[:i], queue.demands[i+1:]...)
+			return demand
+		}
+	}
+	return nil
+}
+
+func (queue *DRFQueue) Len() int {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return len(queue.demands)
+}
+
+// Demands returns the demands in fair share order, leaving out the demands
+// held back by quotas.
+func (queue *DRFQueue) Demands() []*Demand {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return queue.order(queue.usage(), queue.totalCapacity())
+}
+
+// order must be called with the lock held, it adds the ordered demands
+// to usage.
+func (queue *DRFQueue) order(usage map[string]map[string]float64, capacity map[string]float64) []*Demand {
+	// the demands of each tenant in push order, tenants in order of their
+	// first demand
+	var tenants []string
+	byTenant := make(map[string][]*Demand)
+	for _, demand := range queue.demands {
+		tenant := queue.tenant(demand)
+		if _, ok := byTenant[tenant]; !ok {
+			tenants = append(tenants, tenant)
+		}
+		byTenant[tenant] = append(byTenant[tenant], demand)
+	}
+
+	ordered := []*Demand{}
+	for {
+		best, bestShare := -1, 0.0
+		for i, tenant := range tenants {
+			// usage only grows, demands beyond the quota stay beyond it
+			demands := byTenant[tenant]
+			for len(demands) > 0 && !queue.withinQuota(tenant, usage[tenant], demands[0]) {
+				demands = demands[1:]
+			}
+			byTenant[tenant] = demands
+			if len(demands) == 0 {
+				continue
+			}
+			share := queue.weightedShare(tenant, usage[tenant], capacity)
+			if best < 0 || share < bestShare {
+				best, bestShare = i, share
+			}
+		}
+		if best < 0 {
+			return ordered
+		}
+		tenant := tenants[best]
+		demand := byTenant[tenant][0]
+		byTenant[tenant] = byTenant[tenant][1:]
+		if usage[tenant] == nil {
+			usage[tenant] = make(map[string]float64)
+		}
+		addScalars(usage[tenant], demand.Resources())
+		ordered = append(ordered, demand)
+	}
+}
+
+// Report returns the current share of each tenant with running tasks,
+// pending demands, a weight or a quota.
+func (queue *DRFQueue) Report() FairnessReport {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	capacity := queue.totalCapacity()
+	usage := queue.usage()
+	shares := make(map[string]*TenantShare)
+	share := func(tenant string) *TenantShare {
+		if _, ok := shares[tenant]; !ok {
+			shares[tenant] = &TenantShare{Tenant: tenant}
+		}
+		return shares[tenant]
+	}
+	for tenant := range usage {
+		share(tenant)
+	}
+	for tenant := range queue.weights {
+		share(tenant)
+	}
+	for tenant := range queue.quotas {
+		share(tenant)
+	}
+	for _, demand := range queue.demands {
+		entry := share(queue.tenant(demand))
+		entry.Pending++
+		entry.Held++
+	}
+	// order adds to the usage it is given
+	planned := make(map[string]map[string]float64)
+	for tenant, amounts := range usage {
+		planned[tenant] = make(map[string]float64)
+		addAmounts(planned[tenant], amounts)
+	}
+	for _, demand := range queue.order(planned, capacity) {
+		share(queue.tenant(demand)).Held--
+	}
+
+	report := FairnessReport{}
+	for tenant, entry := range shares {
+		entry.Weight = queue.weight(tenant)
+		entry.Usage = scalarsResources(usage[tenant])
+		entry.Quota = queue.quotas[tenant]
+		entry.DominantResource, entry.DominantShare = dominantShare(usage[tenant], capacity)
+		report = append(report, *entry)
+	}
+	sort.Sort(report)
+	return report
+}
+
+func (report FairnessReport) Len() int      { return len(report) }
+func (report FairnessReport) Swap(i, j int) { report[i], report[j] = report[j], report[i] }
+func (report FairnessReport) Less(i, j int) bool {
+	a, b := report[i].DominantShare/report[i].Weight, report[j].DominantShare/report[j].Weight
+	if a != b {
+		return a < b
+	}
+	return report[i].Tenant < report[j].Tenant
+}
+
+// String returns a line per tenant, i.e.
+// tenant search weight=2 share=0.25 (cpus) usage: cpus=4 mem=2G pending=3
+func (report FairnessReport) String() string {
+	lines := make([]string, len(report))
+	for i, share := range report {
+		dominant := share.DominantResource
+		if dominant == "" {
+			dominant = "none"
+		}
+		lines[i] = fmt.Sprintf("tenant %s weight=%g share=%.4g (%s) usage: %s pending=%d",
+			share.Tenant, share.Weight, share.DominantShare, dominant, share.Usage, share.Pending)
+		if share.Quota != nil {
+			lines[i] += fmt.Sprintf(" held=%d quota: %s", share.Held, share.Quota)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tenant must be called with the lock held, as must the methods below.
+func (queue *DRFQueue) tenant(demand *Demand) string {
+	if demand.Tenant != "" {
+		return demand.Tenant
+	}
+	return queue.taskTenant(demand.Task)
+}
+
+func (queue *DRFQueue) taskTenant(task *mesos.TaskInfo) string {
+	if queue.TenantOf != nil {
+		return queue.TenantOf(task)
+	}
+	return TaskTenant(task)
+}
+
+func (queue *DRFQueue) weight(tenant string) float64 {
+	if weight, ok := queue.weights[tenant]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// usage sums the scalar resources of the active tasks by tenant.
+func (queue *DRFQueue) usage() map[string]map[string]float64 {
+	usage := make(map[string]map[string]float64)
+	if queue.Tasks == nil {
+		return usage
+	}
+	for _, record := range queue.Tasks.Active() {
+		tenant := queue.taskTenant(record.Info)
+		if usage[tenant] == nil {
+			usage[tenant] = make(map[string]float64)
+		}
+		addScalars(usage[tenant], NewDemand(record.Info).Resources())
+	}
+	return usage
+}
+
+func (queue *DRFQueue) totalCapacity() map[string]float64 {
+	total := make(map[string]float64)
+	for _, amounts := range queue.capacity {
+		for name, amount := range amounts {
+			total[name] += amount
+		}
+	}
+	return total
+}
+
+func (queue *DRFQueue) weightedShare(tenant string, usage, capacity map[string]float64) float64 {
+	_, share := dominantShare(usage, capacity)
+	return share / queue.weight(tenant)
+}
+
+// withinQuota reports whether demand keeps the usage of tenant within
+// its quota.
+func (queue *DRFQueue) withinQuota(tenant string, usage map[string]float64, demand *Demand) bool {
+	quota, ok := queue.quotas[tenant]
+	if !ok {
+		return true
+	}
+	resources := demand.Resources()
+	for _, resource := range quota {
+		if resource.GetType() != mesos.Value_SCALAR {
+			continue
+		}
+		name := resource.GetName()
+		if roundScalar(usage[name]+resources.Scalar(name)-quota.Scalar(name)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// dominantShare returns the resource of which usage is the largest share
+// of capacity, and that share.
+func dominantShare(usage, capacity map[string]float64) (string, float64) {
+	names := make([]string, 0, len(capacity))
+	for name := range capacity {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	dominant, largest := "", 0.0
+	for _, name := range names {
+		if capacity[name] <= 0 {
+			continue
+		}
+		if share := usage[name] / capacity[name]; share > largest {
+			dominant, largest = name, share
+		}
+	}
+	return dominant, largest
+}
+
+func addAmounts(amounts, other map[string]float64) {
+	for name, amount := range other {
+		amounts[name] += amount
+	}
+}
+
+// addScalars adds the scalar resources to amounts by name, any role.
+func addScalars(amounts map[string]float64, resources Resources) {
+	for _, resource := range resources {
+		if resource.GetType() == mesos.Value_SCALAR {
+			amounts[resource.GetName()] += resource.GetScalar().GetValue()
+		}
+	}
+}
+
+// scalarsResources returns amounts as scalar resources sorted by name.
+func scalarsResources(amounts map[string]float64) Resources {
+	names := make([]string, 0, len(amounts))
+	for name := range amounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	resources := Resources{}
+	for _, name := range names {
+		resources = append(resources, NewScalarResource(name, amounts[name]))
+	}
+	return resources
+}
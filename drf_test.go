@@ -0,0 +1,117 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"strings"
+	"testing"
+)
+
+// addTenantTask registers a running task of tenant.
+func addTenantTask(t *testing.T, registry *TaskRegistry, id, slave, tenant string, cpus, mem float64) {
+	task := makeLabeledTask(id, slave, TENANT_LABEL, tenant)
+	task.Resources = []*mesos.Resource{NewScalarResource("cpus", cpus), NewScalarResource("mem", mem)}
+	if err := registry.Add(task, nil); err != nil {
+		t.Fatal(err)
+	}
+	registry.Update(makeTaskUpdate(id, mesos.TaskState_TASK_RUNNING))
+}
+
+func makeTenantDemand(id, tenant string, cpus, mem float64) *Demand {
+	demand := NewDemand(makeLaunchTask(id, "", cpus, mem))
+	demand.Tenant = tenant
+	return demand
+}
+
+// makeTestDRFQueue returns a queue over 16 cpus and 8G of memory, with
+// tenant a using 4 cpus and tenant b using 1G.
+func makeTestDRFQueue(t *testing.T) *DRFQueue {
+	registry := NewTaskRegistry()
+	addTenantTask(t, registry, "a-0", "slave-1", "a", 2, 128)
+	addTenantTask(t, registry, "a-00", "slave-1", "a", 2, 128)
+	addTenantTask(t, registry, "b-0", "slave-2", "b", 1, 1024)
+	addTenantTask(t, registry, "b-00", "slave-2", "b", 0, 0)
+	registry.Update(makeTaskUpdate("b-00", mesos.TaskState_TASK_FINISHED))
+
+	queue := NewDRFQueue(registry)
+	queue.ObserveOffers([]*mesos.Offer{
+		makeLaunchOffer("offer-1", "slave-1", 4, 3840),
+		makeLaunchOffer("offer-2", "slave-2", 7, 3072),
+	})
+	queue.Push(
+		makeTenantDemand("a-1", "a", 1, 128),
+		makeTenantDemand("b-1", "b", 1, 1024),
+		makeTenantDemand("a-2", "a", 1, 128),
+		makeTenantDemand("c-1", "c", 1, 128),
+		makeTenantDemand("b-2", "b", 1, 1024),
+	)
+	return queue
+}
+
+func checkDemandOrder(t *testing.T, demands []*Demand, expected ...string) {
+	ids := make([]string, len(demands))
+	for i, demand := range demands {
+		ids[i] = demand.Task.GetTaskId().GetValue()
+	}
+	if strings.Join(ids, ",") != strings.Join(expected, ",") {
+		t.Fatal("Expected demands in order", expected, "but got", ids)
+	}
+}
+
+func TestDRFQueue_Order(t *testing.T) {
+	queue := makeTestDRFQueue(t)
+	if capacity := queue.Capacity(); capacity.CPUs() != 16 || capacity.Mem() != 8192 {
+		t.Fatal("Expected capacity of 16 cpus and 8G, but got", capacity)
+	}
+	// c has no share, b has 1/8 of mem, a 1/4 of cpus; b and a tie at 1/4
+	// after b-1, and a-1 was pushed first
+	checkDemandOrder(t, queue.Demands(), "c-1", "b-1", "a-1", "b-2", "a-2")
+
+	queue.SetWeight("a", 4)
+	checkDemandOrder(t, queue.Demands(), "c-1", "a-1", "a-2", "b-1", "b-2")
+
+	if queue.Remove("a-1") == nil || queue.Remove("a-1") != nil || queue.Len() != 4 {
+		t.Fatal("Expected a-1 removed from queue.")
+	}
+}
+
+func TestDRFQueue_Quota(t *testing.T) {
+	queue := makeTestDRFQueue(t)
+	queue.SetQuota("a", Resources{NewScalarResource("cpus", 5)})
+	queue.Push(makeTenantDemand("a-3", "a", 0, 128))
+	checkDemandOrder(t, queue.Demands(), "c-1", "b-1", "a-1", "b-2", "a-3")
+
+	report := queue.Report()
+	if len(report) != 3 {
+		t.Fatal("Expected 3 tenants in report, but got", report)
+	}
+	for _, share := range report {
+		if share.Tenant == "a" && (share.Pending != 3 || share.Held != 1 || share.DominantResource != "cpus" || share.DominantShare != 0.25) {
+			t.Fatal("Unexpected share of tenant a", share)
+		}
+	}
+	if report[0].Tenant != "c" || report[1].Tenant != "b" {
+		t.Fatal("Expected report ordered by share, but got", report)
+	}
+	if !strings.Contains(report.String(), "tenant a weight=1 share=0.25 (cpus) usage: cpus=4 mem=256M pending=3 held=1 quota: cpus=5") {
+		t.Fatal("Unexpected report", report.String())
+	}
+
+	queue.SetQuota("a", nil)
+	if len(queue.Demands()) != 6 {
+		t.Fatal("Expected all demands once the quota is removed.")
+	}
+}
+
+func TestDRFQueue_Capacity(t *testing.T) {
+	queue := makeTestDRFQueue(t)
+	// smaller offers do not shrink the capacity
+	queue.ObserveOffers([]*mesos.Offer{makeLaunchOffer("offer-3", "slave-1", 1, 128)})
+	queue.ObserveOffers([]*mesos.Offer{makeLaunchOffer("offer-4", "slave-3", 16, 8192)})
+	if capacity := queue.Capacity(); capacity.CPUs() != 32 {
+		t.Fatal("Expected capacity of 32 cpus, but got", capacity)
+	}
+	queue.RemoveSlave(NewSlaveID("slave-3"))
+	if capacity := queue.Capacity(); capacity.CPUs() != 16 {
+		t.Fatal("Expected capacity of 16 cpus after slave removal, but got", capacity)
+	}
+}
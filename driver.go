@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"os/user"
+	"sync"
 )
 
 type MesosError string
@@ -18,18 +19,90 @@ func (err MesosError) Error() string {
 	return string(err)
 }
 
+// Transport selects the protocol spoken with the master.
+type Transport int
+
+const (
+	// TRANSPORT_LIBPROCESS posts libprocess messages to the master and
+	// receives events on the scheduler process.
+	TRANSPORT_LIBPROCESS Transport = iota
+	// TRANSPORT_HTTP_API uses the v1 HTTP Scheduler API, with events streamed
+	// over the subscription. No scheduler process is started.
+	TRANSPORT_HTTP_API
+)
+
 type SchedulerDriver struct {
-	Master        string
-	Scheduler     *Scheduler
+	Master    string
+	Scheduler *Scheduler
+	// FrameworkInfo.Id is set by the driver once the framework registers,
+	// read it with FrameworkId.
 	FrameworkInfo *mesos.FrameworkInfo
-	Status        mesos.Status
+	// Status is changed by the driver as it runs, read it with GetStatus.
+	Status mesos.Status
+
+	// BindingAddress and BindingPort is where the scheduler process listens
+	// for events from the master. Port 0 picks a free port at Start.
+	// Defaults are read from LIBPROCESS_IP and LIBPROCESS_PORT.
+	BindingAddress string
+	BindingPort    int
+
+	// AdvertiseAddress and AdvertisePort are the address given to the master
+	// when it differs from the bound one (i.e. containers or NAT).
+	// Defaults are read from LIBPROCESS_ADVERTISE_IP and LIBPROCESS_ADVERTISE_PORT.
+	AdvertiseAddress string
+	AdvertisePort    int
+
+	// TLS enables encrypted transport to and from the master when set.
+	// The default is read from the LIBPROCESS_SSL_* environment variables.
+	TLS *TLSConfig
+
+	// SenderCheck controls whether events not sent by the leading master
+	// are only logged (the default) or rejected.
+	SenderCheck SenderCheck
+
+	// Messages decodes events posted to the scheduler process. Register
+	// names and handlers here to receive messages unknown to the driver.
+	// When set to nil, Start uses a new registry.
+	Messages *MessageRegistry
+
+	// Transport selects the protocol used with the master.
+	Transport Transport
+
+	// ValidateLaunches makes LaunchTasks check tasks against the offers of
+	// OfferPool they are launched on (see ValidateLaunch) before anything is
+	// sent. Start sets an OfferPool, which does not expire offers, when there
+	// is none.
+	ValidateLaunches bool
+
+	// OfferPool, when set, records the offers received by the driver and
+	// loses them as they are rescinded, used or their slave is lost.
+	OfferPool *OfferPool
+
+	// Tasks, when set, records the tasks launched by the driver and is kept
+	// up to date by status updates and lost slaves before the scheduler
+	// callbacks run.
+	Tasks *TaskRegistry
+
+	// Store, when set, saves the FrameworkID once the framework is
+	// registered. See NewSchedDriverWithStore.
+	Store StateStore
+
+	// FailoverOnStart makes Start re-register the framework of
+	// FrameworkInfo.Id, taking over from a failed scheduler, rather than
+	// register it. It is set by NewSchedDriverWithStore when a saved
+	// FrameworkID is used.
+	FailoverOnStart bool
 
 	masterClient *masterClient
+	httpClient   *httpSchedClient
 	schedMsgQ    chan interface{}
 	controlQ     chan mesos.Status
 	schedProc    *schedulerProcess
 	connected    bool
 	failover     bool
+	// lock guards Status, connected, failover and FrameworkInfo.Id, which
+	// the event loop changes while driver methods run on other goroutines.
+	lock sync.RWMutex
 }
 
 func NewSchedDriver(scheduler *Scheduler, framework *mesos.FrameworkInfo, master string) (*SchedulerDriver, error) {
@@ -37,6 +110,11 @@ func NewSchedDriver(scheduler *Scheduler, framework *mesos.FrameworkInfo, master
 		return nil, fmt.Errorf("Missing master address.")
 	}
 
+	masterAddr, err := parseAddress(master)
+	if err != nil {
+		return nil, err
+	}
+
 	if framework == nil {
 		return nil, fmt.Errorf("Missing FrameworkInfo.")
 	}
@@ -60,14 +138,33 @@ func NewSchedDriver(scheduler *Scheduler, framework *mesos.FrameworkInfo, master
 		framework.Hostname = proto.String(host)
 	}
 
+	bindPort, err := portFromEnv(LIBPROCESS_PORT_ENV)
+	if err != nil {
+		return nil, err
+	}
+	advertisePort, err := portFromEnv(LIBPROCESS_ADVERTISE_PORT_ENV)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := TLSConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
 	driver := &SchedulerDriver{
-		Master:        master,
-		FrameworkInfo: framework,
-		Status:        mesos.Status_DRIVER_NOT_STARTED,
-		schedMsgQ:     make(chan interface{}, 10),
-		controlQ:      make(chan mesos.Status),
-		connected:     false,
-		failover:      false,
+		Master:           master,
+		FrameworkInfo:    framework,
+		Status:           mesos.Status_DRIVER_NOT_STARTED,
+		BindingAddress:   os.Getenv(LIBPROCESS_IP_ENV),
+		BindingPort:      bindPort,
+		AdvertiseAddress: os.Getenv(LIBPROCESS_ADVERTISE_IP_ENV),
+		AdvertisePort:    advertisePort,
+		TLS:              tlsConfig,
+		schedMsgQ:        make(chan interface{}, 10),
+		controlQ:         make(chan mesos.Status),
+		connected:        false,
+		failover:         false,
 	}
 
 	driver.Scheduler = scheduler
@@ -78,120 +175,387 @@ func NewSchedDriver(scheduler *Scheduler, framework *mesos.FrameworkInfo, master
 	}
 
 	driver.schedProc = proc
+	driver.Messages = proc.registry
 
 	go setupSchedMsgQ(driver)
 
-	driver.masterClient = newMasterClient(master)
+	driver.masterClient = newMasterClient(string(masterAddr))
 
 	driver.Status = mesos.Status_DRIVER_NOT_STARTED
 
 	return driver, nil
 }
 
+// NewSchedDriverWithStore returns a driver that saves its state in store.
+// When framework has no id, the FrameworkID saved by an earlier run is
+// used, so the driver fails over to the framework rather than registering
+// a new one. Tasks is set to a registry holding the saved tasks.
+// A restart must happen within FrameworkInfo.failover_timeout for the
+// master to keep the framework and its tasks.
+func NewSchedDriverWithStore(scheduler *Scheduler, framework *mesos.FrameworkInfo, master string, store StateStore) (*SchedulerDriver, error) {
+	if store == nil {
+		return nil, fmt.Errorf("Missing StateStore.")
+	}
+	failover := false
+	if framework != nil && framework.GetId().GetValue() == "" {
+		id, err := store.FrameworkId()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read saved FrameworkID: %s", err)
+		}
+		if id != nil {
+			log.Printf("Failing over to saved framework [%s]", id.GetValue())
+			framework.Id = id
+			failover = true
+		}
+	}
+	tasks, err := NewTaskRegistryWithStore(store)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read saved tasks: %s", err)
+	}
+
+	driver, err := NewSchedDriver(scheduler, framework, master)
+	if err != nil {
+		return nil, err
+	}
+	driver.Store = store
+	driver.Tasks = tasks
+	driver.FailoverOnStart = failover
+	return driver, nil
+}
+
 func (driver *SchedulerDriver) Start() mesos.Status {
-	if driver.Status != mesos.Status_DRIVER_NOT_STARTED {
-		return driver.Status
+	if stat := driver.status(); stat != mesos.Status_DRIVER_NOT_STARTED {
+		return stat
+	}
+
+	if driver.ValidateLaunches && driver.OfferPool == nil {
+		driver.OfferPool = NewOfferPool(driver, 0)
+	}
+
+	if driver.Transport == TRANSPORT_HTTP_API {
+		return driver.subscribe()
 	}
 
 	// start sched proc and proc.server (http)
+	driver.schedProc.bindIP = driver.BindingAddress
+	driver.schedProc.bindPort = driver.BindingPort
+	driver.schedProc.advertiseIP = driver.AdvertiseAddress
+	driver.schedProc.advertisePort = driver.AdvertisePort
+	driver.schedProc.tls = driver.TLS
+	driver.schedProc.senderCheck = driver.SenderCheck
+	if driver.Messages == nil {
+		driver.Messages = NewMessageRegistry()
+	}
+	driver.schedProc.registry = driver.Messages
+	driver.schedProc.catchAll = driver.Scheduler != nil && driver.Scheduler.UnhandledMessage != nil
+	// resolved before the process listens, so a master given by hostname is
+	// trusted under its addresses from the first event
+	driver.schedProc.trustMaster(resolveHostPort(string(driver.masterClient.address))...)
+	if driver.TLS != nil {
+		driver.masterClient.useTLS(driver.TLS)
+	}
 	err := driver.schedProc.start()
 	if err != nil {
-		driver.Status = mesos.Status_DRIVER_ABORTED
+		stat := driver.setStatus(mesos.Status_DRIVER_ABORTED)
 		driver.schedMsgQ <- err
-		return driver.Status
+		return stat
 	}
 
-	// register framework
-	err = driver.masterClient.RegisterFramework(driver.schedProc.processId, driver.FrameworkInfo)
+	// register framework, or re-register it when failing over
+	if driver.FailoverOnStart && driver.FrameworkInfo.GetId().GetValue() != "" {
+		driver.lock.Lock()
+		driver.failover = true
+		driver.lock.Unlock()
+		err = driver.masterClient.ReregisterFramework(driver.schedProc.processId, driver.FrameworkInfo, true)
+	} else {
+		err = driver.masterClient.RegisterFramework(driver.schedProc.processId, driver.FrameworkInfo)
+	}
 	if err != nil {
-		driver.Status = mesos.Status_DRIVER_ABORTED
+		stat := driver.setStatus(mesos.Status_DRIVER_ABORTED)
 		driver.schedMsgQ <- NewMesosError("Failed to register the framework:" + err.Error())
-	} else {
-		driver.Status = mesos.Status_DRIVER_RUNNING
+		return stat
 	}
-	return driver.Status
+	return driver.setStatus(mesos.Status_DRIVER_RUNNING)
+}
+
+// subscribe starts the framework over the v1 HTTP Scheduler API.
+func (driver *SchedulerDriver) subscribe() mesos.Status {
+	driver.httpClient = newHttpSchedClient(string(driver.masterClient.address), driver.FrameworkInfo, driver.schedMsgQ)
+	if driver.TLS != nil {
+		driver.httpClient.useTLS(driver.TLS)
+	}
+	err := driver.httpClient.start()
+	if err != nil {
+		stat := driver.setStatus(mesos.Status_DRIVER_ABORTED)
+		driver.schedMsgQ <- NewMesosError("Failed to subscribe the framework:" + err.Error())
+		return stat
+	}
+	return driver.setStatus(mesos.Status_DRIVER_RUNNING)
 }
 
 func (driver *SchedulerDriver) Join() mesos.Status {
-	if driver.Status != mesos.Status_DRIVER_RUNNING {
-		return driver.Status
+	if stat := driver.status(); stat != mesos.Status_DRIVER_RUNNING {
+		return stat
 	}
 	return <-driver.controlQ
 }
 
 func (driver *SchedulerDriver) Run() mesos.Status {
-	driver.Status = driver.Start()
-	if driver.Status != mesos.Status_DRIVER_RUNNING {
-		return driver.Status
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		return stat
 	}
 	return driver.Join()
 }
 
 func (driver *SchedulerDriver) Stop(failover bool) mesos.Status {
-	log.Printf("Stopping framework [%s]", driver.FrameworkInfo.GetId().GetValue())
-	if driver.Status != mesos.Status_DRIVER_RUNNING {
-		return driver.Status
+	log.Printf("Stopping framework [%s]", driver.FrameworkId().GetValue())
+	if stat := driver.status(); stat != mesos.Status_DRIVER_RUNNING {
+		return stat
 	}
-	err := driver.schedProc.stop()
-	if err != nil {
-		driver.schedMsgQ <- err
+	if driver.httpClient == nil {
+		err := driver.schedProc.stop()
+		if err != nil {
+			driver.schedMsgQ <- err
+		}
 	}
 
-	if driver.connected && !failover {
-		err = driver.masterClient.UnregisterFramework(driver.schedProc.processId, driver.FrameworkInfo.Id)
+	if driver.isConnected() && !failover {
+		err := driver.unregisterFramework()
 		if err != nil {
-			driver.Status = mesos.Status_DRIVER_ABORTED //TODO confirm logic
+			driver.setStatus(mesos.Status_DRIVER_ABORTED) //TODO confirm logic
 			driver.schedMsgQ <- NewMesosError("Failed to unregister the framework:" + err.Error())
 		} else {
+			driver.lock.Lock()
 			driver.Status = mesos.Status_DRIVER_STOPPED
 			driver.connected = false // assume disconnection.
+			driver.lock.Unlock()
+			driver.saveFrameworkId(nil)
 		}
 	}
+	if driver.httpClient != nil {
+		driver.httpClient.stop()
+	}
 
-	driver.controlQ <- driver.Status // signal
-	return driver.Status
+	stat := driver.status()
+	driver.controlQ <- stat // signal
+	return stat
 }
 
 func (driver *SchedulerDriver) Abort() mesos.Status {
-	log.Printf("Aborting framework [%s]", driver.FrameworkInfo.GetId().GetValue())
-	if driver.Status != mesos.Status_DRIVER_RUNNING {
-		return driver.Status
+	log.Printf("Aborting framework [%s]", driver.FrameworkId().GetValue())
+	if stat := driver.status(); stat != mesos.Status_DRIVER_RUNNING {
+		return stat
 	}
 
-	if !driver.connected {
+	if !driver.isConnected() {
 		log.Println("Not sending deactivate message, master is disconnected.")
 	} else {
-		err := driver.masterClient.DeactivateFramework(driver.schedProc.processId, driver.FrameworkInfo.Id)
+		err := driver.deactivateFramework()
 		if err != nil {
 			driver.schedMsgQ <- NewMesosError("Failed to abort the framework:" + err.Error())
 		} else {
 			driver.schedProc.aborted = true
-			driver.Status = mesos.Status_DRIVER_ABORTED
+			driver.setStatus(mesos.Status_DRIVER_ABORTED)
 		}
 	}
 
-	driver.controlQ <- driver.Status // signal
-	return driver.Status
+	stat := driver.status()
+	driver.controlQ <- stat // signal
+	return stat
 
 }
 
 func (driver *SchedulerDriver) KillTask(taskId *mesos.TaskID) mesos.Status {
-	if driver.Status != mesos.Status_DRIVER_RUNNING {
-		return driver.Status
+	stat, _ := driver.killTask(taskId)
+	return stat
+}
+
+// killTask is KillTask, also returning the error of a kill not sent.
+func (driver *SchedulerDriver) killTask(taskId *mesos.TaskID) (mesos.Status, error) {
+	stat := driver.status()
+	if stat != mesos.Status_DRIVER_RUNNING {
+		return stat, fmt.Errorf("Unable to kill task, the driver is not running.")
 	}
 
-	if !driver.connected {
+	if !driver.isConnected() {
 		log.Println("Ignoring kill task message, master is disconnected")
+		return stat, fmt.Errorf("Unable to kill task, the master is disconnected.")
+	}
+
+	var err error
+	if driver.httpClient != nil {
+		err = driver.httpClient.KillTask(taskId)
 	} else {
-		err := driver.masterClient.KillTask(driver.schedProc.processId, taskId)
-		if err != nil {
-			log.Println("Unable to kill requested task", taskId.GetValue(), ":", err)
-		}
+		err = driver.masterClient.KillTask(driver.schedProc.processId, driver.FrameworkId(), taskId)
+	}
+	if err != nil {
+		log.Println("Unable to kill requested task", taskId.GetValue(), ":", err)
 	}
+	return stat, err
+}
+
+// GetStatus returns the status of the driver. It is safe to call while
+// the driver runs.
+func (driver *SchedulerDriver) GetStatus() mesos.Status {
+	return driver.status()
+}
 
+func (driver *SchedulerDriver) status() mesos.Status {
+	driver.lock.RLock()
+	defer driver.lock.RUnlock()
 	return driver.Status
 }
 
+// setStatus sets the status of the driver and returns it.
+func (driver *SchedulerDriver) setStatus(stat mesos.Status) mesos.Status {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	driver.Status = stat
+	return stat
+}
+
+func (driver *SchedulerDriver) isConnected() bool {
+	driver.lock.RLock()
+	defer driver.lock.RUnlock()
+	return driver.connected
+}
+
+func (driver *SchedulerDriver) setConnected(connected bool) {
+	driver.lock.Lock()
+	defer driver.lock.Unlock()
+	driver.connected = connected
+}
+
+// LaunchTasks launches tasks on the resources of the given offers.
+// Offers are declined when tasks is empty. When ValidateLaunches is set,
+// an invalid batch is not sent and the driver keeps running, see
+// LaunchTasksErr for the error naming the offending task.
+func (driver *SchedulerDriver) LaunchTasks(offerIds []*mesos.OfferID, tasks []*mesos.TaskInfo, filters *mesos.Filters) mesos.Status {
+	stat, _ := driver.LaunchTasksErr(offerIds, tasks, filters)
+	return stat
+}
+
+// LaunchTasksErr is LaunchTasks, also returning why tasks were not launched.
+// A batch rejected by validation fails with a *LaunchError.
+func (driver *SchedulerDriver) LaunchTasksErr(offerIds []*mesos.OfferID, tasks []*mesos.TaskInfo, filters *mesos.Filters) (mesos.Status, error) {
+	stat := driver.status()
+	if stat != mesos.Status_DRIVER_RUNNING {
+		return stat, fmt.Errorf("Unable to launch tasks, the driver is not running.")
+	}
+
+	if !driver.isConnected() {
+		log.Println("Ignoring launch tasks message, master is disconnected")
+		return stat, fmt.Errorf("Unable to launch tasks, the master is disconnected.")
+	}
+
+	if driver.ValidateLaunches && len(tasks) > 0 {
+		if err := driver.ValidateLaunch(offerIds, tasks); err != nil {
+			log.Println("Rejecting launch of tasks:", err)
+			return stat, err
+		}
+	}
+
+	// tasks are registered before they are sent, the master may answer
+	// with a status update before the launch returns
+	var added []taskAddition
+	if driver.Tasks != nil {
+		for _, task := range tasks {
+			addition, err := driver.Tasks.add(task, offerIds)
+			if err != nil {
+				log.Println("Unable to register launched task:", err)
+				driver.Tasks.undo(added)
+				return stat, err
+			}
+			added = append(added, addition)
+		}
+	}
+
+	var err error
+	if driver.httpClient != nil {
+		err = driver.httpClient.LaunchTasks(offerIds, tasks, filters)
+	} else {
+		err = driver.masterClient.LaunchTasks(driver.schedProc.processId, driver.FrameworkId(), offerIds, tasks, filters)
+	}
+	if err != nil {
+		log.Println("Unable to launch tasks:", err)
+		if driver.Tasks != nil {
+			driver.Tasks.undo(added)
+		}
+		return stat, err
+	}
+	// the master consumes the offers, whether or not all resources are used
+	if driver.OfferPool != nil {
+		driver.OfferPool.Remove(offerIds...)
+	}
+
+	return stat, nil
+}
+
+// ValidateLaunch checks tasks against the offers they are launched on, as
+// LaunchTasks does when ValidateLaunches is set. Offers are looked up in
+// OfferPool, offers it does not hold fail. The error returned is a
+// *LaunchError.
+func (driver *SchedulerDriver) ValidateLaunch(offerIds []*mesos.OfferID, tasks []*mesos.TaskInfo) error {
+	offers := make([]*mesos.Offer, 0, len(offerIds))
+	for _, id := range offerIds {
+		var offer *mesos.Offer
+		ok := false
+		if driver.OfferPool != nil {
+			offer, ok = driver.OfferPool.Get(id)
+		}
+		if !ok {
+			return &LaunchError{OfferId: id.GetValue(), Reason: "offer is unknown or no longer valid"}
+		}
+		offers = append(offers, offer)
+	}
+	return ValidateLaunch(offers, tasks)
+}
+
+// DeclineOffer returns the resources of an offer to the master.
+func (driver *SchedulerDriver) DeclineOffer(offerId *mesos.OfferID, filters *mesos.Filters) mesos.Status {
+	return driver.LaunchTasks([]*mesos.OfferID{offerId}, []*mesos.TaskInfo{}, filters)
+}
+
+func (driver *SchedulerDriver) unregisterFramework() error {
+	if driver.httpClient != nil {
+		return driver.httpClient.Teardown()
+	}
+	return driver.masterClient.UnregisterFramework(driver.schedProc.processId, driver.FrameworkId())
+}
+
+// deactivateFramework stops offers to the framework. The HTTP API has no
+// deactivation call, closing the subscription disconnects the framework.
+func (driver *SchedulerDriver) deactivateFramework() error {
+	if driver.httpClient != nil {
+		driver.httpClient.stop()
+		return nil
+	}
+	return driver.masterClient.DeactivateFramework(driver.schedProc.processId, driver.FrameworkId())
+}
+
+// FrameworkId returns the id of the framework, which the driver sets once
+// the framework is registered. It is safe to call while the driver runs.
+func (driver *SchedulerDriver) FrameworkId() *mesos.FrameworkID {
+	if driver.httpClient != nil {
+		return driver.httpClient.frameworkId()
+	}
+	driver.lock.RLock()
+	defer driver.lock.RUnlock()
+	return driver.FrameworkInfo.Id
+}
+
+// acknowledgeStatusUpdate acknowledges updates received over the HTTP API.
+// Updates carrying no uuid do not need acknowledgement.
+func (driver *SchedulerDriver) acknowledgeStatusUpdate(status *mesos.TaskStatus) {
+	if driver.httpClient == nil || len(status.GetUuid()) == 0 {
+		return
+	}
+	err := driver.httpClient.Acknowledge(status)
+	if err != nil {
+		log.Println("Unable to acknowledge status update for task", status.GetTaskId().GetValue(), ":", err)
+	}
+}
+
 func setupSchedMsgQ(driver *SchedulerDriver) {
 	sched := driver.Scheduler
 	for event := range driver.schedMsgQ {
@@ -210,6 +574,9 @@ func setupSchedMsgQ(driver *SchedulerDriver) {
 			driver.handleResourceOffers(msg)
 
 		case *mesos.RescindResourceOfferMessage:
+			if driver.OfferPool != nil {
+				driver.OfferPool.Remove(msg.OfferId)
+			}
 			go func() {
 				if sched.OfferRescinded != nil {
 					sched.OfferRescinded(driver, msg.OfferId)
@@ -217,10 +584,16 @@ func setupSchedMsgQ(driver *SchedulerDriver) {
 			}()
 
 		case *mesos.StatusUpdateMessage:
+			if driver.Tasks != nil {
+				if err := driver.Tasks.Update(msg.Update.Status); err != nil {
+					log.Println("Task registry rejected status update:", err)
+				}
+			}
 			go func() {
 				if sched.StatusUpdate != nil {
 					sched.StatusUpdate(driver, msg.Update.Status)
 				}
+				driver.acknowledgeStatusUpdate(msg.Update.Status)
 			}()
 
 		case *mesos.ExecutorToFrameworkMessage:
@@ -231,12 +604,28 @@ func setupSchedMsgQ(driver *SchedulerDriver) {
 			}()
 
 		case *mesos.LostSlaveMessage:
+			if driver.OfferPool != nil {
+				driver.OfferPool.RemoveSlave(msg.SlaveId)
+			}
+			if driver.Tasks != nil {
+				driver.Tasks.SlaveLost(msg.SlaveId)
+			}
 			go func() {
 				if sched.SlaveLost != nil {
 					sched.SlaveLost(driver, msg.SlaveId)
 				}
 			}()
 
+		case *disconnectedEvent:
+			log.Println("Framework disconnected from master.")
+			driver.setConnected(false)
+			if driver.OfferPool != nil {
+				driver.OfferPool.Clear()
+			}
+
+		case *messageEvent:
+			go driver.handleMessageEvent(msg)
+
 		case MesosError:
 			go func() {
 				driver.handleError(msg)
@@ -251,23 +640,30 @@ func setupSchedMsgQ(driver *SchedulerDriver) {
 }
 
 func (driver *SchedulerDriver) handleRegistered(msg *mesos.FrameworkRegisteredMessage) {
+	driver.lock.Lock()
 	if driver.Status == mesos.Status_DRIVER_ABORTED {
+		driver.lock.Unlock()
 		log.Println("Ignoring FrameworkRegisteredMessage, the driver is aborted!")
 		return
 	}
 
-	if driver.connected == true {
+	if driver.connected {
+		driver.lock.Unlock()
 		log.Println("Ignoring FrameworkRegisteredMessage, the driver is already connected!")
 		return
 	}
 
-	//TODO detect if message was from leading-master (sched.cpp)
-
-	log.Printf("Framework registered with ID [%s] ", msg.GetFrameworkId().GetValue())
-
-	// TODO add synchronization
+	if driver.httpClient == nil {
+		// the HTTP client sets the id itself when subscribed
+		driver.FrameworkInfo.Id = msg.FrameworkId
+	}
 	driver.connected = true
 	driver.failover = false
+	driver.lock.Unlock()
+
+	log.Printf("Framework registered with ID [%s] ", msg.GetFrameworkId().GetValue())
+	driver.trustLeadingMaster(msg.MasterInfo)
+	driver.saveFrameworkId(msg.FrameworkId)
 
 	sched := driver.Scheduler
 	if sched != nil && sched.Registered != nil {
@@ -276,23 +672,28 @@ func (driver *SchedulerDriver) handleRegistered(msg *mesos.FrameworkRegisteredMe
 }
 
 func (driver *SchedulerDriver) handleReregistered(msg *mesos.FrameworkReregisteredMessage) {
+	driver.lock.Lock()
 	if driver.Status == mesos.Status_DRIVER_ABORTED {
+		driver.lock.Unlock()
 		log.Println("Ignoring FrameworkReRegisteredMessage, the driver is aborted!")
 		return
 	}
 
-	if driver.connected == true {
+	if driver.connected {
+		driver.lock.Unlock()
 		log.Println("Ignoring FrameworkReRegisteredMessage, the driver is already connected!")
 		return
 	}
 
-	//TODO detect if message was from leading-master (sched.cpp)
-
-	log.Printf("Framework re-registered with ID [%s] ", msg.GetFrameworkId().GetValue())
-
-	// TODO add synchronization
 	driver.connected = true
 	driver.failover = false
+	driver.lock.Unlock()
+
+	log.Printf("Framework re-registered with ID [%s] ", msg.GetFrameworkId().GetValue())
+	driver.trustLeadingMaster(msg.MasterInfo)
+	if msg.FrameworkId != nil {
+		driver.saveFrameworkId(msg.FrameworkId)
+	}
 
 	sched := driver.Scheduler
 	if sched != nil && sched.Reregistered != nil {
@@ -300,17 +701,51 @@ func (driver *SchedulerDriver) handleReregistered(msg *mesos.FrameworkReregister
 	}
 }
 
+// trustLeadingMaster makes the master described by info the only accepted
+// sender of master events, so messages from a stale master are detected.
+// The master is trusted under its pid, which it sends from, so nothing is
+// resolved on the event loop.
+func (driver *SchedulerDriver) trustLeadingMaster(info *mesos.MasterInfo) {
+	if pid, err := parseUPID(info.GetPid()); err == nil {
+		driver.schedProc.trustMaster(pid.hostPort())
+		return
+	}
+	if addr, err := MasterInfoAddress(info); err == nil {
+		driver.schedProc.trustMaster(addr)
+	}
+}
+
+// saveFrameworkId saves id in the store, if any. A nil id clears it once
+// the framework is unregistered.
+func (driver *SchedulerDriver) saveFrameworkId(id *mesos.FrameworkID) {
+	if driver.Store == nil {
+		return
+	}
+	if err := driver.Store.SaveFrameworkId(id); err != nil {
+		log.Println("Unable to save FrameworkID:", err)
+	}
+}
+
+// Metrics returns a snapshot of the event counters of the driver.
+func (driver *SchedulerDriver) Metrics() Metrics {
+	return driver.schedProc.metrics.snapshot()
+}
+
 func (driver *SchedulerDriver) handleResourceOffers(msg *mesos.ResourceOffersMessage) {
-	if driver.Status == mesos.Status_DRIVER_ABORTED {
+	if driver.status() == mesos.Status_DRIVER_ABORTED {
 		log.Println("Ignoring ResourceOffersMessage, the driver is aborted!")
 		return
 	}
 
-	if !driver.connected {
+	if !driver.isConnected() {
 		log.Println("Ignoring ResourceOffersMessage, the driver is not connected!")
 		return
 	}
 
+	if driver.OfferPool != nil {
+		driver.OfferPool.Add(msg.Offers...)
+	}
+
 	sched := driver.Scheduler
 	if sched != nil && sched.ResourceOffers != nil {
 		go sched.ResourceOffers(driver, msg.Offers)
@@ -318,8 +753,19 @@ func (driver *SchedulerDriver) handleResourceOffers(msg *mesos.ResourceOffersMes
 
 }
 
+func (driver *SchedulerDriver) handleMessageEvent(event *messageEvent) {
+	if event.handler != nil {
+		event.handler(driver, event.msg)
+		return
+	}
+	sched := driver.Scheduler
+	if sched != nil && sched.UnhandledMessage != nil {
+		sched.UnhandledMessage(driver, event.name, event.data)
+	}
+}
+
 func (driver *SchedulerDriver) handleError(err MesosError) {
-	if driver.Status == mesos.Status_DRIVER_ABORTED {
+	if driver.status() == mesos.Status_DRIVER_ABORTED {
 		log.Println("Ignoring error because driver is aborted.")
 		return
 	}
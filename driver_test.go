@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"os"
 	"os/user"
+	"strings"
 	"testing"
 	"time"
 )
@@ -57,6 +58,87 @@ func TestScheDriverCreation_WithFrameworkInfo_Override(t *testing.T) {
 	}
 }
 
+func TestScheDriverCreation_WithLibprocessEnv(t *testing.T) {
+	os.Setenv(LIBPROCESS_IP_ENV, "127.0.0.1")
+	os.Setenv(LIBPROCESS_PORT_ENV, "0")
+	os.Setenv(LIBPROCESS_ADVERTISE_IP_ENV, "10.1.2.3")
+	os.Setenv(LIBPROCESS_ADVERTISE_PORT_ENV, "15050")
+	defer func() {
+		os.Unsetenv(LIBPROCESS_IP_ENV)
+		os.Unsetenv(LIBPROCESS_PORT_ENV)
+		os.Unsetenv(LIBPROCESS_ADVERTISE_IP_ENV)
+		os.Unsetenv(LIBPROCESS_ADVERTISE_PORT_ENV)
+	}()
+
+	driver, err := NewSchedDriver(nil, &mesos.FrameworkInfo{}, "localhost:5050")
+	if err != nil {
+		t.Fatal("Error creating SchedDriver", err)
+	}
+	if driver.BindingAddress != "127.0.0.1" || driver.BindingPort != 0 {
+		t.Fatal("SchedDriver not reading binding address from env, got", driver.BindingAddress, driver.BindingPort)
+	}
+	if driver.AdvertiseAddress != "10.1.2.3" || driver.AdvertisePort != 15050 {
+		t.Fatal("SchedDriver not reading advertise address from env, got", driver.AdvertiseAddress, driver.AdvertisePort)
+	}
+}
+
+func TestScheDriverCreation_WithBadLibprocessPort(t *testing.T) {
+	os.Setenv(LIBPROCESS_PORT_ENV, "not-a-port")
+	defer os.Unsetenv(LIBPROCESS_PORT_ENV)
+
+	_, err := NewSchedDriver(nil, &mesos.FrameworkInfo{}, "localhost:5050")
+	if err == nil {
+		t.Fatal("SchedDriver expected to fail with invalid LIBPROCESS_PORT.")
+	}
+}
+
+func TestDriverStart_AdvertisesConfiguredAddress(t *testing.T) {
+	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Libprocess-From") != "" &&
+			!strings.HasSuffix(req.Header.Get("Libprocess-From"), "@10.1.2.3:15050") {
+			t.Errorf("Unexpected Libprocess-From %s", req.Header.Get("Libprocess-From"))
+		}
+		rsp.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Close()
+	url, _ := url.Parse(server.URL)
+	driver, err := NewSchedDriver(
+		nil,
+		NewFrameworkInfo("test", "test-framework-1", NewFrameworkID("test-id")),
+		url.Host,
+	)
+	if err != nil {
+		t.Fatal("Error creating SchedulerDriver", err)
+	}
+	driver.BindingAddress = "127.0.0.1"
+	driver.AdvertiseAddress = "10.1.2.3"
+	driver.AdvertisePort = 15050
+
+	stat := driver.Start()
+	if stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("SchedulerDriver.Start() - failed to start:", stat)
+	}
+	defer driver.schedProc.stop()
+	if !strings.HasSuffix(driver.schedProc.processId.value, "@10.1.2.3:15050") {
+		t.Fatal("SchedulerDriver not advertising configured address, got", driver.schedProc.processId.value)
+	}
+}
+
+func TestScheDriverCreation_WithIPv6Master(t *testing.T) {
+	driver, err := NewSchedDriver(nil, &mesos.FrameworkInfo{}, "[2001:db8::1]:5050")
+	if err != nil {
+		t.Fatal("Error creating SchedDriver with IPv6 master", err)
+	}
+	u, _ := driver.masterClient.address.AsHttpURL()
+	if u.Hostname() != "2001:db8::1" || u.Port() != "5050" {
+		t.Fatal("SchedDriver did not preserve IPv6 master address, got", u.Host)
+	}
+
+	if _, err = NewSchedDriver(nil, &mesos.FrameworkInfo{}, "2001:db8::1:5050"); err == nil {
+		t.Fatal("SchedDriver expected to reject unbracketed IPv6 master address.")
+	}
+}
+
 func TestDriverStart(t *testing.T) {
 	// test server to accept Framework Registration
 	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
@@ -85,7 +167,7 @@ func TestDriverStart(t *testing.T) {
 		t.Fatal("SchedulerDriver.Start() - failed to start:", stat, ". Expecting DRIVER_RUNNING ")
 	}
 
-	if !driver.connected {
+	if !driver.isConnected() {
 		t.Fatal("SchedulerDriver.Start() not setting connected flag.")
 	}
 
@@ -152,7 +234,7 @@ func TestDriverRun(t *testing.T) {
 		}
 	}()
 	time.Sleep(time.Millisecond * 21)
-	if driver.Status == mesos.Status_DRIVER_RUNNING {
+	if driver.status() == mesos.Status_DRIVER_RUNNING {
 		// simulate registered event
 		msg := &mesos.FrameworkRegisteredMessage{
 			FrameworkId: NewFrameworkID("framework-1"),
@@ -161,10 +243,10 @@ func TestDriverRun(t *testing.T) {
 		driver.schedMsgQ <- msg
 		time.Sleep(time.Millisecond * 21)
 	} else {
-		t.Fatal("SchedulerDriver.Run() - failed to start:", driver.Status, ". Expecting DRIVER_RUNNING ")
+		t.Fatal("SchedulerDriver.Run() - failed to start:", driver.status(), ". Expecting DRIVER_RUNNING ")
 	}
 
-	if driver.connected {
+	if driver.isConnected() {
 		driver.controlQ <- mesos.Status_DRIVER_ABORTED
 	} else {
 		t.Fatal("SchedulerDriver.Run() did not set connected flag.")
@@ -194,7 +276,7 @@ func TestDriverStop(t *testing.T) {
 		}
 	}()
 	time.Sleep(time.Millisecond * 21) // stall.
-	if driver.Status == mesos.Status_DRIVER_RUNNING {
+	if driver.status() == mesos.Status_DRIVER_RUNNING {
 		// simulate registered event
 		msg := &mesos.FrameworkRegisteredMessage{
 			FrameworkId: NewFrameworkID("framework-1"),
@@ -203,13 +285,13 @@ func TestDriverStop(t *testing.T) {
 		driver.schedMsgQ <- msg
 		time.Sleep(time.Millisecond * 21)
 	} else {
-		t.Fatal("Expected DRIVER_RUNNING, but got ", driver.Status)
+		t.Fatal("Expected DRIVER_RUNNING, but got ", driver.status())
 	}
 	stat := driver.Stop(false)
 	if stat != mesos.Status_DRIVER_STOPPED {
 		t.Fatal("SchedulerDriver.Stop() - Expected DRIVER_STOPPED, but got ", stat)
 	}
-	if driver.connected {
+	if driver.isConnected() {
 		t.Fatal("SchedulerDriver.Stop() not setting connected to false.")
 	}
 }
@@ -238,7 +320,7 @@ func TestDriverAbort(t *testing.T) {
 		}
 	}()
 	time.Sleep(21 * time.Millisecond) // stall.
-	if driver.Status == mesos.Status_DRIVER_RUNNING {
+	if driver.status() == mesos.Status_DRIVER_RUNNING {
 		// simulate registered event
 		msg := &mesos.FrameworkRegisteredMessage{
 			FrameworkId: NewFrameworkID("framework-1"),
@@ -247,7 +329,7 @@ func TestDriverAbort(t *testing.T) {
 		driver.schedMsgQ <- msg
 		time.Sleep(time.Millisecond * 21)
 	} else {
-		t.Fatal("Expected DRIVER_RUNNING, but got ", driver.Status)
+		t.Fatal("Expected DRIVER_RUNNING, but got ", driver.status())
 	}
 
 	stat := driver.Abort()
@@ -256,6 +338,58 @@ func TestDriverAbort(t *testing.T) {
 	}
 }
 
+func TestDriverTrustsLeadingMaster(t *testing.T) {
+	driver, err := NewSchedDriver(nil, &mesos.FrameworkInfo{}, "127.0.0.1:5050")
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.schedProc.trustMaster("127.0.0.1:5050")
+
+	info := NewMasterInfo("master-2", 0, 5050)
+	info.Pid = proto.String("master@10.0.0.2:5050")
+	driver.trustLeadingMaster(info)
+
+	req, _ := http.NewRequest(HTTP_POST_METHOD, "/scheduler(1)/mesos.internal.ResourceOffersMessage", nil)
+	req.Header.Set("Libprocess-From", "master@10.0.0.2:5050")
+	if err := driver.schedProc.verifySender(RESOURCE_OFFERS_EVENT, req); err != nil {
+		t.Fatal("Expected new leading master to be trusted:", err)
+	}
+	req.Header.Set("Libprocess-From", "master@127.0.0.1:5050")
+	if err := driver.schedProc.verifySender(RESOURCE_OFFERS_EVENT, req); err == nil {
+		t.Fatal("Expected stale master to be rejected.")
+	}
+	if driver.Metrics().SenderMismatches != 1 {
+		t.Fatal("Expected stale master to be counted, got", driver.Metrics())
+	}
+}
+
+func TestDriverStart_TrustsResolvedMaster(t *testing.T) {
+	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Close()
+	url, _ := url.Parse(server.URL)
+	driver, err := NewSchedDriver(nil,
+		NewFrameworkInfo("test", "test-framework-1", NewFrameworkID("test-id")),
+		"localhost:"+url.Port())
+	if err != nil {
+		t.Fatal("Error creating SchedulerDriver", err)
+	}
+	driver.BindingAddress = "127.0.0.1"
+	driver.SenderCheck = SENDER_CHECK_REJECT
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", stat)
+	}
+	defer driver.schedProc.stop()
+
+	// the master given by name may send from its address at once
+	req, _ := http.NewRequest(HTTP_POST_METHOD, "/scheduler(1)/mesos.internal.FrameworkRegisteredMessage", nil)
+	req.Header.Set("Libprocess-From", "master@127.0.0.1:"+url.Port())
+	if err := driver.schedProc.verifySender(FRAMEWORK_REGISTERED_EVENT, req); err != nil {
+		t.Fatal("Expected resolved master address trusted from Start:", err)
+	}
+}
+
 func TestKillTask(t *testing.T) {
 	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
 		rsp.WriteHeader(http.StatusAccepted)
@@ -273,10 +407,10 @@ func TestKillTask(t *testing.T) {
 		driver.Run()
 	}()
 	time.Sleep(21 * time.Millisecond) // stall.
-	if driver.Status == mesos.Status_DRIVER_RUNNING {
-		driver.connected = true
+	if driver.status() == mesos.Status_DRIVER_RUNNING {
+		driver.setConnected(true)
 	} else {
-		t.Fatal("Expected DRIVER_RUNNING, but got ", driver.Status)
+		t.Fatal("Expected DRIVER_RUNNING, but got ", driver.status())
 	}
 	driver.KillTask(NewTaskID("test-task-1"))
 
@@ -473,8 +607,8 @@ func TestErrorMessageHandling(t *testing.T) {
 		}
 
 		// make sure driver aborted.
-		if driver.Status != mesos.Status_DRIVER_ABORTED {
-			t.Fatalf("Expected SchedulerDriver to have status, %s, but is %s", mesos.Status_DRIVER_ABORTED, driver.Status)
+		if driver.status() != mesos.Status_DRIVER_ABORTED {
+			t.Fatalf("Expected SchedulerDriver to have status, %s, but is %s", mesos.Status_DRIVER_ABORTED, driver.status())
 		}
 	}
 
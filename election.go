@@ -0,0 +1,267 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_LEASE_TTL = 15 * time.Second
+)
+
+// Lock is a lease shared by the instances of a scheduler. The lease is
+// held by one owner at a time and lapses unless renewed within its ttl.
+type Lock interface {
+	// TryAcquire takes the lease for owner, or renews it when owner holds
+	// it already. It reports whether owner holds the lease.
+	TryAcquire(owner string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if owner holds it.
+	Release(owner string) error
+}
+
+// MemLock is a Lock shared by electors of the same process, i.e. in tests.
+type MemLock struct {
+	lock   sync.Mutex
+	owner  string
+	expiry time.Time
+}
+
+func NewMemLock() *MemLock {
+	return &MemLock{}
+}
+
+func (lock *MemLock) TryAcquire(owner string, ttl time.Duration) (bool, error) {
+	lock.lock.Lock()
+	defer lock.lock.Unlock()
+	now := time.Now()
+	if lock.owner != "" && lock.owner != owner && now.Before(lock.expiry) {
+		return false, nil
+	}
+	lock.owner = owner
+	lock.expiry = now.Add(ttl)
+	return true, nil
+}
+
+func (lock *MemLock) Release(owner string) error {
+	lock.lock.Lock()
+	defer lock.lock.Unlock()
+	if lock.owner == owner {
+		lock.owner = ""
+	}
+	return nil
+}
+
+/*
+LeaderElector runs a scheduler on one of several redundant instances.
+Each instance campaigns for the lease of a shared Lock; the instance
+holding it creates a driver with NewDriver and starts it, while the others
+stand by. NewDriver should fail over to the framework of the previous
+leader, i.e. using NewSchedDriverWithStore with a store shared by the
+instances:
+
+	elector := NewLeaderElector("sched-1", lock, func() (*SchedulerDriver, error) {
+		return NewSchedDriverWithStore(sched, framework, master, store)
+	})
+	elector.Start()
+
+A leader that can not renew its lease within LeaseTTL is demoted and its
+driver stopped with Stop(true), so the framework stays registered for the
+next leader to take over.
+
+The stores of this package do not reach across hosts: MemStateStore is
+shared within a process and FileStore keeps its state in a local
+directory. Instances on different hosts only fail over with a StateStore
+they all reach; without one, a new leader finds no saved FrameworkID and
+registers a new framework. The same holds for the Lock, see FileLock.
+*/
+type LeaderElector struct {
+	Id        string
+	Lock      Lock
+	NewDriver func() (*SchedulerDriver, error)
+
+	// LeaseTTL is how long the lease lasts without renewal. The lease is
+	// renewed, and standbys campaign, every RenewInterval.
+	LeaseTTL      time.Duration
+	RenewInterval time.Duration
+
+	// Elected and Demoted, when set, are called as the instance takes and
+	// loses the lead.
+	Elected func(elector *LeaderElector, driver *SchedulerDriver)
+	Demoted func(elector *LeaderElector, driver *SchedulerDriver)
+
+	lock      sync.Mutex
+	driver    *SchedulerDriver
+	renewed   time.Time
+	running   bool
+	quit      chan struct{}
+	done      chan struct{}
+	driverEnd chan *SchedulerDriver
+}
+
+func NewLeaderElector(id string, lock Lock, newDriver func() (*SchedulerDriver, error)) *LeaderElector {
+	return &LeaderElector{
+		Id:        id,
+		Lock:      lock,
+		NewDriver: newDriver,
+		LeaseTTL:  DEFAULT_LEASE_TTL,
+	}
+}
+
+// Start campaigns for the lead in the background.
+func (elector *LeaderElector) Start() error {
+	if elector.Id == "" {
+		return fmt.Errorf("Missing elector id.")
+	}
+	if elector.Lock == nil || elector.NewDriver == nil {
+		return fmt.Errorf("Elector %s is missing a Lock or NewDriver.", elector.Id)
+	}
+	if elector.LeaseTTL <= 0 {
+		elector.LeaseTTL = DEFAULT_LEASE_TTL
+	}
+	if elector.RenewInterval <= 0 || elector.RenewInterval >= elector.LeaseTTL {
+		elector.RenewInterval = elector.LeaseTTL / 3
+	}
+
+	elector.lock.Lock()
+	defer elector.lock.Unlock()
+	if elector.running {
+		return fmt.Errorf("Elector %s is already started.", elector.Id)
+	}
+	elector.running = true
+	elector.quit = make(chan struct{})
+	elector.done = make(chan struct{})
+	elector.driverEnd = make(chan *SchedulerDriver, 1)
+	go elector.campaign()
+	return nil
+}
+
+// Stop ends the campaign. A leader stops its driver with Stop(true) and
+// releases the lease, so a standby takes over at once.
+func (elector *LeaderElector) Stop() {
+	elector.lock.Lock()
+	if !elector.running {
+		elector.lock.Unlock()
+		return
+	}
+	elector.running = false
+	close(elector.quit)
+	elector.lock.Unlock()
+	<-elector.done
+}
+
+// IsLeader reports whether the instance leads and runs the driver.
+func (elector *LeaderElector) IsLeader() bool {
+	return elector.Driver() != nil
+}
+
+// Driver returns the driver run by the leader, or nil on a standby.
+func (elector *LeaderElector) Driver() *SchedulerDriver {
+	elector.lock.Lock()
+	defer elector.lock.Unlock()
+	return elector.driver
+}
+
+func (elector *LeaderElector) campaign() {
+	defer close(elector.done)
+	ticker := time.NewTicker(elector.RenewInterval)
+	defer ticker.Stop()
+
+	elector.tryLead()
+	for {
+		select {
+		case <-elector.quit:
+			if elector.demote("elector stopped") {
+				if err := elector.Lock.Release(elector.Id); err != nil {
+					log.Println("Unable to release lease:", err)
+				}
+			}
+			return
+		case driver := <-elector.driverEnd:
+			// the driver ended on its own (i.e. aborted), let another instance lead
+			if driver == elector.Driver() {
+				elector.demote("driver ended")
+				elector.Lock.Release(elector.Id)
+			}
+		case <-ticker.C:
+			elector.tryLead()
+		}
+	}
+}
+
+// tryLead renews the lease of a leader, or campaigns for a standby.
+func (elector *LeaderElector) tryLead() {
+	leader := elector.IsLeader()
+	acquired, err := elector.Lock.TryAcquire(elector.Id, elector.LeaseTTL)
+	if err != nil {
+		log.Printf("Elector %s unable to acquire lease: %s", elector.Id, err)
+		// the lease may still be held until it expires
+		if leader && time.Since(elector.renewed) >= elector.LeaseTTL-elector.RenewInterval {
+			elector.demote("lease renewal failed")
+		}
+		return
+	}
+	if !acquired {
+		if leader {
+			elector.demote("lease lost")
+		}
+		return
+	}
+	elector.renewed = time.Now()
+	if !leader {
+		elector.promote()
+	}
+}
+
+func (elector *LeaderElector) promote() {
+	driver, err := elector.NewDriver()
+	if err != nil {
+		log.Printf("Elector %s unable to create driver: %s", elector.Id, err)
+		elector.Lock.Release(elector.Id)
+		return
+	}
+	log.Printf("Elector %s elected leader, starting framework.", elector.Id)
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		log.Printf("Elector %s unable to start driver: %s", elector.Id, stat)
+		elector.Lock.Release(elector.Id)
+		return
+	}
+
+	elector.lock.Lock()
+	elector.driver = driver
+	elector.lock.Unlock()
+	end, done := elector.driverEnd, elector.done
+	go func() {
+		driver.Join()
+		select {
+		case end <- driver:
+		case <-done:
+		}
+	}()
+
+	if elector.Elected != nil {
+		elector.Elected(elector, driver)
+	}
+}
+
+// demote stops the driver of a leader with Stop(true), leaving the
+// framework registered for the next leader. It reports whether the
+// instance was leading.
+func (elector *LeaderElector) demote(reason string) bool {
+	elector.lock.Lock()
+	driver := elector.driver
+	elector.driver = nil
+	elector.lock.Unlock()
+	if driver == nil {
+		return false
+	}
+
+	log.Printf("Elector %s demoted (%s), stopping framework.", elector.Id, reason)
+	driver.Stop(true)
+	if elector.Demoted != nil {
+		elector.Demoted(elector, driver)
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"testing"
+	"time"
+)
+
+func checkLease(t *testing.T, lock Lock, owner string, expected bool) {
+	acquired, err := lock.TryAcquire(owner, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acquired != expected {
+		t.Fatal("Expected TryAcquire for", owner, "to return", expected)
+	}
+}
+
+// checkLockSemantics runs the lease checks shared by every Lock.
+func checkLockSemantics(t *testing.T, lock1, lock2 Lock) {
+	checkLease(t, lock1, "sched-1", true)
+	checkLease(t, lock2, "sched-2", false)
+	checkLease(t, lock1, "sched-1", true)
+
+	lock2.Release("sched-2")
+	checkLease(t, lock2, "sched-2", false)
+	lock1.Release("sched-1")
+	checkLease(t, lock2, "sched-2", true)
+
+	time.Sleep(150 * time.Millisecond)
+	checkLease(t, lock1, "sched-1", true)
+}
+
+func TestMemLock(t *testing.T) {
+	lock := NewMemLock()
+	checkLockSemantics(t, lock, lock)
+}
+
+// failingLock fails every call once broken, as a lock backend that can
+// no longer be reached.
+type failingLock struct {
+	Lock
+	broken chan bool
+}
+
+func (lock *failingLock) TryAcquire(owner string, ttl time.Duration) (bool, error) {
+	select {
+	case <-lock.broken:
+		return false, fmt.Errorf("Lock backend unavailable.")
+	default:
+		return lock.Lock.TryAcquire(owner, ttl)
+	}
+}
+
+func makeTestElector(id string, lock Lock, master *testV1Master, store StateStore) *LeaderElector {
+	elector := NewLeaderElector(id, lock, func() (*SchedulerDriver, error) {
+		driver, err := NewSchedDriverWithStore(NewMesosScheduler(), NewFrameworkInfo("test-user", "test-framework", nil), master.host(), store)
+		if err != nil {
+			return nil, err
+		}
+		driver.Transport = TRANSPORT_HTTP_API
+		return driver, nil
+	})
+	elector.LeaseTTL = 300 * time.Millisecond
+	elector.RenewInterval = 50 * time.Millisecond
+	return elector
+}
+
+func TestLeaderElector_Failover(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	lock := NewMemLock()
+	store := NewMemStateStore()
+
+	elector1 := makeTestElector("sched-1", lock, master, store)
+	if err := elector1.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer elector1.Stop()
+	call := master.expectCall(t, mesos.Call_SUBSCRIBE)
+	if call.GetSubscribe().GetFrameworkInfo().GetId() != nil {
+		t.Fatal("Expected first leader to subscribe a new framework.")
+	}
+	master.send(makeSubscribedEvent("framework-1", 10))
+
+	elector2 := makeTestElector("sched-2", lock, master, store)
+	elector2.Start()
+	defer elector2.Stop()
+	time.Sleep(100 * time.Millisecond)
+	if !elector1.IsLeader() || elector2.IsLeader() {
+		t.Fatal("Expected sched-1 to lead and sched-2 to stand by.")
+	}
+	if id, _ := store.FrameworkId(); id.GetValue() != "framework-1" {
+		t.Fatal("Expected leader to save FrameworkID, but got", id)
+	}
+
+	elector1.Stop()
+	if elector1.IsLeader() {
+		t.Fatal("Expected stopped elector not to lead.")
+	}
+	call = master.expectCall(t, mesos.Call_SUBSCRIBE)
+	if call.GetSubscribe().GetFrameworkInfo().GetId().GetValue() != "framework-1" {
+		t.Fatal("Expected standby to fail over to framework-1, but got", call.GetSubscribe().GetFrameworkInfo().GetId())
+	}
+	for i := 0; !elector2.IsLeader(); i++ {
+		if i == 100 {
+			t.Fatal("Expected sched-2 to lead after sched-1 stopped.")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLeaderElector_LeaseLost(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	memLock := NewMemLock()
+	lock := &failingLock{Lock: memLock, broken: make(chan bool)}
+
+	elected := make(chan *SchedulerDriver, 1)
+	demoted := make(chan *SchedulerDriver, 1)
+	elector := makeTestElector("sched-1", lock, master, NewMemStateStore())
+	elector.Elected = func(elector *LeaderElector, driver *SchedulerDriver) {
+		elected <- driver
+	}
+	elector.Demoted = func(elector *LeaderElector, driver *SchedulerDriver) {
+		demoted <- driver
+	}
+	elector.Start()
+	defer elector.Stop()
+
+	master.expectCall(t, mesos.Call_SUBSCRIBE)
+	var driver *SchedulerDriver
+	select {
+	case driver = <-elected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Elector not elected.")
+	}
+
+	close(lock.broken)
+	select {
+	case d := <-demoted:
+		if d != driver {
+			t.Fatal("Expected the elected driver to be stopped on demotion.")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Elector not demoted after failing to renew its lease.")
+	}
+	if elector.IsLeader() {
+		t.Fatal("Expected demoted elector not to lead.")
+	}
+	// demoted before the lease lapsed, so no two leaders overlap
+	if acquired, _ := memLock.TryAcquire("sched-2", time.Second); acquired {
+		t.Fatal("Expected lease of demoted leader not expired yet.")
+	}
+}
+
+func TestLeaderElector_StartValidation(t *testing.T) {
+	if err := NewLeaderElector("", NewMemLock(), nil).Start(); err == nil {
+		t.Fatal("Expected error starting elector without id.")
+	}
+	if err := NewLeaderElector("sched-1", nil, nil).Start(); err == nil {
+		t.Fatal("Expected error starting elector without lock.")
+	}
+}
@@ -0,0 +1,428 @@
+package gomes
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"github.com/vladimirvivien/gomes/recordio"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// time allowed to reconnect to a restarting agent before shutting down.
+const defaultRecoveryTimeout = 15 * time.Minute
+
+/*
+ExecutorDriver connects an Executor to its agent using the v1 HTTP Executor
+API (/api/v1/executor). Events read from the subscription are passed to the
+Executor callbacks. Status updates are kept until the agent acknowledges
+them, and resent when resubscribing after an agent restart.
+*/
+type ExecutorDriver struct {
+	// Agent is the address of the agent, read from MESOS_AGENT_ENDPOINT.
+	Agent       string
+	Executor    *Executor
+	FrameworkId *mesos.FrameworkID
+	ExecutorId  *mesos.ExecutorID
+	Status      mesos.Status
+
+	// TLS enables encrypted transport to the agent when set.
+	// The default is read from the LIBPROCESS_SSL_* environment variables.
+	TLS *TLSConfig
+
+	// RecoveryTimeout is how long the driver tries to resubscribe after
+	// the connection to the agent is lost, before shutting down.
+	RecoveryTimeout time.Duration
+
+	mutex      sync.Mutex
+	scheme     string
+	httpClient http.Client
+	stream     io.ReadCloser
+	subscribed bool
+	tasks      map[string]*mesos.TaskInfo   // launched, no update acknowledged
+	updates    []*mesos.ExecutorCall_Update // sent, not acknowledged
+	done       chan struct{}
+}
+
+// NewExecDriver returns a driver for executor configured from the
+// environment the agent sets for executors.
+func NewExecDriver(executor *Executor) (*ExecutorDriver, error) {
+	agent := os.Getenv(MESOS_AGENT_ENDPOINT_ENV)
+	if agent == "" {
+		return nil, fmt.Errorf("Missing agent address %s.", MESOS_AGENT_ENDPOINT_ENV)
+	}
+	agentAddr, err := parseAddress(agent)
+	if err != nil {
+		return nil, err
+	}
+	frameworkId := os.Getenv(MESOS_FRAMEWORK_ID_ENV)
+	if frameworkId == "" {
+		return nil, fmt.Errorf("Missing framework id %s.", MESOS_FRAMEWORK_ID_ENV)
+	}
+	executorId := os.Getenv(MESOS_EXECUTOR_ID_ENV)
+	if executorId == "" {
+		return nil, fmt.Errorf("Missing executor id %s.", MESOS_EXECUTOR_ID_ENV)
+	}
+
+	tlsConfig, err := TLSConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExecutorDriver{
+		Agent:           string(agentAddr),
+		Executor:        executor,
+		FrameworkId:     NewFrameworkID(frameworkId),
+		ExecutorId:      &mesos.ExecutorID{Value: proto.String(executorId)},
+		Status:          mesos.Status_DRIVER_NOT_STARTED,
+		TLS:             tlsConfig,
+		RecoveryTimeout: defaultRecoveryTimeout,
+		scheme:          HTTP_SCHEME,
+		httpClient: http.Client{
+			Transport: &http.Transport{
+				Dial: func(netw, addr string) (net.Conn, error) {
+					return net.DialTimeout(netw, addr, time.Second*7)
+				},
+				DisableCompression: true,
+			},
+		},
+		tasks: make(map[string]*mesos.TaskInfo),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+// Start subscribes the executor with the agent.
+func (driver *ExecutorDriver) Start() mesos.Status {
+	if driver.Status != mesos.Status_DRIVER_NOT_STARTED {
+		return driver.Status
+	}
+	if driver.TLS != nil {
+		driver.scheme = HTTPS_SCHEME
+		driver.httpClient.Transport.(*http.Transport).TLSClientConfig = driver.TLS.clientConfig()
+	}
+
+	stream, err := driver.subscribe()
+	if err != nil {
+		log.Println("Failed to subscribe the executor:", err)
+		driver.Status = mesos.Status_DRIVER_ABORTED
+		return driver.Status
+	}
+	driver.mutex.Lock()
+	driver.Status = mesos.Status_DRIVER_RUNNING
+	driver.mutex.Unlock()
+	go driver.readEvents(stream)
+	return mesos.Status_DRIVER_RUNNING
+}
+
+// Join blocks until the driver is stopped or aborted.
+func (driver *ExecutorDriver) Join() mesos.Status {
+	if stat := driver.status(); stat != mesos.Status_DRIVER_RUNNING {
+		return stat
+	}
+	<-driver.done
+	return driver.status()
+}
+
+func (driver *ExecutorDriver) Run() mesos.Status {
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		return stat
+	}
+	return driver.Join()
+}
+
+// Stop closes the subscription with the agent.
+func (driver *ExecutorDriver) Stop() mesos.Status {
+	return driver.stop(mesos.Status_DRIVER_STOPPED)
+}
+
+// Abort closes the subscription and marks the driver aborted.
+func (driver *ExecutorDriver) Abort() mesos.Status {
+	return driver.stop(mesos.Status_DRIVER_ABORTED)
+}
+
+// SendStatusUpdate sends status to the agent. The update is resent after a
+// resubscription until the agent acknowledges it.
+func (driver *ExecutorDriver) SendStatusUpdate(status *mesos.TaskStatus) mesos.Status {
+	if stat := driver.status(); stat != mesos.Status_DRIVER_RUNNING {
+		return stat
+	}
+	status.Uuid = newUUID()
+	if status.Timestamp == nil {
+		status.Timestamp = proto.Float64(float64(time.Now().UnixNano()) / float64(time.Second))
+	}
+	update := &mesos.ExecutorCall_Update{Status: status}
+
+	driver.mutex.Lock()
+	driver.updates = append(driver.updates, update)
+	driver.mutex.Unlock()
+
+	err := driver.send(&mesos.ExecutorCall{Type: mesos.ExecutorCall_UPDATE.Enum(), Update: update})
+	if err != nil {
+		log.Println("Unable to send status update for task", status.GetTaskId().GetValue(), ":", err)
+	}
+	return driver.status()
+}
+
+// SendFrameworkMessage sends data to the scheduler of the framework.
+func (driver *ExecutorDriver) SendFrameworkMessage(data []byte) mesos.Status {
+	if stat := driver.status(); stat != mesos.Status_DRIVER_RUNNING {
+		return stat
+	}
+	err := driver.send(&mesos.ExecutorCall{
+		Type:    mesos.ExecutorCall_MESSAGE.Enum(),
+		Message: &mesos.ExecutorCall_Message{Data: data},
+	})
+	if err != nil {
+		log.Println("Unable to send framework message:", err)
+	}
+	return driver.status()
+}
+
+func (driver *ExecutorDriver) status() mesos.Status {
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+	return driver.Status
+}
+
+func (driver *ExecutorDriver) stop(status mesos.Status) mesos.Status {
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+	if driver.Status != mesos.Status_DRIVER_RUNNING {
+		return driver.Status
+	}
+	driver.Status = status
+	close(driver.done)
+	if driver.stream != nil {
+		driver.stream.Close()
+	}
+	return driver.Status
+}
+
+func (driver *ExecutorDriver) stopped() bool {
+	select {
+	case <-driver.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// subscribe sends SUBSCRIBE with the unacknowledged tasks and updates,
+// and returns the open event stream.
+func (driver *ExecutorDriver) subscribe() (io.ReadCloser, error) {
+	driver.mutex.Lock()
+	subscribe := &mesos.ExecutorCall_Subscribe{UnacknowledgedUpdates: driver.updates}
+	for _, task := range driver.tasks {
+		subscribe.UnacknowledgedTasks = append(subscribe.UnacknowledgedTasks, task)
+	}
+	driver.mutex.Unlock()
+
+	rsp, err := driver.post(&mesos.ExecutorCall{
+		Type:      mesos.ExecutorCall_SUBSCRIBE.Enum(),
+		Subscribe: subscribe,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		return nil, fmt.Errorf("Agent did not accept subscription. Returned status %s: %s",
+			rsp.Status, strings.TrimSpace(string(body)))
+	}
+
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+	if driver.Status != mesos.Status_DRIVER_NOT_STARTED && driver.Status != mesos.Status_DRIVER_RUNNING {
+		rsp.Body.Close()
+		return nil, fmt.Errorf("Driver stopped while subscribing.")
+	}
+	driver.stream = rsp.Body
+	return rsp.Body, nil
+}
+
+// readEvents passes events to the executor until the stream fails, then
+// resubscribes until the driver is stopped.
+func (driver *ExecutorDriver) readEvents(stream io.ReadCloser) {
+	for {
+		reader := recordio.NewReader(stream)
+		for {
+			record, err := reader.ReadRecord()
+			if err != nil {
+				if !driver.stopped() {
+					log.Println("Lost event stream from agent:", err)
+				}
+				break
+			}
+			event := new(mesos.ExecutorEvent)
+			if err := proto.Unmarshal(record, event); err != nil {
+				// skipping the event could leave the executor waiting for
+				// a subscription or task that never comes
+				log.Println("Unable to decode event from agent:", err)
+				driver.Abort()
+				if driver.Executor != nil && driver.Executor.Error != nil {
+					driver.Executor.Error(driver, NewMesosError("Unable to decode event from agent: "+err.Error()))
+				}
+				break
+			}
+			driver.handleEvent(event)
+		}
+		stream.Close()
+
+		if driver.stopped() {
+			return
+		}
+		if driver.Executor != nil && driver.Executor.Disconnected != nil {
+			driver.Executor.Disconnected(driver)
+		}
+		if stream = driver.resubscribe(); stream == nil {
+			return
+		}
+	}
+}
+
+// resubscribe retries SUBSCRIBE with exponential backoff. The executor is
+// shut down when the agent does not recover within RecoveryTimeout.
+func (driver *ExecutorDriver) resubscribe() io.ReadCloser {
+	deadline := time.Now().Add(driver.RecoveryTimeout)
+	backoff := minResubscribeBackoff
+	for {
+		select {
+		case <-driver.done:
+			return nil
+		case <-time.After(backoff):
+		}
+		stream, err := driver.subscribe()
+		if err == nil {
+			return stream
+		}
+		if time.Now().After(deadline) {
+			log.Println("Agent did not recover within", driver.RecoveryTimeout, ", shutting down.")
+			if driver.Executor != nil && driver.Executor.Shutdown != nil {
+				driver.Executor.Shutdown(driver)
+			}
+			driver.stop(mesos.Status_DRIVER_ABORTED)
+			return nil
+		}
+		log.Println("Unable to resubscribe with agent:", err)
+		if backoff *= 2; backoff > maxResubscribeBackoff {
+			backoff = maxResubscribeBackoff
+		}
+	}
+}
+
+func (driver *ExecutorDriver) handleEvent(event *mesos.ExecutorEvent) {
+	exec := driver.Executor
+	if exec == nil {
+		exec = NewMesosExecutor()
+	}
+
+	switch event.GetType() {
+	case mesos.ExecutorEvent_SUBSCRIBED:
+		subscribed := event.GetSubscribed()
+		driver.mutex.Lock()
+		resubscribed := driver.subscribed
+		driver.subscribed = true
+		driver.mutex.Unlock()
+
+		if resubscribed {
+			log.Println("Executor re-subscribed with agent", subscribed.GetAgentInfo().GetHostname())
+			if exec.Reregistered != nil {
+				exec.Reregistered(driver, subscribed.AgentInfo)
+			}
+		} else {
+			log.Println("Executor subscribed with agent", subscribed.GetAgentInfo().GetHostname())
+			if exec.Registered != nil {
+				exec.Registered(driver, subscribed.ExecutorInfo, subscribed.FrameworkInfo, subscribed.AgentInfo)
+			}
+		}
+
+	case mesos.ExecutorEvent_LAUNCH:
+		task := event.GetLaunch().GetTask()
+		driver.mutex.Lock()
+		driver.tasks[task.GetTaskId().GetValue()] = task
+		driver.mutex.Unlock()
+		if exec.LaunchTask != nil {
+			exec.LaunchTask(driver, task)
+		}
+
+	case mesos.ExecutorEvent_KILL:
+		if exec.KillTask != nil {
+			exec.KillTask(driver, event.GetKill().GetTaskId())
+		}
+
+	case mesos.ExecutorEvent_ACKNOWLEDGED:
+		acknowledged := event.GetAcknowledged()
+		driver.mutex.Lock()
+		delete(driver.tasks, acknowledged.GetTaskId().GetValue())
+		for i, update := range driver.updates {
+			if bytes.Equal(update.GetStatus().GetUuid(), acknowledged.GetUuid()) {
+				driver.updates = append(driver.updates[:i:i], driver.updates[i+1:]...)
+				break
+			}
+		}
+		driver.mutex.Unlock()
+
+	case mesos.ExecutorEvent_MESSAGE:
+		if exec.FrameworkMessage != nil {
+			exec.FrameworkMessage(driver, event.GetMessage().GetData())
+		}
+
+	case mesos.ExecutorEvent_SHUTDOWN:
+		if exec.Shutdown != nil {
+			exec.Shutdown(driver)
+		}
+		driver.stop(mesos.Status_DRIVER_STOPPED)
+
+	case mesos.ExecutorEvent_ERROR:
+		if exec.Error != nil {
+			exec.Error(driver, NewMesosError(event.GetError().GetMessage()))
+		}
+
+	case mesos.ExecutorEvent_HEARTBEAT:
+	}
+}
+
+// send posts call to the agent, which answers 202 when it is accepted.
+func (driver *ExecutorDriver) send(call *mesos.ExecutorCall) error {
+	rsp, err := driver.post(call)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusAccepted {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		return fmt.Errorf("Agent did not accept %s call. Returned status %s: %s",
+			call.GetType(), rsp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (driver *ExecutorDriver) post(call *mesos.ExecutorCall) (*http.Response, error) {
+	call.ExecutorId = driver.ExecutorId
+	call.FrameworkId = driver.FrameworkId
+	driver.mutex.Lock()
+	data, err := proto.Marshal(call)
+	driver.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	u := &url.URL{Scheme: driver.scheme, Host: driver.Agent, Path: HTTP_API_EXECUTOR}
+	req, err := http.NewRequest(HTTP_POST_METHOD, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", HTTP_CONTENT_TYPE)
+	req.Header.Add("Accept", HTTP_CONTENT_TYPE)
+	return driver.httpClient.Do(req)
+}
@@ -0,0 +1,384 @@
+package gomes
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"github.com/vladimirvivien/gomes/recordio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAgent serves the v1 executor API. Calls are recorded and events sent
+// with send are streamed on the latest subscription.
+type testAgent struct {
+	server *httptest.Server
+	calls  chan *mesos.ExecutorCall
+	mutex  sync.Mutex
+	stream chan *mesos.ExecutorEvent
+}
+
+func makeTestAgent() *testAgent {
+	agent := &testAgent{calls: make(chan *mesos.ExecutorCall, 20)}
+	agent.server = httptest.NewServer(http.HandlerFunc(agent.serve))
+	return agent
+}
+
+func (agent *testAgent) serve(rsp http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != HTTP_API_EXECUTOR {
+		rsp.WriteHeader(http.StatusNotFound)
+		return
+	}
+	data, _ := ioutil.ReadAll(req.Body)
+	call := new(mesos.ExecutorCall)
+	if err := proto.Unmarshal(data, call); err != nil {
+		rsp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if call.GetExecutorId().GetValue() != "executor-1" || call.GetFrameworkId().GetValue() != "framework-1" {
+		rsp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if call.GetType() != mesos.ExecutorCall_SUBSCRIBE {
+		agent.calls <- call
+		rsp.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	stream := make(chan *mesos.ExecutorEvent, 10)
+	agent.mutex.Lock()
+	agent.stream = stream
+	agent.mutex.Unlock()
+	agent.calls <- call
+
+	rsp.WriteHeader(http.StatusOK)
+	rsp.(http.Flusher).Flush()
+	writer := recordio.NewWriter(rsp)
+	for {
+		select {
+		case event := <-stream:
+			data, _ := proto.Marshal(event)
+			writer.WriteRecord(data)
+			rsp.(http.Flusher).Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func (agent *testAgent) send(event *mesos.ExecutorEvent) {
+	agent.mutex.Lock()
+	defer agent.mutex.Unlock()
+	agent.stream <- event
+}
+
+// restart drops the open subscriptions, as a restarting agent would.
+func (agent *testAgent) restart() {
+	agent.server.CloseClientConnections()
+}
+
+func (agent *testAgent) expectCall(t *testing.T, callType mesos.ExecutorCall_Type) *mesos.ExecutorCall {
+	select {
+	case call := <-agent.calls:
+		if call.GetType() != callType {
+			t.Fatal("Expected call", callType, "but agent received", call.GetType())
+		}
+		return call
+	case <-time.After(3 * time.Second):
+		t.Fatal("Agent did not receive call", callType)
+	}
+	return nil
+}
+
+func (agent *testAgent) close() {
+	agent.server.CloseClientConnections()
+	agent.server.Close()
+}
+
+func makeTestExecDriver(t *testing.T, exec *Executor, agent string) *ExecutorDriver {
+	os.Setenv(MESOS_AGENT_ENDPOINT_ENV, agent)
+	os.Setenv(MESOS_FRAMEWORK_ID_ENV, "framework-1")
+	os.Setenv(MESOS_EXECUTOR_ID_ENV, "executor-1")
+	defer os.Unsetenv(MESOS_AGENT_ENDPOINT_ENV)
+	defer os.Unsetenv(MESOS_FRAMEWORK_ID_ENV)
+	defer os.Unsetenv(MESOS_EXECUTOR_ID_ENV)
+
+	driver, err := NewExecDriver(exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return driver
+}
+
+func makeExecSubscribedEvent() *mesos.ExecutorEvent {
+	return &mesos.ExecutorEvent{
+		Type: mesos.ExecutorEvent_SUBSCRIBED.Enum(),
+		Subscribed: &mesos.ExecutorEvent_Subscribed{
+			ExecutorInfo: &mesos.ExecutorInfo{
+				ExecutorId: &mesos.ExecutorID{Value: proto.String("executor-1")},
+				Command:    &mesos.CommandInfo{Value: proto.String("./executor")},
+			},
+			FrameworkInfo: NewFrameworkInfo("test-user", "test-framework", NewFrameworkID("framework-1")),
+			AgentInfo:     &mesos.SlaveInfo{Hostname: proto.String("agent-1")},
+		},
+	}
+}
+
+func makeLaunchEvent(taskId string) *mesos.ExecutorEvent {
+	return &mesos.ExecutorEvent{
+		Type: mesos.ExecutorEvent_LAUNCH.Enum(),
+		Launch: &mesos.ExecutorEvent_Launch{
+			Task: NewTaskInfo("task", NewTaskID(taskId), NewSlaveID("agent-1"), nil),
+		},
+	}
+}
+
+func expectSignal(t *testing.T, signal chan bool, what string) {
+	select {
+	case <-signal:
+	case <-time.After(3 * time.Second):
+		t.Fatal(what, "not called.")
+	}
+}
+
+func TestNewExecDriver_MissingEnv(t *testing.T) {
+	os.Unsetenv(MESOS_AGENT_ENDPOINT_ENV)
+	if _, err := NewExecDriver(NewMesosExecutor()); err == nil {
+		t.Fatal("Expected error when MESOS_AGENT_ENDPOINT is not set.")
+	}
+	os.Setenv(MESOS_AGENT_ENDPOINT_ENV, "localhost:5051")
+	defer os.Unsetenv(MESOS_AGENT_ENDPOINT_ENV)
+	if _, err := NewExecDriver(NewMesosExecutor()); err == nil {
+		t.Fatal("Expected error when MESOS_FRAMEWORK_ID is not set.")
+	}
+}
+
+func TestExecDriver_LaunchAndUpdate(t *testing.T) {
+	agent := makeTestAgent()
+	defer agent.close()
+
+	registered := make(chan bool, 1)
+	launched := make(chan bool, 1)
+	exec := NewMesosExecutor()
+	exec.Registered = func(driver *ExecutorDriver, info *mesos.ExecutorInfo, framework *mesos.FrameworkInfo, slave *mesos.SlaveInfo) {
+		if slave.GetHostname() != "agent-1" {
+			t.Error("Executor.Registered expected agent-1, but got", slave.GetHostname())
+		}
+		registered <- true
+	}
+	exec.LaunchTask = func(driver *ExecutorDriver, task *mesos.TaskInfo) {
+		driver.SendStatusUpdate(NewTaskStatus(task.TaskId, mesos.TaskState_TASK_RUNNING))
+		launched <- true
+	}
+
+	u, _ := url.Parse(agent.server.URL)
+	driver := makeTestExecDriver(t, exec, u.Host)
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", stat)
+	}
+	defer driver.Stop()
+
+	call := agent.expectCall(t, mesos.ExecutorCall_SUBSCRIBE)
+	if len(call.GetSubscribe().GetUnacknowledgedUpdates()) != 0 {
+		t.Fatal("First SUBSCRIBE call carries unacknowledged updates.")
+	}
+	agent.send(makeExecSubscribedEvent())
+	expectSignal(t, registered, "Executor.Registered")
+
+	agent.send(makeLaunchEvent("task-1"))
+	expectSignal(t, launched, "Executor.LaunchTask")
+	call = agent.expectCall(t, mesos.ExecutorCall_UPDATE)
+	status := call.GetUpdate().GetStatus()
+	if status.GetTaskId().GetValue() != "task-1" || status.GetState() != mesos.TaskState_TASK_RUNNING {
+		t.Fatal("UPDATE call does not match the status sent for task-1.")
+	}
+	if len(status.GetUuid()) != 16 || status.Timestamp == nil {
+		t.Fatal("UPDATE call missing uuid or timestamp.")
+	}
+
+	agent.send(&mesos.ExecutorEvent{
+		Type:         mesos.ExecutorEvent_ACKNOWLEDGED.Enum(),
+		Acknowledged: &mesos.ExecutorEvent_Acknowledged{TaskId: status.TaskId, Uuid: status.Uuid},
+	})
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		driver.mutex.Lock()
+		pending := len(driver.updates) + len(driver.tasks)
+		driver.mutex.Unlock()
+		if pending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Acknowledged update still pending.")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExecDriver_ResubscribesWithUnacknowledged(t *testing.T) {
+	agent := makeTestAgent()
+	defer agent.close()
+
+	launched := make(chan bool, 1)
+	disconnected := make(chan bool, 1)
+	reregistered := make(chan bool, 1)
+	exec := NewMesosExecutor()
+	exec.LaunchTask = func(driver *ExecutorDriver, task *mesos.TaskInfo) {
+		launched <- true
+	}
+	exec.Disconnected = func(driver *ExecutorDriver) {
+		disconnected <- true
+	}
+	exec.Reregistered = func(driver *ExecutorDriver, slave *mesos.SlaveInfo) {
+		reregistered <- true
+	}
+
+	u, _ := url.Parse(agent.server.URL)
+	driver := makeTestExecDriver(t, exec, u.Host)
+	driver.Start()
+	defer driver.Stop()
+	agent.expectCall(t, mesos.ExecutorCall_SUBSCRIBE)
+	agent.send(makeExecSubscribedEvent())
+	agent.send(makeLaunchEvent("task-1"))
+	agent.send(makeLaunchEvent("task-2"))
+	expectSignal(t, launched, "Executor.LaunchTask")
+	expectSignal(t, launched, "Executor.LaunchTask")
+
+	driver.SendStatusUpdate(NewTaskStatus(NewTaskID("task-1"), mesos.TaskState_TASK_FINISHED))
+	sent := agent.expectCall(t, mesos.ExecutorCall_UPDATE).GetUpdate()
+
+	agent.restart()
+	expectSignal(t, disconnected, "Executor.Disconnected")
+	call := agent.expectCall(t, mesos.ExecutorCall_SUBSCRIBE)
+	updates := call.GetSubscribe().GetUnacknowledgedUpdates()
+	if len(updates) != 1 || !bytes.Equal(updates[0].GetStatus().GetUuid(), sent.GetStatus().GetUuid()) {
+		t.Fatal("Resubscription expected the unacknowledged update, but got", updates)
+	}
+	if len(call.GetSubscribe().GetUnacknowledgedTasks()) != 2 {
+		t.Fatal("Resubscription expected 2 unacknowledged tasks, but got", len(call.GetSubscribe().GetUnacknowledgedTasks()))
+	}
+	agent.send(makeExecSubscribedEvent())
+	expectSignal(t, reregistered, "Executor.Reregistered")
+}
+
+func TestExecDriver_FrameworkMessages(t *testing.T) {
+	agent := makeTestAgent()
+	defer agent.close()
+
+	messages := make(chan []byte, 1)
+	exec := NewMesosExecutor()
+	exec.FrameworkMessage = func(driver *ExecutorDriver, data []byte) {
+		messages <- data
+	}
+
+	u, _ := url.Parse(agent.server.URL)
+	driver := makeTestExecDriver(t, exec, u.Host)
+	driver.Start()
+	defer driver.Stop()
+	agent.expectCall(t, mesos.ExecutorCall_SUBSCRIBE)
+
+	agent.send(&mesos.ExecutorEvent{
+		Type:    mesos.ExecutorEvent_MESSAGE.Enum(),
+		Message: &mesos.ExecutorEvent_Message{Data: []byte("hello")},
+	})
+	select {
+	case data := <-messages:
+		if string(data) != "hello" {
+			t.Fatal("Executor.FrameworkMessage expected hello, but got", string(data))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Executor.FrameworkMessage not called.")
+	}
+
+	driver.SendFrameworkMessage([]byte("world"))
+	call := agent.expectCall(t, mesos.ExecutorCall_MESSAGE)
+	if string(call.GetMessage().GetData()) != "world" {
+		t.Fatal("MESSAGE call expected world, but got", string(call.GetMessage().GetData()))
+	}
+}
+
+func TestExecDriver_Shutdown(t *testing.T) {
+	agent := makeTestAgent()
+	defer agent.close()
+
+	shutdown := make(chan bool, 1)
+	exec := NewMesosExecutor()
+	exec.Shutdown = func(driver *ExecutorDriver) {
+		shutdown <- true
+	}
+
+	u, _ := url.Parse(agent.server.URL)
+	driver := makeTestExecDriver(t, exec, u.Host)
+	driver.Start()
+	agent.expectCall(t, mesos.ExecutorCall_SUBSCRIBE)
+	agent.send(&mesos.ExecutorEvent{Type: mesos.ExecutorEvent_SHUTDOWN.Enum()})
+	expectSignal(t, shutdown, "Executor.Shutdown")
+	if stat := driver.Join(); stat != mesos.Status_DRIVER_STOPPED {
+		t.Fatal("Expected DRIVER_STOPPED after SHUTDOWN, but got", stat)
+	}
+}
+
+func TestExecDriver_AbortsOnUndecodableEvent(t *testing.T) {
+	agent := makeTestAgent()
+	defer agent.close()
+
+	failed := make(chan bool, 1)
+	exec := NewMesosExecutor()
+	exec.Error = func(driver *ExecutorDriver, err MesosError) {
+		failed <- true
+	}
+
+	u, _ := url.Parse(agent.server.URL)
+	driver := makeTestExecDriver(t, exec, u.Host)
+	driver.Start()
+	agent.expectCall(t, mesos.ExecutorCall_SUBSCRIBE)
+	// missing the required executor, framework and agent info
+	agent.send(&mesos.ExecutorEvent{
+		Type:       mesos.ExecutorEvent_SUBSCRIBED.Enum(),
+		Subscribed: &mesos.ExecutorEvent_Subscribed{},
+	})
+	expectSignal(t, failed, "Executor.Error")
+	if stat := driver.Join(); stat != mesos.Status_DRIVER_ABORTED {
+		t.Fatal("Expected DRIVER_ABORTED after undecodable event, but got", stat)
+	}
+}
+
+func TestExecDriver_AgentRecoveryTimeout(t *testing.T) {
+	agent := makeTestAgent()
+
+	shutdown := make(chan bool, 1)
+	exec := NewMesosExecutor()
+	exec.Shutdown = func(driver *ExecutorDriver) {
+		shutdown <- true
+	}
+
+	u, _ := url.Parse(agent.server.URL)
+	driver := makeTestExecDriver(t, exec, u.Host)
+	driver.RecoveryTimeout = 200 * time.Millisecond
+	driver.Start()
+	agent.expectCall(t, mesos.ExecutorCall_SUBSCRIBE)
+	agent.close()
+
+	expectSignal(t, shutdown, "Executor.Shutdown")
+	if stat := driver.Join(); stat != mesos.Status_DRIVER_ABORTED {
+		t.Fatal("Expected DRIVER_ABORTED when agent does not recover, but got", stat)
+	}
+}
+
+func TestExecDriver_SubscribeRejected(t *testing.T) {
+	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.WriteHeader(http.StatusBadRequest)
+	})
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	driver := makeTestExecDriver(t, NewMesosExecutor(), u.Host)
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_ABORTED {
+		t.Fatal("Expected DRIVER_ABORTED, but got", stat)
+	}
+}
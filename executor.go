@@ -0,0 +1,22 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+)
+
+// Executor holds the callbacks invoked by the ExecutorDriver, in the order
+// the events are received from the agent.
+type Executor struct {
+	Registered       func(*ExecutorDriver, *mesos.ExecutorInfo, *mesos.FrameworkInfo, *mesos.SlaveInfo)
+	Reregistered     func(*ExecutorDriver, *mesos.SlaveInfo)
+	Disconnected     func(*ExecutorDriver)
+	LaunchTask       func(*ExecutorDriver, *mesos.TaskInfo)
+	KillTask         func(*ExecutorDriver, *mesos.TaskID)
+	FrameworkMessage func(*ExecutorDriver, []byte)
+	Shutdown         func(*ExecutorDriver)
+	Error            func(*ExecutorDriver, MesosError)
+}
+
+func NewMesosExecutor() *Executor {
+	return &Executor{}
+}
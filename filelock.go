@@ -0,0 +1,96 @@
+//go:build !windows
+// +build !windows
+
+package gomes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+/*
+FileLock is a Lock kept in a local file, for instances sharing a host or a
+file system honouring flock. The file holds the owner of the lease and
+its expiry; each update of the lease happens under an exclusive flock of
+the file, so it is atomic across processes.
+*/
+type FileLock struct {
+	path string
+}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+func (lock *FileLock) TryAcquire(owner string, ttl time.Duration) (bool, error) {
+	acquired := false
+	err := lock.update(func(holder string, expiry time.Time) (string, time.Time, bool) {
+		if holder != "" && holder != owner && time.Now().Before(expiry) {
+			return "", time.Time{}, false
+		}
+		acquired = true
+		return owner, time.Now().Add(ttl), true
+	})
+	return acquired, err
+}
+
+func (lock *FileLock) Release(owner string) error {
+	return lock.update(func(holder string, expiry time.Time) (string, time.Time, bool) {
+		return "", time.Time{}, holder == owner
+	})
+}
+
+// update reads the lease under an exclusive flock and writes back the
+// lease returned by change, if it asks for a write.
+func (lock *FileLock) update(change func(string, time.Time) (string, time.Time, bool)) error {
+	file, err := os.OpenFile(lock.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to open lock file %s: %s", lock.path, err)
+	}
+	defer file.Close()
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("Unable to lock %s: %s", lock.path, err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("Unable to read lock file %s: %s", lock.path, err)
+	}
+	holder, expiry := parseLease(string(data))
+	holder, expiry, write := change(holder, expiry)
+	if !write {
+		return nil
+	}
+
+	lease := ""
+	if holder != "" {
+		lease = fmt.Sprintf("%s\n%d\n", holder, expiry.UnixNano())
+	}
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("Unable to write lock file %s: %s", lock.path, err)
+	}
+	if _, err := file.WriteAt([]byte(lease), 0); err != nil {
+		return fmt.Errorf("Unable to write lock file %s: %s", lock.path, err)
+	}
+	return file.Sync()
+}
+
+// parseLease reads a lease written by update. A missing or malformed
+// lease is free.
+func parseLease(data string) (string, time.Time) {
+	lines := strings.Split(data, "\n")
+	if len(lines) < 2 {
+		return "", time.Time{}
+	}
+	nanos, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}
+	}
+	return lines[0], time.Unix(0, nanos)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package gomes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLock(t *testing.T) {
+	dir := makeTestStateDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "leader.lock")
+	checkLockSemantics(t, NewFileLock(path), NewFileLock(path))
+
+	ioutil.WriteFile(path, []byte("garbage"), 0600)
+	checkLease(t, NewFileLock(path), "sched-3", true)
+}
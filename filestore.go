@@ -0,0 +1,348 @@
+package gomes
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"encoding/binary"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"github.com/vladimirvivien/gomes/recordio"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	STATE_SNAPSHOT_FILE = "snapshot"
+	STATE_LOG_FILE      = "log"
+
+	// DEFAULT_SNAPSHOT_INTERVAL is the number of log entries written
+	// before a FileStore compacts its log into a snapshot.
+	DEFAULT_SNAPSHOT_INTERVAL = 1000
+)
+
+/*
+FileStore is a StateStore kept in a local directory. Changes are appended
+to a log and synced to disk before they are acknowledged. Every
+SnapshotInterval entries, the state is written to a new snapshot and the
+log is emptied.
+
+Each record carries a CRC-32 checksum. When the store is opened, a log
+ending in a torn or corrupt record (i.e. after a crash during a write)
+is truncated to its last good record.
+*/
+type FileStore struct {
+	SnapshotInterval int
+
+	dir         string
+	lock        sync.Mutex
+	log         *os.File
+	logSize     int64
+	entries     int
+	failed      error
+	frameworkId *mesos.FrameworkID
+	tasks       map[string]*mesos.StoredTask
+}
+
+// OpenFileStore opens the store in dir, creating it if needed, and
+// recovers the state saved there.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("Unable to create state directory %s: %s", dir, err)
+	}
+	store := &FileStore{
+		SnapshotInterval: DEFAULT_SNAPSHOT_INTERVAL,
+		dir:              dir,
+		tasks:            make(map[string]*mesos.StoredTask),
+	}
+	if err := store.readSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := store.replayLog(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *FileStore) FrameworkId() (*mesos.FrameworkID, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.frameworkId, nil
+}
+
+func (store *FileStore) SaveFrameworkId(id *mesos.FrameworkID) error {
+	return store.append(&mesos.StateLogEntry{
+		Type:        mesos.StateLogEntry_SET_FRAMEWORK_ID.Enum(),
+		FrameworkId: id,
+	})
+}
+
+func (store *FileStore) Tasks() ([]TaskRecord, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	records := []TaskRecord{}
+	for _, task := range store.tasks {
+		records = append(records, taskRecord(task))
+	}
+	sort.Sort(taskRecordsById(records))
+	return records, nil
+}
+
+func (store *FileStore) SaveTask(record TaskRecord) error {
+	return store.append(&mesos.StateLogEntry{
+		Type: mesos.StateLogEntry_SAVE_TASK.Enum(),
+		Task: storedTask(record),
+	})
+}
+
+func (store *FileStore) RemoveTask(taskId *mesos.TaskID) error {
+	return store.append(&mesos.StateLogEntry{
+		Type:   mesos.StateLogEntry_REMOVE_TASK.Enum(),
+		TaskId: taskId,
+	})
+}
+
+// Snapshot compacts the log into a new snapshot.
+func (store *FileStore) Snapshot() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.snapshot()
+}
+
+func (store *FileStore) Close() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.log.Close()
+}
+
+// append writes entry to the log, then applies it to the state. Once the
+// entry is in the log it is saved, a failed snapshot is only logged.
+func (store *FileStore) append(entry *mesos.StateLogEntry) error {
+	data, err := encodeStateRecord(entry)
+	if err != nil {
+		return err
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.failed != nil {
+		return store.failed
+	}
+	if _, err := store.log.Write(data); err != nil {
+		return store.abortWrite(fmt.Errorf("Unable to write state log: %s", err))
+	}
+	if err := store.log.Sync(); err != nil {
+		return store.abortWrite(fmt.Errorf("Unable to sync state log: %s", err))
+	}
+	store.logSize += int64(len(data))
+	store.entries++
+	store.apply(entry)
+
+	if store.SnapshotInterval > 0 && store.entries >= store.SnapshotInterval {
+		if err := store.snapshot(); err != nil {
+			log.Println("Unable to compact state log:", err)
+		}
+	}
+	return nil
+}
+
+// abortWrite cuts a failed write off the log and returns err. When the log
+// can not be cut, it may end in a torn record that would hide any entry
+// written after it, so the store refuses further writes.
+func (store *FileStore) abortWrite(err error) error {
+	if truncErr := store.truncateLog(store.logSize); truncErr != nil {
+		store.failed = fmt.Errorf("%s. State log unusable, unable to truncate it: %s", err, truncErr)
+		return store.failed
+	}
+	return err
+}
+
+// truncateLog cuts the log at size and moves the write position there, so
+// a partial write does not hide the entries appended after it.
+func (store *FileStore) truncateLog(size int64) error {
+	if err := store.log.Truncate(size); err != nil {
+		return err
+	}
+	_, err := store.log.Seek(size, os.SEEK_SET)
+	return err
+}
+
+func (store *FileStore) apply(entry *mesos.StateLogEntry) {
+	switch entry.GetType() {
+	case mesos.StateLogEntry_SET_FRAMEWORK_ID:
+		store.frameworkId = entry.GetFrameworkId()
+	case mesos.StateLogEntry_SAVE_TASK:
+		store.tasks[entry.GetTask().GetInfo().GetTaskId().GetValue()] = entry.GetTask()
+	case mesos.StateLogEntry_REMOVE_TASK:
+		delete(store.tasks, entry.GetTaskId().GetValue())
+	}
+}
+
+// snapshot must be called with the lock held. The new snapshot replaces
+// the old one atomically; entries replayed over it after a crash before
+// the log is emptied are harmless, as they are already part of it.
+func (store *FileStore) snapshot() error {
+	snapshot := &mesos.StateSnapshot{FrameworkId: store.frameworkId}
+	ids := make([]string, 0, len(store.tasks))
+	for id := range store.tasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		snapshot.Tasks = append(snapshot.Tasks, store.tasks[id])
+	}
+	data, err := encodeStateRecord(snapshot)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(store.dir, STATE_SNAPSHOT_FILE)
+	if err := writeFileSync(path+".tmp", data); err != nil {
+		return fmt.Errorf("Unable to write state snapshot: %s", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("Unable to write state snapshot: %s", err)
+	}
+	syncDir(store.dir)
+
+	if err := store.truncateLog(0); err != nil {
+		store.failed = fmt.Errorf("State log unusable, unable to truncate it: %s", err)
+		return store.failed
+	}
+	store.logSize = 0
+	store.entries = 0
+	if err := store.log.Sync(); err != nil {
+		return fmt.Errorf("Unable to sync state log: %s", err)
+	}
+	return nil
+}
+
+func (store *FileStore) readSnapshot() error {
+	path := filepath.Join(store.dir, STATE_SNAPSHOT_FILE)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to read state snapshot %s: %s", path, err)
+	}
+	record, err := recordio.NewReader(bytes.NewReader(data)).ReadRecord()
+	if err != nil {
+		return fmt.Errorf("Corrupt state snapshot %s: %s", path, err)
+	}
+	snapshot := new(mesos.StateSnapshot)
+	if err := decodeStateRecord(record, snapshot); err != nil {
+		return fmt.Errorf("Corrupt state snapshot %s: %s", path, err)
+	}
+	store.frameworkId = snapshot.GetFrameworkId()
+	for _, task := range snapshot.GetTasks() {
+		store.tasks[task.GetInfo().GetTaskId().GetValue()] = task
+	}
+	return nil
+}
+
+// replayLog applies the entries of the log, truncating it after the last
+// good one, and leaves the log open for appending.
+func (store *FileStore) replayLog() error {
+	path := filepath.Join(store.dir, STATE_LOG_FILE)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to open state log %s: %s", path, err)
+	}
+
+	reader := recordio.NewReader(file)
+	var offset int64
+	for {
+		record, err := reader.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		entry := new(mesos.StateLogEntry)
+		if err == nil {
+			err = decodeStateRecord(record, entry)
+		}
+		if err != nil {
+			log.Printf("Truncating state log %s at offset %d: %s", path, offset, err)
+			if err := file.Truncate(offset); err != nil {
+				file.Close()
+				return fmt.Errorf("Unable to truncate state log %s: %s", path, err)
+			}
+			break
+		}
+		store.apply(entry)
+		store.entries++
+		offset += recordSize(record)
+	}
+
+	if _, err := file.Seek(offset, os.SEEK_SET); err != nil {
+		file.Close()
+		return fmt.Errorf("Unable to open state log %s: %s", path, err)
+	}
+	store.log = file
+	store.logSize = offset
+	return nil
+}
+
+// encodeStateRecord frames msg as a RecordIO record holding the CRC-32 of
+// the message followed by the message.
+func encodeStateRecord(msg proto.Message) ([]byte, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	payload := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(payload, crc32.ChecksumIEEE(data))
+	payload = append(payload, data...)
+
+	var buf bytes.Buffer
+	if err := recordio.NewWriter(&buf).WriteRecord(payload); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeStateRecord(record []byte, msg proto.Message) error {
+	if len(record) < 4 {
+		return fmt.Errorf("Record too short.")
+	}
+	data := record[4:]
+	if binary.BigEndian.Uint32(record) != crc32.ChecksumIEEE(data) {
+		return fmt.Errorf("Checksum mismatch.")
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// recordSize returns the framed size of a RecordIO record.
+func recordSize(record []byte) int64 {
+	return int64(len(fmt.Sprintf("%d\n", len(record))) + len(record))
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir makes a rename in dir durable. Errors are ignored, not every
+// platform can sync a directory.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
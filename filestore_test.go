@@ -0,0 +1,225 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeTestStateDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gomes-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func openTestFileStore(t *testing.T, dir string) *FileStore {
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal("Unable to open FileStore:", err)
+	}
+	return store
+}
+
+func makeTestRecord(id string, state mesos.TaskState) TaskRecord {
+	return TaskRecord{
+		Info:     makeLaunchTask(id, "slave-1", 1, 128),
+		OfferIds: []*mesos.OfferID{NewOfferID("offer-1")},
+		State:    state,
+		Status:   makeTaskUpdate(id, state),
+	}
+}
+
+func checkStoredTasks(t *testing.T, store StateStore, ids ...string) []TaskRecord {
+	records, err := store.Tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(ids) {
+		t.Fatal("Expected stored tasks", ids, "but got", records)
+	}
+	for i, id := range ids {
+		if records[i].TaskId() != id {
+			t.Fatal("Expected stored tasks", ids, "but got", records)
+		}
+	}
+	return records
+}
+
+func TestFileStore_Recover(t *testing.T) {
+	dir := makeTestStateDir(t)
+	defer os.RemoveAll(dir)
+
+	store := openTestFileStore(t, dir)
+	if id, _ := store.FrameworkId(); id != nil {
+		t.Fatal("Expected no FrameworkID in new store, but got", id)
+	}
+	store.SaveFrameworkId(NewFrameworkID("framework-1"))
+	store.SaveTask(makeTestRecord("task-1", mesos.TaskState_TASK_STAGING))
+	store.SaveTask(makeTestRecord("task-2", mesos.TaskState_TASK_STAGING))
+	store.SaveTask(makeTestRecord("task-1", mesos.TaskState_TASK_RUNNING))
+	store.RemoveTask(NewTaskID("task-2"))
+	store.Close()
+
+	store = openTestFileStore(t, dir)
+	defer store.Close()
+	if id, _ := store.FrameworkId(); id.GetValue() != "framework-1" {
+		t.Fatal("Expected recovered FrameworkID framework-1, but got", id)
+	}
+	records := checkStoredTasks(t, store, "task-1")
+	if records[0].State != mesos.TaskState_TASK_RUNNING || records[0].Status.GetState() != mesos.TaskState_TASK_RUNNING {
+		t.Fatal("Expected latest state of task-1 recovered, but got", records[0])
+	}
+	if records[0].OfferIds[0].GetValue() != "offer-1" || records[0].Info.GetCommand().GetValue() != "./task-1" {
+		t.Fatal("Expected TaskInfo and offers recovered, but got", records[0])
+	}
+
+	store.SaveFrameworkId(nil)
+	if id, _ := store.FrameworkId(); id != nil {
+		t.Fatal("Expected FrameworkID cleared, but got", id)
+	}
+}
+
+func TestFileStore_Snapshot(t *testing.T) {
+	dir := makeTestStateDir(t)
+	defer os.RemoveAll(dir)
+
+	store := openTestFileStore(t, dir)
+	store.SnapshotInterval = 3
+	store.SaveFrameworkId(NewFrameworkID("framework-1"))
+	store.SaveTask(makeTestRecord("task-1", mesos.TaskState_TASK_STAGING))
+	store.SaveTask(makeTestRecord("task-2", mesos.TaskState_TASK_STAGING))
+	if info, _ := os.Stat(filepath.Join(dir, STATE_LOG_FILE)); info.Size() != 0 {
+		t.Fatal("Expected log emptied by snapshot, but got size", info.Size())
+	}
+	store.SaveTask(makeTestRecord("task-3", mesos.TaskState_TASK_STAGING))
+	store.Close()
+
+	store = openTestFileStore(t, dir)
+	defer store.Close()
+	checkStoredTasks(t, store, "task-1", "task-2", "task-3")
+	if id, _ := store.FrameworkId(); id.GetValue() != "framework-1" {
+		t.Fatal("Expected FrameworkID recovered from snapshot, but got", id)
+	}
+}
+
+func TestFileStore_SnapshotFailure(t *testing.T) {
+	dir := makeTestStateDir(t)
+	defer os.RemoveAll(dir)
+
+	store := openTestFileStore(t, dir)
+	store.SnapshotInterval = 1
+	// the snapshot can not be written over a directory
+	os.Mkdir(filepath.Join(dir, STATE_SNAPSHOT_FILE+".tmp"), 0700)
+	if err := store.SaveFrameworkId(NewFrameworkID("framework-1")); err != nil {
+		t.Fatal("Expected entry saved when the snapshot fails, but got", err)
+	}
+	registry, err := NewTaskRegistryWithStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.Add(makeLaunchTask("task-1", "slave-1", 1, 128), nil)
+	if err := registry.Update(makeTaskUpdate("task-1", mesos.TaskState_TASK_RUNNING)); err != nil {
+		t.Fatal("Expected update saved when the snapshot fails, but got", err)
+	}
+	store.Close()
+
+	store = openTestFileStore(t, dir)
+	defer store.Close()
+	records := checkStoredTasks(t, store, "task-1")
+	if records[0].State != mesos.TaskState_TASK_RUNNING {
+		t.Fatal("Expected task-1 recovered in TASK_RUNNING, but got", records[0].State)
+	}
+	if id, _ := store.FrameworkId(); id.GetValue() != "framework-1" {
+		t.Fatal("Expected FrameworkID recovered from the log, but got", id)
+	}
+}
+
+func TestFileStore_UntruncatableLog(t *testing.T) {
+	dir := makeTestStateDir(t)
+	defer os.RemoveAll(dir)
+
+	store := openTestFileStore(t, dir)
+	defer store.Close()
+	// a read only file can neither be written nor truncated
+	writable := store.log
+	readOnly, err := os.Open(filepath.Join(dir, STATE_LOG_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.log = readOnly
+	if err := store.SaveTask(makeTestRecord("task-1", mesos.TaskState_TASK_STAGING)); err == nil {
+		t.Fatal("Expected failed write to be reported.")
+	}
+	store.log = writable
+	readOnly.Close()
+	if err := store.SaveTask(makeTestRecord("task-2", mesos.TaskState_TASK_STAGING)); err == nil {
+		t.Fatal("Expected store to refuse writes after the log could not be truncated.")
+	}
+	checkStoredTasks(t, store)
+}
+
+func TestFileStore_TornLog(t *testing.T) {
+	dir := makeTestStateDir(t)
+	defer os.RemoveAll(dir)
+
+	store := openTestFileStore(t, dir)
+	store.SaveTask(makeTestRecord("task-1", mesos.TaskState_TASK_STAGING))
+	store.SaveTask(makeTestRecord("task-2", mesos.TaskState_TASK_STAGING))
+	store.Close()
+
+	// cut the last record in half, as a crash during a write would
+	path := filepath.Join(dir, STATE_LOG_FILE)
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-10)
+
+	store = openTestFileStore(t, dir)
+	checkStoredTasks(t, store, "task-1")
+	store.SaveTask(makeTestRecord("task-3", mesos.TaskState_TASK_STAGING))
+	store.Close()
+
+	store = openTestFileStore(t, dir)
+	defer store.Close()
+	checkStoredTasks(t, store, "task-1", "task-3")
+}
+
+func TestFileStore_CorruptLog(t *testing.T) {
+	dir := makeTestStateDir(t)
+	defer os.RemoveAll(dir)
+
+	store := openTestFileStore(t, dir)
+	store.SaveTask(makeTestRecord("task-1", mesos.TaskState_TASK_STAGING))
+	store.SaveTask(makeTestRecord("task-2", mesos.TaskState_TASK_STAGING))
+	store.Close()
+
+	path := filepath.Join(dir, STATE_LOG_FILE)
+	data, _ := ioutil.ReadFile(path)
+	data[len(data)-5] ^= 0xff
+	ioutil.WriteFile(path, data, 0600)
+
+	store = openTestFileStore(t, dir)
+	defer store.Close()
+	checkStoredTasks(t, store, "task-1")
+}
+
+func TestFileStore_CorruptSnapshot(t *testing.T) {
+	dir := makeTestStateDir(t)
+	defer os.RemoveAll(dir)
+
+	store := openTestFileStore(t, dir)
+	store.SaveTask(makeTestRecord("task-1", mesos.TaskState_TASK_STAGING))
+	store.Snapshot()
+	store.Close()
+
+	path := filepath.Join(dir, STATE_SNAPSHOT_FILE)
+	data, _ := ioutil.ReadFile(path)
+	data[len(data)-1] ^= 0xff
+	ioutil.WriteFile(path, data, 0600)
+
+	if _, err := OpenFileStore(dir); err == nil {
+		t.Fatal("Expected error opening store with corrupt snapshot.")
+	}
+}
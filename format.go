@@ -0,0 +1,105 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OfferString returns a one line summary of offer, i.e.
+// offer O1 on host-a: cpus=4 mem=8G ports=[31000-31100]
+func OfferString(offer *mesos.Offer) string {
+	return fmt.Sprintf("offer %s on %s: %s",
+		offer.GetId().GetValue(), offer.GetHostname(), Resources(offer.GetResources()))
+}
+
+// ResourceString returns resource as name=value, i.e. cpus=4 or mem(prod)=512M.
+// Memory and disk, which Mesos counts in megabytes, are scaled to M, G or T.
+func ResourceString(resource *mesos.Resource) string {
+	name := resource.GetName()
+	if resource.GetRole() != mesos.Default_Resource_Role {
+		name += "(" + resource.GetRole() + ")"
+	}
+	if resource.GetType() == mesos.Value_SCALAR &&
+		(resource.GetName() == RESOURCE_MEM || resource.GetName() == RESOURCE_DISK) {
+		return name + "=" + formatMegabytes(resource.GetScalar().GetValue())
+	}
+	return name + "=" + formatValue(&mesos.Value{
+		Type:   resource.Type,
+		Scalar: resource.Scalar,
+		Ranges: resource.Ranges,
+		Set:    resource.Set,
+	})
+}
+
+// String returns the resources as space separated ResourceStrings.
+func (resources Resources) String() string {
+	tokens := make([]string, len(resources))
+	for i, resource := range resources {
+		tokens[i] = ResourceString(resource)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// TaskInfoString returns a one line summary of task, i.e.
+// task T1 (web) on slave S1: cpus=1 mem=128M command="./run"
+func TaskInfoString(task *mesos.TaskInfo) string {
+	summary := fmt.Sprintf("task %s (%s) on slave %s: %s",
+		task.GetTaskId().GetValue(), task.GetName(), task.GetSlaveId().GetValue(), Resources(task.GetResources()))
+	if task.Executor != nil {
+		summary += " executor=" + task.GetExecutor().GetExecutorId().GetValue()
+	}
+	if task.Command != nil {
+		summary += " command=" + strconv.Quote(task.GetCommand().GetValue())
+	}
+	return summary
+}
+
+// TaskStatusString returns a one line summary of status, i.e.
+// task T1 TASK_FAILED on slave S1: command exited with status 1
+func TaskStatusString(status *mesos.TaskStatus) string {
+	summary := fmt.Sprintf("task %s %s", status.GetTaskId().GetValue(), status.GetState())
+	if status.SlaveId != nil {
+		summary += " on slave " + status.GetSlaveId().GetValue()
+	}
+	if status.GetMessage() != "" {
+		summary += ": " + status.GetMessage()
+	}
+	return summary
+}
+
+// FrameworkInfoString returns a one line summary of framework, i.e.
+// framework F1 (test) user=root host=host-a failover_timeout=60 checkpoint
+func FrameworkInfoString(framework *mesos.FrameworkInfo) string {
+	summary := fmt.Sprintf("framework %s (%s) user=%s",
+		framework.GetId().GetValue(), framework.GetName(), framework.GetUser())
+	if framework.GetHostname() != "" {
+		summary += " host=" + framework.GetHostname()
+	}
+	if framework.GetRole() != mesos.Default_FrameworkInfo_Role {
+		summary += " role=" + framework.GetRole()
+	}
+	if framework.GetFailoverTimeout() > 0 {
+		summary += " failover_timeout=" + strconv.FormatFloat(framework.GetFailoverTimeout(), 'f', -1, 64)
+	}
+	if framework.GetCheckpoint() {
+		summary += " checkpoint"
+	}
+	return summary
+}
+
+// formatMegabytes scales mb to the largest unit that keeps it above 1,
+// with at most 2 decimals, i.e. 1536 is 1.5G.
+func formatMegabytes(mb float64) string {
+	unit := "M"
+	for _, next := range []string{"G", "T"} {
+		if mb < 1024 {
+			break
+		}
+		mb /= 1024
+		unit = next
+	}
+	return strconv.FormatFloat(math.Floor(mb*100+0.5)/100, 'f', -1, 64) + unit
+}
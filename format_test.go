@@ -0,0 +1,66 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"testing"
+)
+
+func TestOfferString(t *testing.T) {
+	offer := NewOffer(NewOfferID("O1"), NewFrameworkID("F1"), NewSlaveID("S1"), "host-a")
+	offer.Resources = []*mesos.Resource{
+		NewScalarResource("cpus", 4),
+		NewScalarResource("mem", 8192),
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(31000, 31100)}),
+	}
+	if s := OfferString(offer); s != "offer O1 on host-a: cpus=4 mem=8G ports=[31000-31100]" {
+		t.Fatal("Unexpected offer summary:", s)
+	}
+}
+
+func TestResourceString(t *testing.T) {
+	tests := map[string]*mesos.Resource{
+		"cpus=0.5":          NewScalarResource("cpus", 0.5),
+		"mem=512M":          NewScalarResource("mem", 512),
+		"mem=1.5G":          NewScalarResource("mem", 1536),
+		"disk=2T":           NewScalarResource("disk", 2*1024*1024),
+		"disks={sda,sdb}":   NewSetResource("disks", []string{"sda", "sdb"}),
+		"cpus(prod)=2":      withRole(NewScalarResource("cpus", 2), "prod"),
+		"ports=[1-2,80-80]": NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(1, 2), NewValueRange(80, 80)}),
+	}
+	for expected, resource := range tests {
+		if s := ResourceString(resource); s != expected {
+			t.Fatal("Expected", expected, "but got", s)
+		}
+	}
+}
+
+func TestTaskInfoString(t *testing.T) {
+	task := NewTaskInfo("web", NewTaskID("T1"), NewSlaveID("S1"), []*mesos.Resource{NewScalarResource("cpus", 1), NewScalarResource("mem", 128)})
+	task.Command = &mesos.CommandInfo{Value: proto.String("./run")}
+	if s := TaskInfoString(task); s != `task T1 (web) on slave S1: cpus=1 mem=128M command="./run"` {
+		t.Fatal("Unexpected task summary:", s)
+	}
+}
+
+func TestTaskStatusString(t *testing.T) {
+	status := NewTaskStatus(NewTaskID("T1"), mesos.TaskState_TASK_FAILED)
+	if s := TaskStatusString(status); s != "task T1 TASK_FAILED" {
+		t.Fatal("Unexpected status summary:", s)
+	}
+	status.SlaveId = NewSlaveID("S1")
+	status.Message = proto.String("command exited with status 1")
+	if s := TaskStatusString(status); s != "task T1 TASK_FAILED on slave S1: command exited with status 1" {
+		t.Fatal("Unexpected status summary:", s)
+	}
+}
+
+func TestFrameworkInfoString(t *testing.T) {
+	framework := NewFrameworkInfo("root", "test", NewFrameworkID("F1"))
+	framework.Hostname = proto.String("host-a")
+	framework.FailoverTimeout = proto.Float64(60)
+	framework.Checkpoint = proto.Bool(true)
+	if s := FrameworkInfoString(framework); s != "framework F1 (test) user=root host=host-a failover_timeout=60 checkpoint" {
+		t.Fatal("Unexpected framework summary:", s)
+	}
+}
@@ -0,0 +1,299 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"log"
+	"sync"
+	"time"
+)
+
+// DEFAULT_GANG_MAX_OFFERS is the number of offers a GangScheduler holds
+// unless set otherwise.
+const DEFAULT_GANG_MAX_OFFERS = 100
+
+// Gang is a group of tasks launched all together, or not at all when they
+// do not fit on the offers received before Deadline.
+type Gang struct {
+	Id       string
+	Demands  []*Demand
+	Deadline time.Time
+
+	timer *time.Timer
+}
+
+func NewGang(id string, tasks []*mesos.TaskInfo, deadline time.Time) *Gang {
+	demands := make([]*Demand, len(tasks))
+	for i, task := range tasks {
+		demands[i] = NewDemand(task)
+	}
+	return &Gang{Id: id, Demands: demands, Deadline: deadline}
+}
+
+/*
+GangScheduler holds offers until each task of a gang fits on them, then
+launches the whole gang, with one LaunchTasks call per slave. Offers given
+to ResourceOffers are held in Offers, a pool of the scheduler, and are its
+own to launch on or decline. Held offers the OfferPool of the driver no
+longer has, as they were rescinded or their slave was lost, are dropped:
+
+	gangs, err := NewGangScheduler(driver, time.Minute)
+	gangs.Submit(NewGang("mpi-1", workers, time.Now().Add(5*time.Minute)))
+
+	// in the ResourceOffers callback
+	gangs.ResourceOffers(offers)
+
+Offers are declined once held longer than the hold timeout, beyond
+MaxOffers, oldest first, and when no gang is waiting. A gang not placed
+by its deadline is dropped, releasing the offers it was waiting on.
+Gangs are placed in order of submission, a later gang may be launched
+on offers that do not suit an earlier one.
+*/
+type GangScheduler struct {
+	Planner   *Planner
+	Offers    *OfferPool
+	MaxOffers int
+	// Filters are sent with launches and declined offers.
+	Filters *mesos.Filters
+	// Launched, when set, is called after each gang launch with the error
+	// of the failed LaunchTasks call, if any. The tasks of a gang failing
+	// to launch are killed, the gang is not queued again.
+	Launched func(gang *Gang, err error)
+	// Expired, when set, is called for gangs dropped at their deadline.
+	Expired func(gang *Gang)
+
+	driver *SchedulerDriver
+	lock   sync.Mutex
+	gangs  []*Gang
+}
+
+// gangLaunch is the part of a gang launched on a slave.
+type gangLaunch struct {
+	gang     *Gang
+	offerIds []*mesos.OfferID
+	tasks    []*mesos.TaskInfo
+}
+
+// NewGangScheduler returns a scheduler launching gangs through driver,
+// holding offers for at most holdTimeout, or until used when it is 0. When
+// the driver has no OfferPool, it is given one that does not expire offers.
+// An OfferPool expiring offers before holdTimeout is an error.
+func NewGangScheduler(driver *SchedulerDriver, holdTimeout time.Duration) (*GangScheduler, error) {
+	if driver == nil {
+		return nil, fmt.Errorf("Missing SchedulerDriver.")
+	}
+	if holdTimeout < 0 {
+		return nil, fmt.Errorf("Hold timeout %s is negative.", holdTimeout)
+	}
+	if driver.OfferPool == nil {
+		driver.OfferPool = NewOfferPool(driver, 0)
+	} else if expiry := driver.OfferPool.Expiry(); expiry > 0 && (holdTimeout == 0 || expiry < holdTimeout) {
+		return nil, fmt.Errorf("Driver OfferPool expires offers after %s, before the hold timeout of %s.", expiry, holdTimeout)
+	}
+	return &GangScheduler{
+		Planner:   NewPlanner(BestFit),
+		Offers:    NewOfferPool(driver, holdTimeout),
+		MaxOffers: DEFAULT_GANG_MAX_OFFERS,
+		driver:    driver,
+	}, nil
+}
+
+// Submit queues a gang, which is launched as soon as the held offers fit it.
+func (scheduler *GangScheduler) Submit(gang *Gang) error {
+	if len(gang.Demands) == 0 {
+		return fmt.Errorf("Gang %s has no tasks.", gang.Id)
+	}
+	if !gang.Deadline.After(time.Now()) {
+		return fmt.Errorf("Gang %s deadline has passed.", gang.Id)
+	}
+
+	scheduler.lock.Lock()
+	for _, queued := range scheduler.gangs {
+		if queued.Id == gang.Id {
+			scheduler.lock.Unlock()
+			return fmt.Errorf("Gang %s is already queued.", gang.Id)
+		}
+	}
+	scheduler.gangs = append(scheduler.gangs, gang)
+	gang.timer = time.AfterFunc(gang.Deadline.Sub(time.Now()), func() {
+		scheduler.expire(gang)
+	})
+	scheduler.lock.Unlock()
+
+	scheduler.schedule()
+	return nil
+}
+
+// ResourceOffers holds new offers and launches the gangs they complete.
+func (scheduler *GangScheduler) ResourceOffers(offers []*mesos.Offer) {
+	scheduler.Offers.Add(offers...)
+	scheduler.schedule()
+}
+
+// Pending returns the gangs waiting for offers, in order of submission.
+func (scheduler *GangScheduler) Pending() []*Gang {
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+	return append([]*Gang{}, scheduler.gangs...)
+}
+
+// schedule launches the gangs fitting on the held offers and declines the
+// offers that are not to be held.
+func (scheduler *GangScheduler) schedule() {
+	scheduler.lock.Lock()
+	var launches []*gangLaunch
+	offers := scheduler.held()
+	pending := scheduler.gangs[:0]
+	for _, gang := range scheduler.gangs {
+		plan := scheduler.Planner.Plan(offers, gang.Demands)
+		if len(plan.Unplaced) > 0 || !scheduler.claim(plan) {
+			pending = append(pending, gang)
+			continue
+		}
+		gang.timer.Stop()
+		launches = append(launches, launchesBySlave(gang, plan)...)
+		offers = plan.Unused
+	}
+	scheduler.gangs = pending
+
+	var declines []*mesos.Offer
+	if len(scheduler.gangs) == 0 {
+		declines = offers
+	} else if len(offers) > scheduler.MaxOffers {
+		declines = offers[:len(offers)-scheduler.MaxOffers]
+	}
+	scheduler.lock.Unlock()
+
+	scheduler.launch(launches)
+	scheduler.decline(declines)
+}
+
+// held returns the unclaimed offers held, dropping the offers the driver
+// OfferPool lost.
+func (scheduler *GangScheduler) held() []*mesos.Offer {
+	offers := scheduler.Offers.Offers()
+	if scheduler.driver.OfferPool == nil {
+		return offers
+	}
+	kept := offers[:0]
+	for _, offer := range offers {
+		if scheduler.driver.OfferPool.Contains(offer.GetId()) {
+			kept = append(kept, offer)
+		} else {
+			scheduler.Offers.Remove(offer.GetId())
+		}
+	}
+	return kept
+}
+
+// claim takes the offers of plan from the pool. Offers rescinded since
+// they were listed fail the claim, which gives back the offers claimed.
+func (scheduler *GangScheduler) claim(plan *Plan) bool {
+	for i, launch := range plan.Launches {
+		if _, ok := scheduler.Offers.ClaimOffer(launch.Offer.GetId()); !ok {
+			for _, claimed := range plan.Launches[:i] {
+				scheduler.Offers.Release(claimed.Offer.GetId())
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// launchesBySlave groups the launches of plan by slave.
+func launchesBySlave(gang *Gang, plan *Plan) []*gangLaunch {
+	var launches []*gangLaunch
+	bySlave := make(map[string]*gangLaunch)
+	for _, launch := range plan.Launches {
+		slaveId := launch.Offer.GetSlaveId().GetValue()
+		slaveLaunch, ok := bySlave[slaveId]
+		if !ok {
+			slaveLaunch = &gangLaunch{gang: gang}
+			bySlave[slaveId] = slaveLaunch
+			launches = append(launches, slaveLaunch)
+		}
+		slaveLaunch.offerIds = append(slaveLaunch.offerIds, launch.Offer.GetId())
+		slaveLaunch.tasks = append(slaveLaunch.tasks, launch.Tasks...)
+	}
+	return launches
+}
+
+// launch must be called without the lock held.
+func (scheduler *GangScheduler) launch(launches []*gangLaunch) {
+	for len(launches) > 0 {
+		gang := launches[0].gang
+		end := 1
+		for end < len(launches) && launches[end].gang == gang {
+			end++
+		}
+		err := scheduler.launchGang(gang, launches[:end])
+		launches = launches[end:]
+		if scheduler.Launched != nil {
+			scheduler.Launched(gang, err)
+		}
+	}
+}
+
+// launchGang launches a gang slave by slave. When a launch fails, the
+// tasks already launched are killed, the offers of the failed launch are
+// declined and the offers of the remaining slaves are given back to the
+// pool, so that no part of the gang is left running.
+func (scheduler *GangScheduler) launchGang(gang *Gang, launches []*gangLaunch) error {
+	for i, launch := range launches {
+		_, err := scheduler.driver.LaunchTasksErr(launch.offerIds, launch.tasks, scheduler.Filters)
+		if err == nil {
+			scheduler.Offers.Remove(launch.offerIds...)
+			continue
+		}
+
+		log.Printf("Unable to launch gang %s, killing its launched tasks: %s", gang.Id, err)
+		for _, launched := range launches[:i] {
+			for _, task := range launched.tasks {
+				scheduler.driver.KillTask(task.GetTaskId())
+			}
+		}
+		for _, offerId := range launch.offerIds {
+			scheduler.Offers.Remove(offerId)
+			scheduler.driver.DeclineOffer(offerId, scheduler.Filters)
+		}
+		for _, unlaunched := range launches[i+1:] {
+			for _, offerId := range unlaunched.offerIds {
+				scheduler.Offers.Release(offerId)
+			}
+		}
+		return err
+	}
+	log.Printf("Launched gang %s", gang.Id)
+	return nil
+}
+
+// decline must be called without the lock held.
+func (scheduler *GangScheduler) decline(offers []*mesos.Offer) {
+	for _, offer := range offers {
+		scheduler.Offers.Remove(offer.GetId())
+		scheduler.driver.DeclineOffer(offer.GetId(), scheduler.Filters)
+	}
+}
+
+// expire drops a gang at its deadline.
+func (scheduler *GangScheduler) expire(gang *Gang) {
+	scheduler.lock.Lock()
+	found := false
+	for i, queued := range scheduler.gangs {
+		if queued == gang {
+			scheduler.gangs = append(scheduler.gangs[:i], scheduler.gangs[i+1:]...)
+			found = true
+			break
+		}
+	}
+	scheduler.lock.Unlock()
+	if !found {
+		return
+	}
+
+	log.Printf("Gang %s was not placed by its deadline", gang.Id)
+	scheduler.schedule()
+	if scheduler.Expired != nil {
+		scheduler.Expired(gang)
+	}
+}
@@ -0,0 +1,321 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"testing"
+	"time"
+)
+
+func makeGangTasks(count int, cpus, mem float64) []*mesos.TaskInfo {
+	tasks := make([]*mesos.TaskInfo, count)
+	for i, demand := range makeDemands(count, cpus, mem) {
+		tasks[i] = demand.Task
+	}
+	return tasks
+}
+
+// offerGangs hands offers to gangs as the ResourceOffers callback does,
+// once the driver recorded them in its OfferPool.
+func offerGangs(gangs *GangScheduler, offers ...*mesos.Offer) {
+	gangs.driver.OfferPool.Add(offers...)
+	gangs.ResourceOffers(offers)
+}
+
+// makeGangScheduler returns a GangScheduler of driver holding offers for
+// holdTimeout.
+func makeGangScheduler(t *testing.T, driver *SchedulerDriver, holdTimeout time.Duration) *GangScheduler {
+	gangs, err := NewGangScheduler(driver, holdTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gangs
+}
+
+func TestGangScheduler_Launch(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+
+	launched := make(chan error, 1)
+	gangs := makeGangScheduler(t, driver, time.Minute)
+	if driver.OfferPool == nil || gangs.Offers == driver.OfferPool {
+		t.Fatal("Expected GangScheduler to hold offers apart from the driver OfferPool it sets.")
+	}
+	gangs.Launched = func(gang *Gang, err error) {
+		launched <- err
+	}
+	if err := gangs.Submit(NewGang("mpi-1", makeGangTasks(3, 2, 1024), time.Now().Add(time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+
+	offerGangs(gangs,
+		makeLaunchOffer("offer-1", "slave-1", 2, 2048),
+		makeLaunchOffer("offer-2", "slave-1", 2, 2048),
+	)
+	if len(gangs.Pending()) != 1 || gangs.Offers.Len() != 2 {
+		t.Fatal("Expected gang to wait holding 2 offers, but got", gangs.Pending(), gangs.Offers.Len())
+	}
+
+	offerGangs(gangs,
+		makeLaunchOffer("offer-3", "slave-2", 2, 2048),
+		makeLaunchOffer("offer-4", "slave-3", 0.5, 2048),
+	)
+	call := master.expectCall(t, mesos.Call_ACCEPT)
+	accept := call.GetAccept()
+	if len(accept.GetOfferIds()) != 2 || accept.GetOfferIds()[1].GetValue() != "offer-2" || len(accept.GetOperations()[0].GetLaunch().GetTaskInfos()) != 2 {
+		t.Fatal("Expected 2 tasks launched on the offers of slave-1, but got", accept)
+	}
+	call = master.expectCall(t, mesos.Call_ACCEPT)
+	if call.GetAccept().GetOfferIds()[0].GetValue() != "offer-3" {
+		t.Fatal("Expected a task launched on offer-3, but got", call.GetAccept())
+	}
+	select {
+	case err := <-launched:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("GangScheduler.Launched not called.")
+	}
+
+	call = master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-4" {
+		t.Fatal("Expected offer-4 declined without waiting gangs, but got", call.GetDecline())
+	}
+	if len(gangs.Pending()) != 0 || gangs.Offers.Len() != 0 {
+		t.Fatal("Expected no gang and no offer held.")
+	}
+}
+
+func TestGangScheduler_LaunchFailure(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+	driver.ValidateLaunches = true
+
+	launched := make(chan error, 1)
+	gangs := makeGangScheduler(t, driver, time.Minute)
+	gangs.Planner = NewPlanner(FirstFit)
+	gangs.Launched = func(gang *Gang, err error) {
+		launched <- err
+	}
+	// task-1 has no command, so its launch on slave-2 is rejected
+	tasks := makeGangTasks(2, 2, 1024)
+	tasks[1].Command = nil
+	gangs.Submit(NewGang("mpi-1", tasks, time.Now().Add(time.Minute)))
+	offerGangs(gangs,
+		makeLaunchOffer("offer-1", "slave-1", 2, 2048),
+		makeLaunchOffer("offer-2", "slave-2", 2, 2048),
+	)
+
+	call := master.expectCall(t, mesos.Call_ACCEPT)
+	if call.GetAccept().GetOfferIds()[0].GetValue() != "offer-1" {
+		t.Fatal("Expected task-0 launched on offer-1, but got", call.GetAccept())
+	}
+	call = master.expectCall(t, mesos.Call_KILL)
+	if call.GetKill().GetTaskId().GetValue() != "task-0" {
+		t.Fatal("Expected launched task-0 killed, but got", call.GetKill())
+	}
+	call = master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-2" {
+		t.Fatal("Expected offer-2 of the failed launch declined, but got", call.GetDecline())
+	}
+	select {
+	case err := <-launched:
+		if _, ok := err.(*LaunchError); !ok {
+			t.Fatal("Expected a LaunchError, but got", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("GangScheduler.Launched not called.")
+	}
+}
+
+func TestGangScheduler_Deadline(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+
+	expired := make(chan *Gang, 1)
+	gangs := makeGangScheduler(t, driver, time.Minute)
+	gangs.Expired = func(gang *Gang) {
+		expired <- gang
+	}
+	gangs.Submit(NewGang("mpi-1", makeGangTasks(2, 2, 1024), time.Now().Add(100*time.Millisecond)))
+	offerGangs(gangs, makeLaunchOffer("offer-1", "slave-1", 2, 2048))
+
+	call := master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-1" {
+		t.Fatal("Expected held offer-1 declined at the gang deadline, but got", call.GetDecline())
+	}
+	select {
+	case gang := <-expired:
+		if gang.Id != "mpi-1" {
+			t.Fatal("Expected gang mpi-1 expired, but got", gang.Id)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("GangScheduler.Expired not called.")
+	}
+	if len(gangs.Pending()) != 0 || gangs.Offers.Len() != 0 {
+		t.Fatal("Expected no gang and no offer held after the deadline.")
+	}
+}
+
+func TestGangScheduler_MaxOffers(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+
+	gangs := makeGangScheduler(t, driver, time.Minute)
+	gangs.MaxOffers = 2
+	gangs.Submit(NewGang("mpi-1", makeGangTasks(4, 2, 1024), time.Now().Add(time.Minute)))
+	offerGangs(gangs,
+		makeLaunchOffer("offer-1", "slave-1", 2, 2048),
+		makeLaunchOffer("offer-2", "slave-2", 2, 2048),
+		makeLaunchOffer("offer-3", "slave-3", 2, 2048),
+	)
+	call := master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-1" {
+		t.Fatal("Expected oldest offer-1 declined beyond MaxOffers, but got", call.GetDecline())
+	}
+	if gangs.Offers.Len() != 2 || len(gangs.Pending()) != 1 {
+		t.Fatal("Expected gang waiting with 2 offers held, but got", gangs.Offers.Len())
+	}
+}
+
+func TestGangScheduler_Submit(t *testing.T) {
+	driver, err := NewSchedDriver(NewMesosScheduler(), NewFrameworkInfo("test", "test-framework", nil), "localhost:5050")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gangs := makeGangScheduler(t, driver, time.Minute)
+	deadline := time.Now().Add(time.Minute)
+	if err := gangs.Submit(NewGang("empty", nil, deadline)); err == nil {
+		t.Fatal("Expected error submitting a gang without tasks.")
+	}
+	if err := gangs.Submit(NewGang("late", makeGangTasks(1, 1, 128), time.Now())); err == nil {
+		t.Fatal("Expected error submitting a gang past its deadline.")
+	}
+	if err := gangs.Submit(NewGang("mpi-1", makeGangTasks(1, 1, 128), deadline)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gangs.Submit(NewGang("mpi-1", makeGangTasks(1, 1, 128), deadline)); err == nil {
+		t.Fatal("Expected error submitting a gang twice.")
+	}
+}
+
+func TestNewGangScheduler_HoldTimeout(t *testing.T) {
+	driver, err := NewSchedDriver(NewMesosScheduler(), NewFrameworkInfo("test", "test-framework", nil), "localhost:5050")
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.OfferPool = NewOfferPool(driver, 30*time.Second)
+	if _, err := NewGangScheduler(driver, time.Minute); err == nil {
+		t.Fatal("Expected error holding offers longer than the driver OfferPool expiry.")
+	}
+	if _, err := NewGangScheduler(driver, 0); err == nil {
+		t.Fatal("Expected error holding offers without timeout in an expiring driver OfferPool.")
+	}
+	if _, err := NewGangScheduler(driver, -time.Second); err == nil {
+		t.Fatal("Expected error for a negative hold timeout.")
+	}
+	if _, err := NewGangScheduler(nil, time.Minute); err == nil {
+		t.Fatal("Expected error for a missing driver.")
+	}
+	gangs := makeGangScheduler(t, driver, 10*time.Second)
+	if gangs.Offers.Expiry() != 10*time.Second {
+		t.Fatal("Expected offers held 10s, but got", gangs.Offers.Expiry())
+	}
+}
+
+func TestGangScheduler_HeldOffersOnly(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+	driver.OfferPool = NewOfferPool(driver, 0)
+	other := makeLaunchOffer("offer-0", "slave-0", 2, 2048)
+	driver.OfferPool.Add(other)
+
+	gangs := makeGangScheduler(t, driver, 100*time.Millisecond)
+	gangs.Submit(NewGang("mpi-1", makeGangTasks(3, 2, 1024), time.Now().Add(time.Minute)))
+	offerGangs(gangs,
+		makeLaunchOffer("offer-1", "slave-1", 2, 2048),
+		makeLaunchOffer("offer-2", "slave-2", 2, 2048),
+	)
+	// offer-2 is rescinded, the gang may not be launched on it
+	driver.OfferPool.Remove(NewOfferID("offer-2"))
+	offerGangs(gangs, makeLaunchOffer("offer-3", "slave-3", 1, 2048))
+	if gangs.Offers.Len() != 2 || len(gangs.Pending()) != 1 {
+		t.Fatal("Expected gang waiting with offer-1 and offer-3 held, but got", gangs.Offers.Offers())
+	}
+
+	// held offers are declined after the hold timeout, other offers are kept
+	declined := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		call := master.expectCall(t, mesos.Call_DECLINE)
+		declined[call.GetDecline().GetOfferIds()[0].GetValue()] = true
+	}
+	if !declined["offer-1"] || !declined["offer-3"] {
+		t.Fatal("Expected held offer-1 and offer-3 declined, but got", declined)
+	}
+	if !driver.OfferPool.Contains(other.GetId()) {
+		t.Fatal("Expected offer-0 not held by the GangScheduler left in the driver OfferPool.")
+	}
+}
+
+func TestGangScheduler_OverLibprocess(t *testing.T) {
+	master := makeTestMaster()
+	defer master.close()
+	driver := startTestDriver(t, NewMesosScheduler(), master)
+	defer driver.Stop(true)
+	driver.ValidateLaunches = true
+
+	launched := make(chan error, 2)
+	gangs := makeGangScheduler(t, driver, time.Minute)
+	gangs.Planner = NewPlanner(FirstFit)
+	gangs.Launched = func(gang *Gang, err error) {
+		launched <- err
+	}
+	gangs.Submit(NewGang("mpi-1", makeGangTasks(1, 2, 1024), time.Now().Add(time.Minute)))
+	offerGangs(gangs,
+		makeLaunchOffer("offer-1", "slave-1", 2, 2048),
+		makeLaunchOffer("offer-2", "slave-2", 2, 2048),
+	)
+	msg := new(mesos.LaunchTasksMessage)
+	master.expectCall(t, LAUNCH_TASKS_CALL, msg)
+	if msg.GetOfferIds()[0].GetValue() != "offer-1" || len(msg.GetTasks()) != 1 {
+		t.Fatal("Expected task-0 launched on offer-1, but got", msg)
+	}
+	msg = new(mesos.LaunchTasksMessage)
+	master.expectCall(t, LAUNCH_TASKS_CALL, msg)
+	if msg.GetOfferIds()[0].GetValue() != "offer-2" || len(msg.GetTasks()) != 0 {
+		t.Fatal("Expected offer-2 declined without waiting gangs, but got", msg)
+	}
+	if err := <-launched; err != nil {
+		t.Fatal(err)
+	}
+
+	// task-1 has no command, so its launch on slave-4 is rejected
+	tasks := makeGangTasks(2, 2, 1024)
+	tasks[0].TaskId = NewTaskID("task-10")
+	tasks[1].TaskId = NewTaskID("task-11")
+	tasks[1].Command = nil
+	gangs.Submit(NewGang("mpi-2", tasks, time.Now().Add(time.Minute)))
+	offerGangs(gangs,
+		makeLaunchOffer("offer-3", "slave-3", 2, 2048),
+		makeLaunchOffer("offer-4", "slave-4", 2, 2048),
+	)
+	master.expectCall(t, LAUNCH_TASKS_CALL, new(mesos.LaunchTasksMessage))
+	kill := new(mesos.KillTaskMessage)
+	master.expectCall(t, KILL_TASK_CALL, kill)
+	if kill.GetTaskId().GetValue() != "task-10" || kill.GetFrameworkId().GetValue() != "framework-1" {
+		t.Fatal("Expected launched task-10 of framework-1 killed, but got", kill)
+	}
+	msg = new(mesos.LaunchTasksMessage)
+	master.expectCall(t, LAUNCH_TASKS_CALL, msg)
+	if msg.GetOfferIds()[0].GetValue() != "offer-4" || len(msg.GetTasks()) != 0 {
+		t.Fatal("Expected offer-4 of the failed launch declined, but got", msg)
+	}
+	if _, ok := (<-launched).(*LaunchError); !ok {
+		t.Fatal("Expected a LaunchError launching mpi-2.")
+	}
+}
@@ -21,7 +21,9 @@ func init() {
 
 	Sched.ResourceOffers = func(driver *gomes.SchedulerDriver, offers []*mesos.Offer) {
 		log.Println("Got ", len(offers), "offers from master.")
-		log.Println("Offer 1", offers[0])
+		for _, offer := range offers {
+			log.Println(gomes.OfferString(offer))
+		}
 	}
 
 	Sched.Error = func(driver *gomes.SchedulerDriver, err gomes.MesosError) {
@@ -41,7 +43,7 @@ func main() {
 		Id:   &mesos.FrameworkID{Value: proto.String("gomes-framework-1")},
 	}
 
-	log.Println("Registering framework" + framework.String())
+	log.Println("Registering", gomes.FrameworkInfoString(framework))
 
 	driver, err := gomes.NewSchedDriver(Sched, framework, master)
 	if err != nil {
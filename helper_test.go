@@ -1,9 +1,24 @@
 package gomes
 
 import (
+	"code.google.com/p/goprotobuf/proto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"io/ioutil"
 	"log"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
 )
 
 func makeMockServer(handler func(rsp http.ResponseWriter, req *http.Request)) *httptest.Server {
@@ -11,3 +26,133 @@ func makeMockServer(handler func(rsp http.ResponseWriter, req *http.Request)) *h
 	log.Println("Created server  " + server.URL)
 	return server
 }
+
+// testMaster accepts the libprocess messages posted by a driver. Messages
+// are decoded by expectCall, which fails on missing required fields as the
+// master would.
+type testMaster struct {
+	server *httptest.Server
+	calls  chan *testMasterCall
+}
+
+type testMasterCall struct {
+	path string
+	data []byte
+}
+
+func makeTestMaster() *testMaster {
+	master := &testMaster{calls: make(chan *testMasterCall, 20)}
+	master.server = makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		master.calls <- &testMasterCall{path: req.URL.Path, data: data}
+		rsp.WriteHeader(http.StatusAccepted)
+	})
+	return master
+}
+
+// expectCall waits for the message posted to the call path and decodes it into msg.
+func (master *testMaster) expectCall(t *testing.T, call string, msg proto.Message) {
+	select {
+	case received := <-master.calls:
+		if received.path != buildReqPath(call) {
+			t.Fatal("Expected call", call, "but master received", received.path)
+		}
+		if err := proto.Unmarshal(received.data, msg); err != nil {
+			t.Fatal("Master unable to decode", call, ":", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Master did not receive call", call)
+	}
+}
+
+func (master *testMaster) close() {
+	master.server.Close()
+}
+
+func (master *testMaster) host() string {
+	u, _ := url.Parse(master.server.URL)
+	return u.Host
+}
+
+// startTestDriver starts a driver posting libprocess messages to master and
+// waits until the framework is registered as framework-1.
+func startTestDriver(t *testing.T, sched *Scheduler, master *testMaster) *SchedulerDriver {
+	registered := make(chan bool, 1)
+	callback := sched.Registered
+	sched.Registered = func(driver *SchedulerDriver, id *mesos.FrameworkID, info *mesos.MasterInfo) {
+		if callback != nil {
+			callback(driver, id, info)
+		}
+		registered <- true
+	}
+
+	driver, err := NewSchedDriver(sched, NewFrameworkInfo("test-user", "test-framework", NewFrameworkID("framework-1")), master.host())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", stat)
+	}
+	go driver.Join()
+
+	master.expectCall(t, REGISTER_FRAMEWORK_CALL, new(mesos.RegisterFrameworkMessage))
+	driver.schedMsgQ <- &mesos.FrameworkRegisteredMessage{
+		FrameworkId: NewFrameworkID("framework-1"),
+		MasterInfo:  NewMasterInfo("master-1", 16777343, 5050),
+	}
+	select {
+	case <-registered:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.Registered not called after FrameworkRegisteredMessage.")
+	}
+	return driver
+}
+
+// testCert is an in-memory certificate with its PEM encoding.
+type testCert struct {
+	cert    tls.Certificate
+	x509    *x509.Certificate
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// makeTestCert generates a certificate for 127.0.0.1, ::1 and localhost.
+// The certificate is self-signed when parent is nil, otherwise it is signed by parent.
+func makeTestCert(t *testing.T, name string, isCA bool, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("Unable to generate key:", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.x509, parent.cert.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal("Unable to create certificate:", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("Unable to marshal key:", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal("Unable to load key pair:", err)
+	}
+	parsed, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, x509: parsed, certPEM: certPEM, keyPEM: keyPEM}
+}
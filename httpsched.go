@@ -0,0 +1,402 @@
+package gomes
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"github.com/vladimirvivien/gomes/recordio"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// heartbeat interval assumed until the master sends SUBSCRIBED.
+	defaultHeartbeatInterval = 15 * time.Second
+	// missed heartbeats before the event stream is considered lost.
+	maxMissedHeartbeats = 3
+	// redirects to the leading master followed per subscription.
+	maxSubscribeRedirects = 3
+	// bounds of the delay between resubscription attempts.
+	minResubscribeBackoff = 100 * time.Millisecond
+	maxResubscribeBackoff = 30 * time.Second
+)
+
+// disconnectedEvent tells the driver the event stream was lost.
+type disconnectedEvent struct{}
+
+/*
+httpSchedClient speaks the Mesos v1 HTTP Scheduler API. It subscribes to
+/api/v1/scheduler, holds the streaming RecordIO response open and posts
+calls tagged with the Mesos-Stream-Id of the subscription. Events are
+converted to the libprocess messages handled by the SchedulerDriver.
+*/
+type httpSchedClient struct {
+	mutex      sync.Mutex
+	address    address
+	scheme     string
+	httpClient http.Client
+	framework  *mesos.FrameworkInfo
+	eventQ     chan<- interface{}
+	streamId   string
+	stream     io.ReadCloser
+	subscribed bool
+	heartbeat  time.Duration
+	watchdog   *time.Timer
+	done       chan struct{}
+}
+
+func newHttpSchedClient(master string, framework *mesos.FrameworkInfo, eventQ chan<- interface{}) *httpSchedClient {
+	return &httpSchedClient{
+		address:   address(master),
+		scheme:    HTTP_SCHEME,
+		framework: framework,
+		eventQ:    eventQ,
+		heartbeat: defaultHeartbeatInterval,
+		done:      make(chan struct{}),
+		httpClient: http.Client{
+			Transport: &http.Transport{
+				Dial: func(netw, addr string) (net.Conn, error) {
+					return net.DialTimeout(netw, addr, time.Second*7)
+				},
+				DisableCompression: true,
+			},
+			// redirects to the leading master are followed by subscribe.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// useTLS switches the client to https using the given configuration.
+func (client *httpSchedClient) useTLS(config *TLSConfig) {
+	client.scheme = HTTPS_SCHEME
+	client.httpClient.Transport.(*http.Transport).TLSClientConfig = config.clientConfig()
+}
+
+// start subscribes the framework and reads events in the background.
+func (client *httpSchedClient) start() error {
+	stream, err := client.subscribe()
+	if err != nil {
+		return err
+	}
+	go client.readEvents(stream)
+	return nil
+}
+
+// stop closes the event stream. No resubscription is attempted afterwards.
+func (client *httpSchedClient) stop() {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	select {
+	case <-client.done:
+		return
+	default:
+	}
+	close(client.done)
+	if client.watchdog != nil {
+		client.watchdog.Stop()
+	}
+	if client.stream != nil {
+		client.stream.Close()
+	}
+}
+
+func (client *httpSchedClient) stopped() bool {
+	select {
+	case <-client.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// subscribe sends SUBSCRIBE and returns the open event stream.
+// A 307 answer from a non-leading master is followed to the leader.
+func (client *httpSchedClient) subscribe() (io.ReadCloser, error) {
+	client.mutex.Lock()
+	call := &mesos.Call{
+		FrameworkId: client.framework.Id,
+		Type:        mesos.Call_SUBSCRIBE.Enum(),
+		Subscribe:   &mesos.Call_Subscribe{FrameworkInfo: client.framework},
+	}
+	data, err := proto.Marshal(call)
+	client.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for redirects := 0; ; redirects++ {
+		rsp, err := client.post(data, "")
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case rsp.StatusCode == http.StatusOK:
+			client.mutex.Lock()
+			client.streamId = rsp.Header.Get(HTTP_STREAM_ID_HEADER)
+			client.stream = rsp.Body
+			client.mutex.Unlock()
+			client.resetWatchdog()
+			return rsp.Body, nil
+
+		case rsp.StatusCode == http.StatusTemporaryRedirect && redirects < maxSubscribeRedirects:
+			location := rsp.Header.Get("Location")
+			rsp.Body.Close()
+			leader, err := url.Parse(location)
+			if err != nil || leader.Host == "" {
+				return nil, fmt.Errorf("Master redirected to invalid location [%s].", location)
+			}
+			log.Println("Master redirected subscription to leading master", leader.Host)
+			client.mutex.Lock()
+			client.address = address(leader.Host)
+			client.mutex.Unlock()
+
+		default:
+			body, _ := ioutil.ReadAll(rsp.Body)
+			rsp.Body.Close()
+			return nil, fmt.Errorf("Master did not accept subscription. Returned status %s: %s",
+				rsp.Status, strings.TrimSpace(string(body)))
+		}
+	}
+}
+
+// readEvents decodes the event stream until it fails, then resubscribes
+// until the client is stopped.
+func (client *httpSchedClient) readEvents(stream io.ReadCloser) {
+	for {
+		reader := recordio.NewReader(stream)
+		for {
+			record, err := reader.ReadRecord()
+			if err != nil {
+				if !client.stopped() {
+					log.Println("Lost event stream from master:", err)
+				}
+				break
+			}
+			event := new(mesos.Event)
+			if err := proto.Unmarshal(record, event); err != nil {
+				log.Println("Unable to decode event from master:", err)
+				continue
+			}
+			client.resetWatchdog()
+			client.handleEvent(event)
+		}
+		stream.Close()
+
+		if client.stopped() {
+			return
+		}
+		client.eventQ <- &disconnectedEvent{}
+		if stream = client.resubscribe(); stream == nil {
+			return
+		}
+	}
+}
+
+// resubscribe retries SUBSCRIBE with exponential backoff.
+// It returns nil once the client is stopped.
+func (client *httpSchedClient) resubscribe() io.ReadCloser {
+	backoff := minResubscribeBackoff
+	for {
+		select {
+		case <-client.done:
+			return nil
+		case <-time.After(backoff):
+		}
+		stream, err := client.subscribe()
+		if err == nil {
+			return stream
+		}
+		log.Println("Unable to resubscribe with master:", err)
+		if backoff *= 2; backoff > maxResubscribeBackoff {
+			backoff = maxResubscribeBackoff
+		}
+	}
+}
+
+// resetWatchdog closes the stream when no event (heartbeats included)
+// arrives within maxMissedHeartbeats heartbeat intervals.
+func (client *httpSchedClient) resetWatchdog() {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.watchdog != nil {
+		client.watchdog.Stop()
+	}
+	stream := client.stream
+	client.watchdog = time.AfterFunc(client.heartbeat*maxMissedHeartbeats, func() {
+		log.Println("Missed heartbeats from master, closing event stream.")
+		stream.Close()
+	})
+}
+
+// handleEvent converts event to the message expected by the driver.
+func (client *httpSchedClient) handleEvent(event *mesos.Event) {
+	switch event.GetType() {
+	case mesos.Event_SUBSCRIBED:
+		subscribed := event.GetSubscribed()
+		client.mutex.Lock()
+		client.framework.Id = subscribed.FrameworkId
+		if interval := subscribed.GetHeartbeatIntervalSeconds(); interval > 0 {
+			client.heartbeat = time.Duration(interval * float64(time.Second))
+		}
+		resubscribed := client.subscribed
+		client.subscribed = true
+		client.mutex.Unlock()
+		client.resetWatchdog()
+
+		if resubscribed {
+			client.eventQ <- &mesos.FrameworkReregisteredMessage{
+				FrameworkId: subscribed.FrameworkId,
+				MasterInfo:  subscribed.MasterInfo,
+			}
+		} else {
+			client.eventQ <- &mesos.FrameworkRegisteredMessage{
+				FrameworkId: subscribed.FrameworkId,
+				MasterInfo:  subscribed.MasterInfo,
+			}
+		}
+
+	case mesos.Event_OFFERS:
+		client.eventQ <- &mesos.ResourceOffersMessage{Offers: event.GetOffers().GetOffers()}
+
+	case mesos.Event_RESCIND:
+		client.eventQ <- &mesos.RescindResourceOfferMessage{OfferId: event.GetRescind().GetOfferId()}
+
+	case mesos.Event_UPDATE:
+		status := event.GetUpdate().GetStatus()
+		client.eventQ <- &mesos.StatusUpdateMessage{
+			Update: &mesos.StatusUpdate{
+				FrameworkId: client.frameworkId(),
+				Status:      status,
+				Timestamp:   proto.Float64(status.GetTimestamp()),
+				Uuid:        status.GetUuid(),
+			},
+		}
+
+	case mesos.Event_MESSAGE:
+		message := event.GetMessage()
+		client.eventQ <- &mesos.ExecutorToFrameworkMessage{
+			SlaveId:     message.AgentId,
+			FrameworkId: client.frameworkId(),
+			ExecutorId:  message.ExecutorId,
+			Data:        message.Data,
+		}
+
+	case mesos.Event_FAILURE:
+		failure := event.GetFailure()
+		if failure.ExecutorId == nil && failure.AgentId != nil {
+			client.eventQ <- &mesos.LostSlaveMessage{SlaveId: failure.AgentId}
+		} else {
+			log.Printf("Executor [%s] on slave [%s] failed with status %d",
+				failure.GetExecutorId().GetValue(), failure.GetAgentId().GetValue(), failure.GetStatus())
+		}
+
+	case mesos.Event_ERROR:
+		client.eventQ <- NewMesosError(event.GetError().GetMessage())
+
+	case mesos.Event_HEARTBEAT:
+		// the watchdog was already reset.
+	}
+}
+
+func (client *httpSchedClient) frameworkId() *mesos.FrameworkID {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.framework.Id
+}
+
+func (client *httpSchedClient) Teardown() error {
+	return client.send(&mesos.Call{Type: mesos.Call_TEARDOWN.Enum()})
+}
+
+func (client *httpSchedClient) KillTask(taskId *mesos.TaskID) error {
+	return client.send(&mesos.Call{
+		Type: mesos.Call_KILL.Enum(),
+		Kill: &mesos.Call_Kill{TaskId: taskId},
+	})
+}
+
+// LaunchTasks accepts offerIds with a LAUNCH operation, or declines them
+// when there is no task.
+func (client *httpSchedClient) LaunchTasks(offerIds []*mesos.OfferID, tasks []*mesos.TaskInfo, filters *mesos.Filters) error {
+	if len(tasks) == 0 {
+		return client.send(&mesos.Call{
+			Type:    mesos.Call_DECLINE.Enum(),
+			Decline: &mesos.Call_Decline{OfferIds: offerIds, Filters: filters},
+		})
+	}
+	return client.send(&mesos.Call{
+		Type: mesos.Call_ACCEPT.Enum(),
+		Accept: &mesos.Call_Accept{
+			OfferIds: offerIds,
+			Operations: []*mesos.Call_Operation{
+				&mesos.Call_Operation{
+					Type:   mesos.Call_Operation_LAUNCH.Enum(),
+					Launch: &mesos.Call_Operation_Launch{TaskInfos: tasks},
+				},
+			},
+			Filters: filters,
+		},
+	})
+}
+
+func (client *httpSchedClient) Acknowledge(status *mesos.TaskStatus) error {
+	return client.send(&mesos.Call{
+		Type: mesos.Call_ACKNOWLEDGE.Enum(),
+		Acknowledge: &mesos.Call_Acknowledge{
+			AgentId: status.SlaveId,
+			TaskId:  status.TaskId,
+			Uuid:    status.Uuid,
+		},
+	})
+}
+
+// send posts call on behalf of the subscribed framework.
+func (client *httpSchedClient) send(call *mesos.Call) error {
+	client.mutex.Lock()
+	call.FrameworkId = client.framework.Id
+	streamId := client.streamId
+	data, err := proto.Marshal(call)
+	client.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	rsp, err := client.post(data, streamId)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusAccepted {
+		body, _ := ioutil.ReadAll(rsp.Body)
+		return fmt.Errorf("Master did not accept %s call. Returned status %s: %s",
+			call.GetType(), rsp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (client *httpSchedClient) post(data []byte, streamId string) (*http.Response, error) {
+	client.mutex.Lock()
+	u := &url.URL{Scheme: client.scheme, Host: string(client.address), Path: HTTP_API_SCHEDULER}
+	client.mutex.Unlock()
+
+	req, err := http.NewRequest(HTTP_POST_METHOD, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", HTTP_CONTENT_TYPE)
+	req.Header.Add("Accept", HTTP_CONTENT_TYPE)
+	if streamId != "" {
+		req.Header.Add(HTTP_STREAM_ID_HEADER, streamId)
+	}
+	return client.httpClient.Do(req)
+}
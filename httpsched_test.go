@@ -0,0 +1,339 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"github.com/vladimirvivien/gomes/recordio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testStreamId = "test-stream-1"
+
+// testV1Master serves the v1 scheduler API. Calls are recorded and events
+// sent with send are streamed on the latest subscription.
+type testV1Master struct {
+	server *httptest.Server
+	calls  chan *mesos.Call
+	mutex  sync.Mutex
+	stream chan *mesos.Event
+}
+
+func makeTestV1Master() *testV1Master {
+	master := &testV1Master{calls: make(chan *mesos.Call, 20)}
+	master.server = httptest.NewServer(http.HandlerFunc(master.serve))
+	return master
+}
+
+func (master *testV1Master) serve(rsp http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != HTTP_API_SCHEDULER {
+		rsp.WriteHeader(http.StatusNotFound)
+		return
+	}
+	data, _ := ioutil.ReadAll(req.Body)
+	call := new(mesos.Call)
+	if err := proto.Unmarshal(data, call); err != nil {
+		rsp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if call.GetType() != mesos.Call_SUBSCRIBE {
+		if req.Header.Get(HTTP_STREAM_ID_HEADER) != testStreamId {
+			rsp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		master.calls <- call
+		rsp.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	stream := make(chan *mesos.Event, 10)
+	master.mutex.Lock()
+	master.stream = stream
+	master.mutex.Unlock()
+	master.calls <- call
+
+	rsp.Header().Set(HTTP_STREAM_ID_HEADER, testStreamId)
+	rsp.WriteHeader(http.StatusOK)
+	rsp.(http.Flusher).Flush()
+	writer := recordio.NewWriter(rsp)
+	for {
+		select {
+		case event := <-stream:
+			data, _ := proto.Marshal(event)
+			writer.WriteRecord(data)
+			rsp.(http.Flusher).Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func (master *testV1Master) send(event *mesos.Event) {
+	master.mutex.Lock()
+	defer master.mutex.Unlock()
+	master.stream <- event
+}
+
+func (master *testV1Master) expectCall(t *testing.T, callType mesos.Call_Type) *mesos.Call {
+	select {
+	case call := <-master.calls:
+		if call.GetType() != callType {
+			t.Fatal("Expected call", callType, "but master received", call.GetType())
+		}
+		return call
+	case <-time.After(3 * time.Second):
+		t.Fatal("Master did not receive call", callType)
+	}
+	return nil
+}
+
+func (master *testV1Master) close() {
+	master.server.CloseClientConnections()
+	master.server.Close()
+}
+
+func (master *testV1Master) host() string {
+	u, _ := url.Parse(master.server.URL)
+	return u.Host
+}
+
+func makeSubscribedEvent(frameworkId string, heartbeat float64) *mesos.Event {
+	return &mesos.Event{
+		Type: mesos.Event_SUBSCRIBED.Enum(),
+		Subscribed: &mesos.Event_Subscribed{
+			FrameworkId:              NewFrameworkID(frameworkId),
+			HeartbeatIntervalSeconds: proto.Float64(heartbeat),
+			MasterInfo:               NewMasterInfo("master-1", 16777343, 5050),
+		},
+	}
+}
+
+// startTestHttpDriver starts a driver using the HTTP API against master
+// and waits until the framework is subscribed with the given heartbeat interval.
+func startTestHttpDriver(t *testing.T, sched *Scheduler, master *testV1Master, heartbeat float64) *SchedulerDriver {
+	registered := make(chan bool, 1)
+	callback := sched.Registered
+	sched.Registered = func(driver *SchedulerDriver, id *mesos.FrameworkID, info *mesos.MasterInfo) {
+		if callback != nil {
+			callback(driver, id, info)
+		}
+		registered <- true
+	}
+
+	driver, err := NewSchedDriver(sched, NewFrameworkInfo("test-user", "test-framework", nil), master.host())
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.Transport = TRANSPORT_HTTP_API
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", stat)
+	}
+	go driver.Join()
+
+	call := master.expectCall(t, mesos.Call_SUBSCRIBE)
+	if call.GetSubscribe().GetFrameworkInfo().GetName() != "test-framework" {
+		t.Fatal("SUBSCRIBE call missing FrameworkInfo.")
+	}
+	master.send(makeSubscribedEvent("framework-1", heartbeat))
+	select {
+	case <-registered:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.Registered not called after SUBSCRIBED event.")
+	}
+	return driver
+}
+
+func TestHttpDriver_Subscribe(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+
+	offers := make(chan []*mesos.Offer, 1)
+	sched := NewMesosScheduler()
+	sched.ResourceOffers = func(driver *SchedulerDriver, o []*mesos.Offer) {
+		offers <- o
+	}
+	driver := startTestHttpDriver(t, sched, master, 10)
+	defer driver.Stop(true)
+
+	if driver.FrameworkInfo.GetId().GetValue() != "framework-1" {
+		t.Fatal("Driver did not record the framework id from SUBSCRIBED.")
+	}
+	if driver.httpClient.streamId != testStreamId {
+		t.Fatal("Driver did not record the Mesos-Stream-Id, got", driver.httpClient.streamId)
+	}
+
+	master.send(&mesos.Event{
+		Type: mesos.Event_OFFERS.Enum(),
+		Offers: &mesos.Event_Offers{
+			Offers: []*mesos.Offer{
+				NewOffer(NewOfferID("offer-1"), NewFrameworkID("framework-1"), NewSlaveID("slave-1"), "localhost"),
+			},
+		},
+	})
+	select {
+	case o := <-offers:
+		if len(o) != 1 || o[0].GetId().GetValue() != "offer-1" {
+			t.Fatal("Scheduler.ResourceOffers received unexpected offers", o)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.ResourceOffers not called after OFFERS event.")
+	}
+}
+
+func TestHttpDriver_Calls(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+
+	updates := make(chan *mesos.TaskStatus, 1)
+	sched := NewMesosScheduler()
+	sched.StatusUpdate = func(driver *SchedulerDriver, status *mesos.TaskStatus) {
+		updates <- status
+	}
+	driver := startTestHttpDriver(t, sched, master, 10)
+
+	task := NewTaskInfo("task", NewTaskID("task-1"), NewSlaveID("slave-1"), []*mesos.Resource{NewScalarResource("cpus", 1)})
+	driver.LaunchTasks([]*mesos.OfferID{NewOfferID("offer-1")}, []*mesos.TaskInfo{task}, nil)
+	call := master.expectCall(t, mesos.Call_ACCEPT)
+	if call.GetFrameworkId().GetValue() != "framework-1" {
+		t.Fatal("ACCEPT call missing framework id.")
+	}
+	ops := call.GetAccept().GetOperations()
+	if len(ops) != 1 || ops[0].GetType() != mesos.Call_Operation_LAUNCH ||
+		ops[0].GetLaunch().GetTaskInfos()[0].GetTaskId().GetValue() != "task-1" {
+		t.Fatal("ACCEPT call missing LAUNCH operation for task-1.")
+	}
+
+	driver.DeclineOffer(NewOfferID("offer-2"), nil)
+	call = master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-2" {
+		t.Fatal("DECLINE call missing offer-2.")
+	}
+
+	driver.KillTask(NewTaskID("task-1"))
+	call = master.expectCall(t, mesos.Call_KILL)
+	if call.GetKill().GetTaskId().GetValue() != "task-1" {
+		t.Fatal("KILL call missing task-1.")
+	}
+
+	status := NewTaskStatus(NewTaskID("task-1"), mesos.TaskState_TASK_RUNNING)
+	status.SlaveId = NewSlaveID("slave-1")
+	status.Uuid = []byte("uuid-1")
+	master.send(&mesos.Event{
+		Type:   mesos.Event_UPDATE.Enum(),
+		Update: &mesos.Event_Update{Status: status},
+	})
+	select {
+	case s := <-updates:
+		if s.GetState() != mesos.TaskState_TASK_RUNNING {
+			t.Fatal("Scheduler.StatusUpdate received unexpected state", s.GetState())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.StatusUpdate not called after UPDATE event.")
+	}
+	call = master.expectCall(t, mesos.Call_ACKNOWLEDGE)
+	if string(call.GetAcknowledge().GetUuid()) != "uuid-1" ||
+		call.GetAcknowledge().GetAgentId().GetValue() != "slave-1" {
+		t.Fatal("ACKNOWLEDGE call does not match the status update.")
+	}
+
+	if stat := driver.Stop(false); stat != mesos.Status_DRIVER_STOPPED {
+		t.Fatal("Expected DRIVER_STOPPED, but got", stat)
+	}
+	master.expectCall(t, mesos.Call_TEARDOWN)
+}
+
+func TestHttpDriver_FollowsLeaderRedirect(t *testing.T) {
+	leader := makeTestV1Master()
+	defer leader.close()
+	follower := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.Header().Set("Location", "//"+leader.host()+HTTP_API_SCHEDULER)
+		rsp.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	defer follower.Close()
+
+	followerUrl, _ := url.Parse(follower.URL)
+	driver, err := NewSchedDriver(NewMesosScheduler(), NewFrameworkInfo("test-user", "test-framework", nil), followerUrl.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.Transport = TRANSPORT_HTTP_API
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", stat)
+	}
+	go driver.Join()
+	defer driver.Stop(true)
+	leader.expectCall(t, mesos.Call_SUBSCRIBE)
+	if string(driver.httpClient.address) != leader.host() {
+		t.Fatal("Driver did not follow redirect to leading master.")
+	}
+}
+
+func TestHttpDriver_ResubscribesAfterMissedHeartbeats(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+
+	reregistered := make(chan bool, 1)
+	sched := NewMesosScheduler()
+	sched.Reregistered = func(driver *SchedulerDriver, info *mesos.MasterInfo) {
+		reregistered <- true
+	}
+	driver := startTestHttpDriver(t, sched, master, 0.02)
+	defer driver.Stop(true)
+
+	call := master.expectCall(t, mesos.Call_SUBSCRIBE)
+	if call.GetFrameworkId().GetValue() != "framework-1" {
+		t.Fatal("Resubscription expected framework-1, but got", call.GetFrameworkId().GetValue())
+	}
+	master.send(makeSubscribedEvent("framework-1", 10))
+	select {
+	case <-reregistered:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.Reregistered not called after resubscription.")
+	}
+}
+
+func TestHttpDriver_SubscribeRejected(t *testing.T) {
+	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.WriteHeader(http.StatusForbidden)
+	})
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	driver, err := NewSchedDriver(NewMesosScheduler(), NewFrameworkInfo("test-user", "test-framework", nil), u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.Transport = TRANSPORT_HTTP_API
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_ABORTED {
+		t.Fatal("Expected DRIVER_ABORTED, but got", stat)
+	}
+}
+
+func TestHttpDriver_ErrorEvent(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+
+	errors := make(chan MesosError, 1)
+	sched := NewMesosScheduler()
+	sched.Error = func(driver *SchedulerDriver, err MesosError) {
+		errors <- err
+	}
+	startTestHttpDriver(t, sched, master, 10)
+
+	master.send(&mesos.Event{
+		Type:  mesos.Event_ERROR.Enum(),
+		Error: &mesos.Event_Error{Message: proto.String("Framework removed")},
+	})
+	select {
+	case err := <-errors:
+		if err.Error() != "Framework removed" {
+			t.Fatal("Scheduler.Error received unexpected error", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.Error not called after ERROR event.")
+	}
+}
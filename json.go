@@ -0,0 +1,158 @@
+package gomes
+
+import (
+	"bytes"
+	"code.google.com/p/goprotobuf/proto"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+MarshalJSON encodes msg, which may be any message of mesosproto, following
+the JSON conventions of Mesos: fields are named as in the .proto files,
+enums are written by name, bytes are base64 encoded and unset fields are
+omitted.
+*/
+func MarshalJSON(msg proto.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marshalMessage(&buf, reflect.ValueOf(msg)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes data written by MarshalJSON, or by Mesos, into msg.
+// Enums may be given by name or by number. Unknown fields are ignored.
+func UnmarshalJSON(data []byte, msg proto.Message) error {
+	return unmarshalMessage(data, reflect.ValueOf(msg))
+}
+
+// marshalMessage writes msg, a pointer to a generated struct.
+func marshalMessage(buf *bytes.Buffer, msg reflect.Value) error {
+	if msg.IsNil() {
+		buf.WriteString("null")
+		return nil
+	}
+	st := msg.Elem()
+	buf.WriteByte('{')
+	first := true
+	for i := 0; i < st.NumField(); i++ {
+		name := protoFieldName(st.Type().Field(i))
+		value := st.Field(i)
+		if name == "" || value.IsNil() || (value.Kind() == reflect.Slice && value.Len() == 0) {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.WriteString(strconv.Quote(name))
+		buf.WriteByte(':')
+
+		var err error
+		if isRepeated(value) {
+			buf.WriteByte('[')
+			for j := 0; j < value.Len() && err == nil; j++ {
+				if j > 0 {
+					buf.WriteByte(',')
+				}
+				err = marshalField(buf, value.Index(j))
+			}
+			buf.WriteByte(']')
+		} else {
+			err = marshalField(buf, value)
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to encode field %s: %s", name, err)
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func marshalField(buf *bytes.Buffer, value reflect.Value) error {
+	if value.Kind() == reflect.Ptr && value.Type().Elem().Kind() == reflect.Struct {
+		return marshalMessage(buf, value)
+	}
+	// enums are written by name, unless the value has none.
+	if v := reflect.Indirect(value); v.Kind() == reflect.Int32 {
+		if enum, ok := v.Interface().(fmt.Stringer); ok {
+			if name := enum.String(); name != strconv.FormatInt(v.Int(), 10) {
+				buf.WriteString(strconv.Quote(name))
+				return nil
+			}
+		}
+	}
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// unmarshalMessage decodes data into msg, a pointer to a generated struct.
+func unmarshalMessage(data []byte, msg reflect.Value) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	st := msg.Elem()
+	for i := 0; i < st.NumField(); i++ {
+		name := protoFieldName(st.Type().Field(i))
+		raw, ok := fields[name]
+		if name == "" || !ok || string(raw) == "null" {
+			continue
+		}
+
+		var err error
+		target := st.Field(i)
+		if isRepeated(target) {
+			var items []json.RawMessage
+			if err = json.Unmarshal(raw, &items); err == nil {
+				slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+				for j := 0; j < len(items) && err == nil; j++ {
+					err = unmarshalField(items[j], slice.Index(j))
+				}
+				target.Set(slice)
+			}
+		} else {
+			err = unmarshalField(raw, target)
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to decode field %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func unmarshalField(data []byte, target reflect.Value) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if target.Kind() == reflect.Ptr && target.Type().Elem().Kind() == reflect.Struct {
+		target.Set(reflect.New(target.Type().Elem()))
+		return unmarshalMessage(data, target)
+	}
+	// generated enums decode names and numbers with their UnmarshalJSON.
+	return json.Unmarshal(data, target.Addr().Interface())
+}
+
+// protoFieldName returns the .proto name of a generated field,
+// or "" for fields that are not part of the message.
+func protoFieldName(field reflect.StructField) string {
+	for _, part := range strings.Split(field.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(part, "name=") {
+			return part[len("name="):]
+		}
+	}
+	return ""
+}
+
+// isRepeated tells repeated fields apart from bytes fields.
+func isRepeated(value reflect.Value) bool {
+	return value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8
+}
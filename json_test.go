@@ -0,0 +1,135 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// fillMessage sets every field of msg to a sample value, down to depth
+// nested messages.
+func fillMessage(msg reflect.Value, depth int) {
+	st := msg.Elem()
+	for i := 0; i < st.NumField(); i++ {
+		if protoFieldName(st.Type().Field(i)) == "" {
+			continue
+		}
+		field := st.Field(i)
+		if isRepeated(field) {
+			if field.Type().Elem().Kind() == reflect.Ptr && depth == 0 {
+				continue
+			}
+			slice := reflect.MakeSlice(field.Type(), 2, 2)
+			for j := 0; j < 2; j++ {
+				fillValue(slice.Index(j), depth)
+			}
+			field.Set(slice)
+		} else {
+			fillValue(field, depth)
+		}
+	}
+}
+
+func fillValue(value reflect.Value, depth int) {
+	if value.Kind() == reflect.Ptr {
+		if value.Type().Elem().Kind() == reflect.Struct && depth == 0 {
+			return
+		}
+		value.Set(reflect.New(value.Type().Elem()))
+		if value.Type().Elem().Kind() == reflect.Struct {
+			fillMessage(value, depth-1)
+			return
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString("text")
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Int32, reflect.Int64:
+		value.SetInt(1)
+	case reflect.Uint32, reflect.Uint64:
+		value.SetUint(7)
+	case reflect.Float64:
+		value.SetFloat(1.5)
+	case reflect.Slice:
+		value.SetBytes([]byte("bytes"))
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	resource := withRole(NewScalarResource("cpus", 4), "prod")
+	data, err := MarshalJSON(resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"name":"cpus","type":"SCALAR","scalar":{"value":4},"role":"prod"}` {
+		t.Fatal("Unexpected JSON for resource:", string(data))
+	}
+
+	task := NewTaskInfo("web", NewTaskID("T1"), NewSlaveID("S1"), nil)
+	task.Data = []byte("hello")
+	data, err = MarshalJSON(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"name":"web","task_id":{"value":"T1"},"slave_id":{"value":"S1"},"data":"aGVsbG8="}` {
+		t.Fatal("Unexpected JSON for task:", string(data))
+	}
+
+	if _, err = MarshalJSON(NewScalarResource("cpus", math.Inf(1))); err == nil {
+		t.Fatal("Expected error encoding infinite scalar.")
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	data := `{"name":"ports","type":1,"ranges":{"range":[{"begin":1,"end":2}]},"unknown":true}`
+	resource := new(mesos.Resource)
+	if err := UnmarshalJSON([]byte(data), resource); err != nil {
+		t.Fatal(err)
+	}
+	if resource.GetType() != mesos.Value_RANGES || resource.GetRanges().GetRange()[0].GetEnd() != 2 {
+		t.Fatal("Unexpected resource decoded:", resource)
+	}
+
+	status := new(mesos.TaskStatus)
+	if err := UnmarshalJSON([]byte(`{"task_id":{"value":"T1"},"state":"TASK_LOST"}`), status); err != nil {
+		t.Fatal(err)
+	}
+	if status.GetState() != mesos.TaskState_TASK_LOST {
+		t.Fatal("Expected enum decoded by name, but got", status.GetState())
+	}
+
+	invalid := []string{`[]`, `{"state":"TASK_UNKNOWN_STATE"}`, `{"task_id":"T1"}`, `{"data":"%%%"}`}
+	for _, data := range invalid {
+		if err := UnmarshalJSON([]byte(data), new(mesos.TaskStatus)); err == nil {
+			t.Fatal("Expected error decoding", data)
+		}
+	}
+}
+
+func TestJSON_RoundTripAllMessages(t *testing.T) {
+	for name, newMsg := range mesos.MessageTypes {
+		msg := newMsg()
+		fillMessage(reflect.ValueOf(msg), 3)
+		data, err := MarshalJSON(msg)
+		if err != nil {
+			t.Fatal("Unable to encode", name, err)
+		}
+		decoded := newMsg()
+		if err = UnmarshalJSON(data, decoded); err != nil {
+			t.Fatal("Unable to decode", name, err, string(data))
+		}
+		if !reflect.DeepEqual(msg, decoded) {
+			t.Fatal(fmt.Sprintf("%s changed after JSON round trip: %s", name, data))
+		}
+	}
+
+	empty, _ := MarshalJSON(new(mesos.Offer))
+	if string(empty) != "{}" {
+		t.Fatal("Expected unset fields to be omitted, but got", string(empty))
+	}
+}
@@ -0,0 +1,93 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"strings"
+)
+
+// LaunchError reports a launch rejected before it was sent to the master.
+// TaskId names the offending task, or is empty when the offers are at fault.
+type LaunchError struct {
+	TaskId  string
+	OfferId string
+	Reason  string
+}
+
+func (err *LaunchError) Error() string {
+	reason := strings.TrimSuffix(err.Reason, ".")
+	if err.TaskId != "" {
+		return fmt.Sprintf("Invalid launch of task [%s]: %s.", err.TaskId, reason)
+	}
+	return fmt.Sprintf("Invalid launch on offer [%s]: %s.", err.OfferId, reason)
+}
+
+// ValidateLaunch checks a batch of tasks against the offers it is launched
+// on, catching what the master would otherwise turn into TASK_LOST:
+// duplicate task ids, tasks placed on another slave, tasks without exactly
+// one of an executor or a command, resources beyond the combined offers and
+// offers from different slaves. The error returned is a *LaunchError.
+func ValidateLaunch(offers []*mesos.Offer, tasks []*mesos.TaskInfo) error {
+	if len(offers) == 0 {
+		return &LaunchError{Reason: "no offers given"}
+	}
+
+	slaveId := offers[0].GetSlaveId().GetValue()
+	offerIds := make(map[string]bool)
+	var offered Resources
+	for _, offer := range offers {
+		id := offer.GetId().GetValue()
+		if offerIds[id] {
+			return &LaunchError{OfferId: id, Reason: "offer given more than once"}
+		}
+		offerIds[id] = true
+		if offer.GetSlaveId().GetValue() != slaveId {
+			return &LaunchError{OfferId: id, Reason: fmt.Sprintf(
+				"offers are from different slaves [%s] and [%s]", slaveId, offer.GetSlaveId().GetValue())}
+		}
+		var err error
+		if offered, err = offered.Add(Resources(offer.GetResources())); err != nil {
+			return &LaunchError{OfferId: id, Reason: err.Error()}
+		}
+	}
+
+	taskIds := make(map[string]bool)
+	executorIds := make(map[string]bool)
+	for _, task := range tasks {
+		id := task.GetTaskId().GetValue()
+		if id == "" {
+			return &LaunchError{TaskId: task.GetName(), Reason: "missing task id"}
+		}
+		if taskIds[id] {
+			return &LaunchError{TaskId: id, Reason: "duplicate task id"}
+		}
+		taskIds[id] = true
+
+		if task.GetSlaveId().GetValue() != slaveId {
+			return &LaunchError{TaskId: id, Reason: fmt.Sprintf(
+				"slave id [%s] does not match offered slave [%s]", task.GetSlaveId().GetValue(), slaveId)}
+		}
+		if (task.Executor == nil) == (task.Command == nil) {
+			return &LaunchError{TaskId: id, Reason: "exactly one of executor or command must be set"}
+		}
+
+		requested := Resources(task.GetResources())
+		if err := requested.Validate(); err != nil {
+			return &LaunchError{TaskId: id, Reason: err.Error()}
+		}
+		// an executor shared by several tasks is only launched once
+		if executor := task.GetExecutor(); executor != nil && !executorIds[executor.GetExecutorId().GetValue()] {
+			executorIds[executor.GetExecutorId().GetValue()] = true
+			var err error
+			if requested, err = requested.Add(Resources(executor.GetResources())); err != nil {
+				return &LaunchError{TaskId: id, Reason: err.Error()}
+			}
+		}
+
+		var err error
+		if offered, err = offered.Subtract(requested); err != nil {
+			return &LaunchError{TaskId: id, Reason: err.Error()}
+		}
+	}
+	return nil
+}
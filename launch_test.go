@@ -0,0 +1,197 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func makeLaunchOffer(id, slave string, cpus, mem float64) *mesos.Offer {
+	offer := NewOffer(NewOfferID(id), NewFrameworkID("framework-1"), NewSlaveID(slave), "host-"+slave)
+	offer.Resources = []*mesos.Resource{NewScalarResource("cpus", cpus), NewScalarResource("mem", mem)}
+	return offer
+}
+
+func makeLaunchTask(id, slave string, cpus, mem float64) *mesos.TaskInfo {
+	task := NewTaskInfo(id, NewTaskID(id), NewSlaveID(slave),
+		[]*mesos.Resource{NewScalarResource("cpus", cpus), NewScalarResource("mem", mem)})
+	task.Command = &mesos.CommandInfo{Value: proto.String("./" + id)}
+	return task
+}
+
+func TestValidateLaunch(t *testing.T) {
+	offers := []*mesos.Offer{makeLaunchOffer("offer-1", "slave-1", 2, 256), makeLaunchOffer("offer-2", "slave-1", 1, 256)}
+	tasks := []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 2, 256), makeLaunchTask("task-2", "slave-1", 1, 256)}
+	if err := ValidateLaunch(offers, tasks); err != nil {
+		t.Fatal("Expected launch using the combined offers to be valid, but got", err)
+	}
+
+	executor := &mesos.ExecutorInfo{
+		ExecutorId: NewExecutorID("exec-1"),
+		Resources:  []*mesos.Resource{NewScalarResource("cpus", 1)},
+	}
+	shared := []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 1, 128), makeLaunchTask("task-2", "slave-1", 1, 128)}
+	for _, task := range shared {
+		task.Command = nil
+		task.Executor = executor
+	}
+	if err := ValidateLaunch(offers, shared); err != nil {
+		t.Fatal("Expected executor resources to be counted once, but got", err)
+	}
+}
+
+func TestValidateLaunch_Rejected(t *testing.T) {
+	offer := makeLaunchOffer("offer-1", "slave-1", 2, 256)
+
+	both := makeLaunchTask("task-both", "slave-1", 1, 128)
+	both.Executor = &mesos.ExecutorInfo{ExecutorId: NewExecutorID("exec-1")}
+	neither := makeLaunchTask("task-neither", "slave-1", 1, 128)
+	neither.Command = nil
+
+	cases := []struct {
+		offers []*mesos.Offer
+		tasks  []*mesos.TaskInfo
+		taskId string
+	}{
+		{[]*mesos.Offer{offer}, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 1, 1), makeLaunchTask("task-1", "slave-1", 1, 1)}, "task-1"},
+		{[]*mesos.Offer{offer}, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-2", 1, 1)}, "task-1"},
+		{[]*mesos.Offer{offer}, []*mesos.TaskInfo{both}, "task-both"},
+		{[]*mesos.Offer{offer}, []*mesos.TaskInfo{neither}, "task-neither"},
+		{[]*mesos.Offer{offer}, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 1, 128), makeLaunchTask("task-2", "slave-1", 1.5, 128)}, "task-2"},
+		{[]*mesos.Offer{offer, makeLaunchOffer("offer-2", "slave-2", 1, 1)}, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 1, 1)}, ""},
+		{nil, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 1, 1)}, ""},
+	}
+	for i, c := range cases {
+		err := ValidateLaunch(c.offers, c.tasks)
+		launchErr, ok := err.(*LaunchError)
+		if !ok {
+			t.Fatal("Case", i, "expected a LaunchError, but got", err)
+		}
+		if launchErr.TaskId != c.taskId {
+			t.Fatal("Case", i, "expected error naming task", c.taskId, "but got", launchErr)
+		}
+	}
+}
+
+func TestLaunchError(t *testing.T) {
+	err := &LaunchError{TaskId: "task-1", Reason: "Insufficient cpus: 1 available, 2 requested."}
+	if err.Error() != "Invalid launch of task [task-1]: Insufficient cpus: 1 available, 2 requested." {
+		t.Fatal("Unexpected LaunchError message", err.Error())
+	}
+	err = &LaunchError{OfferId: "offer-1", Reason: "offer is unknown or no longer valid"}
+	if err.Error() != "Invalid launch on offer [offer-1]: offer is unknown or no longer valid." {
+		t.Fatal("Unexpected LaunchError message", err.Error())
+	}
+}
+
+func TestDriverLaunchTasks_Validated(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+
+	received := make(chan bool, 1)
+	sched := NewMesosScheduler()
+	sched.ResourceOffers = func(driver *SchedulerDriver, offers []*mesos.Offer) {
+		received <- true
+	}
+	sched.OfferRescinded = func(driver *SchedulerDriver, offerId *mesos.OfferID) {
+		received <- true
+	}
+	sched.Error = func(driver *SchedulerDriver, err MesosError) {
+		t.Error("Unexpected Scheduler.Error:", err)
+	}
+	driver := startTestHttpDriver(t, sched, master, 10)
+	driver.ValidateLaunches = true
+	driver.OfferPool = NewOfferPool(driver, 0)
+
+	master.send(&mesos.Event{
+		Type: mesos.Event_OFFERS.Enum(),
+		Offers: &mesos.Event_Offers{
+			Offers: []*mesos.Offer{makeLaunchOffer("offer-1", "slave-1", 1, 128), makeLaunchOffer("offer-2", "slave-1", 1, 128)},
+		},
+	})
+	select {
+	case <-received:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.ResourceOffers not called after OFFERS event.")
+	}
+
+	offerIds := []*mesos.OfferID{NewOfferID("offer-1")}
+	err := driver.ValidateLaunch(offerIds, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 2, 128)})
+	if launchErr, ok := err.(*LaunchError); !ok || launchErr.TaskId != "task-1" {
+		t.Fatal("Expected LaunchError for task-1, but got", err)
+	}
+	// invalid launches are not sent, the error is returned to the caller
+	stat, err := driver.LaunchTasksErr(offerIds, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 2, 128)}, nil)
+	if stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING after rejected launch, but got", stat)
+	}
+	if launchErr, ok := err.(*LaunchError); !ok || launchErr.TaskId != "task-1" {
+		t.Fatal("Expected LaunchError for task-1, but got", err)
+	}
+	stat = driver.LaunchTasks(offerIds, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 2, 128)}, nil)
+	if stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING after rejected launch, but got", stat)
+	}
+
+	if err = driver.ValidateLaunch(offerIds, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 1, 128)}); err != nil {
+		t.Fatal("Expected valid launch, but got", err)
+	}
+	stat = driver.LaunchTasks(offerIds, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 1, 128)}, nil)
+	if stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", stat)
+	}
+	call := master.expectCall(t, mesos.Call_ACCEPT)
+	tasks := call.GetAccept().GetOperations()[0].GetLaunch().GetTaskInfos()
+	if len(tasks) != 1 || tasks[0].GetResources()[0].GetScalar().GetValue() != 1 {
+		t.Fatal("Expected ACCEPT call of the valid launch only, but got", call)
+	}
+	if call.GetAccept().GetOfferIds()[0].GetValue() != "offer-1" {
+		t.Fatal("ACCEPT call missing offer-1.")
+	}
+
+	// used offers can not be launched on again
+	if err = driver.ValidateLaunch(offerIds, []*mesos.TaskInfo{makeLaunchTask("task-2", "slave-1", 1, 128)}); err == nil {
+		t.Fatal("Expected LaunchError for used offer.")
+	}
+
+	master.send(&mesos.Event{
+		Type:    mesos.Event_RESCIND.Enum(),
+		Rescind: &mesos.Event_Rescind{OfferId: NewOfferID("offer-2")},
+	})
+	select {
+	case <-received:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.OfferRescinded not called after RESCIND event.")
+	}
+	offerIds = []*mesos.OfferID{NewOfferID("offer-2")}
+	if err = driver.ValidateLaunch(offerIds, []*mesos.TaskInfo{makeLaunchTask("task-2", "slave-1", 1, 128)}); err == nil {
+		t.Fatal("Expected LaunchError for rescinded offer.")
+	}
+}
+
+func TestDriverStart_ValidateLaunchesSetsOfferPool(t *testing.T) {
+	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	driver, err := NewSchedDriver(NewMesosScheduler(), NewFrameworkInfo("test", "test-framework", nil), u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = driver.ValidateLaunch([]*mesos.OfferID{NewOfferID("offer-1")}, nil); err == nil {
+		t.Fatal("Expected LaunchError for offer unknown without OfferPool.")
+	}
+
+	driver.ValidateLaunches = true
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", stat)
+	}
+	defer driver.schedProc.stop()
+	if driver.OfferPool == nil || driver.OfferPool.expiry != 0 {
+		t.Fatal("Expected Start to set an OfferPool for validation, without expiry.")
+	}
+}
@@ -12,12 +12,14 @@ import (
 
 type masterClient struct {
 	address    address
+	scheme     string
 	httpClient http.Client
 }
 
 func newMasterClient(master string) *masterClient {
 	return &masterClient{
 		address: address(master),
+		scheme:  HTTP_SCHEME,
 		httpClient: http.Client{
 			Transport: &http.Transport{
 				Dial: func(netw, addr string) (net.Conn, error) {
@@ -33,11 +35,25 @@ func newMasterClient(master string) *masterClient {
 	}
 }
 
+// useTLS switches the client to https using the given configuration.
+func (client *masterClient) useTLS(config *TLSConfig) {
+	client.scheme = HTTPS_SCHEME
+	client.httpClient.Transport.(*http.Transport).TLSClientConfig = config.clientConfig()
+}
+
 func (client *masterClient) RegisterFramework(schedId schedProcID, framework *mesos.FrameworkInfo) error {
 	regMsg := &mesos.RegisterFrameworkMessage{Framework: framework}
 	return client.send(schedId, buildReqPath(REGISTER_FRAMEWORK_CALL), regMsg)
 }
 
+// ReregisterFramework registers a framework that already has an id. With
+// failover set, the scheduler takes over the framework from a previous,
+// failed scheduler.
+func (client *masterClient) ReregisterFramework(schedId schedProcID, framework *mesos.FrameworkInfo, failover bool) error {
+	msg := &mesos.ReregisterFrameworkMessage{Framework: framework, Failover: proto.Bool(failover)}
+	return client.send(schedId, buildReqPath(REREGISTER_FRAMEWORK_CALL), msg)
+}
+
 func (client *masterClient) UnregisterFramework(schedId schedProcID, frameworkId *mesos.FrameworkID) error {
 	msg := &mesos.UnregisterFrameworkMessage{FrameworkId: frameworkId}
 	return client.send(schedId, buildReqPath(UNREGISTER_FRAMEWORK_CALL), msg)
@@ -48,8 +64,8 @@ func (client *masterClient) DeactivateFramework(schedId schedProcID, frameworkId
 	return client.send(schedId, buildReqPath(DEACTIVATE_FRAMEWORK_CALL), msg)
 }
 
-func (client *masterClient) KillTask(schedId schedProcID, taskId *mesos.TaskID) error {
-	msg := &mesos.KillTaskMessage{TaskId: taskId}
+func (client *masterClient) KillTask(schedId schedProcID, frameworkId *mesos.FrameworkID, taskId *mesos.TaskID) error {
+	msg := &mesos.KillTaskMessage{FrameworkId: frameworkId, TaskId: taskId}
 	return client.send(schedId, buildReqPath(KILL_TASK_CALL), msg)
 }
 
@@ -60,6 +76,9 @@ func (client *masterClient) LaunchTasks(
 	tasks []*mesos.TaskInfo,
 	filters *mesos.Filters,
 ) error {
+	if filters == nil {
+		filters = &mesos.Filters{} // required by the message, the master applies its defaults
+	}
 	msg := &mesos.LaunchTasksMessage{
 		FrameworkId: frameworkId,
 		OfferIds:    offerIds,
@@ -74,6 +93,7 @@ func (client *masterClient) send(from schedProcID, reqPath string, msg proto.Mes
 	if err != nil {
 		return err
 	}
+	u.Scheme = client.scheme
 	u.Path = reqPath
 
 	data, err := proto.Marshal(msg)
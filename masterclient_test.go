@@ -181,12 +181,15 @@ func TestKillTaskMessage(t *testing.T) {
 		if msg.GetTaskId().GetValue() != "test-task-1" {
 			t.Fatal("Got bad TaskID.")
 		}
+		if msg.GetFrameworkId().GetValue() != "test-framework-1" {
+			t.Fatal("Got bad FrameworkID.")
+		}
 	})
 	defer server.Close()
 	url, _ := url.Parse(server.URL)
 	master := newMasterClient(url.Host)
 	taskId := NewTaskID("test-task-1")
-	master.KillTask(newSchedProcID(":7000"), taskId)
+	master.KillTask(newSchedProcID(":7000"), NewFrameworkID("test-framework-1"), taskId)
 }
 
 func TestLaunchTasksMessage(t *testing.T) {
@@ -0,0 +1,404 @@
+// Code generated by protoc-gen-go.
+// source: executor.proto
+// DO NOT EDIT!
+
+package mesosproto
+
+import proto "code.google.com/p/goprotobuf/proto"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = math.Inf
+
+type ExecutorEvent_Type int32
+
+const (
+	ExecutorEvent_SUBSCRIBED   ExecutorEvent_Type = 1
+	ExecutorEvent_LAUNCH       ExecutorEvent_Type = 2
+	ExecutorEvent_KILL         ExecutorEvent_Type = 3
+	ExecutorEvent_ACKNOWLEDGED ExecutorEvent_Type = 4
+	ExecutorEvent_MESSAGE      ExecutorEvent_Type = 5
+	ExecutorEvent_ERROR        ExecutorEvent_Type = 6
+	ExecutorEvent_SHUTDOWN     ExecutorEvent_Type = 7
+	ExecutorEvent_HEARTBEAT    ExecutorEvent_Type = 9
+)
+
+var ExecutorEvent_Type_name = map[int32]string{
+	1: "SUBSCRIBED",
+	2: "LAUNCH",
+	3: "KILL",
+	4: "ACKNOWLEDGED",
+	5: "MESSAGE",
+	6: "ERROR",
+	7: "SHUTDOWN",
+	9: "HEARTBEAT",
+}
+var ExecutorEvent_Type_value = map[string]int32{
+	"SUBSCRIBED":   1,
+	"LAUNCH":       2,
+	"KILL":         3,
+	"ACKNOWLEDGED": 4,
+	"MESSAGE":      5,
+	"ERROR":        6,
+	"SHUTDOWN":     7,
+	"HEARTBEAT":    9,
+}
+
+func (x ExecutorEvent_Type) Enum() *ExecutorEvent_Type {
+	p := new(ExecutorEvent_Type)
+	*p = x
+	return p
+}
+func (x ExecutorEvent_Type) String() string {
+	return proto.EnumName(ExecutorEvent_Type_name, int32(x))
+}
+func (x *ExecutorEvent_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(ExecutorEvent_Type_value, data, "ExecutorEvent_Type")
+	if err != nil {
+		return err
+	}
+	*x = ExecutorEvent_Type(value)
+	return nil
+}
+
+type ExecutorCall_Type int32
+
+const (
+	ExecutorCall_SUBSCRIBE ExecutorCall_Type = 1
+	ExecutorCall_UPDATE    ExecutorCall_Type = 2
+	ExecutorCall_MESSAGE   ExecutorCall_Type = 3
+)
+
+var ExecutorCall_Type_name = map[int32]string{
+	1: "SUBSCRIBE",
+	2: "UPDATE",
+	3: "MESSAGE",
+}
+var ExecutorCall_Type_value = map[string]int32{
+	"SUBSCRIBE": 1,
+	"UPDATE":    2,
+	"MESSAGE":   3,
+}
+
+func (x ExecutorCall_Type) Enum() *ExecutorCall_Type {
+	p := new(ExecutorCall_Type)
+	*p = x
+	return p
+}
+func (x ExecutorCall_Type) String() string {
+	return proto.EnumName(ExecutorCall_Type_name, int32(x))
+}
+func (x *ExecutorCall_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(ExecutorCall_Type_value, data, "ExecutorCall_Type")
+	if err != nil {
+		return err
+	}
+	*x = ExecutorCall_Type(value)
+	return nil
+}
+
+// *
+// Event sent by the agent to a subscribed executor.
+type ExecutorEvent struct {
+	Type             *ExecutorEvent_Type         `protobuf:"varint,1,opt,name=type,enum=mesosproto.ExecutorEvent_Type" json:"type,omitempty"`
+	Subscribed       *ExecutorEvent_Subscribed   `protobuf:"bytes,2,opt,name=subscribed" json:"subscribed,omitempty"`
+	Acknowledged     *ExecutorEvent_Acknowledged `protobuf:"bytes,3,opt,name=acknowledged" json:"acknowledged,omitempty"`
+	Launch           *ExecutorEvent_Launch       `protobuf:"bytes,4,opt,name=launch" json:"launch,omitempty"`
+	Kill             *ExecutorEvent_Kill         `protobuf:"bytes,5,opt,name=kill" json:"kill,omitempty"`
+	Message          *ExecutorEvent_Message      `protobuf:"bytes,6,opt,name=message" json:"message,omitempty"`
+	Error            *ExecutorEvent_Error        `protobuf:"bytes,7,opt,name=error" json:"error,omitempty"`
+	XXX_unrecognized []byte                      `json:"-"`
+}
+
+func (m *ExecutorEvent) Reset()         { *m = ExecutorEvent{} }
+func (m *ExecutorEvent) String() string { return proto.CompactTextString(m) }
+func (*ExecutorEvent) ProtoMessage()    {}
+
+func (m *ExecutorEvent) GetType() ExecutorEvent_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return ExecutorEvent_SUBSCRIBED
+}
+
+func (m *ExecutorEvent) GetSubscribed() *ExecutorEvent_Subscribed {
+	if m != nil {
+		return m.Subscribed
+	}
+	return nil
+}
+
+func (m *ExecutorEvent) GetAcknowledged() *ExecutorEvent_Acknowledged {
+	if m != nil {
+		return m.Acknowledged
+	}
+	return nil
+}
+
+func (m *ExecutorEvent) GetLaunch() *ExecutorEvent_Launch {
+	if m != nil {
+		return m.Launch
+	}
+	return nil
+}
+
+func (m *ExecutorEvent) GetKill() *ExecutorEvent_Kill {
+	if m != nil {
+		return m.Kill
+	}
+	return nil
+}
+
+func (m *ExecutorEvent) GetMessage() *ExecutorEvent_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (m *ExecutorEvent) GetError() *ExecutorEvent_Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type ExecutorEvent_Subscribed struct {
+	ExecutorInfo     *ExecutorInfo  `protobuf:"bytes,1,req,name=executor_info" json:"executor_info,omitempty"`
+	FrameworkInfo    *FrameworkInfo `protobuf:"bytes,2,req,name=framework_info" json:"framework_info,omitempty"`
+	AgentInfo        *SlaveInfo     `protobuf:"bytes,3,req,name=agent_info" json:"agent_info,omitempty"`
+	XXX_unrecognized []byte         `json:"-"`
+}
+
+func (m *ExecutorEvent_Subscribed) Reset()         { *m = ExecutorEvent_Subscribed{} }
+func (m *ExecutorEvent_Subscribed) String() string { return proto.CompactTextString(m) }
+func (*ExecutorEvent_Subscribed) ProtoMessage()    {}
+
+func (m *ExecutorEvent_Subscribed) GetExecutorInfo() *ExecutorInfo {
+	if m != nil {
+		return m.ExecutorInfo
+	}
+	return nil
+}
+
+func (m *ExecutorEvent_Subscribed) GetFrameworkInfo() *FrameworkInfo {
+	if m != nil {
+		return m.FrameworkInfo
+	}
+	return nil
+}
+
+func (m *ExecutorEvent_Subscribed) GetAgentInfo() *SlaveInfo {
+	if m != nil {
+		return m.AgentInfo
+	}
+	return nil
+}
+
+type ExecutorEvent_Launch struct {
+	Task             *TaskInfo `protobuf:"bytes,1,req,name=task" json:"task,omitempty"`
+	XXX_unrecognized []byte    `json:"-"`
+}
+
+func (m *ExecutorEvent_Launch) Reset()         { *m = ExecutorEvent_Launch{} }
+func (m *ExecutorEvent_Launch) String() string { return proto.CompactTextString(m) }
+func (*ExecutorEvent_Launch) ProtoMessage()    {}
+
+func (m *ExecutorEvent_Launch) GetTask() *TaskInfo {
+	if m != nil {
+		return m.Task
+	}
+	return nil
+}
+
+type ExecutorEvent_Kill struct {
+	TaskId           *TaskID `protobuf:"bytes,1,req,name=task_id" json:"task_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *ExecutorEvent_Kill) Reset()         { *m = ExecutorEvent_Kill{} }
+func (m *ExecutorEvent_Kill) String() string { return proto.CompactTextString(m) }
+func (*ExecutorEvent_Kill) ProtoMessage()    {}
+
+func (m *ExecutorEvent_Kill) GetTaskId() *TaskID {
+	if m != nil {
+		return m.TaskId
+	}
+	return nil
+}
+
+type ExecutorEvent_Acknowledged struct {
+	TaskId           *TaskID `protobuf:"bytes,1,req,name=task_id" json:"task_id,omitempty"`
+	Uuid             []byte  `protobuf:"bytes,2,req,name=uuid" json:"uuid,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *ExecutorEvent_Acknowledged) Reset()         { *m = ExecutorEvent_Acknowledged{} }
+func (m *ExecutorEvent_Acknowledged) String() string { return proto.CompactTextString(m) }
+func (*ExecutorEvent_Acknowledged) ProtoMessage()    {}
+
+func (m *ExecutorEvent_Acknowledged) GetTaskId() *TaskID {
+	if m != nil {
+		return m.TaskId
+	}
+	return nil
+}
+
+func (m *ExecutorEvent_Acknowledged) GetUuid() []byte {
+	if m != nil {
+		return m.Uuid
+	}
+	return nil
+}
+
+type ExecutorEvent_Message struct {
+	Data             []byte `protobuf:"bytes,1,req,name=data" json:"data,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *ExecutorEvent_Message) Reset()         { *m = ExecutorEvent_Message{} }
+func (m *ExecutorEvent_Message) String() string { return proto.CompactTextString(m) }
+func (*ExecutorEvent_Message) ProtoMessage()    {}
+
+func (m *ExecutorEvent_Message) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type ExecutorEvent_Error struct {
+	Message          *string `protobuf:"bytes,1,req,name=message" json:"message,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *ExecutorEvent_Error) Reset()         { *m = ExecutorEvent_Error{} }
+func (m *ExecutorEvent_Error) String() string { return proto.CompactTextString(m) }
+func (*ExecutorEvent_Error) ProtoMessage()    {}
+
+func (m *ExecutorEvent_Error) GetMessage() string {
+	if m != nil && m.Message != nil {
+		return *m.Message
+	}
+	return ""
+}
+
+// *
+// Call sent by an executor to the agent.
+type ExecutorCall struct {
+	ExecutorId       *ExecutorID             `protobuf:"bytes,1,req,name=executor_id" json:"executor_id,omitempty"`
+	FrameworkId      *FrameworkID            `protobuf:"bytes,2,req,name=framework_id" json:"framework_id,omitempty"`
+	Type             *ExecutorCall_Type      `protobuf:"varint,3,opt,name=type,enum=mesosproto.ExecutorCall_Type" json:"type,omitempty"`
+	Subscribe        *ExecutorCall_Subscribe `protobuf:"bytes,4,opt,name=subscribe" json:"subscribe,omitempty"`
+	Update           *ExecutorCall_Update    `protobuf:"bytes,5,opt,name=update" json:"update,omitempty"`
+	Message          *ExecutorCall_Message   `protobuf:"bytes,6,opt,name=message" json:"message,omitempty"`
+	XXX_unrecognized []byte                  `json:"-"`
+}
+
+func (m *ExecutorCall) Reset()         { *m = ExecutorCall{} }
+func (m *ExecutorCall) String() string { return proto.CompactTextString(m) }
+func (*ExecutorCall) ProtoMessage()    {}
+
+func (m *ExecutorCall) GetExecutorId() *ExecutorID {
+	if m != nil {
+		return m.ExecutorId
+	}
+	return nil
+}
+
+func (m *ExecutorCall) GetFrameworkId() *FrameworkID {
+	if m != nil {
+		return m.FrameworkId
+	}
+	return nil
+}
+
+func (m *ExecutorCall) GetType() ExecutorCall_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return ExecutorCall_SUBSCRIBE
+}
+
+func (m *ExecutorCall) GetSubscribe() *ExecutorCall_Subscribe {
+	if m != nil {
+		return m.Subscribe
+	}
+	return nil
+}
+
+func (m *ExecutorCall) GetUpdate() *ExecutorCall_Update {
+	if m != nil {
+		return m.Update
+	}
+	return nil
+}
+
+func (m *ExecutorCall) GetMessage() *ExecutorCall_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+type ExecutorCall_Update struct {
+	Status           *TaskStatus `protobuf:"bytes,1,req,name=status" json:"status,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *ExecutorCall_Update) Reset()         { *m = ExecutorCall_Update{} }
+func (m *ExecutorCall_Update) String() string { return proto.CompactTextString(m) }
+func (*ExecutorCall_Update) ProtoMessage()    {}
+
+func (m *ExecutorCall_Update) GetStatus() *TaskStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type ExecutorCall_Subscribe struct {
+	UnacknowledgedTasks   []*TaskInfo            `protobuf:"bytes,1,rep,name=unacknowledged_tasks" json:"unacknowledged_tasks,omitempty"`
+	UnacknowledgedUpdates []*ExecutorCall_Update `protobuf:"bytes,2,rep,name=unacknowledged_updates" json:"unacknowledged_updates,omitempty"`
+	XXX_unrecognized      []byte                 `json:"-"`
+}
+
+func (m *ExecutorCall_Subscribe) Reset()         { *m = ExecutorCall_Subscribe{} }
+func (m *ExecutorCall_Subscribe) String() string { return proto.CompactTextString(m) }
+func (*ExecutorCall_Subscribe) ProtoMessage()    {}
+
+func (m *ExecutorCall_Subscribe) GetUnacknowledgedTasks() []*TaskInfo {
+	if m != nil {
+		return m.UnacknowledgedTasks
+	}
+	return nil
+}
+
+func (m *ExecutorCall_Subscribe) GetUnacknowledgedUpdates() []*ExecutorCall_Update {
+	if m != nil {
+		return m.UnacknowledgedUpdates
+	}
+	return nil
+}
+
+type ExecutorCall_Message struct {
+	Data             []byte `protobuf:"bytes,2,req,name=data" json:"data,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *ExecutorCall_Message) Reset()         { *m = ExecutorCall_Message{} }
+func (m *ExecutorCall_Message) String() string { return proto.CompactTextString(m) }
+func (*ExecutorCall_Message) ProtoMessage()    {}
+
+func (m *ExecutorCall_Message) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("mesosproto.ExecutorEvent_Type", ExecutorEvent_Type_name, ExecutorEvent_Type_value)
+	proto.RegisterEnum("mesosproto.ExecutorCall_Type", ExecutorCall_Type_name, ExecutorCall_Type_value)
+}
@@ -0,0 +1,113 @@
+//go:build ignore
+// +build ignore
+
+// gen_registry generates registry.pb.go, which maps the name of every
+// message declared in the mesosproto .proto files to a constructor.
+// Run with go generate from the mesosproto directory.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var messageDecl = regexp.MustCompile(`^\s*message\s+(\w+)\s*(\{.*)?$`)
+
+func main() {
+	files, err := filepath.Glob("*.proto")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var names []string
+	for _, file := range files {
+		found, err := messageNames(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		names = append(names, found...)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by gen_registry.go.")
+	fmt.Fprintln(&buf, "// source: "+strings.Join(files, ", "))
+	fmt.Fprintln(&buf, "// DO NOT EDIT!")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package mesosproto")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, `import proto "code.google.com/p/goprotobuf/proto"`)
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// MessageTypes maps the name of each message declared in the .proto files")
+	fmt.Fprintln(&buf, "// to a function returning a new instance. Nested messages use dotted names, i.e. Value.Scalar.")
+	fmt.Fprintln(&buf, "var MessageTypes = map[string]func() proto.Message{")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%q: func() proto.Message { return new(%s) },\n", name, strings.Replace(name, ".", "_", -1))
+	}
+	fmt.Fprintln(&buf, "}")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("registry.pb.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// messageNames returns the dotted names of the messages declared in file.
+func messageNames(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	var scopes []string // enclosing message name per open brace, "" for other blocks
+	pending := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		if m := messageDecl.FindStringSubmatch(line); m != nil {
+			pending = m[1]
+		}
+		for _, c := range line {
+			switch c {
+			case '{':
+				scopes = append(scopes, pending)
+				if pending != "" {
+					names = append(names, qualified(scopes))
+				}
+				pending = ""
+			case '}':
+				scopes = scopes[:len(scopes)-1]
+			}
+		}
+	}
+	return names, scanner.Err()
+}
+
+// qualified joins the message names of the enclosing scopes.
+func qualified(scopes []string) string {
+	var parts []string
+	for _, scope := range scopes {
+		if scope == "" {
+			return ""
+		}
+		parts = append(parts, scope)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,3 @@
+package mesosproto
+
+//go:generate go run gen_registry.go
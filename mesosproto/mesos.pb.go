@@ -1176,6 +1176,7 @@ type TaskInfo struct {
 	Executor         *ExecutorInfo `protobuf:"bytes,5,opt,name=executor" json:"executor,omitempty"`
 	Command          *CommandInfo  `protobuf:"bytes,7,opt,name=command" json:"command,omitempty"`
 	Data             []byte        `protobuf:"bytes,6,opt,name=data" json:"data,omitempty"`
+	Labels           *Labels       `protobuf:"bytes,10,opt,name=labels" json:"labels,omitempty"`
 	XXX_unrecognized []byte        `json:"-"`
 }
 
@@ -1232,6 +1233,13 @@ func (m *TaskInfo) GetData() []byte {
 	return nil
 }
 
+func (m *TaskInfo) GetLabels() *Labels {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
 // *
 // Describes the current status of a task.
 type TaskStatus struct {
@@ -1241,6 +1249,7 @@ type TaskStatus struct {
 	Data             []byte     `protobuf:"bytes,3,opt,name=data" json:"data,omitempty"`
 	SlaveId          *SlaveID   `protobuf:"bytes,5,opt,name=slave_id" json:"slave_id,omitempty"`
 	Timestamp        *float64   `protobuf:"fixed64,6,opt,name=timestamp" json:"timestamp,omitempty"`
+	Uuid             []byte     `protobuf:"bytes,11,opt,name=uuid" json:"uuid,omitempty"`
 	XXX_unrecognized []byte     `json:"-"`
 }
 
@@ -1290,6 +1299,13 @@ func (m *TaskStatus) GetTimestamp() float64 {
 	return 0
 }
 
+func (m *TaskStatus) GetUuid() []byte {
+	if m != nil {
+		return m.Uuid
+	}
+	return nil
+}
+
 // *
 // Describes possible filters that can be applied to unused resources
 // (see SchedulerDriver::launchTasks) to influence the allocator.
@@ -1405,6 +1421,50 @@ func (m *Parameters) GetParameter() []*Parameter {
 	return nil
 }
 
+// *
+// A generic (key, value) pair used to tag tasks.
+type Label struct {
+	Key              *string `protobuf:"bytes,1,req,name=key" json:"key,omitempty"`
+	Value            *string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Label) Reset()         { *m = Label{} }
+func (m *Label) String() string { return proto.CompactTextString(m) }
+func (*Label) ProtoMessage()    {}
+
+func (m *Label) GetKey() string {
+	if m != nil && m.Key != nil {
+		return *m.Key
+	}
+	return ""
+}
+
+func (m *Label) GetValue() string {
+	if m != nil && m.Value != nil {
+		return *m.Value
+	}
+	return ""
+}
+
+// *
+// Collection of Label.
+type Labels struct {
+	Labels           []*Label `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Labels) Reset()         { *m = Labels{} }
+func (m *Labels) String() string { return proto.CompactTextString(m) }
+func (*Labels) ProtoMessage()    {}
+
+func (m *Labels) GetLabels() []*Label {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
 // *
 // Credential used for authentication.
 //
@@ -0,0 +1,146 @@
+// Code generated by gen_registry.go.
+// source: executor.proto, log.proto, mesos.proto, message.proto, scheduler.proto, state.proto
+// DO NOT EDIT!
+
+package mesosproto
+
+import proto "code.google.com/p/goprotobuf/proto"
+
+// MessageTypes maps the name of each message declared in the .proto files
+// to a function returning a new instance. Nested messages use dotted names, i.e. Value.Scalar.
+var MessageTypes = map[string]func() proto.Message{
+	"Action":                             func() proto.Message { return new(Action) },
+	"Action.Append":                      func() proto.Message { return new(Action_Append) },
+	"Action.Nop":                         func() proto.Message { return new(Action_Nop) },
+	"Action.Truncate":                    func() proto.Message { return new(Action_Truncate) },
+	"Archive":                            func() proto.Message { return new(Archive) },
+	"Archive.Framework":                  func() proto.Message { return new(Archive_Framework) },
+	"Attribute":                          func() proto.Message { return new(Attribute) },
+	"AuthenticateMessage":                func() proto.Message { return new(AuthenticateMessage) },
+	"AuthenticationCompletedMessage":     func() proto.Message { return new(AuthenticationCompletedMessage) },
+	"AuthenticationErrorMessage":         func() proto.Message { return new(AuthenticationErrorMessage) },
+	"AuthenticationFailedMessage":        func() proto.Message { return new(AuthenticationFailedMessage) },
+	"AuthenticationMechanismsMessage":    func() proto.Message { return new(AuthenticationMechanismsMessage) },
+	"AuthenticationStartMessage":         func() proto.Message { return new(AuthenticationStartMessage) },
+	"AuthenticationStepMessage":          func() proto.Message { return new(AuthenticationStepMessage) },
+	"Call":                               func() proto.Message { return new(Call) },
+	"Call.Accept":                        func() proto.Message { return new(Call_Accept) },
+	"Call.Acknowledge":                   func() proto.Message { return new(Call_Acknowledge) },
+	"Call.Decline":                       func() proto.Message { return new(Call_Decline) },
+	"Call.Kill":                          func() proto.Message { return new(Call_Kill) },
+	"Call.Message":                       func() proto.Message { return new(Call_Message) },
+	"Call.Operation":                     func() proto.Message { return new(Call_Operation) },
+	"Call.Operation.Launch":              func() proto.Message { return new(Call_Operation_Launch) },
+	"Call.Subscribe":                     func() proto.Message { return new(Call_Subscribe) },
+	"CommandInfo":                        func() proto.Message { return new(CommandInfo) },
+	"CommandInfo.URI":                    func() proto.Message { return new(CommandInfo_URI) },
+	"ContainerID":                        func() proto.Message { return new(ContainerID) },
+	"Credential":                         func() proto.Message { return new(Credential) },
+	"DeactivateFrameworkMessage":         func() proto.Message { return new(DeactivateFrameworkMessage) },
+	"Environment":                        func() proto.Message { return new(Environment) },
+	"Environment.Variable":               func() proto.Message { return new(Environment_Variable) },
+	"Event":                              func() proto.Message { return new(Event) },
+	"Event.Error":                        func() proto.Message { return new(Event_Error) },
+	"Event.Failure":                      func() proto.Message { return new(Event_Failure) },
+	"Event.Message":                      func() proto.Message { return new(Event_Message) },
+	"Event.Offers":                       func() proto.Message { return new(Event_Offers) },
+	"Event.Rescind":                      func() proto.Message { return new(Event_Rescind) },
+	"Event.Subscribed":                   func() proto.Message { return new(Event_Subscribed) },
+	"Event.Update":                       func() proto.Message { return new(Event_Update) },
+	"ExecutorCall":                       func() proto.Message { return new(ExecutorCall) },
+	"ExecutorCall.Message":               func() proto.Message { return new(ExecutorCall_Message) },
+	"ExecutorCall.Subscribe":             func() proto.Message { return new(ExecutorCall_Subscribe) },
+	"ExecutorCall.Update":                func() proto.Message { return new(ExecutorCall_Update) },
+	"ExecutorEvent":                      func() proto.Message { return new(ExecutorEvent) },
+	"ExecutorEvent.Acknowledged":         func() proto.Message { return new(ExecutorEvent_Acknowledged) },
+	"ExecutorEvent.Error":                func() proto.Message { return new(ExecutorEvent_Error) },
+	"ExecutorEvent.Kill":                 func() proto.Message { return new(ExecutorEvent_Kill) },
+	"ExecutorEvent.Launch":               func() proto.Message { return new(ExecutorEvent_Launch) },
+	"ExecutorEvent.Message":              func() proto.Message { return new(ExecutorEvent_Message) },
+	"ExecutorEvent.Subscribed":           func() proto.Message { return new(ExecutorEvent_Subscribed) },
+	"ExecutorID":                         func() proto.Message { return new(ExecutorID) },
+	"ExecutorInfo":                       func() proto.Message { return new(ExecutorInfo) },
+	"ExecutorRegisteredMessage":          func() proto.Message { return new(ExecutorRegisteredMessage) },
+	"ExecutorReregisteredMessage":        func() proto.Message { return new(ExecutorReregisteredMessage) },
+	"ExecutorToFrameworkMessage":         func() proto.Message { return new(ExecutorToFrameworkMessage) },
+	"ExitedExecutorMessage":              func() proto.Message { return new(ExitedExecutorMessage) },
+	"Filters":                            func() proto.Message { return new(Filters) },
+	"FrameworkErrorMessage":              func() proto.Message { return new(FrameworkErrorMessage) },
+	"FrameworkExpiredMessage":            func() proto.Message { return new(FrameworkExpiredMessage) },
+	"FrameworkID":                        func() proto.Message { return new(FrameworkID) },
+	"FrameworkInfo":                      func() proto.Message { return new(FrameworkInfo) },
+	"FrameworkRegisteredMessage":         func() proto.Message { return new(FrameworkRegisteredMessage) },
+	"FrameworkReregisteredMessage":       func() proto.Message { return new(FrameworkReregisteredMessage) },
+	"FrameworkToExecutorMessage":         func() proto.Message { return new(FrameworkToExecutorMessage) },
+	"HeartbeatMessage":                   func() proto.Message { return new(HeartbeatMessage) },
+	"KillTaskMessage":                    func() proto.Message { return new(KillTaskMessage) },
+	"Label":                              func() proto.Message { return new(Label) },
+	"Labels":                             func() proto.Message { return new(Labels) },
+	"LaunchTasksMessage":                 func() proto.Message { return new(LaunchTasksMessage) },
+	"LearnedMessage":                     func() proto.Message { return new(LearnedMessage) },
+	"LostSlaveMessage":                   func() proto.Message { return new(LostSlaveMessage) },
+	"MasterInfo":                         func() proto.Message { return new(MasterInfo) },
+	"Metadata":                           func() proto.Message { return new(Metadata) },
+	"Offer":                              func() proto.Message { return new(Offer) },
+	"OfferID":                            func() proto.Message { return new(OfferID) },
+	"Parameter":                          func() proto.Message { return new(Parameter) },
+	"Parameters":                         func() proto.Message { return new(Parameters) },
+	"ProjdReadyMessage":                  func() proto.Message { return new(ProjdReadyMessage) },
+	"ProjdUpdateResourcesMessage":        func() proto.Message { return new(ProjdUpdateResourcesMessage) },
+	"Promise":                            func() proto.Message { return new(Promise) },
+	"PromiseRequest":                     func() proto.Message { return new(PromiseRequest) },
+	"PromiseResponse":                    func() proto.Message { return new(PromiseResponse) },
+	"ReconcileTasksMessage":              func() proto.Message { return new(ReconcileTasksMessage) },
+	"ReconnectExecutorMessage":           func() proto.Message { return new(ReconnectExecutorMessage) },
+	"Record":                             func() proto.Message { return new(Record) },
+	"RecoverRequest":                     func() proto.Message { return new(RecoverRequest) },
+	"RecoverResponse":                    func() proto.Message { return new(RecoverResponse) },
+	"RegisterExecutorMessage":            func() proto.Message { return new(RegisterExecutorMessage) },
+	"RegisterFrameworkMessage":           func() proto.Message { return new(RegisterFrameworkMessage) },
+	"RegisterProjdMessage":               func() proto.Message { return new(RegisterProjdMessage) },
+	"RegisterSlaveMessage":               func() proto.Message { return new(RegisterSlaveMessage) },
+	"Request":                            func() proto.Message { return new(Request) },
+	"ReregisterExecutorMessage":          func() proto.Message { return new(ReregisterExecutorMessage) },
+	"ReregisterFrameworkMessage":         func() proto.Message { return new(ReregisterFrameworkMessage) },
+	"ReregisterSlaveMessage":             func() proto.Message { return new(ReregisterSlaveMessage) },
+	"RescindResourceOfferMessage":        func() proto.Message { return new(RescindResourceOfferMessage) },
+	"Resource":                           func() proto.Message { return new(Resource) },
+	"ResourceOffersMessage":              func() proto.Message { return new(ResourceOffersMessage) },
+	"ResourceRequestMessage":             func() proto.Message { return new(ResourceRequestMessage) },
+	"ResourceStatistics":                 func() proto.Message { return new(ResourceStatistics) },
+	"ResourceUsage":                      func() proto.Message { return new(ResourceUsage) },
+	"ReviveOffersMessage":                func() proto.Message { return new(ReviveOffersMessage) },
+	"RoleInfo":                           func() proto.Message { return new(RoleInfo) },
+	"RunTaskMessage":                     func() proto.Message { return new(RunTaskMessage) },
+	"ShutdownExecutorMessage":            func() proto.Message { return new(ShutdownExecutorMessage) },
+	"ShutdownFrameworkMessage":           func() proto.Message { return new(ShutdownFrameworkMessage) },
+	"ShutdownMessage":                    func() proto.Message { return new(ShutdownMessage) },
+	"SlaveID":                            func() proto.Message { return new(SlaveID) },
+	"SlaveInfo":                          func() proto.Message { return new(SlaveInfo) },
+	"SlaveRegisteredMessage":             func() proto.Message { return new(SlaveRegisteredMessage) },
+	"SlaveReregisteredMessage":           func() proto.Message { return new(SlaveReregisteredMessage) },
+	"StateLogEntry":                      func() proto.Message { return new(StateLogEntry) },
+	"StateSnapshot":                      func() proto.Message { return new(StateSnapshot) },
+	"StatusUpdate":                       func() proto.Message { return new(StatusUpdate) },
+	"StatusUpdateAcknowledgementMessage": func() proto.Message { return new(StatusUpdateAcknowledgementMessage) },
+	"StatusUpdateMessage":                func() proto.Message { return new(StatusUpdateMessage) },
+	"StatusUpdateRecord":                 func() proto.Message { return new(StatusUpdateRecord) },
+	"StoredTask":                         func() proto.Message { return new(StoredTask) },
+	"SubmitSchedulerRequest":             func() proto.Message { return new(SubmitSchedulerRequest) },
+	"SubmitSchedulerResponse":            func() proto.Message { return new(SubmitSchedulerResponse) },
+	"Task":                               func() proto.Message { return new(Task) },
+	"TaskID":                             func() proto.Message { return new(TaskID) },
+	"TaskInfo":                           func() proto.Message { return new(TaskInfo) },
+	"TaskStatus":                         func() proto.Message { return new(TaskStatus) },
+	"UnregisterFrameworkMessage":         func() proto.Message { return new(UnregisterFrameworkMessage) },
+	"UnregisterSlaveMessage":             func() proto.Message { return new(UnregisterSlaveMessage) },
+	"UpdateFrameworkMessage":             func() proto.Message { return new(UpdateFrameworkMessage) },
+	"Value":                              func() proto.Message { return new(Value) },
+	"Value.Range":                        func() proto.Message { return new(Value_Range) },
+	"Value.Ranges":                       func() proto.Message { return new(Value_Ranges) },
+	"Value.Scalar":                       func() proto.Message { return new(Value_Scalar) },
+	"Value.Set":                          func() proto.Message { return new(Value_Set) },
+	"Value.Text":                         func() proto.Message { return new(Value_Text) },
+	"WriteRequest":                       func() proto.Message { return new(WriteRequest) },
+	"WriteResponse":                      func() proto.Message { return new(WriteResponse) },
+}
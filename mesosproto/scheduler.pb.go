@@ -0,0 +1,658 @@
+// Code generated by protoc-gen-go.
+// source: scheduler.proto
+// DO NOT EDIT!
+
+package mesosproto
+
+import proto "code.google.com/p/goprotobuf/proto"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = math.Inf
+
+type Event_Type int32
+
+const (
+	Event_SUBSCRIBED Event_Type = 1
+	Event_OFFERS     Event_Type = 2
+	Event_RESCIND    Event_Type = 3
+	Event_UPDATE     Event_Type = 4
+	Event_MESSAGE    Event_Type = 5
+	Event_FAILURE    Event_Type = 6
+	Event_ERROR      Event_Type = 7
+	Event_HEARTBEAT  Event_Type = 8
+)
+
+var Event_Type_name = map[int32]string{
+	1: "SUBSCRIBED",
+	2: "OFFERS",
+	3: "RESCIND",
+	4: "UPDATE",
+	5: "MESSAGE",
+	6: "FAILURE",
+	7: "ERROR",
+	8: "HEARTBEAT",
+}
+var Event_Type_value = map[string]int32{
+	"SUBSCRIBED": 1,
+	"OFFERS":     2,
+	"RESCIND":    3,
+	"UPDATE":     4,
+	"MESSAGE":    5,
+	"FAILURE":    6,
+	"ERROR":      7,
+	"HEARTBEAT":  8,
+}
+
+func (x Event_Type) Enum() *Event_Type {
+	p := new(Event_Type)
+	*p = x
+	return p
+}
+func (x Event_Type) String() string {
+	return proto.EnumName(Event_Type_name, int32(x))
+}
+func (x *Event_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Event_Type_value, data, "Event_Type")
+	if err != nil {
+		return err
+	}
+	*x = Event_Type(value)
+	return nil
+}
+
+type Call_Type int32
+
+const (
+	Call_SUBSCRIBE   Call_Type = 1
+	Call_TEARDOWN    Call_Type = 2
+	Call_ACCEPT      Call_Type = 3
+	Call_DECLINE     Call_Type = 4
+	Call_REVIVE      Call_Type = 5
+	Call_KILL        Call_Type = 6
+	Call_ACKNOWLEDGE Call_Type = 8
+	Call_MESSAGE     Call_Type = 10
+)
+
+var Call_Type_name = map[int32]string{
+	1:  "SUBSCRIBE",
+	2:  "TEARDOWN",
+	3:  "ACCEPT",
+	4:  "DECLINE",
+	5:  "REVIVE",
+	6:  "KILL",
+	8:  "ACKNOWLEDGE",
+	10: "MESSAGE",
+}
+var Call_Type_value = map[string]int32{
+	"SUBSCRIBE":   1,
+	"TEARDOWN":    2,
+	"ACCEPT":      3,
+	"DECLINE":     4,
+	"REVIVE":      5,
+	"KILL":        6,
+	"ACKNOWLEDGE": 8,
+	"MESSAGE":     10,
+}
+
+func (x Call_Type) Enum() *Call_Type {
+	p := new(Call_Type)
+	*p = x
+	return p
+}
+func (x Call_Type) String() string {
+	return proto.EnumName(Call_Type_name, int32(x))
+}
+func (x *Call_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Call_Type_value, data, "Call_Type")
+	if err != nil {
+		return err
+	}
+	*x = Call_Type(value)
+	return nil
+}
+
+type Call_Operation_Type int32
+
+const (
+	Call_Operation_LAUNCH Call_Operation_Type = 1
+)
+
+var Call_Operation_Type_name = map[int32]string{
+	1: "LAUNCH",
+}
+var Call_Operation_Type_value = map[string]int32{
+	"LAUNCH": 1,
+}
+
+func (x Call_Operation_Type) Enum() *Call_Operation_Type {
+	p := new(Call_Operation_Type)
+	*p = x
+	return p
+}
+func (x Call_Operation_Type) String() string {
+	return proto.EnumName(Call_Operation_Type_name, int32(x))
+}
+func (x *Call_Operation_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Call_Operation_Type_value, data, "Call_Operation_Type")
+	if err != nil {
+		return err
+	}
+	*x = Call_Operation_Type(value)
+	return nil
+}
+
+// *
+// Event sent by the master to a subscribed scheduler.
+type Event struct {
+	Type             *Event_Type       `protobuf:"varint,1,opt,name=type,enum=mesosproto.Event_Type" json:"type,omitempty"`
+	Subscribed       *Event_Subscribed `protobuf:"bytes,2,opt,name=subscribed" json:"subscribed,omitempty"`
+	Offers           *Event_Offers     `protobuf:"bytes,3,opt,name=offers" json:"offers,omitempty"`
+	Rescind          *Event_Rescind    `protobuf:"bytes,4,opt,name=rescind" json:"rescind,omitempty"`
+	Update           *Event_Update     `protobuf:"bytes,5,opt,name=update" json:"update,omitempty"`
+	Message          *Event_Message    `protobuf:"bytes,6,opt,name=message" json:"message,omitempty"`
+	Failure          *Event_Failure    `protobuf:"bytes,7,opt,name=failure" json:"failure,omitempty"`
+	Error            *Event_Error      `protobuf:"bytes,8,opt,name=error" json:"error,omitempty"`
+	XXX_unrecognized []byte            `json:"-"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+
+func (m *Event) GetType() Event_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return Event_SUBSCRIBED
+}
+
+func (m *Event) GetSubscribed() *Event_Subscribed {
+	if m != nil {
+		return m.Subscribed
+	}
+	return nil
+}
+
+func (m *Event) GetOffers() *Event_Offers {
+	if m != nil {
+		return m.Offers
+	}
+	return nil
+}
+
+func (m *Event) GetRescind() *Event_Rescind {
+	if m != nil {
+		return m.Rescind
+	}
+	return nil
+}
+
+func (m *Event) GetUpdate() *Event_Update {
+	if m != nil {
+		return m.Update
+	}
+	return nil
+}
+
+func (m *Event) GetMessage() *Event_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (m *Event) GetFailure() *Event_Failure {
+	if m != nil {
+		return m.Failure
+	}
+	return nil
+}
+
+func (m *Event) GetError() *Event_Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type Event_Subscribed struct {
+	FrameworkId              *FrameworkID `protobuf:"bytes,1,req,name=framework_id" json:"framework_id,omitempty"`
+	HeartbeatIntervalSeconds *float64     `protobuf:"fixed64,2,opt,name=heartbeat_interval_seconds" json:"heartbeat_interval_seconds,omitempty"`
+	MasterInfo               *MasterInfo  `protobuf:"bytes,3,opt,name=master_info" json:"master_info,omitempty"`
+	XXX_unrecognized         []byte       `json:"-"`
+}
+
+func (m *Event_Subscribed) Reset()         { *m = Event_Subscribed{} }
+func (m *Event_Subscribed) String() string { return proto.CompactTextString(m) }
+func (*Event_Subscribed) ProtoMessage()    {}
+
+func (m *Event_Subscribed) GetFrameworkId() *FrameworkID {
+	if m != nil {
+		return m.FrameworkId
+	}
+	return nil
+}
+
+func (m *Event_Subscribed) GetHeartbeatIntervalSeconds() float64 {
+	if m != nil && m.HeartbeatIntervalSeconds != nil {
+		return *m.HeartbeatIntervalSeconds
+	}
+	return 0
+}
+
+func (m *Event_Subscribed) GetMasterInfo() *MasterInfo {
+	if m != nil {
+		return m.MasterInfo
+	}
+	return nil
+}
+
+type Event_Offers struct {
+	Offers           []*Offer `protobuf:"bytes,1,rep,name=offers" json:"offers,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Event_Offers) Reset()         { *m = Event_Offers{} }
+func (m *Event_Offers) String() string { return proto.CompactTextString(m) }
+func (*Event_Offers) ProtoMessage()    {}
+
+func (m *Event_Offers) GetOffers() []*Offer {
+	if m != nil {
+		return m.Offers
+	}
+	return nil
+}
+
+type Event_Rescind struct {
+	OfferId          *OfferID `protobuf:"bytes,1,req,name=offer_id" json:"offer_id,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Event_Rescind) Reset()         { *m = Event_Rescind{} }
+func (m *Event_Rescind) String() string { return proto.CompactTextString(m) }
+func (*Event_Rescind) ProtoMessage()    {}
+
+func (m *Event_Rescind) GetOfferId() *OfferID {
+	if m != nil {
+		return m.OfferId
+	}
+	return nil
+}
+
+type Event_Update struct {
+	Status           *TaskStatus `protobuf:"bytes,1,req,name=status" json:"status,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *Event_Update) Reset()         { *m = Event_Update{} }
+func (m *Event_Update) String() string { return proto.CompactTextString(m) }
+func (*Event_Update) ProtoMessage()    {}
+
+func (m *Event_Update) GetStatus() *TaskStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type Event_Message struct {
+	AgentId          *SlaveID    `protobuf:"bytes,1,req,name=agent_id" json:"agent_id,omitempty"`
+	ExecutorId       *ExecutorID `protobuf:"bytes,2,req,name=executor_id" json:"executor_id,omitempty"`
+	Data             []byte      `protobuf:"bytes,3,req,name=data" json:"data,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *Event_Message) Reset()         { *m = Event_Message{} }
+func (m *Event_Message) String() string { return proto.CompactTextString(m) }
+func (*Event_Message) ProtoMessage()    {}
+
+func (m *Event_Message) GetAgentId() *SlaveID {
+	if m != nil {
+		return m.AgentId
+	}
+	return nil
+}
+
+func (m *Event_Message) GetExecutorId() *ExecutorID {
+	if m != nil {
+		return m.ExecutorId
+	}
+	return nil
+}
+
+func (m *Event_Message) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type Event_Failure struct {
+	AgentId          *SlaveID    `protobuf:"bytes,1,opt,name=agent_id" json:"agent_id,omitempty"`
+	ExecutorId       *ExecutorID `protobuf:"bytes,2,opt,name=executor_id" json:"executor_id,omitempty"`
+	Status           *int32      `protobuf:"varint,3,opt,name=status" json:"status,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *Event_Failure) Reset()         { *m = Event_Failure{} }
+func (m *Event_Failure) String() string { return proto.CompactTextString(m) }
+func (*Event_Failure) ProtoMessage()    {}
+
+func (m *Event_Failure) GetAgentId() *SlaveID {
+	if m != nil {
+		return m.AgentId
+	}
+	return nil
+}
+
+func (m *Event_Failure) GetExecutorId() *ExecutorID {
+	if m != nil {
+		return m.ExecutorId
+	}
+	return nil
+}
+
+func (m *Event_Failure) GetStatus() int32 {
+	if m != nil && m.Status != nil {
+		return *m.Status
+	}
+	return 0
+}
+
+type Event_Error struct {
+	Message          *string `protobuf:"bytes,1,req,name=message" json:"message,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Event_Error) Reset()         { *m = Event_Error{} }
+func (m *Event_Error) String() string { return proto.CompactTextString(m) }
+func (*Event_Error) ProtoMessage()    {}
+
+func (m *Event_Error) GetMessage() string {
+	if m != nil && m.Message != nil {
+		return *m.Message
+	}
+	return ""
+}
+
+// *
+// Call sent by a scheduler to the master.
+type Call struct {
+	FrameworkId      *FrameworkID      `protobuf:"bytes,1,opt,name=framework_id" json:"framework_id,omitempty"`
+	Type             *Call_Type        `protobuf:"varint,2,opt,name=type,enum=mesosproto.Call_Type" json:"type,omitempty"`
+	Subscribe        *Call_Subscribe   `protobuf:"bytes,3,opt,name=subscribe" json:"subscribe,omitempty"`
+	Accept           *Call_Accept      `protobuf:"bytes,4,opt,name=accept" json:"accept,omitempty"`
+	Decline          *Call_Decline     `protobuf:"bytes,5,opt,name=decline" json:"decline,omitempty"`
+	Kill             *Call_Kill        `protobuf:"bytes,6,opt,name=kill" json:"kill,omitempty"`
+	Acknowledge      *Call_Acknowledge `protobuf:"bytes,8,opt,name=acknowledge" json:"acknowledge,omitempty"`
+	Message          *Call_Message     `protobuf:"bytes,10,opt,name=message" json:"message,omitempty"`
+	XXX_unrecognized []byte            `json:"-"`
+}
+
+func (m *Call) Reset()         { *m = Call{} }
+func (m *Call) String() string { return proto.CompactTextString(m) }
+func (*Call) ProtoMessage()    {}
+
+func (m *Call) GetFrameworkId() *FrameworkID {
+	if m != nil {
+		return m.FrameworkId
+	}
+	return nil
+}
+
+func (m *Call) GetType() Call_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return Call_SUBSCRIBE
+}
+
+func (m *Call) GetSubscribe() *Call_Subscribe {
+	if m != nil {
+		return m.Subscribe
+	}
+	return nil
+}
+
+func (m *Call) GetAccept() *Call_Accept {
+	if m != nil {
+		return m.Accept
+	}
+	return nil
+}
+
+func (m *Call) GetDecline() *Call_Decline {
+	if m != nil {
+		return m.Decline
+	}
+	return nil
+}
+
+func (m *Call) GetKill() *Call_Kill {
+	if m != nil {
+		return m.Kill
+	}
+	return nil
+}
+
+func (m *Call) GetAcknowledge() *Call_Acknowledge {
+	if m != nil {
+		return m.Acknowledge
+	}
+	return nil
+}
+
+func (m *Call) GetMessage() *Call_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+type Call_Subscribe struct {
+	FrameworkInfo    *FrameworkInfo `protobuf:"bytes,1,req,name=framework_info" json:"framework_info,omitempty"`
+	XXX_unrecognized []byte         `json:"-"`
+}
+
+func (m *Call_Subscribe) Reset()         { *m = Call_Subscribe{} }
+func (m *Call_Subscribe) String() string { return proto.CompactTextString(m) }
+func (*Call_Subscribe) ProtoMessage()    {}
+
+func (m *Call_Subscribe) GetFrameworkInfo() *FrameworkInfo {
+	if m != nil {
+		return m.FrameworkInfo
+	}
+	return nil
+}
+
+type Call_Operation struct {
+	Type             *Call_Operation_Type   `protobuf:"varint,1,opt,name=type,enum=mesosproto.Call_Operation_Type" json:"type,omitempty"`
+	Launch           *Call_Operation_Launch `protobuf:"bytes,2,opt,name=launch" json:"launch,omitempty"`
+	XXX_unrecognized []byte                 `json:"-"`
+}
+
+func (m *Call_Operation) Reset()         { *m = Call_Operation{} }
+func (m *Call_Operation) String() string { return proto.CompactTextString(m) }
+func (*Call_Operation) ProtoMessage()    {}
+
+func (m *Call_Operation) GetType() Call_Operation_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return Call_Operation_LAUNCH
+}
+
+func (m *Call_Operation) GetLaunch() *Call_Operation_Launch {
+	if m != nil {
+		return m.Launch
+	}
+	return nil
+}
+
+type Call_Operation_Launch struct {
+	TaskInfos        []*TaskInfo `protobuf:"bytes,1,rep,name=task_infos" json:"task_infos,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *Call_Operation_Launch) Reset()         { *m = Call_Operation_Launch{} }
+func (m *Call_Operation_Launch) String() string { return proto.CompactTextString(m) }
+func (*Call_Operation_Launch) ProtoMessage()    {}
+
+func (m *Call_Operation_Launch) GetTaskInfos() []*TaskInfo {
+	if m != nil {
+		return m.TaskInfos
+	}
+	return nil
+}
+
+type Call_Accept struct {
+	OfferIds         []*OfferID        `protobuf:"bytes,1,rep,name=offer_ids" json:"offer_ids,omitempty"`
+	Operations       []*Call_Operation `protobuf:"bytes,2,rep,name=operations" json:"operations,omitempty"`
+	Filters          *Filters          `protobuf:"bytes,3,opt,name=filters" json:"filters,omitempty"`
+	XXX_unrecognized []byte            `json:"-"`
+}
+
+func (m *Call_Accept) Reset()         { *m = Call_Accept{} }
+func (m *Call_Accept) String() string { return proto.CompactTextString(m) }
+func (*Call_Accept) ProtoMessage()    {}
+
+func (m *Call_Accept) GetOfferIds() []*OfferID {
+	if m != nil {
+		return m.OfferIds
+	}
+	return nil
+}
+
+func (m *Call_Accept) GetOperations() []*Call_Operation {
+	if m != nil {
+		return m.Operations
+	}
+	return nil
+}
+
+func (m *Call_Accept) GetFilters() *Filters {
+	if m != nil {
+		return m.Filters
+	}
+	return nil
+}
+
+type Call_Decline struct {
+	OfferIds         []*OfferID `protobuf:"bytes,1,rep,name=offer_ids" json:"offer_ids,omitempty"`
+	Filters          *Filters   `protobuf:"bytes,2,opt,name=filters" json:"filters,omitempty"`
+	XXX_unrecognized []byte     `json:"-"`
+}
+
+func (m *Call_Decline) Reset()         { *m = Call_Decline{} }
+func (m *Call_Decline) String() string { return proto.CompactTextString(m) }
+func (*Call_Decline) ProtoMessage()    {}
+
+func (m *Call_Decline) GetOfferIds() []*OfferID {
+	if m != nil {
+		return m.OfferIds
+	}
+	return nil
+}
+
+func (m *Call_Decline) GetFilters() *Filters {
+	if m != nil {
+		return m.Filters
+	}
+	return nil
+}
+
+type Call_Kill struct {
+	TaskId           *TaskID  `protobuf:"bytes,1,req,name=task_id" json:"task_id,omitempty"`
+	AgentId          *SlaveID `protobuf:"bytes,2,opt,name=agent_id" json:"agent_id,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Call_Kill) Reset()         { *m = Call_Kill{} }
+func (m *Call_Kill) String() string { return proto.CompactTextString(m) }
+func (*Call_Kill) ProtoMessage()    {}
+
+func (m *Call_Kill) GetTaskId() *TaskID {
+	if m != nil {
+		return m.TaskId
+	}
+	return nil
+}
+
+func (m *Call_Kill) GetAgentId() *SlaveID {
+	if m != nil {
+		return m.AgentId
+	}
+	return nil
+}
+
+type Call_Acknowledge struct {
+	AgentId          *SlaveID `protobuf:"bytes,1,req,name=agent_id" json:"agent_id,omitempty"`
+	TaskId           *TaskID  `protobuf:"bytes,2,req,name=task_id" json:"task_id,omitempty"`
+	Uuid             []byte   `protobuf:"bytes,3,req,name=uuid" json:"uuid,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Call_Acknowledge) Reset()         { *m = Call_Acknowledge{} }
+func (m *Call_Acknowledge) String() string { return proto.CompactTextString(m) }
+func (*Call_Acknowledge) ProtoMessage()    {}
+
+func (m *Call_Acknowledge) GetAgentId() *SlaveID {
+	if m != nil {
+		return m.AgentId
+	}
+	return nil
+}
+
+func (m *Call_Acknowledge) GetTaskId() *TaskID {
+	if m != nil {
+		return m.TaskId
+	}
+	return nil
+}
+
+func (m *Call_Acknowledge) GetUuid() []byte {
+	if m != nil {
+		return m.Uuid
+	}
+	return nil
+}
+
+type Call_Message struct {
+	AgentId          *SlaveID    `protobuf:"bytes,1,req,name=agent_id" json:"agent_id,omitempty"`
+	ExecutorId       *ExecutorID `protobuf:"bytes,2,req,name=executor_id" json:"executor_id,omitempty"`
+	Data             []byte      `protobuf:"bytes,3,req,name=data" json:"data,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *Call_Message) Reset()         { *m = Call_Message{} }
+func (m *Call_Message) String() string { return proto.CompactTextString(m) }
+func (*Call_Message) ProtoMessage()    {}
+
+func (m *Call_Message) GetAgentId() *SlaveID {
+	if m != nil {
+		return m.AgentId
+	}
+	return nil
+}
+
+func (m *Call_Message) GetExecutorId() *ExecutorID {
+	if m != nil {
+		return m.ExecutorId
+	}
+	return nil
+}
+
+func (m *Call_Message) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("mesosproto.Event_Type", Event_Type_name, Event_Type_value)
+	proto.RegisterEnum("mesosproto.Call_Type", Call_Type_name, Call_Type_value)
+	proto.RegisterEnum("mesosproto.Call_Operation_Type", Call_Operation_Type_name, Call_Operation_Type_value)
+}
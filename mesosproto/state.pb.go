@@ -0,0 +1,180 @@
+// Code generated by protoc-gen-go.
+// source: state.proto
+// DO NOT EDIT!
+
+package mesosproto
+
+import proto "code.google.com/p/goprotobuf/proto"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = math.Inf
+
+type StateLogEntry_Type int32
+
+const (
+	StateLogEntry_SET_FRAMEWORK_ID StateLogEntry_Type = 1
+	StateLogEntry_SAVE_TASK        StateLogEntry_Type = 2
+	StateLogEntry_REMOVE_TASK      StateLogEntry_Type = 3
+)
+
+var StateLogEntry_Type_name = map[int32]string{
+	1: "SET_FRAMEWORK_ID",
+	2: "SAVE_TASK",
+	3: "REMOVE_TASK",
+}
+var StateLogEntry_Type_value = map[string]int32{
+	"SET_FRAMEWORK_ID": 1,
+	"SAVE_TASK":        2,
+	"REMOVE_TASK":      3,
+}
+
+func (x StateLogEntry_Type) Enum() *StateLogEntry_Type {
+	p := new(StateLogEntry_Type)
+	*p = x
+	return p
+}
+func (x StateLogEntry_Type) String() string {
+	return proto.EnumName(StateLogEntry_Type_name, int32(x))
+}
+func (x *StateLogEntry_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(StateLogEntry_Type_value, data, "StateLogEntry_Type")
+	if err != nil {
+		return err
+	}
+	*x = StateLogEntry_Type(value)
+	return nil
+}
+
+// *
+// A task launched by the framework, with its latest status.
+// Timestamps are in seconds since the epoch.
+type StoredTask struct {
+	Info             *TaskInfo   `protobuf:"bytes,1,req,name=info" json:"info,omitempty"`
+	OfferIds         []*OfferID  `protobuf:"bytes,2,rep,name=offer_ids" json:"offer_ids,omitempty"`
+	State            *TaskState  `protobuf:"varint,3,req,name=state,enum=mesosproto.TaskState" json:"state,omitempty"`
+	Status           *TaskStatus `protobuf:"bytes,4,opt,name=status" json:"status,omitempty"`
+	Launched         *float64    `protobuf:"fixed64,5,opt,name=launched" json:"launched,omitempty"`
+	Updated          *float64    `protobuf:"fixed64,6,opt,name=updated" json:"updated,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *StoredTask) Reset()         { *m = StoredTask{} }
+func (m *StoredTask) String() string { return proto.CompactTextString(m) }
+func (*StoredTask) ProtoMessage()    {}
+
+func (m *StoredTask) GetInfo() *TaskInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *StoredTask) GetOfferIds() []*OfferID {
+	if m != nil {
+		return m.OfferIds
+	}
+	return nil
+}
+
+func (m *StoredTask) GetState() TaskState {
+	if m != nil && m.State != nil {
+		return *m.State
+	}
+	return TaskState_TASK_STAGING
+}
+
+func (m *StoredTask) GetStatus() *TaskStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *StoredTask) GetLaunched() float64 {
+	if m != nil && m.Launched != nil {
+		return *m.Launched
+	}
+	return 0
+}
+
+func (m *StoredTask) GetUpdated() float64 {
+	if m != nil && m.Updated != nil {
+		return *m.Updated
+	}
+	return 0
+}
+
+// *
+// Full state of a framework, written when a log is compacted.
+type StateSnapshot struct {
+	FrameworkId      *FrameworkID  `protobuf:"bytes,1,opt,name=framework_id" json:"framework_id,omitempty"`
+	Tasks            []*StoredTask `protobuf:"bytes,2,rep,name=tasks" json:"tasks,omitempty"`
+	XXX_unrecognized []byte        `json:"-"`
+}
+
+func (m *StateSnapshot) Reset()         { *m = StateSnapshot{} }
+func (m *StateSnapshot) String() string { return proto.CompactTextString(m) }
+func (*StateSnapshot) ProtoMessage()    {}
+
+func (m *StateSnapshot) GetFrameworkId() *FrameworkID {
+	if m != nil {
+		return m.FrameworkId
+	}
+	return nil
+}
+
+func (m *StateSnapshot) GetTasks() []*StoredTask {
+	if m != nil {
+		return m.Tasks
+	}
+	return nil
+}
+
+// *
+// Change appended to a state log. A SET_FRAMEWORK_ID entry without
+// framework_id clears the saved id.
+type StateLogEntry struct {
+	Type             *StateLogEntry_Type `protobuf:"varint,1,req,name=type,enum=mesosproto.StateLogEntry_Type" json:"type,omitempty"`
+	FrameworkId      *FrameworkID        `protobuf:"bytes,2,opt,name=framework_id" json:"framework_id,omitempty"`
+	Task             *StoredTask         `protobuf:"bytes,3,opt,name=task" json:"task,omitempty"`
+	TaskId           *TaskID             `protobuf:"bytes,4,opt,name=task_id" json:"task_id,omitempty"`
+	XXX_unrecognized []byte              `json:"-"`
+}
+
+func (m *StateLogEntry) Reset()         { *m = StateLogEntry{} }
+func (m *StateLogEntry) String() string { return proto.CompactTextString(m) }
+func (*StateLogEntry) ProtoMessage()    {}
+
+func (m *StateLogEntry) GetType() StateLogEntry_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return StateLogEntry_SET_FRAMEWORK_ID
+}
+
+func (m *StateLogEntry) GetFrameworkId() *FrameworkID {
+	if m != nil {
+		return m.FrameworkId
+	}
+	return nil
+}
+
+func (m *StateLogEntry) GetTask() *StoredTask {
+	if m != nil {
+		return m.Task
+	}
+	return nil
+}
+
+func (m *StateLogEntry) GetTaskId() *TaskID {
+	if m != nil {
+		return m.TaskId
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("mesosproto.StateLogEntry_Type", StateLogEntry_Type_name, StateLogEntry_Type_value)
+}
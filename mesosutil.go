@@ -2,7 +2,10 @@ package gomes
 
 import (
 	"code.google.com/p/goprotobuf/proto"
+	"fmt"
 	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"net"
+	"strconv"
 )
 
 func NewValueRange(begin, end uint64) *mesos.Value_Range {
@@ -34,6 +37,26 @@ func NewSetResource(name string, items []string) *mesos.Resource {
 
 }
 
+func NewLabel(key, value string) *mesos.Label {
+	return &mesos.Label{Key: proto.String(key), Value: proto.String(value)}
+}
+
+// TaskLabel returns the value of the label key of task, and whether the
+// task has it.
+func TaskLabel(task *mesos.TaskInfo, key string) (string, bool) {
+	for _, label := range task.GetLabels().GetLabels() {
+		if label.GetKey() == key {
+			return label.GetValue(), true
+		}
+	}
+	return "", false
+}
+
+// NewFilters returns Filters declining unused resources for refuseSeconds.
+func NewFilters(refuseSeconds float64) *mesos.Filters {
+	return &mesos.Filters{RefuseSeconds: proto.Float64(refuseSeconds)}
+}
+
 func NewFrameworkID(id string) *mesos.FrameworkID {
 	return &mesos.FrameworkID{Value: proto.String(id)}
 }
@@ -54,6 +77,25 @@ func NewMasterInfo(id string, ip, port uint32) *mesos.MasterInfo {
 	}
 }
 
+// MasterInfoAddress returns the host:port used to reach the master described by info.
+// The hostname is preferred when present, otherwise the ip field is decoded.
+// Mesos packs the IPv4 address into ip in network byte order.
+func MasterInfoAddress(info *mesos.MasterInfo) (string, error) {
+	if info == nil {
+		return "", fmt.Errorf("Missing MasterInfo.")
+	}
+	port := strconv.Itoa(int(info.GetPort()))
+	if info.GetHostname() != "" {
+		return net.JoinHostPort(info.GetHostname(), port), nil
+	}
+	packed := info.GetIp()
+	if packed == 0 {
+		return "", fmt.Errorf("MasterInfo %s has neither hostname nor ip.", info.GetId())
+	}
+	ip := net.IPv4(byte(packed), byte(packed>>8), byte(packed>>16), byte(packed>>24))
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
 func NewOfferID(id string) *mesos.OfferID {
 	return &mesos.OfferID{Value: proto.String(id)}
 }
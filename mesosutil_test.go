@@ -60,6 +60,13 @@ func TestNewSetResource(t *testing.T) {
 	}
 }
 
+func TestNewFilters(t *testing.T) {
+	filters := NewFilters(30)
+	if filters.GetRefuseSeconds() != 30 {
+		t.Fatal("Protobuf object Filters.RefuseSeconds missing.")
+	}
+}
+
 func TestNewFrameworkID(t *testing.T) {
 	id := NewFrameworkID("test-id")
 	if id == nil {
@@ -106,6 +113,37 @@ func TestNewMasterInfo(t *testing.T) {
 	}
 }
 
+func TestMasterInfoAddress(t *testing.T) {
+	// 127.0.0.1 packed in network byte order
+	addr, err := MasterInfoAddress(NewMasterInfo("master-1", 16777343, 5050))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != "127.0.0.1:5050" {
+		t.Fatal("MasterInfoAddress expected 127.0.0.1:5050, but got", addr)
+	}
+
+	info := NewMasterInfo("master-1", 16777343, 5050)
+	info.Hostname = proto.String("2001:db8::1")
+	addr, err = MasterInfoAddress(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != "[2001:db8::1]:5050" {
+		t.Fatal("MasterInfoAddress expected bracketed IPv6 hostname, but got", addr)
+	}
+
+	info.Hostname = proto.String("mesos-master.local")
+	addr, _ = MasterInfoAddress(info)
+	if addr != "mesos-master.local:5050" {
+		t.Fatal("MasterInfoAddress expected hostname address, but got", addr)
+	}
+
+	if _, err = MasterInfoAddress(NewMasterInfo("master-1", 0, 5050)); err == nil {
+		t.Fatal("MasterInfoAddress expected error for MasterInfo without address.")
+	}
+}
+
 func TestNewOfferID(t *testing.T) {
 	id := NewOfferID("offer-1")
 	if id == nil {
@@ -0,0 +1,38 @@
+package gomes
+
+import (
+	"sync/atomic"
+)
+
+// Metrics is a snapshot of the counters kept by the scheduler process.
+type Metrics struct {
+	// EventsReceived counts events accepted and queued for the driver.
+	EventsReceived uint64
+
+	// SenderMismatches counts events whose libprocess sender is neither the
+	// leading master nor a known slave.
+	SenderMismatches uint64
+
+	// EventsRejected counts events dropped because of a sender mismatch.
+	EventsRejected uint64
+
+	// UnknownMessages counts messages whose name is not in the message registry.
+	UnknownMessages uint64
+}
+
+// procMetrics holds the live counters, updated atomically.
+type procMetrics struct {
+	eventsReceived   uint64
+	senderMismatches uint64
+	eventsRejected   uint64
+	unknownMessages  uint64
+}
+
+func (m *procMetrics) snapshot() Metrics {
+	return Metrics{
+		EventsReceived:   atomic.LoadUint64(&m.eventsReceived),
+		SenderMismatches: atomic.LoadUint64(&m.senderMismatches),
+		EventsRejected:   atomic.LoadUint64(&m.eventsRejected),
+		UnknownMessages:  atomic.LoadUint64(&m.unknownMessages),
+	}
+}
@@ -0,0 +1,240 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"log"
+	"sync"
+	"time"
+)
+
+/*
+OfferPool records the outstanding offers of a framework. Attached to a
+driver, offers are added as they arrive and removed when they are
+rescinded, their slave is lost, or they are launched on or declined:
+
+	pool := NewOfferPool(driver, 30*time.Second)
+	driver.OfferPool = pool
+
+Offers are handed out with Claim and must be given back with Release,
+or removed once used. Offers held longer than the expiry are declined.
+The pool is safe for concurrent use.
+*/
+type OfferPool struct {
+	// Filters are sent with offers declined on expiry. When nil, the
+	// master applies its default filters.
+	Filters *mesos.Filters
+
+	driver *SchedulerDriver
+	expiry time.Duration
+	lock   sync.Mutex
+	offers map[string]*pooledOffer
+	order  []string
+}
+
+type pooledOffer struct {
+	offer   *mesos.Offer
+	claimed bool
+	expired bool
+	timer   *time.Timer
+}
+
+// NewOfferPool returns a pool declining offers through driver once they
+// are held longer than expiry. An expiry of 0 keeps offers until they are
+// removed. Without a driver, expired offers are only dropped from the pool.
+func NewOfferPool(driver *SchedulerDriver, expiry time.Duration) *OfferPool {
+	return &OfferPool{
+		driver: driver,
+		expiry: expiry,
+		offers: make(map[string]*pooledOffer),
+	}
+}
+
+// Add records offers. Offers already in the pool are left unchanged.
+func (pool *OfferPool) Add(offers ...*mesos.Offer) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for _, offer := range offers {
+		id := offer.GetId().GetValue()
+		if _, ok := pool.offers[id]; ok {
+			continue
+		}
+		entry := &pooledOffer{offer: offer}
+		if pool.expiry > 0 {
+			entry.timer = time.AfterFunc(pool.expiry, func() {
+				pool.expire(id)
+			})
+		}
+		pool.offers[id] = entry
+		pool.order = append(pool.order, id)
+	}
+}
+
+// Claim hands out the oldest unclaimed offer accepted by match, or nil
+// when there is none. A nil match accepts any offer.
+func (pool *OfferPool) Claim(match func(*mesos.Offer) bool) *mesos.Offer {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for _, id := range pool.order {
+		entry := pool.offers[id]
+		if entry.claimed || (match != nil && !match(entry.offer)) {
+			continue
+		}
+		entry.claimed = true
+		return entry.offer
+	}
+	return nil
+}
+
+// ClaimOffer hands out the offer with the given id, if it is in the pool
+// and not claimed already.
+func (pool *OfferPool) ClaimOffer(offerId *mesos.OfferID) (*mesos.Offer, bool) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	entry, ok := pool.offers[offerId.GetValue()]
+	if !ok || entry.claimed {
+		return nil, false
+	}
+	entry.claimed = true
+	return entry.offer, true
+}
+
+// Release returns a claimed offer to the pool. An offer that expired while
+// claimed is declined instead.
+func (pool *OfferPool) Release(offerId *mesos.OfferID) {
+	pool.lock.Lock()
+	entry, ok := pool.offers[offerId.GetValue()]
+	if !ok {
+		pool.lock.Unlock()
+		return
+	}
+	entry.claimed = false
+	if !entry.expired {
+		pool.lock.Unlock()
+		return
+	}
+	pool.remove(offerId.GetValue())
+	pool.lock.Unlock()
+	pool.decline(offerId)
+}
+
+// Get returns the offer with the given id, claimed or not.
+func (pool *OfferPool) Get(offerId *mesos.OfferID) (*mesos.Offer, bool) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	entry, ok := pool.offers[offerId.GetValue()]
+	if !ok {
+		return nil, false
+	}
+	return entry.offer, true
+}
+
+// Contains reports whether an offer is still outstanding. Claimers check
+// it to learn whether a claimed offer was rescinded meanwhile.
+func (pool *OfferPool) Contains(offerId *mesos.OfferID) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	_, ok := pool.offers[offerId.GetValue()]
+	return ok
+}
+
+// Remove drops offers from the pool, claimed or not.
+func (pool *OfferPool) Remove(offerIds ...*mesos.OfferID) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for _, id := range offerIds {
+		pool.remove(id.GetValue())
+	}
+}
+
+// RemoveSlave drops the offers of a slave from the pool.
+func (pool *OfferPool) RemoveSlave(slaveId *mesos.SlaveID) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for id, entry := range pool.offers {
+		if entry.offer.GetSlaveId().GetValue() == slaveId.GetValue() {
+			pool.remove(id)
+		}
+	}
+}
+
+// Clear drops every offer, i.e. after the master is lost.
+func (pool *OfferPool) Clear() {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for id := range pool.offers {
+		pool.remove(id)
+	}
+}
+
+// Offers returns the unclaimed offers, oldest first.
+func (pool *OfferPool) Offers() []*mesos.Offer {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	offers := []*mesos.Offer{}
+	for _, id := range pool.order {
+		if entry := pool.offers[id]; !entry.claimed {
+			offers = append(offers, entry.offer)
+		}
+	}
+	return offers
+}
+
+// Expiry returns how long offers are held before they are declined, 0
+// when they are kept until removed.
+func (pool *OfferPool) Expiry() time.Duration {
+	return pool.expiry
+}
+
+// Len returns the number of offers in the pool, claimed or not.
+func (pool *OfferPool) Len() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return len(pool.offers)
+}
+
+// remove must be called with the lock held.
+func (pool *OfferPool) remove(id string) {
+	entry, ok := pool.offers[id]
+	if !ok {
+		return
+	}
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+	delete(pool.offers, id)
+	for i, oid := range pool.order {
+		if oid == id {
+			pool.order = append(pool.order[:i], pool.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// expire declines an offer held past the expiry. Claimed offers are
+// declined when they are released.
+func (pool *OfferPool) expire(id string) {
+	pool.lock.Lock()
+	entry, ok := pool.offers[id]
+	if !ok {
+		pool.lock.Unlock()
+		return
+	}
+	if entry.claimed {
+		entry.expired = true
+		pool.lock.Unlock()
+		return
+	}
+	pool.remove(id)
+	pool.lock.Unlock()
+	pool.decline(entry.offer.GetId())
+}
+
+// decline must be called without the lock held, the driver removes
+// declined offers from the pool.
+func (pool *OfferPool) decline(offerId *mesos.OfferID) {
+	if pool.driver == nil {
+		return
+	}
+	log.Println("Declining expired offer", offerId.GetValue())
+	pool.driver.DeclineOffer(offerId, pool.Filters)
+}
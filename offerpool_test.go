@@ -0,0 +1,229 @@
+package gomes
+
+import (
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOfferPool_ClaimRelease(t *testing.T) {
+	pool := NewOfferPool(nil, 0)
+	pool.Add(makeLaunchOffer("offer-1", "slave-1", 1, 128), makeLaunchOffer("offer-2", "slave-2", 4, 512))
+	pool.Add(makeLaunchOffer("offer-1", "slave-1", 1, 128))
+	if pool.Len() != 2 {
+		t.Fatal("Expected 2 offers in pool, but got", pool.Len())
+	}
+
+	offer := pool.Claim(func(offer *mesos.Offer) bool {
+		return Resources(offer.GetResources()).CPUs() >= 2
+	})
+	if offer.GetId().GetValue() != "offer-2" {
+		t.Fatal("Expected Claim to match offer-2, but got", offer)
+	}
+	if offer = pool.Claim(nil); offer.GetId().GetValue() != "offer-1" {
+		t.Fatal("Expected Claim to return the oldest offer, but got", offer)
+	}
+	if pool.Claim(nil) != nil || len(pool.Offers()) != 0 {
+		t.Fatal("Expected no unclaimed offers left.")
+	}
+	if _, ok := pool.ClaimOffer(NewOfferID("offer-1")); ok {
+		t.Fatal("Expected claimed offer-1 not to be handed out again.")
+	}
+
+	pool.Release(NewOfferID("offer-1"))
+	if offer, ok := pool.ClaimOffer(NewOfferID("offer-1")); !ok || offer.GetId().GetValue() != "offer-1" {
+		t.Fatal("Expected released offer-1 to be claimed again.")
+	}
+
+	pool.Remove(NewOfferID("offer-1"))
+	pool.Release(NewOfferID("offer-1"))
+	if pool.Contains(NewOfferID("offer-1")) || pool.Len() != 1 {
+		t.Fatal("Expected removed offer-1 to stay out of the pool.")
+	}
+	pool.RemoveSlave(NewSlaveID("slave-2"))
+	if pool.Len() != 0 {
+		t.Fatal("Expected offers of lost slave to be removed.")
+	}
+}
+
+func TestOfferPool_ConcurrentClaims(t *testing.T) {
+	pool := NewOfferPool(nil, 0)
+	for i := 0; i < 100; i++ {
+		pool.Add(makeLaunchOffer(fmt.Sprintf("offer-%d", i), "slave-1", 1, 1))
+	}
+
+	var wait sync.WaitGroup
+	claimed := make(chan string, 100)
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for offer := pool.Claim(nil); offer != nil; offer = pool.Claim(nil) {
+				claimed <- offer.GetId().GetValue()
+			}
+		}()
+	}
+	wait.Wait()
+	close(claimed)
+
+	seen := make(map[string]bool)
+	for id := range claimed {
+		if seen[id] {
+			t.Fatal("Offer claimed twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != 100 {
+		t.Fatal("Expected 100 offers claimed, but got", len(seen))
+	}
+}
+
+func TestOfferPool_DriverEvents(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+
+	events := make(chan bool, 3)
+	sched := NewMesosScheduler()
+	sched.ResourceOffers = func(driver *SchedulerDriver, offers []*mesos.Offer) {
+		events <- true
+	}
+	sched.OfferRescinded = func(driver *SchedulerDriver, offerId *mesos.OfferID) {
+		events <- true
+	}
+	sched.SlaveLost = func(driver *SchedulerDriver, slaveId *mesos.SlaveID) {
+		events <- true
+	}
+	driver := startTestHttpDriver(t, sched, master, 10)
+	pool := NewOfferPool(driver, 0)
+	driver.OfferPool = pool
+
+	expectEvent := func(name string) {
+		select {
+		case <-events:
+		case <-time.After(3 * time.Second):
+			t.Fatal("Scheduler callback not called after", name, "event.")
+		}
+	}
+
+	master.send(&mesos.Event{
+		Type: mesos.Event_OFFERS.Enum(),
+		Offers: &mesos.Event_Offers{Offers: []*mesos.Offer{
+			makeLaunchOffer("offer-1", "slave-1", 1, 128),
+			makeLaunchOffer("offer-2", "slave-1", 1, 128),
+			makeLaunchOffer("offer-3", "slave-2", 1, 128),
+			makeLaunchOffer("offer-4", "slave-3", 1, 128),
+		}},
+	})
+	expectEvent("OFFERS")
+	if pool.Len() != 4 {
+		t.Fatal("Expected 4 offers in pool, but got", pool.Len())
+	}
+
+	master.send(&mesos.Event{
+		Type:    mesos.Event_RESCIND.Enum(),
+		Rescind: &mesos.Event_Rescind{OfferId: NewOfferID("offer-3")},
+	})
+	expectEvent("RESCIND")
+	master.send(&mesos.Event{
+		Type:    mesos.Event_FAILURE.Enum(),
+		Failure: &mesos.Event_Failure{AgentId: NewSlaveID("slave-1")},
+	})
+	expectEvent("FAILURE")
+	offers := pool.Offers()
+	if len(offers) != 1 || offers[0].GetId().GetValue() != "offer-4" {
+		t.Fatal("Expected only offer-4 left in pool, but got", offers)
+	}
+
+	driver.DeclineOffer(NewOfferID("offer-4"), nil)
+	master.expectCall(t, mesos.Call_DECLINE)
+	if pool.Len() != 0 {
+		t.Fatal("Expected declined offer to be removed from pool.")
+	}
+}
+
+func TestOfferPool_Expiry(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+
+	received := make(chan bool, 1)
+	sched := NewMesosScheduler()
+	sched.ResourceOffers = func(driver *SchedulerDriver, offers []*mesos.Offer) {
+		received <- true
+	}
+	driver := startTestHttpDriver(t, sched, master, 10)
+	pool := NewOfferPool(driver, 50*time.Millisecond)
+	pool.Filters = NewFilters(60)
+	driver.OfferPool = pool
+
+	master.send(&mesos.Event{
+		Type: mesos.Event_OFFERS.Enum(),
+		Offers: &mesos.Event_Offers{Offers: []*mesos.Offer{
+			makeLaunchOffer("offer-1", "slave-1", 1, 128),
+			makeLaunchOffer("offer-2", "slave-1", 1, 128),
+		}},
+	})
+	select {
+	case <-received:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.ResourceOffers not called after OFFERS event.")
+	}
+	if _, ok := pool.ClaimOffer(NewOfferID("offer-2")); !ok {
+		t.Fatal("Expected offer-2 to be claimed.")
+	}
+
+	call := master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-1" {
+		t.Fatal("Expected expired offer-1 to be declined, but got", call.GetDecline())
+	}
+	if call.GetDecline().GetFilters().GetRefuseSeconds() != 60 {
+		t.Fatal("Expected DECLINE call to carry the pool filters.")
+	}
+	if !pool.Contains(NewOfferID("offer-2")) {
+		t.Fatal("Expected claimed offer-2 to stay in the pool while claimed.")
+	}
+
+	pool.Release(NewOfferID("offer-2"))
+	call = master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-2" {
+		t.Fatal("Expected offer-2 to be declined on release, but got", call.GetDecline())
+	}
+	if pool.Len() != 0 {
+		t.Fatal("Expected empty pool, but got", pool.Len())
+	}
+}
+
+func TestOfferPool_ExpiryOverLibprocess(t *testing.T) {
+	master := makeTestMaster()
+	defer master.close()
+
+	received := make(chan bool, 1)
+	sched := NewMesosScheduler()
+	sched.ResourceOffers = func(driver *SchedulerDriver, offers []*mesos.Offer) {
+		received <- true
+	}
+	driver := startTestDriver(t, sched, master)
+	defer driver.Stop(true)
+	pool := NewOfferPool(driver, 50*time.Millisecond)
+	driver.OfferPool = pool
+
+	driver.schedMsgQ <- &mesos.ResourceOffersMessage{
+		Offers: []*mesos.Offer{makeLaunchOffer("offer-1", "slave-1", 1, 128)},
+	}
+	select {
+	case <-received:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.ResourceOffers not called after ResourceOffersMessage.")
+	}
+
+	// the pool has no filters, the decline must still be a valid message
+	msg := new(mesos.LaunchTasksMessage)
+	master.expectCall(t, LAUNCH_TASKS_CALL, msg)
+	if len(msg.GetOfferIds()) != 1 || msg.GetOfferIds()[0].GetValue() != "offer-1" || len(msg.GetTasks()) != 0 {
+		t.Fatal("Expected expired offer-1 to be declined, but got", msg)
+	}
+	if pool.Len() != 0 {
+		t.Fatal("Expected empty pool, but got", pool.Len())
+	}
+}
@@ -0,0 +1,251 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+ParseResources parses resources in the format used by Mesos operators:
+
+	cpus:4;mem:8192;ports:[31000-32000];disks:{a,b};cpus(prod):2
+
+Values in brackets are ranges, values in braces are sets and other values are
+scalars. A role may follow the name in parentheses, the default role is *.
+Resources with the same name and role are merged.
+*/
+func ParseResources(text string) (Resources, error) {
+	resources := Resources{}
+	for _, token := range strings.Split(text, ";") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		pair := strings.SplitN(token, ":", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("Invalid resource [%s]: expected name:value.", token)
+		}
+		name, role, err := parseResourceName(strings.TrimSpace(pair[0]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid resource [%s]: %s", token, err)
+		}
+		value, err := parseValue(strings.TrimSpace(pair[1]), false)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid resource [%s]: %s", token, err)
+		}
+		resource := &mesos.Resource{
+			Name:   proto.String(name),
+			Type:   value.Type,
+			Scalar: value.Scalar,
+			Ranges: value.Ranges,
+			Set:    value.Set,
+			Role:   proto.String(role),
+		}
+		if resources, err = resources.Add(Resources{resource}); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+/*
+ParseAttributes parses attributes in the format used by Mesos operators:
+
+	rack:r1;zone:us-east-1a;level:10;ids:[1-5];tags:{ssd,gpu}
+
+Numbers are scalars and values that are neither numbers, ranges nor sets
+are text. Text in double quotes, i.e. "10", is read as text whatever it holds.
+*/
+func ParseAttributes(text string) ([]*mesos.Attribute, error) {
+	attributes := []*mesos.Attribute{}
+	for _, token := range splitAttributes(text) {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		pair := strings.SplitN(token, ":", 2)
+		name := strings.TrimSpace(pair[0])
+		if len(pair) != 2 || name == "" {
+			return nil, fmt.Errorf("Invalid attribute [%s]: expected name:value.", token)
+		}
+		value, err := parseValue(strings.TrimSpace(pair[1]), true)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid attribute [%s]: %s", token, err)
+		}
+		attributes = append(attributes, &mesos.Attribute{
+			Name:   proto.String(name),
+			Type:   value.Type,
+			Scalar: value.Scalar,
+			Ranges: value.Ranges,
+			Set:    value.Set,
+			Text:   value.Text,
+		})
+	}
+	return attributes, nil
+}
+
+// FormatResources returns resources in the format read by ParseResources.
+func FormatResources(resources []*mesos.Resource) string {
+	tokens := make([]string, len(resources))
+	for i, resource := range resources {
+		name := resource.GetName()
+		if resource.GetRole() != mesos.Default_Resource_Role {
+			name += "(" + resource.GetRole() + ")"
+		}
+		tokens[i] = name + ":" + formatValue(&mesos.Value{
+			Type:   resource.Type,
+			Scalar: resource.Scalar,
+			Ranges: resource.Ranges,
+			Set:    resource.Set,
+		})
+	}
+	return strings.Join(tokens, ";")
+}
+
+// FormatAttributes returns attributes in the format read by ParseAttributes.
+func FormatAttributes(attributes []*mesos.Attribute) string {
+	tokens := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		tokens[i] = attribute.GetName() + ":" + formatValue(&mesos.Value{
+			Type:   attribute.Type,
+			Scalar: attribute.Scalar,
+			Ranges: attribute.Ranges,
+			Set:    attribute.Set,
+			Text:   attribute.Text,
+		})
+	}
+	return strings.Join(tokens, ";")
+}
+
+// parseResourceName splits name(role) into its name and role.
+func parseResourceName(text string) (string, string, error) {
+	open := strings.Index(text, "(")
+	if open < 0 {
+		if text == "" || strings.ContainsAny(text, ")") {
+			return "", "", fmt.Errorf("invalid name.")
+		}
+		return text, mesos.Default_Resource_Role, nil
+	}
+	if !strings.HasSuffix(text, ")") || open == 0 {
+		return "", "", fmt.Errorf("expected name(role).")
+	}
+	name, role := strings.TrimSpace(text[:open]), strings.TrimSpace(text[open+1:len(text)-1])
+	if err := validateRole(role); err != nil {
+		return "", "", err
+	}
+	return name, role, nil
+}
+
+// parseValue parses ranges, sets and scalars. When text is allowed,
+// quoted values and values that are none of these are returned as TEXT.
+func parseValue(text string, allowText bool) (*mesos.Value, error) {
+	switch {
+	case allowText && len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`):
+		unquoted, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted text %s.", text)
+		}
+		return &mesos.Value{Type: mesos.Value_TEXT.Enum(), Text: &mesos.Value_Text{Value: proto.String(unquoted)}}, nil
+
+	case strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]"):
+		ranges := []*mesos.Value_Range{}
+		for _, token := range splitList(text[1 : len(text)-1]) {
+			bounds := strings.SplitN(token, "-", 2)
+			begin, err := strconv.ParseUint(strings.TrimSpace(bounds[0]), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %s.", token)
+			}
+			end := begin
+			if len(bounds) == 2 {
+				if end, err = strconv.ParseUint(strings.TrimSpace(bounds[1]), 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid range %s.", token)
+				}
+			}
+			if begin > end {
+				return nil, fmt.Errorf("invalid range %s, begin is greater than end.", token)
+			}
+			ranges = append(ranges, NewValueRange(begin, end))
+		}
+		return &mesos.Value{Type: mesos.Value_RANGES.Enum(), Ranges: &mesos.Value_Ranges{Range: ranges}}, nil
+
+	case strings.HasPrefix(text, "{") && strings.HasSuffix(text, "}"):
+		items := splitList(text[1 : len(text)-1])
+		for i, item := range items {
+			if containsString(items[:i], item) {
+				return nil, fmt.Errorf("duplicate item %s.", item)
+			}
+		}
+		return &mesos.Value{Type: mesos.Value_SET.Enum(), Set: &mesos.Value_Set{Item: items}}, nil
+	}
+
+	scalar, err := strconv.ParseFloat(text, 64)
+	if err == nil && !math.IsNaN(scalar) && !math.IsInf(scalar, 0) {
+		return &mesos.Value{Type: mesos.Value_SCALAR.Enum(), Scalar: &mesos.Value_Scalar{Value: proto.Float64(scalar)}}, nil
+	}
+	if !allowText {
+		return nil, fmt.Errorf("invalid scalar %s.", text)
+	}
+	if text == "" || strings.ContainsAny(text, ";[]{}") {
+		return nil, fmt.Errorf("invalid text %s.", text)
+	}
+	return &mesos.Value{Type: mesos.Value_TEXT.Enum(), Text: &mesos.Value_Text{Value: proto.String(text)}}, nil
+}
+
+// splitAttributes splits attributes on semicolons outside of double quotes,
+// so quoted text may hold semicolons.
+func splitAttributes(text string) []string {
+	tokens := []string{}
+	start, quoted := 0, false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && quoted:
+			i++ // escaped character
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			tokens = append(tokens, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(tokens, text[start:])
+}
+
+// splitList splits a comma separated list, trimming items.
+func splitList(text string) []string {
+	items := []string{}
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// formatValue returns value in the format read by parseValue. Text that
+// would not parse back as the same text is quoted.
+func formatValue(value *mesos.Value) string {
+	switch value.GetType() {
+	case mesos.Value_SCALAR:
+		return strconv.FormatFloat(value.GetScalar().GetValue(), 'f', -1, 64)
+	case mesos.Value_RANGES:
+		ranges := make([]string, len(value.GetRanges().GetRange()))
+		for i, r := range value.GetRanges().GetRange() {
+			ranges[i] = fmt.Sprintf("%d-%d", r.GetBegin(), r.GetEnd())
+		}
+		return "[" + strings.Join(ranges, ",") + "]"
+	case mesos.Value_SET:
+		return "{" + strings.Join(value.GetSet().GetItem(), ",") + "}"
+	case mesos.Value_TEXT:
+		text := value.GetText().GetValue()
+		parsed, err := parseValue(text, true)
+		if err != nil || parsed.GetType() != mesos.Value_TEXT || parsed.GetText().GetValue() != text {
+			return strconv.Quote(text)
+		}
+		return text
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"testing"
+)
+
+func TestParseResources(t *testing.T) {
+	resources, err := ParseResources(" cpus:4; mem:8192;ports:[31000-32000, 33000-33000];disks:{a, b};cpus(prod):2.5; ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 5 {
+		t.Fatal("Expected 5 resources, but got", len(resources))
+	}
+	if resources.Filter("*").CPUs() != 4 || resources.Filter("prod").CPUs() != 2.5 || resources.Mem() != 8192 {
+		t.Fatal("Unexpected scalar values in", resources)
+	}
+	checkRanges(t, resources.Ports(), 31000, 32000, 33000, 33000)
+	disks := resources[3]
+	if disks.GetType() != mesos.Value_SET || len(disks.GetSet().GetItem()) != 2 || disks.GetSet().GetItem()[1] != "b" {
+		t.Fatal("Unexpected set resource", disks)
+	}
+
+	merged, err := ParseResources("cpus:1;cpus:2;ports:[1-2];ports:[3-4]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 2 || merged.CPUs() != 3 || len(merged.Ports()) != 1 {
+		t.Fatal("Expected resources with the same name and role to be merged, but got", merged)
+	}
+}
+
+func TestParseResources_Errors(t *testing.T) {
+	invalid := []string{
+		"cpus",
+		"cpus:abc",
+		"cpus:-1",
+		"cpus:NaN",
+		"mem:{a,b}",
+		"ports:[5-1]",
+		"ports:[a-b]",
+		"disks:{a,a}",
+		"cpus(prod:1",
+		"cpus():1",
+		"cpus(a/b):1",
+		"(prod):1",
+		"notes:hello",
+	}
+	for _, text := range invalid {
+		if _, err := ParseResources(text); err == nil {
+			t.Fatal("Expected parse error for", text)
+		}
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	attributes, err := ParseAttributes("rack:r1;zone:us-east-1a;level:10;ids:[1-5];tags:{ssd,gpu};url:http://a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		name  string
+		vtype mesos.Value_Type
+	}{
+		{"rack", mesos.Value_TEXT},
+		{"zone", mesos.Value_TEXT},
+		{"level", mesos.Value_SCALAR},
+		{"ids", mesos.Value_RANGES},
+		{"tags", mesos.Value_SET},
+		{"url", mesos.Value_TEXT},
+	}
+	if len(attributes) != len(expected) {
+		t.Fatal("Expected", len(expected), "attributes, but got", len(attributes))
+	}
+	for i, e := range expected {
+		if attributes[i].GetName() != e.name || attributes[i].GetType() != e.vtype {
+			t.Fatal("Expected attribute", e.name, e.vtype, "but got", attributes[i])
+		}
+	}
+	if attributes[0].GetText().GetValue() != "r1" || attributes[2].GetScalar().GetValue() != 10 {
+		t.Fatal("Unexpected attribute values", attributes)
+	}
+
+	for _, text := range []string{"rack", ":r1", "rack:", "ids:[3-1]"} {
+		if _, err := ParseAttributes(text); err == nil {
+			t.Fatal("Expected parse error for", text)
+		}
+	}
+}
+
+func TestFormatResources_RoundTrip(t *testing.T) {
+	texts := []string{
+		"cpus:4;mem:8192.5;ports:[31000-32000,33000-33000];disks:{a,b}",
+		"cpus(prod):0.25;mem(prod):64",
+		"",
+	}
+	for _, text := range texts {
+		resources, err := ParseResources(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted := FormatResources(resources)
+		if formatted != text {
+			t.Fatalf("Expected %q after round trip, but got %q", text, formatted)
+		}
+		again, err := ParseResources(formatted)
+		if err != nil || !again.Equal(resources) {
+			t.Fatal("Formatted resources do not parse to the same resources:", formatted, err)
+		}
+	}
+}
+
+func TestFormatAttributes_RoundTrip(t *testing.T) {
+	text := `rack:r1;label:"a;b";level:10;weight:0.5;ids:[1-5,7-7];tags:{ssd,gpu}`
+	attributes, err := ParseAttributes(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted := FormatAttributes(attributes); formatted != text {
+		t.Fatalf("Expected %q after round trip, but got %q", text, formatted)
+	}
+
+	// text looking like another type is quoted
+	texts := map[string]string{
+		"10":     `"10"`,
+		"0.5":    `"0.5"`,
+		"[1-2]":  `"[1-2]"`,
+		"{a}":    `"{a}"`,
+		`"r1"`:   `"\"r1\""`,
+		"":       `""`,
+		"a;b":    `"a;b"`,
+		`a\";b`:  `"a\\\";b"`,
+		"rack 1": "rack 1",
+	}
+	for value, expected := range texts {
+		attribute := &mesos.Attribute{
+			Name: proto.String("label"),
+			Type: mesos.Value_TEXT.Enum(),
+			Text: &mesos.Value_Text{Value: proto.String(value)},
+		}
+		formatted := FormatAttributes([]*mesos.Attribute{attribute})
+		if formatted != "label:"+expected {
+			t.Fatalf("Expected text %q formatted as %q, but got %q", value, expected, formatted)
+		}
+		parsed, err := ParseAttributes(formatted)
+		if err != nil || len(parsed) != 1 || parsed[0].GetType() != mesos.Value_TEXT || parsed[0].GetText().GetValue() != value {
+			t.Fatalf("Expected text %q after round trip, but got %v %v", value, parsed, err)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Demand is a task waiting to be placed on an offer. Task holds everything
+// but the slave id, which is set from the offer chosen by the Planner.
+// Constraints are evaluated against the offers of the tasks in Group.
+// Demands of higher Priority are queued first and may preempt tasks.
+// Tenant is the team the demand is accounted to by a DRFQueue. Deadline
+// and the estimated Runtime of the task order demands in an EDFQueue.
+type Demand struct {
+	Task        *mesos.TaskInfo
+	Group       string
+	Constraints Constraints
+	Priority    int
+	Tenant      string
+	Deadline    time.Time
+	Runtime     time.Duration
+
+	// late is set once an EDFQueue reported the demand to Late.
+	late       bool
+	resources  Resources
+	scalars    map[string]float64
+	scalarOnly bool
+	amounts    [3]float64
+}
+
+// packedResources are the resources weighed by Candidate.Leftover.
+var packedResources = [3]string{RESOURCE_CPUS, RESOURCE_MEM, RESOURCE_DISK}
+
+func packedAmounts(resources Resources) [3]float64 {
+	var amounts [3]float64
+	for i, name := range packedResources {
+		amounts[i] = resources.Scalar(name)
+	}
+	return amounts
+}
+
+func NewDemand(task *mesos.TaskInfo) *Demand {
+	return &Demand{Task: task}
+}
+
+// Resources returns the resources of the task, and of its executor.
+func (demand *Demand) Resources() Resources {
+	if demand.resources == nil {
+		resources := Resources(demand.Task.GetResources())
+		if executor := demand.Task.GetExecutor(); executor != nil {
+			if sum, err := resources.Add(Resources(executor.GetResources())); err == nil {
+				resources = sum
+			}
+		}
+		demand.resources = resources
+		demand.scalars, demand.scalarOnly = scalarsByKey(resources)
+		demand.amounts = packedAmounts(resources)
+	}
+	return demand.resources
+}
+
+// scalarsByKey sums the scalar resources by name and role. It also reports
+// whether resources holds scalars only.
+func scalarsByKey(resources Resources) (map[string]float64, bool) {
+	scalars := make(map[string]float64)
+	scalarOnly := true
+	for _, resource := range resources {
+		if resource.GetType() != mesos.Value_SCALAR {
+			scalarOnly = false
+			continue
+		}
+		scalars[resourceKey(resource)] += resource.GetScalar().GetValue()
+	}
+	return scalars, scalarOnly
+}
+
+// Candidate is an offer as seen while planning: the resources left after
+// the tasks placed on it so far.
+type Candidate struct {
+	Offer     *mesos.Offer
+	Remaining Resources
+	Tasks     []*mesos.TaskInfo
+	// HostTasks counts the tasks planned on the host of the offer, across
+	// all its offers.
+	HostTasks int
+	// Violations are the soft affinity rules the demand being placed
+	// would break on the offer.
+	Violations []*AffinityViolation
+
+	scalars   map[string]float64
+	totals    [3]float64
+	left      [3]float64
+	hostTally *int
+}
+
+// Fits reports whether the remaining resources of the candidate hold the
+// demand.
+func (candidate *Candidate) Fits(demand *Demand) bool {
+	resources := demand.Resources()
+	// scalars are compared directly, Contains is comparatively costly
+	for key, value := range demand.scalars {
+		if roundScalar(candidate.scalars[key]-value) < 0 {
+			return false
+		}
+	}
+	return demand.scalarOnly || candidate.Remaining.Contains(resources)
+}
+
+// Leftover returns the fraction of the offered scalar resources that would
+// be left on the candidate after placing demand, averaged over cpus, mem
+// and disk offered.
+func (candidate *Candidate) Leftover(demand *Demand) float64 {
+	demand.Resources()
+	sum, count := 0.0, 0
+	for i, total := range candidate.totals {
+		if total > 0 {
+			sum += (candidate.left[i] - demand.amounts[i]) / total
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+func (candidate *Candidate) place(demand *Demand, task *mesos.TaskInfo) error {
+	remaining, err := candidate.Remaining.Subtract(demand.Resources())
+	if err != nil {
+		return err
+	}
+	candidate.Remaining = remaining
+	candidate.scalars, _ = scalarsByKey(remaining)
+	candidate.left = packedAmounts(remaining)
+	candidate.Tasks = append(candidate.Tasks, task)
+	*candidate.hostTally++
+	return nil
+}
+
+// PlacementStrategy chooses where a demand goes among the candidates it
+// fits on, given in offer order. It returns nil to leave the demand unplaced.
+type PlacementStrategy interface {
+	Select(demand *Demand, fits []*Candidate) *Candidate
+}
+
+type firstFit struct{}
+
+// FirstFit places each demand on the first offer it fits on.
+var FirstFit PlacementStrategy = firstFit{}
+
+func (firstFit) Select(demand *Demand, fits []*Candidate) *Candidate {
+	return fits[0]
+}
+
+type bestFit struct{}
+
+// BestFit packs demands on the offers they leave the least resources of,
+// keeping other offers whole for larger demands.
+var BestFit PlacementStrategy = bestFit{}
+
+func (bestFit) Select(demand *Demand, fits []*Candidate) *Candidate {
+	best, bestLeft := fits[0], fits[0].Leftover(demand)
+	for _, candidate := range fits[1:] {
+		if left := candidate.Leftover(demand); left < bestLeft {
+			best, bestLeft = candidate, left
+		}
+	}
+	return best
+}
+
+type spreadByHost struct{}
+
+// SpreadByHost places demands on the hosts with the fewest planned tasks.
+var SpreadByHost PlacementStrategy = spreadByHost{}
+
+func (spreadByHost) Select(demand *Demand, fits []*Candidate) *Candidate {
+	best := fits[0]
+	for _, candidate := range fits[1:] {
+		if candidate.HostTasks < best.HostTasks {
+			best = candidate
+		}
+	}
+	return best
+}
+
+type randomFit struct {
+	rand *rand.Rand
+}
+
+// NewRandomFit returns a strategy placing demands on a random offer they
+// fit on. Plans are repeatable for a given seed.
+func NewRandomFit(seed int64) PlacementStrategy {
+	return &randomFit{rand: rand.New(rand.NewSource(seed))}
+}
+
+func (strategy *randomFit) Select(demand *Demand, fits []*Candidate) *Candidate {
+	return fits[strategy.rand.Intn(len(fits))]
+}
+
+// Launch is the tasks planned on an offer.
+type Launch struct {
+	Offer *mesos.Offer
+	Tasks []*mesos.TaskInfo
+}
+
+// Plan is the outcome of a placement round. Offers left without tasks are
+// listed in Unused, demands that could not be placed in Unplaced. Soft
+// affinity rules broken by the planned tasks are listed in Violations.
+type Plan struct {
+	Launches   []*Launch
+	Unused     []*mesos.Offer
+	Unplaced   []*Demand
+	Violations []*AffinityViolation
+}
+
+// Execute launches the planned tasks and declines the unused offers. A
+// failed launch does not stop the others, its offer is declined instead.
+// The first error met is returned once every offer was handled.
+func (plan *Plan) Execute(driver *SchedulerDriver, filters *mesos.Filters) error {
+	var first error
+	decline := func(offer *mesos.Offer) {
+		if _, err := driver.LaunchTasksErr([]*mesos.OfferID{offer.GetId()}, []*mesos.TaskInfo{}, filters); err != nil && first == nil {
+			first = err
+		}
+	}
+	for _, launch := range plan.Launches {
+		if _, err := driver.LaunchTasksErr([]*mesos.OfferID{launch.Offer.GetId()}, launch.Tasks, filters); err != nil {
+			log.Println("Unable to launch planned tasks on offer", launch.Offer.GetId().GetValue(), ":", err)
+			if first == nil {
+				first = err
+			}
+			decline(launch.Offer)
+		}
+	}
+	for _, offer := range plan.Unused {
+		decline(offer)
+	}
+	return first
+}
+
+/*
+Planner matches demands to offers. Demands are placed in order, each on
+the offer picked by Strategy among the offers it fits on; the resources
+of the task and its executor are taken from the offer. Planning is
+deterministic for given offers, demands and strategy.
+
+Offers that break the constraints of a demand are left out, and demands
+with invalid constraints are left unplaced. Placed holds
+the offers tasks of each group were placed on before, i.e. in earlier
+plans, one entry per task still running.
+
+When Affinity is set, offers breaking its hard rules are left out too,
+and the offers breaking the fewest soft rules are preferred. The tasks
+already running must have been added to it.
+
+	plan := NewPlanner(BestFit).Plan(offers, demands)
+	plan.Execute(driver, nil)
+*/
+type Planner struct {
+	Strategy PlacementStrategy
+	Placed   map[string][]*mesos.Offer
+	Affinity *AffinityEvaluator
+}
+
+func NewPlanner(strategy PlacementStrategy) *Planner {
+	return &Planner{Strategy: strategy}
+}
+
+func (planner *Planner) Plan(offers []*mesos.Offer, demands []*Demand) *Plan {
+	candidates := planner.candidates(offers)
+	plan := &Plan{}
+	fits := make([]*Candidate, 0, len(candidates))
+	groups := make(map[string]*ConstraintEvaluator)
+	var affinity *AffinityEvaluator
+	if planner.Affinity != nil {
+		affinity = planner.Affinity.clone()
+		for _, offer := range offers {
+			affinity.AddSlave(offer)
+		}
+	}
+	for _, demand := range demands {
+		constraints, err := demand.Constraints.compile()
+		if err != nil {
+			log.Println("Unable to place task", demand.Task.GetTaskId().GetValue(), ":", err)
+			plan.Unplaced = append(plan.Unplaced, demand)
+			continue
+		}
+		group, ok := groups[demand.Group]
+		if !ok {
+			group = NewConstraintEvaluator(planner.Placed[demand.Group])
+			groups[demand.Group] = group
+		}
+		fits = fits[:0]
+		for _, candidate := range candidates {
+			if candidate.Fits(demand) && group.Satisfied(candidate.Offer, constraints) {
+				fits = append(fits, candidate)
+			}
+		}
+		if affinity != nil {
+			fits = affinity.filter(demand.Task, fits)
+		}
+		chosen := planner.place(demand, fits)
+		if chosen == nil {
+			plan.Unplaced = append(plan.Unplaced, demand)
+			continue
+		}
+		group.Place(chosen.Offer)
+		if affinity != nil {
+			affinity.AddTask(chosen.Tasks[len(chosen.Tasks)-1])
+			plan.Violations = append(plan.Violations, chosen.Violations...)
+		}
+	}
+
+	for _, candidate := range candidates {
+		if len(candidate.Tasks) == 0 {
+			plan.Unused = append(plan.Unused, candidate.Offer)
+		} else {
+			plan.Launches = append(plan.Launches, &Launch{Offer: candidate.Offer, Tasks: candidate.Tasks})
+		}
+	}
+	return plan
+}
+
+func (planner *Planner) candidates(offers []*mesos.Offer) []*Candidate {
+	candidates := make([]*Candidate, 0, len(offers))
+	hosts := make(map[string]*int)
+	for _, offer := range offers {
+		resources := Resources(offer.GetResources())
+		scalars, _ := scalarsByKey(resources)
+		tally, ok := hosts[offer.GetHostname()]
+		if !ok {
+			tally = new(int)
+			hosts[offer.GetHostname()] = tally
+		}
+		candidates = append(candidates, &Candidate{
+			Offer:     offer,
+			Remaining: resources,
+			scalars:   scalars,
+			totals:    packedAmounts(resources),
+			left:      packedAmounts(resources),
+			hostTally: tally,
+		})
+	}
+	return candidates
+}
+
+// place puts demand on the candidate selected among fits, which it returns.
+func (planner *Planner) place(demand *Demand, fits []*Candidate) *Candidate {
+	if len(fits) == 0 {
+		return nil
+	}
+	for _, candidate := range fits {
+		candidate.HostTasks = *candidate.hostTally
+	}
+	chosen := planner.Strategy.Select(demand, fits)
+	if chosen == nil {
+		return nil
+	}
+
+	task := proto.Clone(demand.Task).(*mesos.TaskInfo)
+	task.SlaveId = chosen.Offer.GetSlaveId()
+	if err := chosen.place(demand, task); err != nil {
+		log.Printf("Demand %s does not fit on selected offer: %s", task.GetTaskId().GetValue(), err)
+		return nil
+	}
+	return chosen
+}
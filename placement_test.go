@@ -0,0 +1,249 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"testing"
+)
+
+func makePlacementOffers(count int, cpus, mem float64) []*mesos.Offer {
+	offers := make([]*mesos.Offer, count)
+	for i := range offers {
+		offers[i] = makeLaunchOffer(fmt.Sprintf("offer-%d", i), fmt.Sprintf("slave-%d", i), cpus, mem)
+		offers[i].Hostname = proto.String(fmt.Sprintf("host-%d", i))
+	}
+	return offers
+}
+
+func makeDemands(count int, cpus, mem float64) []*Demand {
+	demands := make([]*Demand, count)
+	for i := range demands {
+		task := makeLaunchTask(fmt.Sprintf("task-%d", i), "", cpus, mem)
+		task.SlaveId = nil
+		demands[i] = NewDemand(task)
+	}
+	return demands
+}
+
+// planSummary maps each planned task id to the offer it was placed on.
+func planSummary(plan *Plan) map[string]string {
+	summary := make(map[string]string)
+	for _, launch := range plan.Launches {
+		for _, task := range launch.Tasks {
+			summary[task.GetTaskId().GetValue()] = launch.Offer.GetId().GetValue()
+		}
+	}
+	return summary
+}
+
+func TestPlanner_FirstFit(t *testing.T) {
+	offers := makePlacementOffers(3, 4, 4096)
+	plan := NewPlanner(FirstFit).Plan(offers, makeDemands(5, 1, 512))
+	summary := planSummary(plan)
+	for i := 0; i < 4; i++ {
+		if summary[fmt.Sprintf("task-%d", i)] != "offer-0" {
+			t.Fatal("Expected first 4 tasks on offer-0, but got", summary)
+		}
+	}
+	if summary["task-4"] != "offer-1" {
+		t.Fatal("Expected task-4 on offer-1, but got", summary)
+	}
+	if len(plan.Unused) != 1 || plan.Unused[0].GetId().GetValue() != "offer-2" || len(plan.Unplaced) != 0 {
+		t.Fatal("Expected offer-2 unused and every task placed, but got", plan.Unused, plan.Unplaced)
+	}
+
+	task := plan.Launches[0].Tasks[0]
+	if task.GetSlaveId().GetValue() != "slave-0" {
+		t.Fatal("Expected planned task on slave-0, but got", task.GetSlaveId())
+	}
+	if err := ValidateLaunch([]*mesos.Offer{plan.Launches[0].Offer}, plan.Launches[0].Tasks); err != nil {
+		t.Fatal("Expected valid launch from plan, but got", err)
+	}
+}
+
+func TestPlanner_BestFit(t *testing.T) {
+	offers := []*mesos.Offer{
+		makeLaunchOffer("offer-large", "slave-1", 8, 8192),
+		makeLaunchOffer("offer-small", "slave-2", 2, 2048),
+	}
+	demands := makeDemands(2, 2, 2048)
+	demands = append(demands, NewDemand(makeLaunchTask("task-big", "", 6, 6144)))
+	plan := NewPlanner(BestFit).Plan(offers, demands)
+	summary := planSummary(plan)
+	if summary["task-0"] != "offer-small" || summary["task-1"] != "offer-large" || summary["task-big"] != "offer-large" {
+		t.Fatal("Expected best fit to keep the large offer for the big task, but got", summary)
+	}
+
+	plan = NewPlanner(FirstFit).Plan(offers, demands)
+	if len(plan.Unplaced) != 1 || plan.Unplaced[0].Task.GetTaskId().GetValue() != "task-big" {
+		t.Fatal("Expected first fit to leave task-big unplaced, but got", plan.Unplaced)
+	}
+}
+
+func TestPlanner_SpreadByHost(t *testing.T) {
+	offers := makePlacementOffers(3, 8, 8192)
+	// a second offer from host-0
+	offers = append(offers, makeLaunchOffer("offer-3", "slave-0", 8, 8192))
+	offers[3].Hostname = proto.String("host-0")
+
+	plan := NewPlanner(SpreadByHost).Plan(offers, makeDemands(6, 1, 512))
+	hosts := make(map[string]int)
+	for _, launch := range plan.Launches {
+		hosts[launch.Offer.GetHostname()] += len(launch.Tasks)
+	}
+	if hosts["host-0"] != 2 || hosts["host-1"] != 2 || hosts["host-2"] != 2 {
+		t.Fatal("Expected 2 tasks per host, but got", hosts)
+	}
+}
+
+func TestPlanner_RandomFit(t *testing.T) {
+	offers := makePlacementOffers(10, 100, 102400)
+	demands := makeDemands(50, 1, 512)
+	plan1 := planSummary(NewPlanner(NewRandomFit(42)).Plan(offers, demands))
+	plan2 := planSummary(NewPlanner(NewRandomFit(42)).Plan(offers, demands))
+	plan3 := planSummary(NewPlanner(NewRandomFit(7)).Plan(offers, demands))
+	same, differ := true, false
+	for id, offer := range plan1 {
+		same = same && plan2[id] == offer
+		differ = differ || plan3[id] != offer
+	}
+	if len(plan1) != 50 || !same {
+		t.Fatal("Expected identical plans for the same seed.")
+	}
+	if !differ {
+		t.Fatal("Expected different plans for different seeds.")
+	}
+}
+
+func TestPlanner_Resources(t *testing.T) {
+	offer := makeLaunchOffer("offer-1", "slave-1", 4, 4096)
+	offer.Resources = append(offer.Resources, NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(8000, 8001)}))
+
+	withExecutor := makeLaunchTask("task-exec", "", 1, 512)
+	withExecutor.Command = nil
+	withExecutor.Executor = &mesos.ExecutorInfo{
+		ExecutorId: NewExecutorID("exec-1"),
+		Resources:  []*mesos.Resource{NewScalarResource("cpus", 2.5)},
+	}
+	withPort := makeLaunchTask("task-port", "", 0.5, 128)
+	withPort.Resources = append(withPort.Resources, NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(8001, 8001)}))
+	otherPort := makeLaunchTask("task-port2", "", 0.1, 128)
+	otherPort.Resources = append(otherPort.Resources, NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(8001, 8001)}))
+
+	demands := []*Demand{NewDemand(withExecutor), NewDemand(withPort), NewDemand(otherPort), NewDemand(makeLaunchTask("task-cpu", "", 1, 128))}
+	plan := NewPlanner(FirstFit).Plan([]*mesos.Offer{offer}, demands)
+	summary := planSummary(plan)
+	if summary["task-exec"] != "offer-1" || summary["task-port"] != "offer-1" {
+		t.Fatal("Expected task-exec and task-port placed, but got", summary)
+	}
+	if len(plan.Unplaced) != 2 {
+		t.Fatal("Expected port conflict and exhausted cpus to leave 2 demands unplaced, but got", plan.Unplaced)
+	}
+	if withExecutor.GetSlaveId().GetValue() != "" {
+		t.Fatal("Expected demand task to be left unchanged.")
+	}
+}
+
+func TestPlan_Execute(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+
+	plan := NewPlanner(FirstFit).Plan(makePlacementOffers(2, 4, 4096), makeDemands(2, 1, 512))
+	if err := plan.Execute(driver, nil); err != nil {
+		t.Fatal(err)
+	}
+	call := master.expectCall(t, mesos.Call_ACCEPT)
+	if call.GetAccept().GetOfferIds()[0].GetValue() != "offer-0" || len(call.GetAccept().GetOperations()[0].GetLaunch().GetTaskInfos()) != 2 {
+		t.Fatal("Expected both tasks launched on offer-0, but got", call.GetAccept())
+	}
+	call = master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-1" {
+		t.Fatal("Expected unused offer-1 declined, but got", call.GetDecline())
+	}
+}
+
+func TestPlan_ExecuteFailedLaunch(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+
+	// offer-0 is unknown to the pool, so its launch is rejected
+	offers := makePlacementOffers(3, 4, 4096)
+	driver.ValidateLaunches = true
+	driver.OfferPool = NewOfferPool(nil, 0)
+	driver.OfferPool.Add(offers[1])
+
+	plan := NewPlanner(FirstFit).Plan(offers, makeDemands(2, 3, 512))
+	err := plan.Execute(driver, nil)
+	if _, ok := err.(*LaunchError); !ok {
+		t.Fatal("Expected a LaunchError, but got", err)
+	}
+	call := master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-0" {
+		t.Fatal("Expected offer-0 of the failed launch declined, but got", call.GetDecline())
+	}
+	call = master.expectCall(t, mesos.Call_ACCEPT)
+	if call.GetAccept().GetOfferIds()[0].GetValue() != "offer-1" {
+		t.Fatal("Expected launch on offer-1 to go on, but got", call.GetAccept())
+	}
+	call = master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-2" {
+		t.Fatal("Expected unused offer-2 declined, but got", call.GetDecline())
+	}
+}
+
+func TestPlan_ExecuteOverLibprocess(t *testing.T) {
+	master := makeTestMaster()
+	defer master.close()
+	driver := startTestDriver(t, NewMesosScheduler(), master)
+	defer driver.Stop(true)
+
+	plan := NewPlanner(FirstFit).Plan(makePlacementOffers(2, 4, 4096), makeDemands(2, 1, 512))
+	if err := plan.Execute(driver, nil); err != nil {
+		t.Fatal(err)
+	}
+	msg := new(mesos.LaunchTasksMessage)
+	master.expectCall(t, LAUNCH_TASKS_CALL, msg)
+	if msg.GetOfferIds()[0].GetValue() != "offer-0" || len(msg.GetTasks()) != 2 {
+		t.Fatal("Expected both tasks launched on offer-0, but got", msg)
+	}
+	msg = new(mesos.LaunchTasksMessage)
+	master.expectCall(t, LAUNCH_TASKS_CALL, msg)
+	if msg.GetOfferIds()[0].GetValue() != "offer-1" || len(msg.GetTasks()) != 0 {
+		t.Fatal("Expected unused offer-1 declined, but got", msg)
+	}
+}
+
+func BenchmarkPlanner_BestFit(b *testing.B) {
+	offers := makePlacementOffers(200, 32, 65536)
+	for i := 0; i < b.N; i++ {
+		NewPlanner(BestFit).Plan(offers, makeDemands(5000, 1, 1024))
+	}
+}
+
+func BenchmarkPlanner_Affinity(b *testing.B) {
+	offers := makePlacementOffers(200, 32, 65536)
+	for i, offer := range offers {
+		offer.Attributes, _ = ParseAttributes(fmt.Sprintf("rack:r%d", i%10))
+	}
+	web := map[string]string{"app": "web"}
+	evaluator := NewAffinityEvaluator(
+		&AffinityRule{Selector: web, Target: web, Scope: "rack", Anti: true, MaxPer: 400},
+		&AffinityRule{Selector: web, Target: web, Scope: HOSTNAME_FIELD, Anti: true, MaxPer: 10, Soft: true},
+	)
+	for i := 0; i < 2000; i++ {
+		evaluator.AddTask(makeLabeledTask(fmt.Sprintf("running-%d", i), fmt.Sprintf("slave-%d", i%200), "app", "web"))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		demands := makeDemands(1000, 1, 1024)
+		for _, demand := range demands {
+			demand.Task.Labels = &mesos.Labels{Labels: []*mesos.Label{NewLabel("app", "web")}}
+		}
+		planner := NewPlanner(BestFit)
+		planner.Affinity = evaluator
+		planner.Plan(offers, demands)
+	}
+}
@@ -0,0 +1,488 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PRIORITY_LABEL is the task label read by TaskPriority.
+const PRIORITY_LABEL = "priority"
+
+// DEFAULT_RESERVATION_TIMEOUT bounds how long the resources freed by a
+// preemption are held for the waiting demand, unless set otherwise.
+const DEFAULT_RESERVATION_TIMEOUT = 30 * time.Second
+
+// TaskPriority returns the priority label of task, 0 when it has none.
+func TaskPriority(task *mesos.TaskInfo) int {
+	value, ok := TaskLabel(task, PRIORITY_LABEL)
+	if !ok {
+		return 0
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return priority
+}
+
+// PreemptionPolicy configures which tasks a Preemptor kills.
+type PreemptionPolicy struct {
+	// Priority returns the priority of running tasks the Preemptor did not
+	// launch itself, TaskPriority when nil.
+	Priority func(task *mesos.TaskInfo) int
+	// Margin is how much the priority of a demand must exceed the
+	// priority of its victims, beyond being higher.
+	Margin int
+	// MaxVictims bounds the tasks killed for a demand, 0 for no bound.
+	MaxVictims int
+	// PreferOldest kills the oldest of the tasks of equal priority rather
+	// than the youngest, which lose the least work.
+	PreferOldest bool
+	// ReservationTimeout bounds how long freed resources are held for the
+	// demand, DEFAULT_RESERVATION_TIMEOUT when 0.
+	ReservationTimeout time.Duration
+}
+
+/*
+Preemptor places queued demands on offers and, when a demand does not
+fit, kills lower priority tasks to make room. The victims are chosen on
+a single slave, lowest priority first and then by age, as few as needed
+for the demand to fit with the offers of the slave. Offers of the slave
+are then reserved for the demand until the freed resources come back,
+and the victims are queued again once they are killed. Victims failing
+to be killed, or not killed before the reservation expires, are not
+queued again:
+
+	preemptor, err := NewPreemptor(driver, PreemptionPolicy{MaxVictims: 4})
+	preemptor.Submit(&Demand{Task: task, Priority: 10})
+
+	// in the Scheduler callbacks
+	preemptor.ResourceOffers(offers)
+	preemptor.StatusUpdate(status)
+	preemptor.OfferRescinded(offerId)
+	preemptor.SlaveLost(slaveId)
+
+Running tasks are taken from driver.Tasks. Offers not used nor reserved
+are declined.
+*/
+type Preemptor struct {
+	Planner *Planner
+	Queue   DemandQueue
+	Policy  PreemptionPolicy
+	// Filters are sent with launches and declined offers.
+	Filters *mesos.Filters
+	// Preempted, when set, is called with the tasks killed for a demand.
+	Preempted func(demand *Demand, victims []TaskRecord)
+
+	driver       *SchedulerDriver
+	lock         sync.Mutex
+	launched     map[string]*Demand
+	victims      map[string]*Demand
+	reservations map[string]*reservation
+}
+
+// reservation holds the offers of a slave for a demand, while its victims
+// are being killed.
+type reservation struct {
+	demand  *Demand
+	slaveId string
+	offers  []*mesos.Offer
+	victims []string
+	timer   *time.Timer
+}
+
+// preemptorLaunch is a launch to make once the lock is released.
+type preemptorLaunch struct {
+	offerIds []*mesos.OfferID
+	demands  []*Demand
+	tasks    []*mesos.TaskInfo
+}
+
+// preemption is a kill of victims to make once the lock is released.
+type preemption struct {
+	demand  *Demand
+	victims []TaskRecord
+	held    *reservation
+}
+
+func NewPreemptor(driver *SchedulerDriver, policy PreemptionPolicy) (*Preemptor, error) {
+	if driver.Tasks == nil {
+		return nil, fmt.Errorf("Preemptor needs the task registry of the driver.")
+	}
+	return &Preemptor{
+		Planner:      NewPlanner(BestFit),
+		Queue:        NewPriorityQueue(),
+		Policy:       policy,
+		driver:       driver,
+		launched:     make(map[string]*Demand),
+		victims:      make(map[string]*Demand),
+		reservations: make(map[string]*reservation),
+	}, nil
+}
+
+// Submit queues demands until offers are received.
+func (preemptor *Preemptor) Submit(demands ...*Demand) {
+	preemptor.lock.Lock()
+	defer preemptor.lock.Unlock()
+	preemptor.Queue.Push(demands...)
+}
+
+// Pending returns the queued demands, not counting the reserved ones.
+func (preemptor *Preemptor) Pending() []*Demand {
+	preemptor.lock.Lock()
+	defer preemptor.lock.Unlock()
+	return preemptor.Queue.Demands()
+}
+
+// ResourceOffers launches the reserved and queued demands fitting on
+// offers, and preempts tasks for the queued demands that do not fit.
+func (preemptor *Preemptor) ResourceOffers(offers []*mesos.Offer) {
+	preemptor.lock.Lock()
+	var launches []*preemptorLaunch
+	free := []*mesos.Offer{}
+	for _, offer := range offers {
+		if held, ok := preemptor.reservations[offer.GetSlaveId().GetValue()]; ok {
+			held.offers = append(held.offers, offer)
+		} else {
+			free = append(free, offer)
+		}
+	}
+	for slaveId, held := range preemptor.reservations {
+		if launch := preemptor.launchReserved(held); launch != nil {
+			held.timer.Stop()
+			delete(preemptor.reservations, slaveId)
+			launches = append(launches, launch)
+		}
+	}
+
+	demands := preemptor.Queue.Demands()
+	byTask := make(map[string]*Demand)
+	for _, demand := range demands {
+		byTask[demand.Task.GetTaskId().GetValue()] = demand
+	}
+	plan := preemptor.Planner.Plan(free, demands)
+	for _, launch := range plan.Launches {
+		planned := &preemptorLaunch{offerIds: []*mesos.OfferID{launch.Offer.GetId()}, tasks: launch.Tasks}
+		for _, task := range launch.Tasks {
+			demand := byTask[task.GetTaskId().GetValue()]
+			preemptor.Queue.Remove(task.GetTaskId().GetValue())
+			planned.demands = append(planned.demands, demand)
+		}
+		launches = append(launches, planned)
+	}
+
+	unused := plan.Unused
+	var preemptions []*preemption
+	for _, demand := range plan.Unplaced {
+		victims, slaveId := preemptor.selectVictims(demand, unused)
+		if len(victims) == 0 {
+			continue
+		}
+		unused = preemptor.reserve(demand, slaveId, unused)
+		held := preemptor.reservations[slaveId]
+		for _, victim := range victims {
+			preemptor.victims[victim.TaskId()] = preemptor.requeued(victim)
+			held.victims = append(held.victims, victim.TaskId())
+		}
+		preemptions = append(preemptions, &preemption{demand: demand, victims: victims, held: held})
+	}
+	preemptor.lock.Unlock()
+
+	preemptor.launch(launches)
+	for _, preempted := range preemptions {
+		log.Printf("Preempting %d tasks for task %s", len(preempted.victims), preempted.demand.Task.GetTaskId().GetValue())
+		killed := preemptor.kill(preempted)
+		if len(killed) > 0 && preemptor.Preempted != nil {
+			preemptor.Preempted(preempted.demand, killed)
+		}
+	}
+	for _, offer := range unused {
+		preemptor.driver.DeclineOffer(offer.GetId(), preemptor.Filters)
+	}
+}
+
+// StatusUpdate queues preempted tasks again once they are terminal.
+func (preemptor *Preemptor) StatusUpdate(status *mesos.TaskStatus) {
+	if !IsTerminalState(status.GetState()) {
+		return
+	}
+	preemptor.lock.Lock()
+	defer preemptor.lock.Unlock()
+	id := status.GetTaskId().GetValue()
+	delete(preemptor.launched, id)
+	if demand, ok := preemptor.victims[id]; ok {
+		delete(preemptor.victims, id)
+		preemptor.Queue.Push(demand)
+	}
+}
+
+// OfferRescinded drops a rescinded offer from the reservations.
+func (preemptor *Preemptor) OfferRescinded(offerId *mesos.OfferID) {
+	preemptor.lock.Lock()
+	defer preemptor.lock.Unlock()
+	for _, held := range preemptor.reservations {
+		for i, offer := range held.offers {
+			if offer.GetId().GetValue() == offerId.GetValue() {
+				held.offers = append(held.offers[:i], held.offers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// SlaveLost queues the demand reserved on a lost slave again.
+func (preemptor *Preemptor) SlaveLost(slaveId *mesos.SlaveID) {
+	preemptor.lock.Lock()
+	defer preemptor.lock.Unlock()
+	if held, ok := preemptor.reservations[slaveId.GetValue()]; ok {
+		held.timer.Stop()
+		delete(preemptor.reservations, slaveId.GetValue())
+		preemptor.Queue.Push(held.demand)
+	}
+}
+
+// priority must be called with the lock held.
+func (preemptor *Preemptor) priority(task *mesos.TaskInfo) int {
+	if demand, ok := preemptor.launched[task.GetTaskId().GetValue()]; ok {
+		return demand.Priority
+	}
+	if preemptor.Policy.Priority != nil {
+		return preemptor.Policy.Priority(task)
+	}
+	return TaskPriority(task)
+}
+
+// requeued returns the demand queued once victim is killed.
+func (preemptor *Preemptor) requeued(victim TaskRecord) *Demand {
+	if demand, ok := preemptor.launched[victim.TaskId()]; ok {
+		return demand
+	}
+	task := proto.Clone(victim.Info).(*mesos.TaskInfo)
+	task.SlaveId = nil
+	demand := NewDemand(task)
+	demand.Priority = preemptor.priority(victim.Info)
+	return demand
+}
+
+// selectVictims returns the fewest tasks to kill for demand to fit on a
+// slave, given the unused offers, and the id of the slave. It must be
+// called with the lock held.
+func (preemptor *Preemptor) selectVictims(demand *Demand, unused []*mesos.Offer) ([]TaskRecord, string) {
+	bySlave := make(map[string]*victimsByPolicy)
+	for _, record := range preemptor.driver.Tasks.Active() {
+		slaveId := record.SlaveId()
+		if _, killed := preemptor.victims[record.TaskId()]; killed {
+			continue
+		}
+		if _, reserved := preemptor.reservations[slaveId]; reserved {
+			continue
+		}
+		priority := preemptor.priority(record.Info)
+		if priority+preemptor.Policy.Margin >= demand.Priority {
+			continue
+		}
+		candidates, ok := bySlave[slaveId]
+		if !ok {
+			candidates = &victimsByPolicy{oldest: preemptor.Policy.PreferOldest}
+			bySlave[slaveId] = candidates
+		}
+		candidates.records = append(candidates.records, record)
+		candidates.priorities = append(candidates.priorities, priority)
+	}
+
+	slaveIds := make([]string, 0, len(bySlave))
+	for slaveId := range bySlave {
+		slaveIds = append(slaveIds, slaveId)
+	}
+	sort.Strings(slaveIds)
+
+	needed := demand.Resources()
+	var best []TaskRecord
+	var bestSlave string
+	for _, slaveId := range slaveIds {
+		available := Resources{}
+		for _, offer := range unused {
+			if offer.GetSlaveId().GetValue() == slaveId {
+				available, _ = available.Add(Resources(offer.GetResources()))
+			}
+		}
+		candidates := bySlave[slaveId]
+		sort.Sort(candidates)
+		var victims []TaskRecord
+		for _, record := range candidates.records {
+			if available.Contains(needed) || (preemptor.Policy.MaxVictims > 0 && len(victims) == preemptor.Policy.MaxVictims) {
+				break
+			}
+			available, _ = available.Add(Resources(record.Info.GetResources()))
+			victims = append(victims, record)
+		}
+		if len(victims) == 0 || !available.Contains(needed) {
+			continue
+		}
+		if best == nil || len(victims) < len(best) {
+			best, bestSlave = victims, slaveId
+		}
+	}
+	return best, bestSlave
+}
+
+// reserve holds the unused offers of a slave for demand, returning the
+// other offers. It must be called with the lock held.
+func (preemptor *Preemptor) reserve(demand *Demand, slaveId string, unused []*mesos.Offer) []*mesos.Offer {
+	preemptor.Queue.Remove(demand.Task.GetTaskId().GetValue())
+	held := &reservation{demand: demand, slaveId: slaveId}
+	rest := []*mesos.Offer{}
+	for _, offer := range unused {
+		if offer.GetSlaveId().GetValue() == slaveId {
+			held.offers = append(held.offers, offer)
+		} else {
+			rest = append(rest, offer)
+		}
+	}
+	timeout := preemptor.Policy.ReservationTimeout
+	if timeout == 0 {
+		timeout = DEFAULT_RESERVATION_TIMEOUT
+	}
+	held.timer = time.AfterFunc(timeout, func() {
+		preemptor.expire(held)
+	})
+	preemptor.reservations[slaveId] = held
+	return rest
+}
+
+// kill kills the victims of a preemption, returning the victims killed.
+// Victims failing to be killed are not queued again. When none is killed,
+// the reservation is released. It must be called without the lock held.
+func (preemptor *Preemptor) kill(preempted *preemption) []TaskRecord {
+	var killed []TaskRecord
+	for _, victim := range preempted.victims {
+		if _, err := preemptor.driver.killTask(victim.Info.GetTaskId()); err != nil {
+			log.Println("Unable to preempt task", victim.TaskId(), ":", err)
+			preemptor.lock.Lock()
+			delete(preemptor.victims, victim.TaskId())
+			preemptor.lock.Unlock()
+			continue
+		}
+		killed = append(killed, victim)
+	}
+	if len(killed) == 0 {
+		preemptor.release(preempted.held)
+	}
+	return killed
+}
+
+// expire releases a reservation when the freed resources did not come back
+// in time.
+func (preemptor *Preemptor) expire(held *reservation) {
+	if preemptor.release(held) {
+		log.Println("Reservation expired on slave", held.slaveId)
+	}
+}
+
+// release queues a reserved demand again and declines the offers held for
+// it. Victims not known to be killed yet are not queued again. It returns
+// false when the reservation was already released.
+func (preemptor *Preemptor) release(held *reservation) bool {
+	preemptor.lock.Lock()
+	if preemptor.reservations[held.slaveId] != held {
+		preemptor.lock.Unlock()
+		return false
+	}
+	held.timer.Stop()
+	delete(preemptor.reservations, held.slaveId)
+	for _, id := range held.victims {
+		delete(preemptor.victims, id)
+	}
+	preemptor.Queue.Push(held.demand)
+	offers := held.offers
+	preemptor.lock.Unlock()
+
+	for _, offer := range offers {
+		preemptor.driver.DeclineOffer(offer.GetId(), preemptor.Filters)
+	}
+	return true
+}
+
+// launch must be called without the lock held. Demands failing to launch
+// are queued again.
+func (preemptor *Preemptor) launch(launches []*preemptorLaunch) {
+	for _, launch := range launches {
+		preemptor.lock.Lock()
+		for _, demand := range launch.demands {
+			preemptor.launched[demand.Task.GetTaskId().GetValue()] = demand
+		}
+		preemptor.lock.Unlock()
+
+		if _, err := preemptor.driver.LaunchTasksErr(launch.offerIds, launch.tasks, preemptor.Filters); err != nil {
+			log.Println("Unable to launch tasks:", err)
+			preemptor.lock.Lock()
+			for _, demand := range launch.demands {
+				delete(preemptor.launched, demand.Task.GetTaskId().GetValue())
+				preemptor.Queue.Push(demand)
+			}
+			preemptor.lock.Unlock()
+			continue
+		}
+		preemptor.lock.Lock()
+		if tracker, ok := preemptor.Queue.(LaunchTracker); ok {
+			tracker.Launched(launch.demands...)
+		}
+		preemptor.lock.Unlock()
+	}
+}
+
+// launchReserved returns the launch of a reserved demand once the held
+// offers hold its resources, nil until then. The held offers are planned
+// together as one offer of the slave, so the demand is placed under its
+// constraints and affinity like queued demands. It must be called with the
+// lock held.
+func (preemptor *Preemptor) launchReserved(held *reservation) *preemptorLaunch {
+	if len(held.offers) == 0 {
+		return nil
+	}
+	available := Resources{}
+	offerIds := make([]*mesos.OfferID, len(held.offers))
+	for i, offer := range held.offers {
+		available, _ = available.Add(Resources(offer.GetResources()))
+		offerIds[i] = offer.GetId()
+	}
+	combined := proto.Clone(held.offers[0]).(*mesos.Offer)
+	combined.Resources = available
+	plan := preemptor.Planner.Plan([]*mesos.Offer{combined}, []*Demand{held.demand})
+	if len(plan.Launches) == 0 {
+		return nil
+	}
+	return &preemptorLaunch{offerIds: offerIds, demands: []*Demand{held.demand}, tasks: plan.Launches[0].Tasks}
+}
+
+// victimsByPolicy sorts candidate victims lowest priority first, then
+// youngest first, or oldest first when oldest is set.
+type victimsByPolicy struct {
+	records    []TaskRecord
+	priorities []int
+	oldest     bool
+}
+
+func (v *victimsByPolicy) Len() int { return len(v.records) }
+
+func (v *victimsByPolicy) Less(i, j int) bool {
+	if v.priorities[i] != v.priorities[j] {
+		return v.priorities[i] < v.priorities[j]
+	}
+	a, b := v.records[i].Launched, v.records[j].Launched
+	if !a.Equal(b) {
+		return a.Before(b) == v.oldest
+	}
+	return v.records[i].TaskId() < v.records[j].TaskId()
+}
+
+func (v *victimsByPolicy) Swap(i, j int) {
+	v.records[i], v.records[j] = v.records[j], v.records[i]
+	v.priorities[i], v.priorities[j] = v.priorities[j], v.priorities[i]
+}
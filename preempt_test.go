@@ -0,0 +1,275 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"testing"
+	"time"
+)
+
+// addRunningTask registers a running task labeled with priority, launched
+// age ago.
+func addRunningTask(t *testing.T, registry *TaskRegistry, id, slave string, cpus float64, priority string, age time.Duration) {
+	task := makeLabeledTask(id, slave, PRIORITY_LABEL, priority)
+	task.Resources = []*mesos.Resource{NewScalarResource("cpus", cpus), NewScalarResource("mem", 128)}
+	if err := registry.Add(task, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Update(makeTaskUpdate(id, mesos.TaskState_TASK_RUNNING)); err != nil {
+		t.Fatal(err)
+	}
+	registry.lock.Lock()
+	registry.tasks[id].Launched = time.Now().Add(-age)
+	registry.lock.Unlock()
+}
+
+func makePriorityDemand(id string, cpus float64, priority int) *Demand {
+	task := makeLaunchTask(id, "", cpus, 128)
+	task.SlaveId = nil
+	demand := NewDemand(task)
+	demand.Priority = priority
+	return demand
+}
+
+func TestTaskPriority(t *testing.T) {
+	if TaskPriority(makeLabeledTask("task-1", "slave-1", PRIORITY_LABEL, "7")) != 7 {
+		t.Fatal("Expected priority 7 read from label.")
+	}
+	if TaskPriority(makeLabeledTask("task-1", "slave-1")) != 0 || TaskPriority(makeLabeledTask("task-1", "slave-1", PRIORITY_LABEL, "high")) != 0 {
+		t.Fatal("Expected priority 0 without a numeric label.")
+	}
+}
+
+func TestPreemptor_SelectVictims(t *testing.T) {
+	driver := &SchedulerDriver{Tasks: NewTaskRegistry()}
+	addRunningTask(t, driver.Tasks, "batch-1", "slave-1", 2, "0", 3*time.Minute)
+	addRunningTask(t, driver.Tasks, "batch-2", "slave-1", 2, "0", 2*time.Minute)
+	addRunningTask(t, driver.Tasks, "batch-3", "slave-1", 2, "1", time.Minute)
+	addRunningTask(t, driver.Tasks, "service-1", "slave-2", 4, "10", 0)
+	offers := []*mesos.Offer{makeLaunchOffer("offer-1", "slave-1", 1, 1024)}
+
+	victimIds := func(victims []TaskRecord) []string {
+		ids := []string{}
+		for _, victim := range victims {
+			ids = append(ids, victim.TaskId())
+		}
+		return ids
+	}
+	check := func(policy PreemptionPolicy, demand *Demand, expected ...string) {
+		preemptor, err := NewPreemptor(driver, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		victims, slaveId := preemptor.selectVictims(demand, offers)
+		ids := victimIds(victims)
+		if len(ids) != len(expected) || (len(ids) > 0 && slaveId != "slave-1") {
+			t.Fatal("Expected victims", expected, "but got", ids, "on", slaveId)
+		}
+		for i := range ids {
+			if ids[i] != expected[i] {
+				t.Fatal("Expected victims", expected, "but got", ids)
+			}
+		}
+	}
+
+	check(PreemptionPolicy{}, makePriorityDemand("service-2", 3, 10), "batch-2")
+	check(PreemptionPolicy{PreferOldest: true}, makePriorityDemand("service-2", 3, 10), "batch-1")
+	check(PreemptionPolicy{}, makePriorityDemand("service-2", 5, 10), "batch-2", "batch-1")
+	check(PreemptionPolicy{}, makePriorityDemand("service-2", 7, 10), "batch-2", "batch-1", "batch-3")
+	check(PreemptionPolicy{MaxVictims: 2}, makePriorityDemand("service-2", 7, 10))
+	check(PreemptionPolicy{Margin: 10}, makePriorityDemand("service-2", 3, 10))
+	check(PreemptionPolicy{}, makePriorityDemand("batch-4", 3, 0))
+	// demands too large for any slave kill nothing
+	check(PreemptionPolicy{}, makePriorityDemand("service-2", 16, 20))
+
+	if _, err := NewPreemptor(&SchedulerDriver{}, PreemptionPolicy{}); err == nil {
+		t.Fatal("Expected error creating a Preemptor without task registry.")
+	}
+}
+
+func TestPreemptor_Preempt(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+	driver.Tasks = NewTaskRegistry()
+	addRunningTask(t, driver.Tasks, "batch-1", "slave-1", 2, "0", 3*time.Minute)
+	addRunningTask(t, driver.Tasks, "batch-2", "slave-1", 2, "0", 2*time.Minute)
+
+	preempted := make(chan []TaskRecord, 1)
+	preemptor, _ := NewPreemptor(driver, PreemptionPolicy{})
+	preemptor.Preempted = func(demand *Demand, victims []TaskRecord) {
+		preempted <- victims
+	}
+	preemptor.Submit(makePriorityDemand("service-1", 3, 10))
+	preemptor.ResourceOffers([]*mesos.Offer{makeLaunchOffer("offer-1", "slave-1", 1, 1024)})
+
+	call := master.expectCall(t, mesos.Call_KILL)
+	if call.GetKill().GetTaskId().GetValue() != "batch-2" {
+		t.Fatal("Expected youngest batch-2 killed, but got", call.GetKill())
+	}
+	select {
+	case victims := <-preempted:
+		if len(victims) != 1 {
+			t.Fatal("Expected 1 victim, but got", victims)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Preemptor.Preempted not called.")
+	}
+	if len(preemptor.Pending()) != 0 {
+		t.Fatal("Expected reserved demand out of the queue.")
+	}
+
+	driver.Tasks.Update(makeTaskUpdate("batch-2", mesos.TaskState_TASK_KILLED))
+	preemptor.StatusUpdate(makeTaskUpdate("batch-2", mesos.TaskState_TASK_KILLED))
+	pending := preemptor.Pending()
+	if len(pending) != 1 || pending[0].Task.GetTaskId().GetValue() != "batch-2" || pending[0].Task.SlaveId != nil {
+		t.Fatal("Expected killed batch-2 queued again, but got", pending)
+	}
+
+	// freed resources come back on slave-1 and are kept for service-1
+	preemptor.ResourceOffers([]*mesos.Offer{makeLaunchOffer("offer-2", "slave-1", 2, 1024)})
+	call = master.expectCall(t, mesos.Call_ACCEPT)
+	accept := call.GetAccept()
+	if len(accept.GetOfferIds()) != 2 || accept.GetOfferIds()[0].GetValue() != "offer-1" || accept.GetOfferIds()[1].GetValue() != "offer-2" {
+		t.Fatal("Expected reserved offers used together, but got", accept.GetOfferIds())
+	}
+	task := accept.GetOperations()[0].GetLaunch().GetTaskInfos()[0]
+	if task.GetTaskId().GetValue() != "service-1" || task.GetSlaveId().GetValue() != "slave-1" {
+		t.Fatal("Expected service-1 launched on slave-1, but got", task)
+	}
+	if len(preemptor.Pending()) != 1 {
+		t.Fatal("Expected batch-2 still waiting for offers.")
+	}
+}
+
+func TestPreemptor_ReservedConstraints(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+	driver.Tasks = NewTaskRegistry()
+	addRunningTask(t, driver.Tasks, "batch-1", "slave-1", 2, "0", 3*time.Minute)
+
+	preemptor, _ := NewPreemptor(driver, PreemptionPolicy{})
+	demand := makePriorityDemand("service-1", 2, 10)
+	demand.Constraints = mustParseConstraints(t, "hostname UNLIKE host-slave-1")
+	preemptor.Submit(demand)
+	preemptor.ResourceOffers([]*mesos.Offer{makeLaunchOffer("offer-1", "slave-1", 0.5, 1024)})
+	master.expectCall(t, mesos.Call_KILL)
+
+	// the freed resources fit, but the constraint keeps service-1 off slave-1
+	preemptor.ResourceOffers([]*mesos.Offer{makeLaunchOffer("offer-2", "slave-1", 2, 1024)})
+	preemptor.lock.Lock()
+	held, ok := preemptor.reservations["slave-1"]
+	offers := 0
+	if ok {
+		offers = len(held.offers)
+	}
+	preemptor.lock.Unlock()
+	if offers != 2 {
+		t.Fatal("Expected service-1 not launched and both offers still held, but got", offers)
+	}
+}
+
+func TestPreemptor_ReservationExpiry(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+	driver.Tasks = NewTaskRegistry()
+	addRunningTask(t, driver.Tasks, "batch-1", "slave-1", 2, "0", 3*time.Minute)
+
+	preemptor, _ := NewPreemptor(driver, PreemptionPolicy{ReservationTimeout: 50 * time.Millisecond})
+	preemptor.Submit(makePriorityDemand("service-1", 2, 10))
+	preemptor.ResourceOffers([]*mesos.Offer{
+		makeLaunchOffer("offer-1", "slave-1", 0.5, 1024),
+		makeLaunchOffer("offer-2", "slave-2", 0.5, 1024),
+	})
+	master.expectCall(t, mesos.Call_KILL)
+	call := master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-2" {
+		t.Fatal("Expected offer-2 declined, but got", call.GetDecline())
+	}
+
+	call = master.expectCall(t, mesos.Call_DECLINE)
+	if call.GetDecline().GetOfferIds()[0].GetValue() != "offer-1" {
+		t.Fatal("Expected reserved offer-1 declined on expiry, but got", call.GetDecline())
+	}
+	if pending := preemptor.Pending(); len(pending) != 1 || pending[0].Task.GetTaskId().GetValue() != "service-1" {
+		t.Fatal("Expected service-1 queued again, but got", pending)
+	}
+	preemptor.lock.Lock()
+	victims := len(preemptor.victims)
+	preemptor.lock.Unlock()
+	if victims != 0 {
+		t.Fatal("Expected batch-1 not killed by the expiry dropped from the victims, but got", victims)
+	}
+}
+
+func TestPreemptor_KillFailure(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+	driver := startTestHttpDriver(t, NewMesosScheduler(), master, 10)
+	driver.Tasks = NewTaskRegistry()
+	addRunningTask(t, driver.Tasks, "batch-1", "slave-1", 2, "0", 3*time.Minute)
+
+	preempted := make(chan []TaskRecord, 1)
+	preemptor, _ := NewPreemptor(driver, PreemptionPolicy{})
+	preemptor.Preempted = func(demand *Demand, victims []TaskRecord) {
+		preempted <- victims
+	}
+	preemptor.Submit(makePriorityDemand("service-1", 2, 10))
+	// kills are not sent to a disconnected master
+	driver.setConnected(false)
+	preemptor.ResourceOffers([]*mesos.Offer{makeLaunchOffer("offer-1", "slave-1", 0.5, 1024)})
+
+	select {
+	case victims := <-preempted:
+		t.Fatal("Expected Preempted not called without victims killed, but got", victims)
+	default:
+	}
+	preemptor.lock.Lock()
+	victims, reservations := len(preemptor.victims), len(preemptor.reservations)
+	preemptor.lock.Unlock()
+	if victims != 0 || reservations != 0 {
+		t.Fatal("Expected no victim and no reservation kept, but got", victims, reservations)
+	}
+	if pending := preemptor.Pending(); len(pending) != 1 || pending[0].Task.GetTaskId().GetValue() != "service-1" {
+		t.Fatal("Expected service-1 queued again, but got", pending)
+	}
+}
+
+func TestPreemptor_OverLibprocess(t *testing.T) {
+	master := makeTestMaster()
+	defer master.close()
+	driver := startTestDriver(t, NewMesosScheduler(), master)
+	defer driver.Stop(true)
+	driver.Tasks = NewTaskRegistry()
+	addRunningTask(t, driver.Tasks, "batch-1", "slave-1", 2, "0", 3*time.Minute)
+
+	preemptor, _ := NewPreemptor(driver, PreemptionPolicy{ReservationTimeout: 50 * time.Millisecond})
+	preemptor.Submit(makePriorityDemand("web-1", 1, 0), makePriorityDemand("service-1", 2, 10))
+	preemptor.ResourceOffers([]*mesos.Offer{
+		makeLaunchOffer("offer-1", "slave-1", 0.5, 1024),
+		makeLaunchOffer("offer-2", "slave-2", 1, 1024),
+		makeLaunchOffer("offer-3", "slave-3", 0.5, 1024),
+	})
+
+	msg := new(mesos.LaunchTasksMessage)
+	master.expectCall(t, LAUNCH_TASKS_CALL, msg)
+	if msg.GetOfferIds()[0].GetValue() != "offer-2" || len(msg.GetTasks()) != 1 || msg.GetTasks()[0].GetTaskId().GetValue() != "web-1" {
+		t.Fatal("Expected web-1 launched on offer-2, but got", msg)
+	}
+	kill := new(mesos.KillTaskMessage)
+	master.expectCall(t, KILL_TASK_CALL, kill)
+	if kill.GetTaskId().GetValue() != "batch-1" || kill.GetFrameworkId().GetValue() != "framework-1" {
+		t.Fatal("Expected batch-1 of framework-1 killed, but got", kill)
+	}
+	msg = new(mesos.LaunchTasksMessage)
+	master.expectCall(t, LAUNCH_TASKS_CALL, msg)
+	if msg.GetOfferIds()[0].GetValue() != "offer-3" || len(msg.GetTasks()) != 0 {
+		t.Fatal("Expected unused offer-3 declined, but got", msg)
+	}
+	msg = new(mesos.LaunchTasksMessage)
+	master.expectCall(t, LAUNCH_TASKS_CALL, msg)
+	if msg.GetOfferIds()[0].GetValue() != "offer-1" || len(msg.GetTasks()) != 0 {
+		t.Fatal("Expected reserved offer-1 declined on expiry, but got", msg)
+	}
+}
@@ -0,0 +1,64 @@
+package gomes
+
+import (
+	"sort"
+)
+
+// DemandQueue orders the demands waiting to be placed. A PriorityQueue is
+// not safe for concurrent use, an EDFQueue or a DRFQueue is.
+type DemandQueue interface {
+	Push(demands ...*Demand)
+	// Remove drops the demand of a task, returning it if it was queued.
+	Remove(taskId string) *Demand
+	// Demands returns the queued demands in the order they are placed in.
+	Demands() []*Demand
+	Len() int
+}
+
+// LaunchTracker is a DemandQueue following demands once their tasks are
+// launched. The Preemptor calls Launched for the demands it removed from
+// the queue once their launch was sent, so that demands removed otherwise
+// are not taken as launched.
+type LaunchTracker interface {
+	DemandQueue
+	Launched(demands ...*Demand)
+}
+
+// PriorityQueue places demands of higher priority first, and demands of
+// equal priority in the order they were pushed.
+type PriorityQueue struct {
+	demands []*Demand
+}
+
+func NewPriorityQueue() *PriorityQueue {
+	return &PriorityQueue{}
+}
+
+func (queue *PriorityQueue) Push(demands ...*Demand) {
+	for _, demand := range demands {
+		i := sort.Search(len(queue.demands), func(i int) bool {
+			return queue.demands[i].Priority < demand.Priority
+		})
+		queue.demands = append(queue.demands, nil)
+		copy(queue.demands[i+1:], queue.demands[i:])
+		queue.demands[i] = demand
+	}
+}
+
+func (queue *PriorityQueue) Remove(taskId string) *Demand {
+	for i, demand := range queue.demands {
+		if demand.Task.GetTaskId().GetValue() == taskId {
+			queue.demands = append(queue.demands[:i], queue.demands[i+1:]...)
+			return demand
+		}
+	}
+	return nil
+}
+
+func (queue *PriorityQueue) Demands() []*Demand {
+	return append([]*Demand{}, queue.demands...)
+}
+
+func (queue *PriorityQueue) Len() int {
+	return len(queue.demands)
+}
@@ -0,0 +1,28 @@
+package gomes
+
+import (
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	queue := NewPriorityQueue()
+	demands := makeDemands(5, 1, 128)
+	for i, priority := range []int{1, 5, 1, 3, 5} {
+		demands[i].Priority = priority
+	}
+	queue.Push(demands...)
+
+	expected := []string{"task-1", "task-4", "task-3", "task-0", "task-2"}
+	for i, demand := range queue.Demands() {
+		if demand.Task.GetTaskId().GetValue() != expected[i] {
+			t.Fatal("Expected demands ordered by priority then push order, but got", demand.Task.GetTaskId().GetValue(), "at", i)
+		}
+	}
+
+	if demand := queue.Remove("task-3"); demand != demands[3] {
+		t.Fatal("Expected task-3 removed from queue.")
+	}
+	if queue.Remove("task-3") != nil || queue.Len() != 4 {
+		t.Fatal("Expected 4 demands left, but got", queue.Len())
+	}
+}
@@ -0,0 +1,253 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+type rangesByBegin []*mesos.Value_Range
+
+func (r rangesByBegin) Len() int           { return len(r) }
+func (r rangesByBegin) Less(i, j int) bool { return r[i].GetBegin() < r[j].GetBegin() }
+func (r rangesByBegin) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// NormalizeRanges validates ranges and returns them in canonical form: sorted,
+// with overlapping and adjacent ranges merged. Equal sets of values have
+// equal normalized ranges.
+func NormalizeRanges(ranges []*mesos.Value_Range) ([]*mesos.Value_Range, error) {
+	for _, r := range ranges {
+		if r == nil || r.Begin == nil || r.End == nil {
+			return nil, fmt.Errorf("Range is missing begin or end.")
+		}
+		if r.GetBegin() > r.GetEnd() {
+			return nil, fmt.Errorf("Invalid range [%d-%d].", r.GetBegin(), r.GetEnd())
+		}
+	}
+	return CoalesceRanges(ranges), nil
+}
+
+// CoalesceRanges returns a copy of ranges sorted by begin, with overlapping
+// and adjacent ranges merged.
+func CoalesceRanges(ranges []*mesos.Value_Range) []*mesos.Value_Range {
+	sorted := make([]*mesos.Value_Range, len(ranges))
+	copy(sorted, ranges)
+	sort.Sort(rangesByBegin(sorted))
+
+	result := []*mesos.Value_Range{}
+	for _, r := range sorted {
+		last := len(result) - 1
+		if last >= 0 && (result[last].GetEnd() == math.MaxUint64 || r.GetBegin() <= result[last].GetEnd()+1) {
+			if r.GetEnd() > result[last].GetEnd() {
+				result[last] = NewValueRange(result[last].GetBegin(), r.GetEnd())
+			}
+			continue
+		}
+		result = append(result, NewValueRange(r.GetBegin(), r.GetEnd()))
+	}
+	return result
+}
+
+// SubtractRanges returns the values of ranges that are not in other.
+func SubtractRanges(ranges, other []*mesos.Value_Range) []*mesos.Value_Range {
+	result := CoalesceRanges(ranges)
+	for _, o := range CoalesceRanges(other) {
+		next := []*mesos.Value_Range{}
+		for _, r := range result {
+			if o.GetEnd() < r.GetBegin() || o.GetBegin() > r.GetEnd() {
+				next = append(next, r)
+				continue
+			}
+			if r.GetBegin() < o.GetBegin() {
+				next = append(next, NewValueRange(r.GetBegin(), o.GetBegin()-1))
+			}
+			if r.GetEnd() > o.GetEnd() {
+				next = append(next, NewValueRange(o.GetEnd()+1, r.GetEnd()))
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// IntersectRanges returns the values that are both in ranges and in other.
+func IntersectRanges(ranges, other []*mesos.Value_Range) []*mesos.Value_Range {
+	a, b := CoalesceRanges(ranges), CoalesceRanges(other)
+	result := []*mesos.Value_Range{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		begin, end := a[i].GetBegin(), a[i].GetEnd()
+		if b[j].GetBegin() > begin {
+			begin = b[j].GetBegin()
+		}
+		if b[j].GetEnd() < end {
+			end = b[j].GetEnd()
+		}
+		if begin <= end {
+			result = append(result, NewValueRange(begin, end))
+		}
+		if a[i].GetEnd() < b[j].GetEnd() {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+// RangesSize returns the number of values in ranges, which must be coalesced.
+func RangesSize(ranges []*mesos.Value_Range) uint64 {
+	var size uint64
+	for _, r := range ranges {
+		n := r.GetEnd() - r.GetBegin() + 1
+		if n == 0 || size+n < size {
+			return math.MaxUint64
+		}
+		size += n
+	}
+	return size
+}
+
+// RangesContain reports whether every value of other is in ranges.
+func RangesContain(ranges, other []*mesos.Value_Range) bool {
+	return len(SubtractRanges(other, ranges)) == 0
+}
+
+// PortStrategy selects the ports picked by AllocatePorts.
+type PortStrategy int
+
+const (
+	// PORTS_LOWEST picks the lowest available ports.
+	PORTS_LOWEST PortStrategy = iota
+	// PORTS_RANDOM picks available ports at random.
+	PORTS_RANDOM
+)
+
+// PortRequest describes the ports needed by a task.
+type PortRequest struct {
+	// Fixed lists ports that must be assigned as is.
+	Fixed []uint64
+	// Count is the number of ports picked in addition to Fixed.
+	Count    int
+	Strategy PortStrategy
+	// Rand is the source used by PORTS_RANDOM. A time seeded source is used when nil.
+	Rand *rand.Rand
+}
+
+/*
+AllocatePorts assigns the ports of request from the "ports" resources of an
+offer. All the ports are taken from a single role, the first one that can
+satisfy the request. It returns the assigned ports, fixed ports first, and
+the ports resource to put on the TaskInfo.
+*/
+func AllocatePorts(resources Resources, request PortRequest) ([]uint64, *mesos.Resource, error) {
+	if request.Count < 0 {
+		return nil, nil, fmt.Errorf("Invalid port count %d.", request.Count)
+	}
+	for i, port := range request.Fixed {
+		for _, other := range request.Fixed[:i] {
+			if port == other {
+				return nil, nil, fmt.Errorf("Port %d is requested twice.", port)
+			}
+		}
+	}
+
+	var roles []string
+	available := make(map[string][]*mesos.Value_Range)
+	for _, resource := range resources {
+		if resource.GetName() != RESOURCE_PORTS || resource.GetType() != mesos.Value_RANGES {
+			continue
+		}
+		role := resource.GetRole()
+		if _, ok := available[role]; !ok {
+			roles = append(roles, role)
+		}
+		available[role] = append(available[role], resource.GetRanges().GetRange()...)
+	}
+
+	err := fmt.Errorf("Offer has no ports resource.")
+	for _, role := range roles {
+		var ports []uint64
+		ports, err = allocatePorts(CoalesceRanges(available[role]), request)
+		if err != nil {
+			continue
+		}
+		ranges := make([]*mesos.Value_Range, len(ports))
+		for i, port := range ports {
+			ranges[i] = NewValueRange(port, port)
+		}
+		resource := NewRangesResource(RESOURCE_PORTS, CoalesceRanges(ranges))
+		resource.Role = proto.String(role)
+		return ports, resource, nil
+	}
+	return nil, nil, err
+}
+
+// allocatePorts assigns the ports of request from the coalesced ranges.
+func allocatePorts(ranges []*mesos.Value_Range, request PortRequest) ([]uint64, error) {
+	ports := make([]uint64, 0, len(request.Fixed)+request.Count)
+	fixed := make([]*mesos.Value_Range, 0, len(request.Fixed))
+	for _, port := range request.Fixed {
+		if !RangesContain(ranges, []*mesos.Value_Range{NewValueRange(port, port)}) {
+			return nil, fmt.Errorf("Port %d is not available in the offer.", port)
+		}
+		ports = append(ports, port)
+		fixed = append(fixed, NewValueRange(port, port))
+	}
+
+	free := SubtractRanges(ranges, fixed)
+	size := RangesSize(free)
+	if uint64(request.Count) > size {
+		return nil, fmt.Errorf("Offer has %d ports available, %d requested.", size, request.Count)
+	}
+
+	switch request.Strategy {
+	case PORTS_RANDOM:
+		if size > math.MaxInt64 {
+			size = math.MaxInt64
+		}
+		rnd := request.Rand
+		if rnd == nil {
+			rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+		}
+		// partial Fisher-Yates shuffle over the indexes of the free ports.
+		swapped := make(map[uint64]uint64)
+		index := func(i uint64) uint64 {
+			if v, ok := swapped[i]; ok {
+				return v
+			}
+			return i
+		}
+		for i := uint64(0); i < uint64(request.Count); i++ {
+			j := i + uint64(rnd.Int63n(int64(size-i)))
+			picked := index(j)
+			swapped[j] = index(i)
+			ports = append(ports, portAt(free, picked))
+		}
+	default:
+		for _, r := range free {
+			for port := r.GetBegin(); len(ports) < cap(ports); port++ {
+				ports = append(ports, port)
+				if port == r.GetEnd() {
+					break
+				}
+			}
+		}
+	}
+	return ports, nil
+}
+
+// portAt returns the value at index i of the coalesced ranges.
+func portAt(ranges []*mesos.Value_Range, i uint64) uint64 {
+	for _, r := range ranges {
+		n := r.GetEnd() - r.GetBegin() + 1
+		if i < n {
+			return r.GetBegin() + i
+		}
+		i -= n
+	}
+	panic("port index out of ranges")
+}
@@ -0,0 +1,169 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func makeRanges(bounds ...uint64) []*mesos.Value_Range {
+	ranges := []*mesos.Value_Range{}
+	for i := 0; i+1 < len(bounds); i += 2 {
+		ranges = append(ranges, NewValueRange(bounds[i], bounds[i+1]))
+	}
+	return ranges
+}
+
+func checkRanges(t *testing.T, ranges []*mesos.Value_Range, bounds ...uint64) {
+	expected := makeRanges(bounds...)
+	if len(ranges) != len(expected) {
+		t.Fatal("Expected ranges", expected, "but got", ranges)
+	}
+	for i := range ranges {
+		if ranges[i].GetBegin() != expected[i].GetBegin() || ranges[i].GetEnd() != expected[i].GetEnd() {
+			t.Fatal("Expected ranges", expected, "but got", ranges)
+		}
+	}
+}
+
+func TestCoalesceRanges(t *testing.T) {
+	checkRanges(t, CoalesceRanges(makeRanges(10, 20, 1, 5, 6, 8, 15, 25, 30, 30)), 1, 8, 10, 25, 30, 30)
+	checkRanges(t, CoalesceRanges(makeRanges(5, math.MaxUint64, 0, 4)), 0, math.MaxUint64)
+	checkRanges(t, CoalesceRanges(nil))
+
+	input := makeRanges(3, 4, 1, 2)
+	CoalesceRanges(input)
+	if input[0].GetBegin() != 3 {
+		t.Fatal("CoalesceRanges modified its input.")
+	}
+}
+
+func TestNormalizeRanges(t *testing.T) {
+	ranges, err := NormalizeRanges(makeRanges(4, 6, 1, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRanges(t, ranges, 1, 6)
+	if _, err := NormalizeRanges(makeRanges(6, 4)); err == nil {
+		t.Fatal("Expected error for range with begin > end.")
+	}
+	if _, err := NormalizeRanges([]*mesos.Value_Range{&mesos.Value_Range{}}); err == nil {
+		t.Fatal("Expected error for range without bounds.")
+	}
+}
+
+func TestSubtractRanges(t *testing.T) {
+	checkRanges(t, SubtractRanges(makeRanges(1, 10), makeRanges(3, 4, 8, 12)), 1, 2, 5, 7)
+	checkRanges(t, SubtractRanges(makeRanges(1, 10), makeRanges(0, 20)))
+	checkRanges(t, SubtractRanges(makeRanges(1, 10, 20, 30), makeRanges(11, 19)), 1, 10, 20, 30)
+	checkRanges(t, SubtractRanges(makeRanges(0, math.MaxUint64), makeRanges(math.MaxUint64, math.MaxUint64)), 0, math.MaxUint64-1)
+}
+
+func TestIntersectRanges(t *testing.T) {
+	checkRanges(t, IntersectRanges(makeRanges(1, 10, 20, 30), makeRanges(5, 25)), 5, 10, 20, 25)
+	checkRanges(t, IntersectRanges(makeRanges(1, 10), makeRanges(11, 20)))
+	checkRanges(t, IntersectRanges(makeRanges(1, 3, 5, 7, 9, 11), makeRanges(2, 10)), 2, 3, 5, 7, 9, 10)
+}
+
+func TestRangesContainAndSize(t *testing.T) {
+	if !RangesContain(makeRanges(1, 10, 20, 30), makeRanges(2, 3, 25, 30)) {
+		t.Fatal("Expected ranges to be contained.")
+	}
+	if RangesContain(makeRanges(1, 10), makeRanges(10, 11)) {
+		t.Fatal("Range [10-11] should not be contained in [1-10].")
+	}
+	if RangesSize(makeRanges(1, 10, 20, 20)) != 11 {
+		t.Fatal("Expected 11 values, but got", RangesSize(makeRanges(1, 10, 20, 20)))
+	}
+	if RangesSize(makeRanges(0, math.MaxUint64)) != math.MaxUint64 {
+		t.Fatal("RangesSize expected to saturate.")
+	}
+}
+
+func TestAllocatePorts_Lowest(t *testing.T) {
+	offer := Resources{NewRangesResource("ports", makeRanges(31005, 31010, 31000, 31001))}
+	ports, resource, err := AllocatePorts(offer, PortRequest{Fixed: []uint64{31000}, Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) != 4 || ports[0] != 31000 || ports[1] != 31001 || ports[2] != 31005 || ports[3] != 31006 {
+		t.Fatal("Expected fixed port then lowest ports, but got", ports)
+	}
+	checkRanges(t, resource.GetRanges().GetRange(), 31000, 31001, 31005, 31006)
+	if resource.GetName() != "ports" || resource.GetRole() != "*" {
+		t.Fatal("Unexpected ports resource", resource)
+	}
+	left, err := offer.Subtract(Resources{resource})
+	if err != nil {
+		t.Fatal("Allocated ports resource is not contained in the offer:", err)
+	}
+	checkRanges(t, left.Ports(), 31007, 31010)
+}
+
+func TestAllocatePorts_Random(t *testing.T) {
+	offer := Resources{NewRangesResource("ports", makeRanges(1000, 1009, 2000, 2009))}
+	request := PortRequest{Count: 20, Strategy: PORTS_RANDOM, Rand: rand.New(rand.NewSource(7))}
+	ports, resource, err := AllocatePorts(offer, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[uint64]bool)
+	for _, port := range ports {
+		if seen[port] || !RangesContain(offer.Ports(), makeRanges(port, port)) {
+			t.Fatal("Random allocation returned duplicate or unavailable port", port)
+		}
+		seen[port] = true
+	}
+	checkRanges(t, resource.GetRanges().GetRange(), 1000, 1009, 2000, 2009)
+
+	request = PortRequest{Count: 3, Strategy: PORTS_RANDOM, Rand: rand.New(rand.NewSource(1))}
+	first, _, _ := AllocatePorts(offer, request)
+	request.Rand = rand.New(rand.NewSource(1))
+	second, _, _ := AllocatePorts(offer, request)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatal("Random allocation not reproducible with the same seed.")
+		}
+	}
+}
+
+func TestAllocatePorts_Roles(t *testing.T) {
+	offer := Resources{
+		withRole(NewRangesResource("ports", makeRanges(8000, 8001)), "prod"),
+		NewRangesResource("ports", makeRanges(9000, 9010)),
+	}
+	ports, resource, err := AllocatePorts(offer, PortRequest{Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.GetRole() != "prod" || ports[0] != 8000 {
+		t.Fatal("Expected ports from first role, but got", ports, resource.GetRole())
+	}
+	ports, resource, err = AllocatePorts(offer, PortRequest{Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.GetRole() != "*" || ports[0] != 9000 {
+		t.Fatal("Expected ports from role able to satisfy request, but got", ports, resource.GetRole())
+	}
+}
+
+func TestAllocatePorts_Errors(t *testing.T) {
+	offer := Resources{NewRangesResource("ports", makeRanges(31000, 31002))}
+	requests := []PortRequest{
+		PortRequest{Count: 4},
+		PortRequest{Fixed: []uint64{80}},
+		PortRequest{Fixed: []uint64{31000, 31000}},
+		PortRequest{Fixed: []uint64{31000}, Count: 3},
+		PortRequest{Count: -1},
+	}
+	for _, request := range requests {
+		if _, _, err := AllocatePorts(offer, request); err == nil {
+			t.Fatal("Expected allocation error for", request)
+		}
+	}
+	if _, _, err := AllocatePorts(Resources{NewScalarResource("cpus", 1)}, PortRequest{Count: 1}); err == nil {
+		t.Fatal("Expected error for offer without ports.")
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Package recordio implements the RecordIO framing used by the streaming HTTP
+endpoints of Mesos. Each record is written as its length in decimal ASCII,
+a newline, then the record bytes:
+
+	5\nhello11\nhello world
+
+Reader and Writer work on top of any io.Reader or io.Writer.
+*/
+package recordio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// DEFAULT_MAX_RECORD_SIZE is the largest record accepted by NewReader.
+	DEFAULT_MAX_RECORD_SIZE = 64 << 20
+	// headers longer than this can not hold a valid record length.
+	maxHeaderSize = 20
+)
+
+// FrameError reports a malformed or truncated record.
+type FrameError struct {
+	// Offset is the position of the record header in the stream.
+	Offset int64
+	Msg    string
+	// Err is io.ErrUnexpectedEOF for truncated records, or the error
+	// returned by the underlying reader.
+	Err error
+}
+
+func (err *FrameError) Error() string {
+	if err.Err != nil {
+		return fmt.Sprintf("Bad record at offset %d: %s (%s)", err.Offset, err.Msg, err.Err)
+	}
+	return fmt.Sprintf("Bad record at offset %d: %s", err.Offset, err.Msg)
+}
+
+// Reader decodes records from a stream.
+type Reader struct {
+	reader  *bufio.Reader
+	maxSize int64
+	offset  int64
+}
+
+// NewReader returns a Reader accepting records up to DEFAULT_MAX_RECORD_SIZE.
+func NewReader(r io.Reader) *Reader {
+	return NewReaderMax(r, DEFAULT_MAX_RECORD_SIZE)
+}
+
+// NewReaderMax returns a Reader that fails on records larger than maxSize.
+func NewReaderMax(r io.Reader, maxSize int64) *Reader {
+	return &Reader{reader: bufio.NewReader(r), maxSize: maxSize}
+}
+
+// ReadRecord returns the next record. It returns io.EOF when the stream ends
+// between records and a *FrameError when a record is malformed, too large or
+// truncated. The returned slice is not reused by later reads.
+func (r *Reader) ReadRecord() ([]byte, error) {
+	start := r.offset
+	header, err := r.reader.ReadSlice('\n')
+	r.offset += int64(len(header))
+	switch {
+	case err == io.EOF && len(header) == 0:
+		return nil, io.EOF
+	case err == io.EOF:
+		return nil, &FrameError{Offset: start, Msg: "Truncated record header.", Err: io.ErrUnexpectedEOF}
+	case err == bufio.ErrBufferFull || len(header) > maxHeaderSize+1:
+		return nil, &FrameError{Offset: start, Msg: "Record header too long."}
+	case err != nil:
+		return nil, &FrameError{Offset: start, Msg: "Unable to read record header.", Err: err}
+	}
+
+	length := header[:len(header)-1]
+	if !isDecimal(length) {
+		return nil, &FrameError{Offset: start, Msg: fmt.Sprintf("Invalid record length %q.", length)}
+	}
+	size, err := strconv.ParseInt(string(length), 10, 64)
+	if err != nil {
+		return nil, &FrameError{Offset: start, Msg: fmt.Sprintf("Invalid record length %q.", length)}
+	}
+	if size > r.maxSize {
+		return nil, &FrameError{
+			Offset: start,
+			Msg:    fmt.Sprintf("Record of %d bytes exceeds maximum size of %d bytes.", size, r.maxSize),
+		}
+	}
+
+	record := make([]byte, size)
+	n, err := io.ReadFull(r.reader, record)
+	r.offset += int64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, &FrameError{
+			Offset: start,
+			Msg:    fmt.Sprintf("Truncated record, expected %d bytes but got %d.", size, n),
+			Err:    io.ErrUnexpectedEOF,
+		}
+	}
+	if err != nil {
+		return nil, &FrameError{Offset: start, Msg: "Unable to read record.", Err: err}
+	}
+	return record, nil
+}
+
+// isDecimal reports whether length is a decimal number without sign or
+// leading zeros, the only form Mesos writes.
+func isDecimal(length []byte) bool {
+	if len(length) == 0 || (len(length) > 1 && length[0] == '0') {
+		return false
+	}
+	for _, c := range length {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Writer encodes records to a stream.
+type Writer struct {
+	writer io.Writer
+	header []byte
+}
+
+// NewWriter returns a Writer that frames records written to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{writer: w, header: make([]byte, 0, maxHeaderSize+1)}
+}
+
+// WriteRecord writes data as a single record.
+func (w *Writer) WriteRecord(data []byte) error {
+	w.header = strconv.AppendInt(w.header[:0], int64(len(data)), 10)
+	w.header = append(w.header, '\n')
+	if _, err := w.writer.Write(w.header); err != nil {
+		return err
+	}
+	_, err := w.writer.Write(data)
+	return err
+}
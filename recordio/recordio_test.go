@@ -0,0 +1,196 @@
+package recordio
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestReadRecord(t *testing.T) {
+	reader := NewReader(strings.NewReader("5\nhello0\n11\nhello world"))
+	for _, expected := range []string{"hello", "", "hello world"} {
+		record, err := reader.ReadRecord()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(record) != expected {
+			t.Fatalf("Expected record %q, but got %q", expected, record)
+		}
+	}
+	if _, err := reader.ReadRecord(); err != io.EOF {
+		t.Fatal("Expected io.EOF at end of stream, but got", err)
+	}
+}
+
+func TestReadRecord_Errors(t *testing.T) {
+	tests := []struct {
+		stream    string
+		offset    int64
+		truncated bool
+	}{
+		{"5\nhello12", 7, true},        // truncated header
+		{"5\nhello11\nhello", 7, true}, // truncated record
+		{"abc\nhello", 0, false},       // bad length
+		{"-1\n", 0, false},             // negative length
+		{"+5\nhello", 0, false},        // signed length
+		{" 5\nhello", 0, false},        // padded length
+		{"05\nhello", 0, false},        // leading zero
+		{"\nhello", 0, false},          // empty length
+		{"1234567890123456789012345\n", 0, false},
+		{"101\n", 0, false}, // exceeds maximum size
+	}
+	for _, test := range tests {
+		reader := NewReaderMax(strings.NewReader(test.stream), 100)
+		var err error
+		for err == nil {
+			_, err = reader.ReadRecord()
+		}
+		frameErr, ok := err.(*FrameError)
+		if !ok {
+			t.Fatalf("Expected FrameError for stream %q, but got %v", test.stream, err)
+		}
+		if frameErr.Offset != test.offset {
+			t.Fatalf("Expected error at offset %d for stream %q, but got %d", test.offset, test.stream, frameErr.Offset)
+		}
+		if test.truncated != (frameErr.Err == io.ErrUnexpectedEOF) {
+			t.Fatalf("Stream %q reported unexpected truncation state: %v", test.stream, err)
+		}
+	}
+}
+
+func TestReadRecord_ByteAtATime(t *testing.T) {
+	reader := NewReader(&oneByteReader{strings.NewReader("3\nabc2\nde")})
+	for _, expected := range []string{"abc", "de"} {
+		record, err := reader.ReadRecord()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(record) != expected {
+			t.Fatalf("Expected record %q, but got %q", expected, record)
+		}
+	}
+}
+
+func TestWriteRecord(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	for _, record := range []string{"hello", "", "hello world"} {
+		if err := writer.WriteRecord([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.String() != "5\nhello0\n11\nhello world" {
+		t.Fatalf("Unexpected stream %q", buf.String())
+	}
+}
+
+// TestRoundTrip checks that random records survive encoding and decoding.
+func TestRoundTrip(t *testing.T) {
+	roundTrip := func(records [][]byte) bool {
+		var buf bytes.Buffer
+		writer := NewWriter(&buf)
+		for _, record := range records {
+			if writer.WriteRecord(record) != nil {
+				return false
+			}
+		}
+		reader := NewReader(&oneByteReader{&buf})
+		for _, record := range records {
+			decoded, err := reader.ReadRecord()
+			if err != nil || !bytes.Equal(decoded, record) {
+				return false
+			}
+		}
+		_, err := reader.ReadRecord()
+		return err == io.EOF
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReadRecord_CorruptStreams feeds mutated and truncated streams to the
+// Reader, which must fail with a FrameError or io.EOF rather than panic.
+func TestReadRecord_CorruptStreams(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var valid bytes.Buffer
+	writer := NewWriter(&valid)
+	for i := 0; i < 20; i++ {
+		record := make([]byte, rnd.Intn(300))
+		rnd.Read(record)
+		writer.WriteRecord(record)
+	}
+
+	for i := 0; i < 2000; i++ {
+		stream := append([]byte(nil), valid.Bytes()...)
+		for n := rnd.Intn(4); n >= 0; n-- {
+			stream[rnd.Intn(len(stream))] = "0123456789\n-x"[rnd.Intn(13)]
+		}
+		stream = stream[:rnd.Intn(len(stream)+1)]
+
+		reader := NewReaderMax(bytes.NewReader(stream), 1024)
+		for {
+			record, err := reader.ReadRecord()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if _, ok := err.(*FrameError); !ok {
+					t.Fatal("Expected FrameError, but got", err)
+				}
+				break
+			}
+			if len(record) > 1024 {
+				t.Fatal("Reader returned record larger than maximum size.")
+			}
+		}
+	}
+}
+
+func BenchmarkReadRecord(b *testing.B) {
+	record := bytes.Repeat([]byte("x"), 1024)
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	for i := 0; i < 1000; i++ {
+		writer.WriteRecord(record)
+	}
+	stream := buf.Bytes()
+	b.SetBytes(int64(len(record)))
+	b.ResetTimer()
+	var reader *Reader
+	for i := 0; i < b.N; i++ {
+		if i%1000 == 0 {
+			reader = NewReader(bytes.NewReader(stream))
+		}
+		if _, err := reader.ReadRecord(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteRecord(b *testing.B) {
+	record := bytes.Repeat([]byte("x"), 1024)
+	writer := NewWriter(ioutil.Discard)
+	b.SetBytes(int64(len(record)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := writer.WriteRecord(record); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type oneByteReader struct {
+	reader io.Reader
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.reader.Read(p[:1])
+}
@@ -0,0 +1,98 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"strings"
+	"sync"
+)
+
+// MessageHandler handles a registered libprocess message received by the driver.
+type MessageHandler func(*SchedulerDriver, proto.Message)
+
+// builtinEvents are the messages delivered through the Scheduler callbacks.
+var builtinEvents = map[string]bool{
+	MESOS_INTERNAL_PREFIX + FRAMEWORK_REGISTERED_EVENT:   true,
+	MESOS_INTERNAL_PREFIX + FRAMEWORK_REREGISTERED_EVENT: true,
+	MESOS_INTERNAL_PREFIX + RESOURCE_OFFERS_EVENT:        true,
+	MESOS_INTERNAL_PREFIX + RESCIND_OFFER_EVENT:          true,
+	MESOS_INTERNAL_PREFIX + STATUS_UPDATE_EVENT:          true,
+	MESOS_INTERNAL_PREFIX + FRAMEWORK_MESSAGE_EVENT:      true,
+	MESOS_INTERNAL_PREFIX + LOST_SLAVE_EVENT:             true,
+}
+
+/*
+MessageRegistry maps libprocess message names, such as
+mesos.internal.StatusUpdateMessage, to protobuf types and optional handlers.
+A new registry knows every top-level message declared in mesosproto.
+*/
+type MessageRegistry struct {
+	mutex    sync.RWMutex
+	types    map[string]func() proto.Message
+	handlers map[string]MessageHandler
+}
+
+func NewMessageRegistry() *MessageRegistry {
+	registry := &MessageRegistry{
+		types:    make(map[string]func() proto.Message),
+		handlers: make(map[string]MessageHandler),
+	}
+	for name, newMsg := range mesos.MessageTypes {
+		if !strings.Contains(name, ".") {
+			registry.types[MESOS_INTERNAL_PREFIX+name] = newMsg
+		}
+	}
+	return registry
+}
+
+// Register maps name to the message type returned by newMsg.
+// When handler is not nil, it receives the decoded messages in place of the
+// Scheduler callback. A nil newMsg keeps the type already registered for name.
+func (registry *MessageRegistry) Register(name string, newMsg func() proto.Message, handler MessageHandler) error {
+	if name == "" {
+		return fmt.Errorf("Missing message name.")
+	}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if newMsg == nil {
+		newMsg = registry.types[name]
+	}
+	if newMsg == nil {
+		return fmt.Errorf("No message type registered for %s.", name)
+	}
+	registry.types[name] = newMsg
+	if handler != nil {
+		registry.handlers[name] = handler
+	} else {
+		delete(registry.handlers, name)
+	}
+	return nil
+}
+
+// New returns a new instance of the message registered under name.
+func (registry *MessageRegistry) New(name string) (proto.Message, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	newMsg, ok := registry.types[name]
+	if !ok {
+		return nil, false
+	}
+	return newMsg(), true
+}
+
+// Handler returns the handler registered for name, or nil.
+func (registry *MessageRegistry) Handler(name string) MessageHandler {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	return registry.handlers[name]
+}
+
+// messageEvent carries a message the driver has no built-in callback for.
+// msg is nil when name is not in the registry.
+type messageEvent struct {
+	name    string
+	msg     proto.Message
+	data    []byte
+	handler MessageHandler
+}
@@ -0,0 +1,176 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestNewMessageRegistry(t *testing.T) {
+	registry := NewMessageRegistry()
+	msg, ok := registry.New(MESOS_INTERNAL_PREFIX + STATUS_UPDATE_EVENT)
+	if !ok {
+		t.Fatal("MessageRegistry missing StatusUpdateMessage")
+	}
+	if _, ok := msg.(*mesos.StatusUpdateMessage); !ok {
+		t.Fatalf("MessageRegistry returned %T for StatusUpdateMessage", msg)
+	}
+	for _, name := range []string{"ReconcileTasksMessage", "FrameworkErrorMessage", "ReviveOffersMessage"} {
+		if _, ok := registry.New(MESOS_INTERNAL_PREFIX + name); !ok {
+			t.Fatal("MessageRegistry missing", name)
+		}
+	}
+	if _, ok := registry.New(MESOS_INTERNAL_PREFIX + "Value.Scalar"); ok {
+		t.Fatal("MessageRegistry should not register nested messages as libprocess names.")
+	}
+	if _, ok := mesos.MessageTypes["Value.Scalar"]; !ok {
+		t.Fatal("mesosproto.MessageTypes missing nested message Value.Scalar")
+	}
+}
+
+func TestMessageRegistryRegister(t *testing.T) {
+	registry := NewMessageRegistry()
+	handler := func(driver *SchedulerDriver, msg proto.Message) {}
+
+	err := registry.Register("custom.PingMessage", func() proto.Message { return new(mesos.FrameworkID) }, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.New("custom.PingMessage"); !ok {
+		t.Fatal("MessageRegistry missing custom message.")
+	}
+	if registry.Handler("custom.PingMessage") == nil {
+		t.Fatal("MessageRegistry missing custom handler.")
+	}
+
+	// handler for a message type already known
+	if err = registry.Register(MESOS_INTERNAL_PREFIX+"FrameworkErrorMessage", nil, handler); err != nil {
+		t.Fatal(err)
+	}
+	if registry.Handler(MESOS_INTERNAL_PREFIX+"FrameworkErrorMessage") == nil {
+		t.Fatal("MessageRegistry missing handler for FrameworkErrorMessage.")
+	}
+
+	if err = registry.Register("custom.Unknown", nil, handler); err == nil {
+		t.Fatal("Expected error registering handler without message type.")
+	}
+	if err = registry.Register("", nil, nil); err == nil {
+		t.Fatal("Expected error registering empty name.")
+	}
+}
+
+func TestSchedProc_RegisteredMessage(t *testing.T) {
+	eventQ := make(chan interface{}, 1)
+	proc := makeStartedProc(t, eventQ, SENDER_CHECK_LOG)
+	proc.registry.Register(MESOS_INTERNAL_PREFIX+"FrameworkErrorMessage", nil,
+		func(driver *SchedulerDriver, msg proto.Message) {})
+
+	data, _ := proto.Marshal(&mesos.FrameworkErrorMessage{Message: proto.String("boom")})
+	resp := httptest.NewRecorder()
+	proc.ServeHTTP(resp, buildHttpRequestFrom(t, "FrameworkErrorMessage", data, "master@127.0.0.1:5050"))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expecting server status %d but got status %d", http.StatusAccepted, resp.Code)
+	}
+	event, ok := (<-eventQ).(*messageEvent)
+	if !ok || event.handler == nil {
+		t.Fatal("Expected messageEvent with handler.")
+	}
+	if msg, ok := event.msg.(*mesos.FrameworkErrorMessage); !ok || msg.GetMessage() != "boom" {
+		t.Fatal("Expected decoded FrameworkErrorMessage, got", event.msg)
+	}
+}
+
+func TestSchedProc_UnknownMessage(t *testing.T) {
+	eventQ := make(chan interface{}, 1)
+	proc := makeStartedProc(t, eventQ, SENDER_CHECK_LOG)
+
+	resp := httptest.NewRecorder()
+	proc.ServeHTTP(resp, buildHttpRequestFrom(t, "NewerMasterMessage", []byte("data"), "master@127.0.0.1:5050"))
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("Expecting server status %d but got status %d", http.StatusBadRequest, resp.Code)
+	}
+	if proc.metrics.snapshot().UnknownMessages != 1 {
+		t.Fatal("Expected unknown message to be counted.")
+	}
+
+	proc.catchAll = true
+	resp = httptest.NewRecorder()
+	proc.ServeHTTP(resp, buildHttpRequestFrom(t, "NewerMasterMessage", []byte("data"), "master@127.0.0.1:5050"))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expecting server status %d but got status %d", http.StatusAccepted, resp.Code)
+	}
+	event, ok := (<-eventQ).(*messageEvent)
+	if !ok || event.msg != nil || string(event.data) != "data" {
+		t.Fatal("Expected raw messageEvent for catch-all handler.")
+	}
+	if event.name != MESOS_INTERNAL_PREFIX+"NewerMasterMessage" {
+		t.Fatal("Expected messageEvent name, got", event.name)
+	}
+}
+
+func TestDriverMessageHandlers(t *testing.T) {
+	received := make(chan string, 2)
+	sched := NewMesosScheduler()
+	sched.UnhandledMessage = func(driver *SchedulerDriver, name string, data []byte) {
+		received <- name + ":" + string(data)
+	}
+	driver, err := NewSchedDriver(sched, &mesos.FrameworkInfo{}, "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.Messages.Register("custom.PingMessage", func() proto.Message { return new(mesos.FrameworkID) },
+		func(driver *SchedulerDriver, msg proto.Message) {
+			received <- "ping:" + msg.(*mesos.FrameworkID).GetValue()
+		})
+
+	driver.schedMsgQ <- &messageEvent{
+		name:    "custom.PingMessage",
+		msg:     NewFrameworkID("framework-1"),
+		handler: driver.Messages.Handler("custom.PingMessage"),
+	}
+	driver.schedMsgQ <- &messageEvent{name: "custom.Other", data: []byte("raw")}
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case value := <-received:
+			got[value] = true
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message handlers.")
+		}
+	}
+	if !got["ping:framework-1"] || !got["custom.Other:raw"] {
+		t.Fatal("Message handlers not invoked as expected, got", got)
+	}
+}
+
+func TestDriverStart_WithoutMessages(t *testing.T) {
+	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	driver, err := NewSchedDriver(NewMesosScheduler(), NewFrameworkInfo("test", "test-framework", nil), u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.Messages = nil
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", stat)
+	}
+	defer driver.schedProc.stop()
+	if driver.Messages == nil || driver.schedProc.registry != driver.Messages {
+		t.Fatal("Expected Start to fall back to a new MessageRegistry.")
+	}
+
+	data, _ := proto.Marshal(&mesos.RescindResourceOfferMessage{OfferId: NewOfferID("offer-1")})
+	resp := httptest.NewRecorder()
+	driver.schedProc.ServeHTTP(resp, buildHttpRequestFrom(t, RESCIND_OFFER_EVENT, data, "master@"+u.Host))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expecting server status %d but got status %d", http.StatusAccepted, resp.Code)
+	}
+}
@@ -0,0 +1,353 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"math"
+	"strings"
+)
+
+// Names of the resources known to Mesos.
+const (
+	RESOURCE_CPUS  = "cpus"
+	RESOURCE_MEM   = "mem"
+	RESOURCE_DISK  = "disk"
+	RESOURCE_PORTS = "ports"
+)
+
+// value types required for the known resources.
+var resourceTypes = map[string]mesos.Value_Type{
+	RESOURCE_CPUS:  mesos.Value_SCALAR,
+	RESOURCE_MEM:   mesos.Value_SCALAR,
+	RESOURCE_DISK:  mesos.Value_SCALAR,
+	RESOURCE_PORTS: mesos.Value_RANGES,
+}
+
+/*
+Resources is a list of resources, i.e. the resources of an offer or the
+resources used by a task. A resource is identified by its name and role.
+Add and Subtract merge the values of resources with the same identity and
+return new Resources, leaving their operands unchanged. As in Mesos, scalar
+values are rounded to 3 decimals.
+*/
+type Resources []*mesos.Resource
+
+// Validate checks every resource, and that each name is used with a single type.
+func (resources Resources) Validate() error {
+	types := make(map[string]mesos.Value_Type)
+	for _, resource := range resources {
+		if err := validateResource(resource); err != nil {
+			return err
+		}
+		name := resource.GetName()
+		if t, ok := types[name]; ok && t != resource.GetType() {
+			return fmt.Errorf("Resource %s used with types %s and %s.", name, t, resource.GetType())
+		}
+		types[name] = resource.GetType()
+	}
+	return nil
+}
+
+// Add returns the sum of resources and other.
+func (resources Resources) Add(other Resources) (Resources, error) {
+	if err := resources.Validate(); err != nil {
+		return nil, err
+	}
+	if err := other.Validate(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	result := Resources{}
+	for _, resource := range append(resources[:len(resources):len(resources)], other...) {
+		if result, err = result.add(resource); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Subtract returns resources minus other. It fails when other is not
+// contained in resources, rather than returning negative resources.
+func (resources Resources) Subtract(other Resources) (Resources, error) {
+	result, err := resources.Add(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := other.Validate(); err != nil {
+		return nil, err
+	}
+	for _, resource := range other {
+		if result, err = result.subtract(resource); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Contains reports whether other can be subtracted from resources.
+func (resources Resources) Contains(other Resources) bool {
+	_, err := resources.Subtract(other)
+	return err == nil
+}
+
+// Equal reports whether resources and other hold the same values,
+// regardless of order or of how the values are split among resources.
+func (resources Resources) Equal(other Resources) bool {
+	return resources.Contains(other) && other.Contains(resources)
+}
+
+// Flatten returns resources with every role replaced by role, merging the
+// values of resources with the same name. Invalid resources are dropped.
+func (resources Resources) Flatten(role string) Resources {
+	result := Resources{}
+	for _, resource := range resources {
+		if validateResource(resource) != nil {
+			continue
+		}
+		flat := proto.Clone(resource).(*mesos.Resource)
+		flat.Role = proto.String(role)
+		if merged, err := result.add(flat); err == nil {
+			result = merged
+		}
+	}
+	return result
+}
+
+// Filter returns the resources reserved to role.
+func (resources Resources) Filter(role string) Resources {
+	result := Resources{}
+	for _, resource := range resources {
+		if resource.GetRole() == role {
+			result = append(result, resource)
+		}
+	}
+	return result
+}
+
+// Scalar returns the sum of the scalar resources named name, for all roles.
+func (resources Resources) Scalar(name string) float64 {
+	sum := 0.0
+	for _, resource := range resources {
+		if resource.GetName() == name && resource.GetType() == mesos.Value_SCALAR {
+			sum += resource.GetScalar().GetValue()
+		}
+	}
+	return roundScalar(sum)
+}
+
+func (resources Resources) CPUs() float64 {
+	return resources.Scalar(RESOURCE_CPUS)
+}
+
+func (resources Resources) Mem() float64 {
+	return resources.Scalar(RESOURCE_MEM)
+}
+
+func (resources Resources) Disk() float64 {
+	return resources.Scalar(RESOURCE_DISK)
+}
+
+// Ports returns the coalesced port ranges, for all roles.
+func (resources Resources) Ports() []*mesos.Value_Range {
+	ports := []*mesos.Value_Range{}
+	for _, resource := range resources {
+		if resource.GetName() == RESOURCE_PORTS && resource.GetType() == mesos.Value_RANGES {
+			ports = append(ports, resource.GetRanges().GetRange()...)
+		}
+	}
+	return CoalesceRanges(ports)
+}
+
+// add merges resource into resources. The resources of the receiver are
+// modified, they must be owned by the caller.
+func (resources Resources) add(resource *mesos.Resource) (Resources, error) {
+	for _, r := range resources {
+		if r.GetName() != resource.GetName() {
+			continue
+		}
+		if r.GetType() != resource.GetType() {
+			return nil, fmt.Errorf("Resource %s used with types %s and %s.", r.GetName(), r.GetType(), resource.GetType())
+		}
+		if r.GetRole() != resource.GetRole() {
+			continue
+		}
+
+		switch r.GetType() {
+		case mesos.Value_SCALAR:
+			sum := roundScalar(r.GetScalar().GetValue() + resource.GetScalar().GetValue())
+			if math.IsInf(sum, 0) {
+				return nil, fmt.Errorf("Resource %s overflows.", resourceKey(r))
+			}
+			r.Scalar = &mesos.Value_Scalar{Value: proto.Float64(sum)}
+		case mesos.Value_RANGES:
+			ranges := append([]*mesos.Value_Range{}, r.GetRanges().GetRange()...)
+			ranges = append(ranges, resource.GetRanges().GetRange()...)
+			r.Ranges = &mesos.Value_Ranges{Range: CoalesceRanges(ranges)}
+		case mesos.Value_SET:
+			items := append([]string{}, r.GetSet().GetItem()...)
+			for _, item := range resource.GetSet().GetItem() {
+				if !containsString(items, item) {
+					items = append(items, item)
+				}
+			}
+			r.Set = &mesos.Value_Set{Item: items}
+		}
+		return resources, nil
+	}
+
+	clone := proto.Clone(resource).(*mesos.Resource)
+	switch clone.GetType() {
+	case mesos.Value_SCALAR:
+		clone.Scalar.Value = proto.Float64(roundScalar(clone.GetScalar().GetValue()))
+	case mesos.Value_RANGES:
+		clone.Ranges.Range = CoalesceRanges(clone.GetRanges().GetRange())
+	}
+	if isEmptyResource(clone) {
+		return resources, nil
+	}
+	return append(resources, clone), nil
+}
+
+// subtract removes resource from resources. The resources of the receiver
+// are modified, they must be owned by the caller.
+func (resources Resources) subtract(resource *mesos.Resource) (Resources, error) {
+	if isEmptyResource(resource) {
+		return resources, nil
+	}
+	for i, r := range resources {
+		if r.GetName() != resource.GetName() || r.GetRole() != resource.GetRole() || r.GetType() != resource.GetType() {
+			continue
+		}
+
+		switch r.GetType() {
+		case mesos.Value_SCALAR:
+			have, want := r.GetScalar().GetValue(), resource.GetScalar().GetValue()
+			diff := roundScalar(have - want)
+			if diff < 0 {
+				return nil, fmt.Errorf("Insufficient %s: %v available, %v requested.", resourceKey(r), have, want)
+			}
+			r.Scalar = &mesos.Value_Scalar{Value: proto.Float64(diff)}
+		case mesos.Value_RANGES:
+			if !RangesContain(r.GetRanges().GetRange(), resource.GetRanges().GetRange()) {
+				return nil, fmt.Errorf("Insufficient %s: requested ranges are not available.", resourceKey(r))
+			}
+			r.Ranges = &mesos.Value_Ranges{Range: SubtractRanges(r.GetRanges().GetRange(), resource.GetRanges().GetRange())}
+		case mesos.Value_SET:
+			items := []string{}
+			for _, item := range resource.GetSet().GetItem() {
+				if !containsString(r.GetSet().GetItem(), item) {
+					return nil, fmt.Errorf("Insufficient %s: item %s is not available.", resourceKey(r), item)
+				}
+			}
+			for _, item := range r.GetSet().GetItem() {
+				if !containsString(resource.GetSet().GetItem(), item) {
+					items = append(items, item)
+				}
+			}
+			r.Set = &mesos.Value_Set{Item: items}
+		}
+
+		if isEmptyResource(r) {
+			resources = append(resources[:i], resources[i+1:]...)
+		}
+		return resources, nil
+	}
+	return nil, fmt.Errorf("Insufficient %s: resource is not available.", resourceKey(resource))
+}
+
+func validateResource(resource *mesos.Resource) error {
+	if resource == nil {
+		return fmt.Errorf("Missing resource.")
+	}
+	name := resource.GetName()
+	if name == "" {
+		return fmt.Errorf("Resource is missing a name.")
+	}
+	if resource.Type == nil {
+		return fmt.Errorf("Resource %s is missing a type.", name)
+	}
+	if expected, ok := resourceTypes[name]; ok && expected != resource.GetType() {
+		return fmt.Errorf("Resource %s must be of type %s, not %s.", name, expected, resource.GetType())
+	}
+	if err := validateRole(resource.GetRole()); err != nil {
+		return err
+	}
+
+	switch resource.GetType() {
+	case mesos.Value_SCALAR:
+		if resource.Scalar == nil || resource.Ranges != nil || resource.Set != nil {
+			return fmt.Errorf("Resource %s must only have a scalar value.", name)
+		}
+		value := resource.GetScalar().GetValue()
+		if math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+			return fmt.Errorf("Resource %s has invalid scalar value %v.", name, value)
+		}
+	case mesos.Value_RANGES:
+		if resource.Ranges == nil || resource.Scalar != nil || resource.Set != nil {
+			return fmt.Errorf("Resource %s must only have a ranges value.", name)
+		}
+		for _, r := range resource.GetRanges().GetRange() {
+			if r.GetBegin() > r.GetEnd() {
+				return fmt.Errorf("Resource %s has invalid range [%d-%d].", name, r.GetBegin(), r.GetEnd())
+			}
+		}
+	case mesos.Value_SET:
+		if resource.Set == nil || resource.Scalar != nil || resource.Ranges != nil {
+			return fmt.Errorf("Resource %s must only have a set value.", name)
+		}
+		items := resource.GetSet().GetItem()
+		for i, item := range items {
+			if containsString(items[:i], item) {
+				return fmt.Errorf("Resource %s has duplicate item %s.", name, item)
+			}
+		}
+	default:
+		return fmt.Errorf("Resource %s has unsupported type %s.", name, resource.GetType())
+	}
+	return nil
+}
+
+// validateRole applies the role name rules of Mesos.
+func validateRole(role string) error {
+	if role == "" || role == "." || role == ".." || strings.HasPrefix(role, "-") ||
+		strings.ContainsAny(role, "/\\ \t\n") {
+		return fmt.Errorf("Invalid role [%s].", role)
+	}
+	return nil
+}
+
+func isEmptyResource(resource *mesos.Resource) bool {
+	switch resource.GetType() {
+	case mesos.Value_SCALAR:
+		return resource.GetScalar().GetValue() == 0
+	case mesos.Value_RANGES:
+		return len(resource.GetRanges().GetRange()) == 0
+	case mesos.Value_SET:
+		return len(resource.GetSet().GetItem()) == 0
+	}
+	return false
+}
+
+// resourceKey identifies resource in error messages, i.e. cpus(*).
+func resourceKey(resource *mesos.Resource) string {
+	return resource.GetName() + "(" + resource.GetRole() + ")"
+}
+
+// roundScalar rounds value to 3 decimals, the precision used by Mesos.
+func roundScalar(value float64) float64 {
+	if math.IsInf(value, 0) || math.IsNaN(value) || math.Abs(value) > 1e15 {
+		return value
+	}
+	return math.Floor(value*1000+0.5) / 1000
+}
+
+func containsString(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
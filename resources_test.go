@@ -0,0 +1,215 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"math"
+	"testing"
+)
+
+func withRole(resource *mesos.Resource, role string) *mesos.Resource {
+	resource.Role = proto.String(role)
+	return resource
+}
+
+func TestResourcesValidate(t *testing.T) {
+	valid := Resources{
+		NewScalarResource("cpus", 2),
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(31000, 32000)}),
+		NewSetResource("disks", []string{"sda", "sdb"}),
+		withRole(NewScalarResource("mem", 512), "prod"),
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatal("Expected valid resources, but got", err)
+	}
+
+	invalid := []*mesos.Resource{
+		nil,
+		NewScalarResource("", 1),
+		&mesos.Resource{Name: proto.String("cpus")},
+		NewScalarResource("cpus", -1),
+		NewScalarResource("cpus", math.NaN()),
+		NewScalarResource("cpus", math.Inf(1)),
+		NewSetResource("cpus", []string{"a"}),
+		NewScalarResource("ports", 1),
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(10, 5)}),
+		NewSetResource("disks", []string{"sda", "sda"}),
+		withRole(NewScalarResource("mem", 1), ""),
+		withRole(NewScalarResource("mem", 1), "a/b"),
+		withRole(NewScalarResource("mem", 1), ".."),
+		&mesos.Resource{
+			Name:   proto.String("mem"),
+			Type:   mesos.Value_SCALAR.Enum(),
+			Scalar: &mesos.Value_Scalar{Value: proto.Float64(1)},
+			Set:    &mesos.Value_Set{Item: []string{"a"}},
+		},
+		&mesos.Resource{
+			Name: proto.String("notes"),
+			Type: mesos.Value_TEXT.Enum(),
+		},
+	}
+	for _, resource := range invalid {
+		if err := (Resources{resource}).Validate(); err == nil {
+			t.Fatal("Expected validation error for", resource)
+		}
+	}
+
+	mixed := Resources{NewScalarResource("gpus", 1), withRole(NewSetResource("gpus", []string{"a"}), "prod")}
+	if err := mixed.Validate(); err == nil {
+		t.Fatal("Expected validation error for name used with two types.")
+	}
+}
+
+func TestResourcesAdd(t *testing.T) {
+	a := Resources{
+		NewScalarResource("cpus", 1.5),
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(1000, 1999)}),
+		NewSetResource("disks", []string{"sda"}),
+	}
+	b := Resources{
+		NewScalarResource("cpus", 0.1),
+		withRole(NewScalarResource("cpus", 4), "prod"),
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(2000, 2999), NewValueRange(5000, 5000)}),
+		NewSetResource("disks", []string{"sda", "sdb"}),
+	}
+	sum, err := a.Add(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sum) != 4 {
+		t.Fatal("Expected 4 merged resources, but got", len(sum))
+	}
+	if sum.Filter("*").CPUs() != 1.6 || sum.CPUs() != 5.6 {
+		t.Fatal("Unexpected cpus after Add:", sum.Filter("*").CPUs(), sum.CPUs())
+	}
+	ports := sum.Ports()
+	if len(ports) != 2 || ports[0].GetBegin() != 1000 || ports[0].GetEnd() != 2999 || ports[1].GetBegin() != 5000 {
+		t.Fatal("Expected adjacent port ranges to be coalesced, but got", ports)
+	}
+	for _, resource := range sum {
+		if resource.GetName() == "disks" && len(resource.GetSet().GetItem()) != 2 {
+			t.Fatal("Expected set union of 2 items, but got", resource.GetSet().GetItem())
+		}
+	}
+	if a.CPUs() != 1.5 || len(a.Ports()) != 1 {
+		t.Fatal("Add modified its operand.")
+	}
+
+	_, err = Resources{NewScalarResource("gpus", 1)}.Add(Resources{NewSetResource("gpus", []string{"a"})})
+	if err == nil {
+		t.Fatal("Expected error when adding resources of different types.")
+	}
+	huge := Resources{NewScalarResource("mem", math.MaxFloat64)}
+	if _, err = huge.Add(huge); err == nil {
+		t.Fatal("Expected overflow error.")
+	}
+}
+
+func TestResourcesSubtract(t *testing.T) {
+	offer := Resources{
+		NewScalarResource("cpus", 4),
+		NewScalarResource("mem", 1024),
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(31000, 31009)}),
+		NewSetResource("disks", []string{"sda", "sdb"}),
+	}
+	task := Resources{
+		NewScalarResource("cpus", 0.3),
+		NewScalarResource("mem", 1024),
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(31002, 31003)}),
+		NewSetResource("disks", []string{"sdb"}),
+	}
+	left, err := offer.Subtract(task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left.CPUs() != 3.7 {
+		t.Fatal("Expected 3.7 cpus left, but got", left.CPUs())
+	}
+	if left.Mem() != 0 || len(left.Filter("*")) != 3 {
+		t.Fatal("Expected exhausted mem to be removed, but got", left)
+	}
+	ports := left.Ports()
+	if len(ports) != 2 || ports[0].GetEnd() != 31001 || ports[1].GetBegin() != 31004 {
+		t.Fatal("Unexpected ports left:", ports)
+	}
+
+	failures := []Resources{
+		Resources{NewScalarResource("cpus", 4.001)},
+		Resources{withRole(NewScalarResource("cpus", 1), "prod")},
+		Resources{NewScalarResource("gpus", 1)},
+		Resources{NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(31009, 31010)})},
+		Resources{NewSetResource("disks", []string{"sdc"})},
+	}
+	for _, request := range failures {
+		if _, err := offer.Subtract(request); err == nil {
+			t.Fatal("Expected error subtracting", request)
+		}
+		if offer.Contains(request) {
+			t.Fatal("Resources should not contain", request)
+		}
+	}
+	if offer.CPUs() != 4 || offer.Mem() != 1024 {
+		t.Fatal("Subtract modified its operand.")
+	}
+}
+
+func TestResourcesContainsAndEqual(t *testing.T) {
+	a := Resources{
+		NewScalarResource("cpus", 1),
+		NewScalarResource("cpus", 1),
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(1, 10)}),
+	}
+	b := Resources{
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(6, 10), NewValueRange(1, 5)}),
+		NewScalarResource("cpus", 2),
+	}
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Fatal("Expected resources to be equal regardless of order and split.")
+	}
+	if !a.Contains(Resources{NewScalarResource("cpus", 0.5)}) {
+		t.Fatal("Expected resources to contain half a cpu.")
+	}
+	if a.Equal(Resources{NewScalarResource("cpus", 2)}) {
+		t.Fatal("Resources without ports should not be equal.")
+	}
+	if !(Resources{}).Equal(Resources{NewScalarResource("cpus", 0)}) {
+		t.Fatal("Empty resources should equal zero resources.")
+	}
+}
+
+func TestResourcesFlattenAndFilter(t *testing.T) {
+	resources := Resources{
+		NewScalarResource("cpus", 1),
+		withRole(NewScalarResource("cpus", 2), "prod"),
+		withRole(NewScalarResource("mem", 64), "prod"),
+	}
+	if len(resources.Filter("prod")) != 2 || len(resources.Filter("*")) != 1 || len(resources.Filter("dev")) != 0 {
+		t.Fatal("Filter returned unexpected resources.")
+	}
+
+	flat := resources.Flatten("*")
+	if len(flat) != 2 || len(flat.Filter("*")) != 2 || flat.CPUs() != 3 {
+		t.Fatal("Flatten expected cpus(*):3 and mem(*):64, but got", flat)
+	}
+	if resources[1].GetRole() != "prod" {
+		t.Fatal("Flatten modified its receiver.")
+	}
+}
+
+func TestResourcesAccessors(t *testing.T) {
+	resources := Resources{
+		NewScalarResource("cpus", 0.1),
+		NewScalarResource("cpus", 0.2),
+		NewScalarResource("mem", 128),
+		NewScalarResource("disk", 1024),
+		NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(100, 200)}),
+		withRole(NewRangesResource("ports", []*mesos.Value_Range{NewValueRange(150, 300)}), "prod"),
+	}
+	if resources.CPUs() != 0.3 || resources.Mem() != 128 || resources.Disk() != 1024 {
+		t.Fatal("Unexpected scalar accessors:", resources.CPUs(), resources.Mem(), resources.Disk())
+	}
+	ports := resources.Ports()
+	if len(ports) != 1 || ports[0].GetBegin() != 100 || ports[0].GetEnd() != 300 {
+		t.Fatal("Expected ports [100-300], but got", ports)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	mesos "github.com/vladimirvivien/gomes/mesosproto"
 	"io/ioutil"
+	"log"
 	"net"
 	"net/http"
 	"net/url"
@@ -12,6 +13,17 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+)
+
+// SenderCheck selects what the scheduler process does with an event whose
+// libprocess sender is not the leading master (or, for framework messages,
+// a slave known from earlier offers).
+type SenderCheck int
+
+const (
+	SENDER_CHECK_LOG    SenderCheck = iota // log and count the event, then deliver it
+	SENDER_CHECK_REJECT                    // log, count and drop the event
 )
 
 var schedIdMutex = new(sync.Mutex)
@@ -41,13 +53,26 @@ SchedHttpProcess manages http requests from the connected master.
 It wraps the standard Http Server.
 */
 type schedulerProcess struct {
-	listener  net.Listener
-	server    *http.Server
-	processId schedProcID
-	eventMsgQ chan<- interface{}
-	controlQ  chan int32
-	started   bool
-	aborted   bool
+	listener      net.Listener
+	server        *http.Server
+	processId     schedProcID
+	eventMsgQ     chan<- interface{}
+	controlQ      chan int32
+	started       bool
+	aborted       bool
+	bindIP        string
+	bindPort      int
+	advertiseIP   string
+	advertisePort int
+	tls           *TLSConfig
+	senderCheck   SenderCheck
+	metrics       procMetrics
+	registry      *MessageRegistry
+	catchAll      bool
+
+	sendersMutex sync.RWMutex
+	masterAddrs  map[string]bool // host:port the leading master may send from
+	slaveAddrs   map[string]bool // host:port of slaves seen in offers
 }
 
 // newSchedHttpProcess creates and starts htttp process.
@@ -64,25 +89,41 @@ func newSchedulerProcess(eventQ chan<- interface{}) (*schedulerProcess, error) {
 	}
 
 	proc := &schedulerProcess{
-		server:    serv,
-		eventMsgQ: eventQ,
-		controlQ:  make(chan int32),
-		started:   false,
-		aborted:   false,
+		server:      serv,
+		eventMsgQ:   eventQ,
+		controlQ:    make(chan int32),
+		started:     false,
+		aborted:     false,
+		registry:    NewMessageRegistry(),
+		masterAddrs: make(map[string]bool),
+		slaveAddrs:  make(map[string]bool),
 	}
 
 	return proc, nil
 }
 
 // start Starts the internal http process to listen to incoming events from Master.
+// The listener is bound to bindIP:bindPort (port 0 lets the OS pick a free port)
+// while the process id sent to the master uses the advertised address.
 func (proc *schedulerProcess) start() error {
-	addr := fmt.Sprintf("%s:%d", localIP4String(), nextTcpPort())
-	listener, err := net.Listen("tcp", addr)
+	bindIP := proc.bindIP
+	if bindIP == "" {
+		bindIP = localIPString()
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(bindIP, strconv.Itoa(proc.bindPort)))
 	if err != nil {
 		return err
 	}
+	if proc.tls != nil {
+		tlsListener, err := newTLSListener(listener, proc.tls)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		listener = tlsListener
+	}
 	proc.listener = listener
-	proc.processId = newSchedProcID(addr)
+	proc.processId = newSchedProcID(proc.advertisedAddr())
 	proc.registerEventHandlers()
 
 	// launch internal http.server
@@ -97,7 +138,7 @@ func (proc *schedulerProcess) start() error {
 	}(proc.listener)
 
 	// ping proc.server listening at proc.listener.Addr()
-	rsp, err := http.Get("http://" + proc.listener.Addr().String() + "/isalive")
+	rsp, err := proc.pingClient().Get(proc.scheme() + "://" + loopbackAddr(proc.listener.Addr(), bindIP) + "/isalive")
 	if err != nil {
 		return err
 	}
@@ -108,6 +149,43 @@ func (proc *schedulerProcess) start() error {
 	return nil
 }
 
+// scheme returns the URL scheme served by the process listener.
+func (proc *schedulerProcess) scheme() string {
+	if proc.tls != nil {
+		return HTTPS_SCHEME
+	}
+	return HTTP_SCHEME
+}
+
+// pingClient returns an http client able to reach the process' own listener.
+func (proc *schedulerProcess) pingClient() *http.Client {
+	if proc.tls == nil {
+		return http.DefaultClient
+	}
+	// the ping dials the loopback address, so the certificate host can't be verified.
+	config := proc.tls.clientConfig()
+	config.InsecureSkipVerify = true
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+// advertisedAddr returns the host:port the master should use to reach this process.
+// Unset advertise values fall back to the address the listener is bound to.
+func (proc *schedulerProcess) advertisedAddr() string {
+	boundAddr := proc.listener.Addr().(*net.TCPAddr)
+	host := proc.advertiseIP
+	if host == "" {
+		host = boundAddr.IP.String()
+		if boundAddr.IP.IsUnspecified() {
+			host = localIPString()
+		}
+	}
+	port := proc.advertisePort
+	if port == 0 {
+		port = boundAddr.Port
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
 // stop Stops the Scheduler process and internal server.
 func (proc *schedulerProcess) stop() error {
 	//TODO Needs a better way than this.
@@ -120,6 +198,7 @@ func (proc *schedulerProcess) stop() error {
 }
 
 // registerEventHandlers Registers http handlers for Mesos master events.
+// Every message posted under /<process-prefix>/ is dispatched by ServeHTTP.
 func (proc *schedulerProcess) registerEventHandlers() {
 	//TODO hack: only way to clear default mux.
 	//     fix - maybe use custom mux
@@ -128,13 +207,7 @@ func (proc *schedulerProcess) registerEventHandlers() {
 		rsp.WriteHeader(http.StatusOK)
 	})
 
-	http.Handle(makeProcEventPath(proc, FRAMEWORK_REGISTERED_EVENT), proc)
-	http.Handle(makeProcEventPath(proc, FRAMEWORK_REREGISTERED_EVENT), proc)
-	http.Handle(makeProcEventPath(proc, RESOURCE_OFFERS_EVENT), proc)
-	http.Handle(makeProcEventPath(proc, RESCIND_OFFER_EVENT), proc)
-	http.Handle(makeProcEventPath(proc, STATUS_UPDATE_EVENT), proc)
-	http.Handle(makeProcEventPath(proc, FRAMEWORK_MESSAGE_EVENT), proc)
-	http.Handle(makeProcEventPath(proc, LOST_SLAVE_EVENT), proc)
+	http.Handle("/"+proc.processId.prefix+"/", proc)
 }
 
 func (proc *schedulerProcess) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
@@ -142,9 +215,8 @@ func (proc *schedulerProcess) ServeHTTP(rsp http.ResponseWriter, req *http.Reque
 	var comment string = ""
 
 	// decompose incoming request path of form:
-	// /scheduler(?)/mesos.internal.<MessageTypeNamee>
-	_, internalName := path.Split(req.URL.Path)      // returns mesos.internal.<MessageTypeName>
-	messageParts := strings.Split(internalName, ".") // last index is messageType.
+	// /scheduler(?)/<MessageName>, i.e. /scheduler(1)/mesos.internal.StatusUpdateMessage
+	_, messageName := path.Split(req.URL.Path)
 
 	// do not continue if marked aborted or stopped.
 	if proc.aborted || !proc.started {
@@ -154,73 +226,138 @@ func (proc *schedulerProcess) ServeHTTP(rsp http.ResponseWriter, req *http.Reque
 		code = http.StatusMethodNotAllowed
 	} else
 	// if request path is badly formed
-	if len(messageParts) != 3 {
+	if messageName == "" {
 		err := NewMesosError("Event posted by master is malformed:" + req.URL.Path)
 		proc.eventMsgQ <- err
 		code = http.StatusBadRequest
 		comment = "Request path malformed."
+	} else if err := proc.verifySender(messageName, req); err != nil && proc.senderCheck == SENDER_CHECK_REJECT {
+		log.Println("Rejecting event:", err)
+		atomic.AddUint64(&proc.metrics.eventsRejected, 1)
+		code = http.StatusForbidden
+		comment = err.Error()
 	} else {
-		messageType := messageParts[2]
+		if err != nil {
+			log.Println("WARN:", err)
+		}
 
 		data, err := ioutil.ReadAll(req.Body)
+		defer req.Body.Close()
 		if err != nil {
 			code = http.StatusBadRequest
 			comment = "Request body missing."
+		} else {
+			code, comment = proc.dispatch(messageName, data)
 		}
-		defer req.Body.Close()
-
-		// dispatch msg based on type
-		var msg proto.Message
-		switch messageType {
-		case FRAMEWORK_REGISTERED_EVENT:
-			msg = new(mesos.FrameworkRegisteredMessage)
-			err = proto.Unmarshal(data, msg)
-
-		case FRAMEWORK_REREGISTERED_EVENT:
-			msg = new(mesos.FrameworkReregisteredMessage)
-			err = proto.Unmarshal(data, msg)
-
-		case RESOURCE_OFFERS_EVENT:
-			msg = new(mesos.ResourceOffersMessage)
-			err = proto.Unmarshal(data, msg)
+	}
 
-		case RESCIND_OFFER_EVENT:
-			msg = new(mesos.RescindResourceOfferMessage)
-			err = proto.Unmarshal(data, msg)
+	rsp.WriteHeader(code)
+	if comment != "" {
+		fmt.Fprintln(rsp, comment)
+	}
+}
 
-		case STATUS_UPDATE_EVENT:
-			msg = new(mesos.StatusUpdateMessage)
-			err = proto.Unmarshal(data, msg)
+// dispatch decodes data using the message registry and queues the event.
+// Messages without a built-in callback or registered handler are queued for
+// the catch-all handler when one is set, otherwise they are refused.
+func (proc *schedulerProcess) dispatch(messageName string, data []byte) (int, string) {
+	msg, known := proc.registry.New(messageName)
+	handler := proc.registry.Handler(messageName)
+	if !known || (handler == nil && !builtinEvents[messageName]) {
+		if !proc.catchAll {
+			atomic.AddUint64(&proc.metrics.unknownMessages, 1)
+			comment := fmt.Sprintf("Unable to parse event from master: %s unrecognized.", messageName)
+			log.Println(comment)
+			return http.StatusBadRequest, comment
+		}
+		if !known {
+			atomic.AddUint64(&proc.metrics.unknownMessages, 1)
+		}
+		msg = nil
+	}
 
-		case FRAMEWORK_MESSAGE_EVENT:
-			msg = new(mesos.ExecutorToFrameworkMessage)
-			err = proto.Unmarshal(data, msg)
+	if msg != nil {
+		if err := proto.Unmarshal(data, msg); err != nil {
+			comment := fmt.Sprintf("Error unmashalling %s: %s", messageName, err.Error())
+			proc.eventMsgQ <- NewMesosError(comment)
+			return http.StatusBadRequest, comment
+		}
+		if offers, ok := msg.(*mesos.ResourceOffersMessage); ok {
+			proc.trustSlaves(offers.Pids)
+		}
+	}
 
-		case LOST_SLAVE_EVENT:
-			msg = new(mesos.LostSlaveMessage)
-			err = proto.Unmarshal(data, msg)
+	atomic.AddUint64(&proc.metrics.eventsReceived, 1)
+	if handler == nil && msg != nil {
+		proc.eventMsgQ <- msg
+	} else {
+		proc.eventMsgQ <- &messageEvent{name: messageName, msg: msg, data: data, handler: handler}
+	}
+	return http.StatusAccepted, ""
+}
 
-		default:
-			err = fmt.Errorf("Unable to parse event from master: %s unrecognized.", messageType)
-			code = http.StatusBadRequest
-			comment = err.Error()
-		}
+// trustMaster makes the master at addrs the only accepted sender of master
+// events. Hosts are not resolved here, as this runs on the event loop: the
+// caller passes every address the master may send from, see resolveHostPort.
+func (proc *schedulerProcess) trustMaster(addrs ...string) {
+	trusted := make(map[string]bool)
+	for _, addr := range addrs {
+		trusted[normalizeHostPort(addr)] = true
+	}
+	proc.sendersMutex.Lock()
+	proc.masterAddrs = trusted
+	proc.sendersMutex.Unlock()
+}
 
+// trustSlaves accepts the slave pids listed in a ResourceOffersMessage as
+// senders of framework messages. It runs on the request path, so pids are
+// trusted as given and never resolved: a slave sends under its own pid.
+func (proc *schedulerProcess) trustSlaves(pids []string) {
+	for _, value := range pids {
+		pid, err := parseUPID(value)
 		if err != nil {
-			code = http.StatusBadRequest
-			comment = fmt.Sprintf("Error unmashalling %s: %s", messageType, err.Error())
-			proc.eventMsgQ <- NewMesosError(comment)
-		} else {
-			proc.eventMsgQ <- msg
+			continue
 		}
+		hostPort := normalizeHostPort(pid.hostPort())
+		proc.sendersMutex.Lock()
+		proc.slaveAddrs[hostPort] = true
+		proc.sendersMutex.Unlock()
 	}
+}
 
-	rsp.WriteHeader(code)
-	if comment != "" {
-		fmt.Fprintln(rsp, comment)
+// verifySender checks the libprocess sender of req against the leading master.
+// Framework messages may also come from a known slave. Senders are compared
+// with the addresses master and slaves were trusted under, nothing is
+// resolved while a request is served.
+func (proc *schedulerProcess) verifySender(messageName string, req *http.Request) error {
+	from := libprocessSender(req)
+	pid, err := parseUPID(from)
+	if err != nil {
+		atomic.AddUint64(&proc.metrics.senderMismatches, 1)
+		return fmt.Errorf("%s has unverifiable sender [%s]: %s", messageName, from, err)
 	}
+
+	addr := normalizeHostPort(pid.hostPort())
+	proc.sendersMutex.RLock()
+	trusted := proc.masterAddrs[addr] ||
+		(messageName == MESOS_INTERNAL_PREFIX+FRAMEWORK_MESSAGE_EVENT && proc.slaveAddrs[addr])
+	proc.sendersMutex.RUnlock()
+	if trusted {
+		return nil
+	}
+	atomic.AddUint64(&proc.metrics.senderMismatches, 1)
+	return fmt.Errorf("%s sent by [%s] which is not the leading master.", messageName, from)
 }
 
-func makeProcEventPath(proc *schedulerProcess, eventName string) string {
-	return fmt.Sprintf("/%s/%s%s", proc.processId.prefix, MESOS_INTERNAL_PREFIX, eventName)
+// libprocessSender returns the process id found in the Libprocess-From header,
+// or in a User-Agent header of the form libprocess/<pid>.
+func libprocessSender(req *http.Request) string {
+	if from := req.Header.Get("Libprocess-From"); from != "" {
+		return from
+	}
+	agent := req.Header.Get("User-Agent")
+	if strings.HasPrefix(agent, HTTP_LIBPROC_PREFIX) {
+		return agent[len(HTTP_LIBPROC_PREFIX):]
+	}
+	return ""
 }
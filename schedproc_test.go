@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -115,6 +116,96 @@ func TestSchedProcStop(t *testing.T) {
 	}
 }
 
+func TestSchedProcStart_WithBindAndAdvertiseAddress(t *testing.T) {
+	proc, err := newSchedulerProcess(make(chan interface{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.bindIP = "127.0.0.1"
+	proc.advertiseIP = "10.1.2.3"
+	proc.advertisePort = 9999
+
+	err = proc.start()
+	if err != nil {
+		t.Fatalf("Error starting SchedProc %s", err)
+	}
+	defer proc.stop()
+
+	host, _, _ := net.SplitHostPort(proc.listener.Addr().String())
+	if host != "127.0.0.1" {
+		t.Fatal("SchedProc not bound to requested address, got", host)
+	}
+	if !strings.HasSuffix(proc.processId.value, "@10.1.2.3:9999") {
+		t.Fatal("SchedProc not advertising requested address, got", proc.processId.value)
+	}
+}
+
+func TestSchedProcStart_AdvertisesBoundPort(t *testing.T) {
+	proc, err := newSchedulerProcess(make(chan interface{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.bindIP = "0.0.0.0"
+	proc.advertiseIP = "sched.example.com"
+
+	err = proc.start()
+	if err != nil {
+		t.Fatalf("Error starting SchedProc %s", err)
+	}
+	defer proc.stop()
+
+	_, port, _ := net.SplitHostPort(proc.listener.Addr().String())
+	if !strings.HasSuffix(proc.processId.value, "@sched.example.com:"+port) {
+		t.Fatal("SchedProc expected to advertise bound port", port, "got", proc.processId.value)
+	}
+}
+
+func TestSchedProcStart_WithIPv6Address(t *testing.T) {
+	if l, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skip("IPv6 loopback not available:", err)
+	} else {
+		l.Close()
+	}
+
+	proc, err := newSchedulerProcess(make(chan interface{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.bindIP = "::1"
+
+	err = proc.start()
+	if err != nil {
+		t.Fatalf("Error starting SchedProc %s", err)
+	}
+	defer proc.stop()
+
+	pid, err := parseUPID(proc.processId.value)
+	if err != nil {
+		t.Fatal("SchedProc generated unparsable process id:", err)
+	}
+	if pid.host != "::1" || !strings.Contains(proc.processId.value, "@[::1]:") {
+		t.Fatal("SchedProc expected bracketed IPv6 process id, got", proc.processId.value)
+	}
+	u, err := proc.processId.asURL()
+	if err != nil || u.Host != pid.hostPort() {
+		t.Fatal("SchedProc process id not convertible to URL:", proc.processId.value, err)
+	}
+}
+
+func TestLoopbackAddr_FollowsBindFamily(t *testing.T) {
+	wildcard := &net.TCPAddr{IP: net.IPv6unspecified, Port: 5050}
+	if addr := loopbackAddr(wildcard, "0.0.0.0"); addr != "127.0.0.1:5050" {
+		t.Fatal("Expected IPv4 loopback for listener bound to 0.0.0.0, got", addr)
+	}
+	if addr := loopbackAddr(wildcard, "::"); addr != "[::1]:5050" {
+		t.Fatal("Expected IPv6 loopback for listener bound to ::, got", addr)
+	}
+	bound := &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 5050}
+	if addr := loopbackAddr(bound, "10.1.2.3"); addr != "10.1.2.3:5050" {
+		t.Fatal("Expected bound address kept, got", addr)
+	}
+}
+
 func TestScheProcError(t *testing.T) {
 	eventQ := make(chan interface{})
 	go func() {
@@ -465,3 +556,149 @@ func buildHttpRequest(t *testing.T, msgName string, data []byte) *http.Request {
 	req.Header.Add("Libprocess-From", "master(1)")
 	return req
 }
+
+func buildHttpRequestFrom(t *testing.T, msgName string, data []byte, from string) *http.Request {
+	req := buildHttpRequest(t, msgName, data)
+	req.Header.Del("Libprocess-From")
+	if from != "" {
+		req.Header.Add("Libprocess-From", from)
+	}
+	return req
+}
+
+func makeStartedProc(t *testing.T, eventQ chan interface{}, check SenderCheck) *schedulerProcess {
+	proc, err := newSchedulerProcess(eventQ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.started = true
+	proc.senderCheck = check
+	proc.trustMaster("127.0.0.1:5050")
+	return proc
+}
+
+func TestSchedProcSenderCheck_AcceptsLeadingMaster(t *testing.T) {
+	eventQ := make(chan interface{}, 1)
+	proc := makeStartedProc(t, eventQ, SENDER_CHECK_REJECT)
+
+	data, _ := proto.Marshal(&mesos.RescindResourceOfferMessage{OfferId: NewOfferID("offer-1")})
+	resp := httptest.NewRecorder()
+	proc.ServeHTTP(resp, buildHttpRequestFrom(t, RESCIND_OFFER_EVENT, data, "master@127.0.0.1:5050"))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expecting server status %d but got status %d", http.StatusAccepted, resp.Code)
+	}
+	if _, ok := (<-eventQ).(*mesos.RescindResourceOfferMessage); !ok {
+		t.Fatal("Expected RescindResourceOfferMessage from leading master to be queued.")
+	}
+	metrics := proc.metrics.snapshot()
+	if metrics.EventsReceived != 1 || metrics.SenderMismatches != 0 {
+		t.Fatal("Unexpected metrics", metrics)
+	}
+}
+
+func TestSchedProcSenderCheck_UserAgentSender(t *testing.T) {
+	eventQ := make(chan interface{}, 1)
+	proc := makeStartedProc(t, eventQ, SENDER_CHECK_REJECT)
+
+	data, _ := proto.Marshal(&mesos.RescindResourceOfferMessage{OfferId: NewOfferID("offer-1")})
+	req := buildHttpRequestFrom(t, RESCIND_OFFER_EVENT, data, "")
+	req.Header.Set("User-Agent", HTTP_LIBPROC_PREFIX+"master@127.0.0.1:5050")
+	resp := httptest.NewRecorder()
+	proc.ServeHTTP(resp, req)
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expecting server status %d but got status %d", http.StatusAccepted, resp.Code)
+	}
+}
+
+func TestSchedProcTrustMaster_ResolvedAddresses(t *testing.T) {
+	proc := makeStartedProc(t, make(chan interface{}, 1), SENDER_CHECK_REJECT)
+	proc.trustMaster(resolveHostPort("localhost:5050")...)
+
+	for _, from := range []string{"master@localhost:5050", "master@127.0.0.1:5050"} {
+		req := buildHttpRequestFrom(t, RESCIND_OFFER_EVENT, nil, from)
+		if err := proc.verifySender(RESCIND_OFFER_EVENT, req); err != nil {
+			t.Fatal("Expected master trusted by name and resolved address:", err)
+		}
+	}
+}
+
+func TestSchedProcSenderCheck_RejectsSpoofedMaster(t *testing.T) {
+	eventQ := make(chan interface{}, 1)
+	proc := makeStartedProc(t, eventQ, SENDER_CHECK_REJECT)
+
+	data, _ := proto.Marshal(&mesos.FrameworkRegisteredMessage{
+		FrameworkId: NewFrameworkID("evil-framework"),
+		MasterInfo:  NewMasterInfo("master-1", 12345, 5050),
+	})
+	// senders are not resolved, a hostname only matches a master trusted by name
+	for _, from := range []string{"master@10.6.6.6:5050", "master@127.0.0.1:5051", "master@localhost:5050", "", "garbage"} {
+		resp := httptest.NewRecorder()
+		proc.ServeHTTP(resp, buildHttpRequestFrom(t, FRAMEWORK_REGISTERED_EVENT, data, from))
+		if resp.Code != http.StatusForbidden {
+			t.Fatalf("Expecting status %d for sender [%s], but got %d", http.StatusForbidden, from, resp.Code)
+		}
+	}
+	if len(eventQ) != 0 {
+		t.Fatal("Spoofed events should not be queued.")
+	}
+	metrics := proc.metrics.snapshot()
+	if metrics.EventsRejected != 5 || metrics.SenderMismatches != 5 || metrics.EventsReceived != 0 {
+		t.Fatal("Unexpected metrics", metrics)
+	}
+}
+
+func TestSchedProcSenderCheck_LogsSpoofedMaster(t *testing.T) {
+	eventQ := make(chan interface{}, 1)
+	proc := makeStartedProc(t, eventQ, SENDER_CHECK_LOG)
+
+	data, _ := proto.Marshal(&mesos.RescindResourceOfferMessage{OfferId: NewOfferID("offer-1")})
+	resp := httptest.NewRecorder()
+	proc.ServeHTTP(resp, buildHttpRequestFrom(t, RESCIND_OFFER_EVENT, data, "master@10.6.6.6:5050"))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expecting server status %d but got status %d", http.StatusAccepted, resp.Code)
+	}
+	<-eventQ
+	metrics := proc.metrics.snapshot()
+	if metrics.SenderMismatches != 1 || metrics.EventsRejected != 0 {
+		t.Fatal("Unexpected metrics", metrics)
+	}
+}
+
+func TestSchedProcSenderCheck_FrameworkMessageFromKnownSlave(t *testing.T) {
+	eventQ := make(chan interface{}, 2)
+	proc := makeStartedProc(t, eventQ, SENDER_CHECK_REJECT)
+
+	offers, _ := proto.Marshal(&mesos.ResourceOffersMessage{
+		Offers: []*mesos.Offer{NewOffer(NewOfferID("offer-1"), NewFrameworkID("framework-1"), NewSlaveID("slave-1"), "host-a")},
+		Pids:   []string{"slave(1)@10.0.0.7:5051"},
+	})
+	resp := httptest.NewRecorder()
+	proc.ServeHTTP(resp, buildHttpRequestFrom(t, RESOURCE_OFFERS_EVENT, offers, "master@127.0.0.1:5050"))
+	<-eventQ
+
+	data, _ := proto.Marshal(&mesos.ExecutorToFrameworkMessage{
+		SlaveId:     NewSlaveID("slave-1"),
+		FrameworkId: NewFrameworkID("framework-1"),
+		ExecutorId:  NewExecutorID("executor-1"),
+		Data:        []byte("hello"),
+	})
+	resp = httptest.NewRecorder()
+	proc.ServeHTTP(resp, buildHttpRequestFrom(t, FRAMEWORK_MESSAGE_EVENT, data, "slave(1)@10.0.0.7:5051"))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expecting framework message from known slave accepted, but got %d", resp.Code)
+	}
+	<-eventQ
+
+	resp = httptest.NewRecorder()
+	proc.ServeHTTP(resp, buildHttpRequestFrom(t, FRAMEWORK_MESSAGE_EVENT, data, "slave(1)@10.0.0.8:5051"))
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("Expecting framework message from unknown slave rejected, but got %d", resp.Code)
+	}
+
+	// slaves may only send framework messages
+	resp = httptest.NewRecorder()
+	proc.ServeHTTP(resp, buildHttpRequestFrom(t, RESOURCE_OFFERS_EVENT, offers, "slave(1)@10.0.0.7:5051"))
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("Expecting offers from slave rejected, but got %d", resp.Code)
+	}
+}
@@ -13,6 +13,11 @@ type Scheduler struct {
 	FrameworkMessage func(*SchedulerDriver, *mesos.ExecutorID, *mesos.SlaveID, []byte)
 	SlaveLost        func(*SchedulerDriver, *mesos.SlaveID)
 	Error            func(*SchedulerDriver, MesosError)
+
+	// UnhandledMessage receives messages that have neither a Scheduler
+	// callback nor a handler in the driver's MessageRegistry.
+	// When nil, such messages are refused.
+	UnhandledMessage func(driver *SchedulerDriver, name string, data []byte)
 }
 
 func NewMesosScheduler() *Scheduler {
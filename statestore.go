@@ -0,0 +1,111 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StateStore persists what a framework needs to fail over: its FrameworkID
+// and the records of the tasks it launched. Implementations must be safe
+// for concurrent use.
+type StateStore interface {
+	// FrameworkId returns the saved id, or nil when there is none.
+	FrameworkId() (*mesos.FrameworkID, error)
+	// SaveFrameworkId saves id. A nil id clears the saved one.
+	SaveFrameworkId(id *mesos.FrameworkID) error
+	// Tasks returns the saved task records.
+	Tasks() ([]TaskRecord, error)
+	SaveTask(record TaskRecord) error
+	RemoveTask(taskId *mesos.TaskID) error
+}
+
+// MemStateStore keeps state in memory, i.e. for tests. Nothing survives
+// the process.
+type MemStateStore struct {
+	lock        sync.Mutex
+	frameworkId *mesos.FrameworkID
+	tasks       map[string]TaskRecord
+}
+
+func NewMemStateStore() *MemStateStore {
+	return &MemStateStore{tasks: make(map[string]TaskRecord)}
+}
+
+func (store *MemStateStore) FrameworkId() (*mesos.FrameworkID, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.frameworkId, nil
+}
+
+func (store *MemStateStore) SaveFrameworkId(id *mesos.FrameworkID) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.frameworkId = id
+	return nil
+}
+
+func (store *MemStateStore) Tasks() ([]TaskRecord, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	records := []TaskRecord{}
+	for _, record := range store.tasks {
+		records = append(records, record)
+	}
+	sort.Sort(taskRecordsById(records))
+	return records, nil
+}
+
+func (store *MemStateStore) SaveTask(record TaskRecord) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.tasks[record.TaskId()] = record
+	return nil
+}
+
+func (store *MemStateStore) RemoveTask(taskId *mesos.TaskID) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	delete(store.tasks, taskId.GetValue())
+	return nil
+}
+
+// storedTask converts a record to its persisted form.
+func storedTask(record TaskRecord) *mesos.StoredTask {
+	return &mesos.StoredTask{
+		Info:     record.Info,
+		OfferIds: record.OfferIds,
+		State:    record.State.Enum(),
+		Status:   record.Status,
+		Launched: proto.Float64(timeToSeconds(record.Launched)),
+		Updated:  proto.Float64(timeToSeconds(record.Updated)),
+	}
+}
+
+// taskRecord converts a persisted task back to a record.
+func taskRecord(task *mesos.StoredTask) TaskRecord {
+	return TaskRecord{
+		Info:     task.GetInfo(),
+		OfferIds: task.GetOfferIds(),
+		State:    task.GetState(),
+		Status:   task.GetStatus(),
+		Launched: secondsToTime(task.GetLaunched()),
+		Updated:  secondsToTime(task.GetUpdated()),
+	}
+}
+
+func timeToSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func secondsToTime(seconds float64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
@@ -0,0 +1,160 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemStateStore(t *testing.T) {
+	store := NewMemStateStore()
+	store.SaveFrameworkId(NewFrameworkID("framework-1"))
+	store.SaveTask(makeTestRecord("task-2", mesos.TaskState_TASK_STAGING))
+	store.SaveTask(makeTestRecord("task-1", mesos.TaskState_TASK_STAGING))
+	store.RemoveTask(NewTaskID("task-2"))
+	if id, _ := store.FrameworkId(); id.GetValue() != "framework-1" {
+		t.Fatal("Expected FrameworkID framework-1, but got", id)
+	}
+	checkStoredTasks(t, store, "task-1")
+}
+
+func TestTaskRegistry_WithStore(t *testing.T) {
+	dir := makeTestStateDir(t)
+	defer os.RemoveAll(dir)
+
+	store := openTestFileStore(t, dir)
+	registry, err := NewTaskRegistryWithStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.Add(makeLaunchTask("task-1", "slave-1", 1, 128), nil)
+	registry.Add(makeLaunchTask("task-2", "slave-1", 1, 128), nil)
+	registry.Add(makeLaunchTask("task-3", "slave-2", 1, 128), nil)
+	registry.Update(makeTaskUpdate("task-1", mesos.TaskState_TASK_RUNNING))
+	registry.SlaveLost(NewSlaveID("slave-2"))
+	registry.Remove(NewTaskID("task-2"))
+	store.Close()
+
+	store = openTestFileStore(t, dir)
+	defer store.Close()
+	registry, err = NewTaskRegistryWithStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registry.Len() != 2 {
+		t.Fatal("Expected 2 recovered tasks, but got", registry.All())
+	}
+	record, _ := registry.Get(NewTaskID("task-1"))
+	if record.State != mesos.TaskState_TASK_RUNNING || record.Launched.IsZero() {
+		t.Fatal("Expected task-1 recovered in TASK_RUNNING, but got", record)
+	}
+	if record, _ = registry.Get(NewTaskID("task-3")); record.State != mesos.TaskState_TASK_LOST {
+		t.Fatal("Expected task-3 recovered in TASK_LOST, but got", record.State)
+	}
+	if err := registry.Update(makeTaskUpdate("task-1", mesos.TaskState_TASK_FINISHED)); err != nil {
+		t.Fatal("Expected recovered task to accept updates, but got", err)
+	}
+}
+
+func TestTaskRegistry_UndoWithStore(t *testing.T) {
+	store := NewMemStateStore()
+	registry, err := NewTaskRegistryWithStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.Add(makeLaunchTask("task-1", "slave-1", 1, 128), nil)
+	registry.Update(makeTaskUpdate("task-1", mesos.TaskState_TASK_FINISHED))
+
+	relaunch, err := registry.add(makeLaunchTask("task-1", "slave-1", 1, 128), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	launch, err := registry.add(makeLaunchTask("task-2", "slave-1", 1, 128), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.undo([]taskAddition{relaunch, launch})
+
+	records := checkStoredTasks(t, store, "task-1")
+	if records[0].State != mesos.TaskState_TASK_FINISHED {
+		t.Fatal("Expected finished task-1 saved again after undo, but got", records[0].State)
+	}
+	if record, _ := registry.Get(NewTaskID("task-1")); record.State != mesos.TaskState_TASK_FINISHED {
+		t.Fatal("Expected finished task-1 restored after undo, but got", record.State)
+	}
+}
+
+func TestDriverWithStore_Failover(t *testing.T) {
+	master := makeTestMaster()
+	defer master.close()
+
+	store := NewMemStateStore()
+	driver, err := NewSchedDriverWithStore(nil, NewFrameworkInfo("test", "test-framework", nil), master.host(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if driver.Start() != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", driver.status())
+	}
+	go driver.Join()
+	master.expectCall(t, REGISTER_FRAMEWORK_CALL, new(mesos.RegisterFrameworkMessage))
+	driver.schedMsgQ <- &mesos.FrameworkRegisteredMessage{
+		FrameworkId: NewFrameworkID("framework-1"),
+		MasterInfo:  NewMasterInfo("master-1", 12345, 1234),
+	}
+	time.Sleep(21 * time.Millisecond)
+	if id, _ := store.FrameworkId(); id.GetValue() != "framework-1" {
+		t.Fatal("Expected FrameworkID saved after registration, but got", id)
+	}
+	driver.LaunchTasks([]*mesos.OfferID{NewOfferID("offer-1")}, []*mesos.TaskInfo{makeLaunchTask("task-1", "slave-1", 1, 128)}, nil)
+	master.expectCall(t, LAUNCH_TASKS_CALL, new(mesos.LaunchTasksMessage))
+	driver.Stop(true)
+
+	// restarted scheduler
+	driver, err = NewSchedDriverWithStore(nil, NewFrameworkInfo("test", "test-framework", nil), master.host(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if driver.FrameworkInfo.GetId().GetValue() != "framework-1" || !driver.FailoverOnStart {
+		t.Fatal("Expected saved FrameworkID picked up for failover, but got", driver.FrameworkInfo.GetId())
+	}
+	if record, ok := driver.Tasks.Get(NewTaskID("task-1")); !ok || record.State != mesos.TaskState_TASK_STAGING {
+		t.Fatal("Expected task-1 recovered, but got", record)
+	}
+	driver.Start()
+	go driver.Join()
+	msg := new(mesos.ReregisterFrameworkMessage)
+	master.expectCall(t, REREGISTER_FRAMEWORK_CALL, msg)
+	if !msg.GetFailover() || msg.GetFramework().GetId().GetValue() != "framework-1" {
+		t.Fatal("Expected failover re-registration of framework-1, but got", msg)
+	}
+	if driver.FrameworkId().GetValue() != "framework-1" || driver.GetStatus() != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected running driver of framework-1, but got", driver.FrameworkId(), driver.GetStatus())
+	}
+	driver.Stop(true)
+}
+
+func TestDriverStart_PresetIdRegisters(t *testing.T) {
+	calls := make(chan string, 2)
+	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		calls <- req.URL.Path
+		rsp.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Close()
+	url, _ := url.Parse(server.URL)
+
+	driver, err := NewSchedDriver(nil, NewFrameworkInfo("test", "test-framework", NewFrameworkID("framework-1")), url.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if driver.Start() != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", driver.status())
+	}
+	defer driver.schedProc.stop()
+	if path := <-calls; path != buildReqPath(REGISTER_FRAMEWORK_CALL) {
+		t.Fatal("Expected framework with preset id and no store to register, but got", path)
+	}
+}
@@ -0,0 +1,353 @@
+package gomes
+
+import (
+	"code.google.com/p/goprotobuf/proto"
+	"fmt"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TaskRecord is what a TaskRegistry knows about a launched task.
+type TaskRecord struct {
+	Info     *mesos.TaskInfo
+	OfferIds []*mesos.OfferID
+	State    mesos.TaskState
+	// Status is the latest status update, nil until the first one arrives.
+	Status   *mesos.TaskStatus
+	Launched time.Time
+	Updated  time.Time
+}
+
+func (record TaskRecord) TaskId() string {
+	return record.Info.GetTaskId().GetValue()
+}
+
+func (record TaskRecord) SlaveId() string {
+	if record.Status.GetSlaveId() != nil {
+		return record.Status.GetSlaveId().GetValue()
+	}
+	return record.Info.GetSlaveId().GetValue()
+}
+
+// TransitionError reports a status update moving a task through a
+// transition its state machine does not allow.
+type TransitionError struct {
+	TaskId string
+	From   mesos.TaskState
+	To     mesos.TaskState
+}
+
+func (err *TransitionError) Error() string {
+	return fmt.Sprintf("Illegal transition of task [%s] from %s to %s.", err.TaskId, err.From, err.To)
+}
+
+// IsTerminalState reports whether a task in state has stopped for good.
+func IsTerminalState(state mesos.TaskState) bool {
+	switch state {
+	case mesos.TaskState_TASK_FINISHED,
+		mesos.TaskState_TASK_FAILED,
+		mesos.TaskState_TASK_KILLED,
+		mesos.TaskState_TASK_LOST:
+		return true
+	}
+	return false
+}
+
+// taskTransitions lists the states a task may move to from each non
+// terminal state. Repeated updates of the same state are always legal.
+var taskTransitions = map[mesos.TaskState][]mesos.TaskState{
+	mesos.TaskState_TASK_STAGING:  {mesos.TaskState_TASK_STARTING, mesos.TaskState_TASK_RUNNING},
+	mesos.TaskState_TASK_STARTING: {mesos.TaskState_TASK_RUNNING},
+	mesos.TaskState_TASK_RUNNING:  {},
+}
+
+// IsLegalTransition reports whether a task may move from state from to state to.
+func IsLegalTransition(from, to mesos.TaskState) bool {
+	if from == to {
+		return true
+	}
+	next, ok := taskTransitions[from]
+	if !ok {
+		return false
+	}
+	if IsTerminalState(to) {
+		return true
+	}
+	for _, state := range next {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+TaskRegistry tracks launched tasks with their latest status. Tasks start
+in TASK_STAGING, move through TASK_STARTING and TASK_RUNNING and stop in
+one of the terminal states. Attached to a driver, tasks are added when
+launched and updated by status updates and lost slaves:
+
+	driver.Tasks = NewTaskRegistry()
+
+A registry created with NewTaskRegistryWithStore also writes every change
+through to a StateStore. The registry is safe for concurrent use. Queries
+return copies of the records, sorted by task id.
+*/
+type TaskRegistry struct {
+	// IllegalTransition, when set, is called with updates that are
+	// rejected because of an illegal transition.
+	IllegalTransition func(record TaskRecord, status *mesos.TaskStatus)
+
+	lock  sync.RWMutex
+	tasks map[string]*TaskRecord
+	store StateStore
+}
+
+func NewTaskRegistry() *TaskRegistry {
+	return &TaskRegistry{tasks: make(map[string]*TaskRecord)}
+}
+
+// NewTaskRegistryWithStore returns a registry holding the tasks saved in
+// store, and saving its changes there.
+func NewTaskRegistryWithStore(store StateStore) (*TaskRegistry, error) {
+	records, err := store.Tasks()
+	if err != nil {
+		return nil, err
+	}
+	registry := NewTaskRegistry()
+	for i := range records {
+		record := records[i]
+		registry.tasks[record.TaskId()] = &record
+	}
+	registry.store = store
+	return registry, nil
+}
+
+// Add records a task launched on the given offers, in TASK_STAGING.
+// A task id may only be reused once its earlier task is terminal.
+func (registry *TaskRegistry) Add(task *mesos.TaskInfo, offerIds []*mesos.OfferID) error {
+	_, err := registry.add(task, offerIds)
+	return err
+}
+
+// taskAddition is a record added to a registry, with the terminal record
+// of an earlier task with the same id it replaced, if any.
+type taskAddition struct {
+	record   *TaskRecord
+	replaced *TaskRecord
+}
+
+// add is Add, returning what was added so a failed launch can undo it.
+func (registry *TaskRegistry) add(task *mesos.TaskInfo, offerIds []*mesos.OfferID) (taskAddition, error) {
+	id := task.GetTaskId().GetValue()
+	if id == "" {
+		return taskAddition{}, fmt.Errorf("Task %s is missing a task id.", task.GetName())
+	}
+
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	replaced, ok := registry.tasks[id]
+	if ok && !IsTerminalState(replaced.State) {
+		return taskAddition{}, fmt.Errorf("Task [%s] is already registered in state %s.", id, replaced.State)
+	}
+	now := time.Now()
+	record := &TaskRecord{
+		Info:     task,
+		OfferIds: offerIds,
+		State:    mesos.TaskState_TASK_STAGING,
+		Launched: now,
+		Updated:  now,
+	}
+	if err := registry.save(record); err != nil {
+		return taskAddition{}, err
+	}
+	registry.tasks[id] = record
+	return taskAddition{record: record, replaced: replaced}, nil
+}
+
+// undo removes the records of additions, latest first, and restores the
+// records they replaced. Records added since by others are left alone.
+func (registry *TaskRegistry) undo(additions []taskAddition) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	for i := len(additions) - 1; i >= 0; i-- {
+		addition := additions[i]
+		id := addition.record.TaskId()
+		if registry.tasks[id] != addition.record {
+			continue
+		}
+		var err error
+		if addition.replaced != nil {
+			registry.tasks[id] = addition.replaced
+			err = registry.save(addition.replaced)
+		} else {
+			delete(registry.tasks, id)
+			if registry.store != nil {
+				err = registry.store.RemoveTask(addition.record.Info.TaskId)
+			}
+		}
+		if err != nil {
+			log.Println("Unable to save undone launch of task", id, ":", err)
+		}
+	}
+}
+
+// Update applies a status update. Updates of unknown tasks and illegal
+// transitions are rejected, leaving the record unchanged.
+func (registry *TaskRegistry) Update(status *mesos.TaskStatus) error {
+	id := status.GetTaskId().GetValue()
+
+	registry.lock.Lock()
+	record, ok := registry.tasks[id]
+	if !ok {
+		registry.lock.Unlock()
+		return fmt.Errorf("Status update for unknown task [%s].", id)
+	}
+	if !IsLegalTransition(record.State, status.GetState()) {
+		rejected := *record
+		registry.lock.Unlock()
+		if registry.IllegalTransition != nil {
+			registry.IllegalTransition(rejected, status)
+		}
+		return &TransitionError{TaskId: id, From: rejected.State, To: status.GetState()}
+	}
+	updated := *record
+	updated.State = status.GetState()
+	updated.Status = status
+	updated.Updated = time.Now()
+	err := registry.save(&updated)
+	if err == nil {
+		*record = updated
+	}
+	registry.lock.Unlock()
+	return err
+}
+
+// SlaveLost moves the unfinished tasks of a slave to TASK_LOST and returns
+// their records.
+func (registry *TaskRegistry) SlaveLost(slaveId *mesos.SlaveID) []TaskRecord {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	lost := []TaskRecord{}
+	now := time.Now()
+	for _, record := range registry.tasks {
+		if IsTerminalState(record.State) || record.SlaveId() != slaveId.GetValue() {
+			continue
+		}
+		status := NewTaskStatus(record.Info.GetTaskId(), mesos.TaskState_TASK_LOST)
+		status.SlaveId = slaveId
+		status.Message = proto.String("Slave lost.")
+		record.State = mesos.TaskState_TASK_LOST
+		record.Status = status
+		record.Updated = now
+		if err := registry.save(record); err != nil {
+			log.Println("Unable to save lost task:", err)
+		}
+		lost = append(lost, *record)
+	}
+	sort.Sort(taskRecordsById(lost))
+	return lost
+}
+
+// Get returns the record of a task.
+func (registry *TaskRegistry) Get(taskId *mesos.TaskID) (TaskRecord, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	record, ok := registry.tasks[taskId.GetValue()]
+	if !ok {
+		return TaskRecord{}, false
+	}
+	return *record, true
+}
+
+// Remove forgets a task, i.e. once its terminal state has been handled.
+func (registry *TaskRegistry) Remove(taskId *mesos.TaskID) error {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if registry.store != nil {
+		if err := registry.store.RemoveTask(taskId); err != nil {
+			return err
+		}
+	}
+	delete(registry.tasks, taskId.GetValue())
+	return nil
+}
+
+// save writes record through to the store, if any. It must be called with
+// the lock held, so the store sees changes in order.
+func (registry *TaskRegistry) save(record *TaskRecord) error {
+	if registry.store == nil {
+		return nil
+	}
+	return registry.store.SaveTask(*record)
+}
+
+// Len returns the number of tasks in the registry.
+func (registry *TaskRegistry) Len() int {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	return len(registry.tasks)
+}
+
+// All returns every record.
+func (registry *TaskRegistry) All() []TaskRecord {
+	return registry.find(func(record *TaskRecord) bool {
+		return true
+	})
+}
+
+// ByState returns the tasks in any of the given states.
+func (registry *TaskRegistry) ByState(states ...mesos.TaskState) []TaskRecord {
+	return registry.find(func(record *TaskRecord) bool {
+		for _, state := range states {
+			if record.State == state {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Active returns the tasks not in a terminal state.
+func (registry *TaskRegistry) Active() []TaskRecord {
+	return registry.find(func(record *TaskRecord) bool {
+		return !IsTerminalState(record.State)
+	})
+}
+
+// BySlave returns the tasks placed on a slave.
+func (registry *TaskRegistry) BySlave(slaveId *mesos.SlaveID) []TaskRecord {
+	return registry.find(func(record *TaskRecord) bool {
+		return record.SlaveId() == slaveId.GetValue()
+	})
+}
+
+// ByNamePrefix returns the tasks whose name starts with prefix.
+func (registry *TaskRegistry) ByNamePrefix(prefix string) []TaskRecord {
+	return registry.find(func(record *TaskRecord) bool {
+		return strings.HasPrefix(record.Info.GetName(), prefix)
+	})
+}
+
+func (registry *TaskRegistry) find(match func(*TaskRecord) bool) []TaskRecord {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	records := []TaskRecord{}
+	for _, record := range registry.tasks {
+		if match(record) {
+			records = append(records, *record)
+		}
+	}
+	sort.Sort(taskRecordsById(records))
+	return records
+}
+
+type taskRecordsById []TaskRecord
+
+func (records taskRecordsById) Len() int           { return len(records) }
+func (records taskRecordsById) Swap(i, j int)      { records[i], records[j] = records[j], records[i] }
+func (records taskRecordsById) Less(i, j int) bool { return records[i].TaskId() < records[j].TaskId() }
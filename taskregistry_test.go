@@ -0,0 +1,268 @@
+package gomes
+
+import (
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func makeTaskUpdate(id string, state mesos.TaskState) *mesos.TaskStatus {
+	return NewTaskStatus(NewTaskID(id), state)
+}
+
+func TestIsLegalTransition(t *testing.T) {
+	cases := []struct {
+		from, to mesos.TaskState
+		legal    bool
+	}{
+		{mesos.TaskState_TASK_STAGING, mesos.TaskState_TASK_STARTING, true},
+		{mesos.TaskState_TASK_STAGING, mesos.TaskState_TASK_RUNNING, true},
+		{mesos.TaskState_TASK_STAGING, mesos.TaskState_TASK_LOST, true},
+		{mesos.TaskState_TASK_STARTING, mesos.TaskState_TASK_RUNNING, true},
+		{mesos.TaskState_TASK_STARTING, mesos.TaskState_TASK_STAGING, false},
+		{mesos.TaskState_TASK_RUNNING, mesos.TaskState_TASK_RUNNING, true},
+		{mesos.TaskState_TASK_RUNNING, mesos.TaskState_TASK_FINISHED, true},
+		{mesos.TaskState_TASK_RUNNING, mesos.TaskState_TASK_STARTING, false},
+		{mesos.TaskState_TASK_FINISHED, mesos.TaskState_TASK_RUNNING, false},
+		{mesos.TaskState_TASK_LOST, mesos.TaskState_TASK_KILLED, false},
+		{mesos.TaskState_TASK_KILLED, mesos.TaskState_TASK_KILLED, true},
+	}
+	for _, c := range cases {
+		if IsLegalTransition(c.from, c.to) != c.legal {
+			t.Fatal("Expected transition from", c.from, "to", c.to, "legal:", c.legal)
+		}
+	}
+	if IsTerminalState(mesos.TaskState_TASK_RUNNING) || !IsTerminalState(mesos.TaskState_TASK_FAILED) {
+		t.Fatal("IsTerminalState returned unexpected values.")
+	}
+}
+
+func TestTaskRegistry(t *testing.T) {
+	registry := NewTaskRegistry()
+	offerIds := []*mesos.OfferID{NewOfferID("offer-1")}
+	registry.Add(makeLaunchTask("web-1", "slave-1", 1, 128), offerIds)
+	registry.Add(makeLaunchTask("web-2", "slave-2", 1, 128), offerIds)
+	registry.Add(makeLaunchTask("db-1", "slave-1", 1, 128), offerIds)
+	if err := registry.Add(makeLaunchTask("web-1", "slave-1", 1, 128), offerIds); err == nil {
+		t.Fatal("Expected error registering active task twice.")
+	}
+
+	record, ok := registry.Get(NewTaskID("web-1"))
+	if !ok || record.State != mesos.TaskState_TASK_STAGING || record.OfferIds[0].GetValue() != "offer-1" || record.Launched.IsZero() {
+		t.Fatal("Unexpected record for launched task", record)
+	}
+
+	if err := registry.Update(makeTaskUpdate("web-1", mesos.TaskState_TASK_RUNNING)); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Update(makeTaskUpdate("db-1", mesos.TaskState_TASK_FAILED)); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Update(makeTaskUpdate("task-x", mesos.TaskState_TASK_RUNNING)); err == nil {
+		t.Fatal("Expected error updating unknown task.")
+	}
+
+	running := registry.ByState(mesos.TaskState_TASK_RUNNING)
+	if len(running) != 1 || running[0].TaskId() != "web-1" || running[0].Status == nil {
+		t.Fatal("ByState returned unexpected records", running)
+	}
+	web := registry.ByNamePrefix("web-")
+	if len(web) != 2 || web[0].TaskId() != "web-1" || web[1].TaskId() != "web-2" {
+		t.Fatal("ByNamePrefix returned unexpected records", web)
+	}
+	onSlave := registry.BySlave(NewSlaveID("slave-1"))
+	if len(onSlave) != 2 || onSlave[0].TaskId() != "db-1" {
+		t.Fatal("BySlave returned unexpected records", onSlave)
+	}
+	if active := registry.Active(); len(active) != 2 {
+		t.Fatal("Expected 2 active tasks, but got", active)
+	}
+
+	if err := registry.Add(makeLaunchTask("db-1", "slave-2", 1, 128), offerIds); err != nil {
+		t.Fatal("Expected task id of terminal task to be reusable, but got", err)
+	}
+	registry.Remove(NewTaskID("db-1"))
+	if registry.Len() != 2 || len(registry.All()) != 2 {
+		t.Fatal("Expected 2 tasks after remove, but got", registry.Len())
+	}
+}
+
+func TestTaskRegistry_IllegalTransition(t *testing.T) {
+	registry := NewTaskRegistry()
+	var flagged *mesos.TaskStatus
+	registry.IllegalTransition = func(record TaskRecord, status *mesos.TaskStatus) {
+		if record.State != mesos.TaskState_TASK_FINISHED {
+			t.Fatal("Expected record in TASK_FINISHED, but got", record.State)
+		}
+		flagged = status
+	}
+	registry.Add(makeLaunchTask("task-1", "slave-1", 1, 128), nil)
+	registry.Update(makeTaskUpdate("task-1", mesos.TaskState_TASK_FINISHED))
+
+	err := registry.Update(makeTaskUpdate("task-1", mesos.TaskState_TASK_RUNNING))
+	transitionErr, ok := err.(*TransitionError)
+	if !ok || transitionErr.From != mesos.TaskState_TASK_FINISHED || transitionErr.To != mesos.TaskState_TASK_RUNNING {
+		t.Fatal("Expected TransitionError, but got", err)
+	}
+	if flagged == nil || flagged.GetState() != mesos.TaskState_TASK_RUNNING {
+		t.Fatal("Expected IllegalTransition to be called with the update.")
+	}
+	if record, _ := registry.Get(NewTaskID("task-1")); record.State != mesos.TaskState_TASK_FINISHED {
+		t.Fatal("Expected illegal update not to be applied, but got", record.State)
+	}
+}
+
+func TestTaskRegistry_SlaveLost(t *testing.T) {
+	registry := NewTaskRegistry()
+	registry.Add(makeLaunchTask("task-1", "slave-1", 1, 128), nil)
+	registry.Add(makeLaunchTask("task-2", "slave-1", 1, 128), nil)
+	registry.Add(makeLaunchTask("task-3", "slave-2", 1, 128), nil)
+	registry.Update(makeTaskUpdate("task-2", mesos.TaskState_TASK_FINISHED))
+
+	lost := registry.SlaveLost(NewSlaveID("slave-1"))
+	if len(lost) != 1 || lost[0].TaskId() != "task-1" || lost[0].State != mesos.TaskState_TASK_LOST {
+		t.Fatal("Expected only task-1 to be lost, but got", lost)
+	}
+	if record, _ := registry.Get(NewTaskID("task-3")); record.State != mesos.TaskState_TASK_STAGING {
+		t.Fatal("Expected task on other slave to be unaffected, but got", record.State)
+	}
+}
+
+func TestTaskRegistry_DriverUpdates(t *testing.T) {
+	master := makeTestV1Master()
+	defer master.close()
+
+	states := make(chan mesos.TaskState, 1)
+	lost := make(chan bool, 1)
+	sched := NewMesosScheduler()
+	sched.StatusUpdate = func(driver *SchedulerDriver, status *mesos.TaskStatus) {
+		record, _ := driver.Tasks.Get(status.GetTaskId())
+		states <- record.State
+	}
+	sched.SlaveLost = func(driver *SchedulerDriver, slaveId *mesos.SlaveID) {
+		lost <- true
+	}
+	driver := startTestHttpDriver(t, sched, master, 10)
+	driver.Tasks = NewTaskRegistry()
+
+	task := makeLaunchTask("task-1", "slave-1", 1, 128)
+	driver.LaunchTasks([]*mesos.OfferID{NewOfferID("offer-1")}, []*mesos.TaskInfo{task}, nil)
+	master.expectCall(t, mesos.Call_ACCEPT)
+	if record, ok := driver.Tasks.Get(task.GetTaskId()); !ok || record.State != mesos.TaskState_TASK_STAGING {
+		t.Fatal("Expected launched task in TASK_STAGING, but got", record)
+	}
+
+	master.send(&mesos.Event{
+		Type:   mesos.Event_UPDATE.Enum(),
+		Update: &mesos.Event_Update{Status: makeTaskUpdate("task-1", mesos.TaskState_TASK_RUNNING)},
+	})
+	select {
+	case state := <-states:
+		if state != mesos.TaskState_TASK_RUNNING {
+			t.Fatal("Expected registry updated before StatusUpdate callback, but got", state)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.StatusUpdate not called after UPDATE event.")
+	}
+
+	master.send(&mesos.Event{
+		Type:    mesos.Event_FAILURE.Enum(),
+		Failure: &mesos.Event_Failure{AgentId: NewSlaveID("slave-1")},
+	})
+	select {
+	case <-lost:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Scheduler.SlaveLost not called after FAILURE event.")
+	}
+	if record, _ := driver.Tasks.Get(task.GetTaskId()); record.State != mesos.TaskState_TASK_LOST {
+		t.Fatal("Expected task on lost slave in TASK_LOST, but got", record.State)
+	}
+}
+
+func TestTaskRegistry_DriverRegistersBeforeLaunch(t *testing.T) {
+	launched := make(chan bool)
+	answer := make(chan int)
+	server := makeMockServer(func(rsp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == buildReqPath(LAUNCH_TASKS_CALL) {
+			launched <- true
+			rsp.WriteHeader(<-answer)
+			return
+		}
+		rsp.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	driver, err := NewSchedDriver(NewMesosScheduler(), NewFrameworkInfo("test", "test-framework", NewFrameworkID("framework-1")), u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.Tasks = NewTaskRegistry()
+	if stat := driver.Start(); stat != mesos.Status_DRIVER_RUNNING {
+		t.Fatal("Expected DRIVER_RUNNING, but got", stat)
+	}
+	defer driver.schedProc.stop()
+	driver.setConnected(true)
+
+	launch := func(offerId, taskId string, status int) {
+		done := make(chan bool)
+		go func() {
+			driver.LaunchTasks([]*mesos.OfferID{NewOfferID(offerId)}, []*mesos.TaskInfo{makeLaunchTask(taskId, "slave-1", 1, 128)}, nil)
+			done <- true
+		}()
+		select {
+		case <-launched:
+		case <-time.After(3 * time.Second):
+			t.Fatal("Master did not receive the launch of", taskId)
+		}
+		// a status update may arrive before the master answers the launch
+		if _, ok := driver.Tasks.Get(NewTaskID(taskId)); !ok {
+			t.Fatal("Expected", taskId, "registered before the launch is answered.")
+		}
+		answer <- status
+		<-done
+	}
+
+	launch("offer-1", "task-1", http.StatusAccepted)
+	launch("offer-2", "task-2", http.StatusServiceUnavailable)
+	if _, ok := driver.Tasks.Get(NewTaskID("task-1")); !ok {
+		t.Fatal("Expected task-1 to stay registered after its launch.")
+	}
+	if _, ok := driver.Tasks.Get(NewTaskID("task-2")); ok {
+		t.Fatal("Expected task-2 unregistered after its launch failed.")
+	}
+
+	// a failed launch reusing the id of a terminal task restores its record
+	if err := driver.Tasks.Update(makeTaskUpdate("task-1", mesos.TaskState_TASK_FINISHED)); err != nil {
+		t.Fatal(err)
+	}
+	launch("offer-3", "task-1", http.StatusServiceUnavailable)
+	if record, ok := driver.Tasks.Get(NewTaskID("task-1")); !ok || record.State != mesos.TaskState_TASK_FINISHED {
+		t.Fatal("Expected finished task-1 kept after a failed launch of the same id, but got", record)
+	}
+
+	// a batch with a task that can not be registered is not sent
+	if err := driver.Tasks.Add(makeLaunchTask("task-3", "slave-1", 1, 128), nil); err != nil {
+		t.Fatal(err)
+	}
+	result := make(chan error, 1)
+	go func() {
+		tasks := []*mesos.TaskInfo{makeLaunchTask("task-4", "slave-1", 1, 128), makeLaunchTask("task-3", "slave-1", 1, 128)}
+		_, err := driver.LaunchTasksErr([]*mesos.OfferID{NewOfferID("offer-4")}, tasks, nil)
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Fatal("Expected error launching task-3, which is already registered.")
+		}
+	case <-launched:
+		t.Fatal("Master received a launch with a task that could not be registered.")
+	case <-time.After(3 * time.Second):
+		t.Fatal("LaunchTasksErr did not return.")
+	}
+	if _, ok := driver.Tasks.Get(NewTaskID("task-4")); ok {
+		t.Fatal("Expected task-4 unregistered after the launch failed.")
+	}
+}
@@ -0,0 +1,218 @@
+package gomes
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+)
+
+// TLS record type of a handshake message, the first byte sent by a TLS client.
+const tlsHandshakeRecord = 0x16
+
+/*
+TLSConfig configures encrypted transport for calls sent to the master and
+for the listener receiving events from the master. It mirrors the libprocess
+LIBPROCESS_SSL_* settings.
+*/
+type TLSConfig struct {
+	// Certificates served by the event listener. The same certificates are
+	// presented to the master when it asks for a client certificate.
+	Certificates []tls.Certificate
+
+	// CAs is used to verify the master certificate and, when verification is
+	// turned on, certificates presented to the event listener. When nil, the
+	// master certificate is verified against the system roots.
+	CAs *x509.CertPool
+
+	// VerifyCert verifies certificates presented to the event listener.
+	VerifyCert bool
+
+	// InsecureSkipVerify accepts any certificate presented by the master.
+	// It is meant for testing only.
+	InsecureSkipVerify bool
+
+	// RequireCert makes the event listener reject peers without a valid
+	// certificate (mutual authentication).
+	RequireCert bool
+
+	// SupportDowngrade lets the event listener accept unencrypted
+	// connections next to encrypted ones.
+	SupportDowngrade bool
+}
+
+// TLSConfigFromEnv builds a TLSConfig from the LIBPROCESS_SSL_* environment variables.
+// It returns nil when LIBPROCESS_SSL_ENABLED is not set to true.
+func TLSConfigFromEnv() (*TLSConfig, error) {
+	enabled, err := boolFromEnv(LIBPROCESS_SSL_ENABLED_ENV)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	config := new(TLSConfig)
+	if config.VerifyCert, err = boolFromEnv(LIBPROCESS_SSL_VERIFY_CERT_ENV); err != nil {
+		return nil, err
+	}
+	if config.RequireCert, err = boolFromEnv(LIBPROCESS_SSL_REQUIRE_CERT_ENV); err != nil {
+		return nil, err
+	}
+	if config.SupportDowngrade, err = boolFromEnv(LIBPROCESS_SSL_SUPPORT_DOWNGRADE_ENV); err != nil {
+		return nil, err
+	}
+
+	certFile := os.Getenv(LIBPROCESS_SSL_CERT_FILE_ENV)
+	keyFile := os.Getenv(LIBPROCESS_SSL_KEY_FILE_ENV)
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load TLS certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile := os.Getenv(LIBPROCESS_SSL_CA_FILE_ENV); caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read TLS CA file: %s", err)
+		}
+		config.CAs = x509.NewCertPool()
+		if !config.CAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("No certificates found in TLS CA file %s.", caFile)
+		}
+	}
+
+	// like libprocess, the master is trusted blindly unless asked otherwise
+	config.InsecureSkipVerify = !config.VerifyCert && config.CAs == nil
+
+	return config, nil
+}
+
+// clientConfig returns the tls.Config used for calls to the master.
+func (config *TLSConfig) clientConfig() *tls.Config {
+	return &tls.Config{
+		Certificates:       config.Certificates,
+		RootCAs:            config.CAs,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+}
+
+// serverConfig returns the tls.Config used by the event listener.
+func (config *TLSConfig) serverConfig() *tls.Config {
+	clientAuth := tls.NoClientCert
+	switch {
+	case config.RequireCert:
+		clientAuth = tls.RequireAndVerifyClientCert
+	case config.VerifyCert:
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	return &tls.Config{
+		Certificates: config.Certificates,
+		ClientCAs:    config.CAs,
+		ClientAuth:   clientAuth,
+	}
+}
+
+// newTLSListener wraps l so accepted connections are encrypted.
+// With SupportDowngrade, connections that do not open with a TLS handshake
+// are served in plain text.
+func newTLSListener(l net.Listener, config *TLSConfig) (net.Listener, error) {
+	if len(config.Certificates) == 0 {
+		return nil, fmt.Errorf("TLS is enabled but no certificate is configured.")
+	}
+	if !config.SupportDowngrade {
+		return tls.NewListener(l, config.serverConfig()), nil
+	}
+	return newDowngradeListener(l, config.serverConfig()), nil
+}
+
+// downgradeListener accepts both TLS and plain text connections. The first
+// byte of each connection is sniffed on its own goroutine, so a slow client
+// cannot block Accept. TLS connections are handed out as *tls.Conn, which
+// lets http.Server fill in Request.TLS.
+type downgradeListener struct {
+	net.Listener
+	config   *tls.Config
+	accepted chan acceptedConn
+	done     chan struct{}
+	closing  sync.Once
+}
+
+type acceptedConn struct {
+	conn net.Conn
+	err  error
+}
+
+func newDowngradeListener(l net.Listener, config *tls.Config) *downgradeListener {
+	listener := &downgradeListener{
+		Listener: l,
+		config:   config,
+		accepted: make(chan acceptedConn),
+		done:     make(chan struct{}),
+	}
+	go listener.acceptConns()
+	return listener
+}
+
+func (l *downgradeListener) acceptConns() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err == nil {
+			go l.sniff(conn)
+			continue
+		}
+		select {
+		case l.accepted <- acceptedConn{err: err}:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// sniff reads the first byte of conn to decide between TLS and plain text.
+func (l *downgradeListener) sniff(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	var sniffed net.Conn = &sniffedConn{Conn: conn, reader: reader}
+	if first[0] == tlsHandshakeRecord {
+		sniffed = tls.Server(sniffed, l.config)
+	}
+	select {
+	case l.accepted <- acceptedConn{conn: sniffed}:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *downgradeListener) Accept() (net.Conn, error) {
+	select {
+	case accepted := <-l.accepted:
+		return accepted.conn, accepted.err
+	case <-l.done:
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Addr: l.Addr(), Err: net.ErrClosed}
+	}
+}
+
+func (l *downgradeListener) Close() error {
+	l.closing.Do(func() {
+		close(l.done)
+	})
+	return l.Listener.Close()
+}
+
+// sniffedConn replays the bytes buffered while sniffing.
+type sniffedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *sniffedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
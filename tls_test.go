@@ -0,0 +1,270 @@
+package gomes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	mesos "github.com/vladimirvivien/gomes/mesosproto"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeTestTLSConfig(t *testing.T) (*TLSConfig, *testCert) {
+	ca := makeTestCert(t, "test-ca", true, nil)
+	cert := makeTestCert(t, "test-sched", false, ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.x509)
+	return &TLSConfig{
+		Certificates: []tls.Certificate{cert.cert},
+		CAs:          pool,
+		VerifyCert:   true,
+	}, ca
+}
+
+// isUnknownAuthority tells whether err is the rejection of a certificate
+// signed by an unknown CA.
+func isUnknownAuthority(err error) bool {
+	var unknown x509.UnknownAuthorityError
+	return errors.As(err, &unknown)
+}
+
+func TestMasterClient_WithMutualTLS(t *testing.T) {
+	config, _ := makeTestTLSConfig(t)
+	config.RequireCert = true
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+			t.Errorf("Expected client certificate from masterClient.")
+		}
+		rsp.WriteHeader(http.StatusAccepted)
+	}))
+	server.TLS = config.serverConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	master := newMasterClient(u.Host)
+	master.useTLS(config)
+	err := master.DeactivateFramework(newSchedProcID(":7000"), NewFrameworkID("test-framework-1"))
+	if err != nil {
+		t.Fatal("masterClient failed to send over TLS:", err)
+	}
+}
+
+func TestMasterClient_WithTLS_RejectsUnknownCA(t *testing.T) {
+	config, _ := makeTestTLSConfig(t)
+	other, _ := makeTestTLSConfig(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.WriteHeader(http.StatusAccepted)
+	}))
+	server.TLS = other.serverConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	master := newMasterClient(u.Host)
+	master.useTLS(config)
+	err := master.DeactivateFramework(newSchedProcID(":7000"), NewFrameworkID("test-framework-1"))
+	if !isUnknownAuthority(err) {
+		t.Fatal("masterClient expected to reject master certificate from unknown CA, but got", err)
+	}
+
+	// the master certificate is verified whether or not VerifyCert is set
+	config.VerifyCert = false
+	master.useTLS(config)
+	err = master.DeactivateFramework(newSchedProcID(":7000"), NewFrameworkID("test-framework-1"))
+	if !isUnknownAuthority(err) {
+		t.Fatal("masterClient expected to verify master certificate without VerifyCert, but got", err)
+	}
+
+	// the master verifies client certificates given, so none is sent
+	config.InsecureSkipVerify = true
+	config.Certificates = nil
+	master.useTLS(config)
+	if err := master.DeactivateFramework(newSchedProcID(":7000"), NewFrameworkID("test-framework-1")); err != nil {
+		t.Fatal("masterClient expected to skip verification with InsecureSkipVerify, but got", err)
+	}
+}
+
+func TestSchedProcStart_WithTLS(t *testing.T) {
+	config, _ := makeTestTLSConfig(t)
+	config.RequireCert = true
+
+	proc, err := newSchedulerProcess(make(chan interface{}, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.bindIP = "127.0.0.1"
+	proc.tls = config
+	if err = proc.start(); err != nil {
+		t.Fatal("Error starting SchedProc with TLS:", err)
+	}
+	defer proc.stop()
+
+	addr := proc.listener.Addr().String()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config.clientConfig()}}
+	rsp, err := client.Get("https://" + addr + "/isalive")
+	if err != nil {
+		t.Fatal("Unable to reach SchedProc over TLS:", err)
+	}
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatal("Expected status OK from SchedProc over TLS, got", rsp.Status)
+	}
+
+	// no client certificate: mutual authentication fails
+	anonymous := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: config.CAs},
+	}}
+	if _, err = anonymous.Get("https://" + addr + "/isalive"); err == nil {
+		t.Fatal("SchedProc expected to reject client without certificate.")
+	}
+
+	// plain text not accepted without downgrade
+	if rsp, err := http.Get("http://" + addr + "/isalive"); err == nil && rsp.StatusCode == http.StatusOK {
+		t.Fatal("SchedProc expected to reject plain text connection.")
+	}
+}
+
+func TestSchedProcStart_WithTLSDowngrade(t *testing.T) {
+	config, _ := makeTestTLSConfig(t)
+	config.SupportDowngrade = true
+
+	proc, err := newSchedulerProcess(make(chan interface{}, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.bindIP = "127.0.0.1"
+	proc.tls = config
+	if err = proc.start(); err != nil {
+		t.Fatal("Error starting SchedProc with TLS:", err)
+	}
+	defer proc.stop()
+
+	addr := proc.listener.Addr().String()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config.clientConfig()}}
+	if rsp, err := client.Get("https://" + addr + "/isalive"); err != nil || rsp.StatusCode != http.StatusOK {
+		t.Fatal("Unable to reach downgrade SchedProc over TLS:", err)
+	}
+	if rsp, err := http.Get("http://" + addr + "/isalive"); err != nil || rsp.StatusCode != http.StatusOK {
+		t.Fatal("Unable to reach downgrade SchedProc in plain text:", err)
+	}
+}
+
+func TestDowngradeListener_RequestTLS(t *testing.T) {
+	config, _ := makeTestTLSConfig(t)
+	config.SupportDowngrade = true
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := newTLSListener(l, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		if req.TLS != nil {
+			rsp.Write([]byte("tls"))
+		} else {
+			rsp.Write([]byte("plain"))
+		}
+	})}
+	go server.Serve(listener)
+	defer listener.Close()
+
+	get := func(client *http.Client, u string) string {
+		rsp, err := client.Get(u)
+		if err != nil {
+			t.Fatal("Unable to reach downgrade listener:", err)
+		}
+		defer rsp.Body.Close()
+		body, _ := ioutil.ReadAll(rsp.Body)
+		return string(body)
+	}
+	addr := listener.Addr().String()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config.clientConfig()}}
+	if body := get(client, "https://"+addr+"/"); body != "tls" {
+		t.Fatal("Expected Request.TLS set over TLS, handler saw", body)
+	}
+	if body := get(http.DefaultClient, "http://"+addr+"/"); body != "plain" {
+		t.Fatal("Expected Request.TLS unset in plain text, handler saw", body)
+	}
+}
+
+func TestSchedProcStart_WithTLSMissingCertificate(t *testing.T) {
+	proc, err := newSchedulerProcess(make(chan interface{}, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.bindIP = "127.0.0.1"
+	proc.tls = &TLSConfig{}
+	if err = proc.start(); err == nil {
+		proc.stop()
+		t.Fatal("SchedProc expected to fail starting TLS without certificate.")
+	}
+}
+
+func TestTLSConfigFromEnv(t *testing.T) {
+	if config, err := TLSConfigFromEnv(); err != nil || config != nil {
+		t.Fatal("Expected no TLSConfig when LIBPROCESS_SSL_ENABLED is unset.")
+	}
+
+	ca := makeTestCert(t, "test-ca", true, nil)
+	cert := makeTestCert(t, "test-sched", false, ca)
+	dir, err := ioutil.TempDir("", "gomes-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string][]byte{"cert.pem": cert.certPEM, "key.pem": cert.keyPEM, "ca.pem": ca.certPEM}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := map[string]string{
+		LIBPROCESS_SSL_ENABLED_ENV:           "true",
+		LIBPROCESS_SSL_CERT_FILE_ENV:         filepath.Join(dir, "cert.pem"),
+		LIBPROCESS_SSL_KEY_FILE_ENV:          filepath.Join(dir, "key.pem"),
+		LIBPROCESS_SSL_CA_FILE_ENV:           filepath.Join(dir, "ca.pem"),
+		LIBPROCESS_SSL_REQUIRE_CERT_ENV:      "1",
+		LIBPROCESS_SSL_SUPPORT_DOWNGRADE_ENV: "true",
+	}
+	for name, val := range env {
+		os.Setenv(name, val)
+		defer os.Unsetenv(name)
+	}
+
+	config, err := TLSConfigFromEnv()
+	if err != nil {
+		t.Fatal("Unable to load TLSConfig from env:", err)
+	}
+	if len(config.Certificates) != 1 || config.CAs == nil {
+		t.Fatal("TLSConfig missing certificate or CA from env.")
+	}
+	if !config.RequireCert || !config.SupportDowngrade || config.VerifyCert || config.InsecureSkipVerify {
+		t.Fatal("TLSConfig flags not read from env:", config.RequireCert, config.SupportDowngrade, config.VerifyCert, config.InsecureSkipVerify)
+	}
+
+	driver, err := NewSchedDriver(nil, &mesos.FrameworkInfo{}, "localhost:5050")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if driver.TLS == nil {
+		t.Fatal("SchedDriver not reading TLS settings from env.")
+	}
+
+	os.Setenv(LIBPROCESS_SSL_VERIFY_CERT_ENV, "maybe")
+	defer os.Unsetenv(LIBPROCESS_SSL_VERIFY_CERT_ENV)
+	if _, err = TLSConfigFromEnv(); err == nil {
+		t.Fatal("Expected error for invalid LIBPROCESS_SSL_VERIFY_CERT value.")
+	}
+}
@@ -0,0 +1,41 @@
+package gomes
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// upid identifies a libprocess process with the form id@host:port.
+// The host may be an IPv4 address, a bracketed IPv6 literal or a hostname.
+type upid struct {
+	id   string
+	host string
+	port string
+}
+
+// parseUPID parses values such as master@10.0.0.1:5050,
+// master@[2001:db8::1]:5050 or master@mesos-master.local:5050.
+func parseUPID(value string) (*upid, error) {
+	at := strings.Index(value, "@")
+	if at <= 0 {
+		return nil, fmt.Errorf("Invalid process id %s: missing id@ prefix.", value)
+	}
+	host, port, err := net.SplitHostPort(value[at+1:])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid process id %s: %s", value, err)
+	}
+	if host == "" {
+		return nil, fmt.Errorf("Invalid process id %s: missing host.", value)
+	}
+	return &upid{id: value[:at], host: host, port: port}, nil
+}
+
+// hostPort returns the dialable host:port of the process.
+func (pid *upid) hostPort() string {
+	return net.JoinHostPort(pid.host, pid.port)
+}
+
+func (pid *upid) String() string {
+	return pid.id + "@" + pid.hostPort()
+}
@@ -0,0 +1,38 @@
+package gomes
+
+import (
+	"testing"
+)
+
+func TestParseUPID(t *testing.T) {
+	tests := []struct {
+		value    string
+		id       string
+		host     string
+		hostPort string
+	}{
+		{"master@10.0.0.1:5050", "master", "10.0.0.1", "10.0.0.1:5050"},
+		{"master@[2001:db8::1]:5050", "master", "2001:db8::1", "[2001:db8::1]:5050"},
+		{"scheduler(1)@mesos-master.local:5050", "scheduler(1)", "mesos-master.local", "mesos-master.local:5050"},
+	}
+	for _, test := range tests {
+		pid, err := parseUPID(test.value)
+		if err != nil {
+			t.Fatal("Unable to parse UPID", test.value, err)
+		}
+		if pid.id != test.id || pid.host != test.host || pid.hostPort() != test.hostPort {
+			t.Fatalf("UPID %s parsed as id=%s host=%s hostPort=%s", test.value, pid.id, pid.host, pid.hostPort())
+		}
+		if pid.String() != test.value {
+			t.Fatalf("Expected UPID string %s, but got %s", test.value, pid.String())
+		}
+	}
+}
+
+func TestParseUPID_Malformed(t *testing.T) {
+	for _, value := range []string{"", "master", "@host:5050", "master@host", "master@:5050", "master@2001:db8::1:5050"} {
+		if _, err := parseUPID(value); err == nil {
+			t.Fatal("Expected error parsing malformed UPID", value)
+		}
+	}
+}
@@ -1,14 +1,26 @@
 package gomes
 
 import (
+	"crypto/rand"
+	"fmt"
 	"net"
 	"net/url"
+	"os"
 	"strconv"
-	"strings"
 )
 
 type address string
 
+// parseAddress validates a host:port string and returns it as an address.
+// IPv6 literals are bracketed, i.e. [::1]:5050.
+func parseAddress(hostPort string) (address, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return "", fmt.Errorf("Invalid address %s: %s", hostPort, err)
+	}
+	return address(net.JoinHostPort(host, port)), nil
+}
+
 func (addr address) AsFullHttpURL(path string) (*url.URL, error) {
 	return &url.URL{
 		Scheme: HTTP_SCHEME,
@@ -24,27 +36,117 @@ func (addr address) AsHttpURL() (*url.URL, error) {
 	}, nil
 }
 
-func localIP4String() string {
+// resolveHostPort returns hostPort followed by the ip:port form of every
+// address its host resolves to. Hosts that fail to resolve are returned as is.
+// It blocks on the resolver, so it must not run while requests are served.
+func resolveHostPort(hostPort string) []string {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return []string{hostPort}
+	}
+	result := []string{normalizeHostPort(hostPort)}
+	if net.ParseIP(host) != nil {
+		return result
+	}
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		return result
+	}
+	for _, ip := range ips {
+		result = append(result, net.JoinHostPort(ip, port))
+	}
+	return result
+}
+
+// normalizeHostPort returns hostPort with an ip host in canonical form, so
+// that equal addresses compare equal whatever their notation.
+func normalizeHostPort(hostPort string) string {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return hostPort
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// localIPString returns the first non-loopback IPv4 address of the host.
+// When the host has no IPv4 address, the first global IPv6 address is used.
+func localIPString() string {
 	addrs, _ := net.InterfaceAddrs()
+	var ip6 string
 	for _, addr := range addrs {
 		switch addr.(type) {
 		case *net.IPNet:
-			ip := addr.(*net.IPNet)
-			if !ip.IP.IsLoopback() && ip.IP.To4() != nil {
-				return ip.String()[:strings.LastIndex(ip.String(), "/")]
+			ip := addr.(*net.IPNet).IP
+			if ip.IsLoopback() {
+				continue
+			}
+			if ip.To4() != nil {
+				return ip.String()
+			}
+			if ip6 == "" && ip.IsGlobalUnicast() {
+				ip6 = ip.String()
 			}
 		}
 	}
-	return ""
+	return ip6
+}
+
+// portFromEnv returns the port number stored in environment variable name,
+// or 0 if the variable is not set.
+func portFromEnv(name string) (int, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return 0, nil
+	}
+	port, err := strconv.Atoi(val)
+	if err != nil || port < 0 || port > 65535 {
+		return 0, fmt.Errorf("Invalid port value %s=%s.", name, val)
+	}
+	return port, nil
 }
 
-func nextTcpPort() int {
-	l, err := net.Listen("tcp4", ":0")
-	defer l.Close()
-	addr := l.Addr().String()
-	port, err := strconv.Atoi(addr[strings.LastIndex(addr, ":")+1:])
+// boolFromEnv returns the boolean stored in environment variable name,
+// or false if the variable is not set.
+func boolFromEnv(name string) (bool, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(val)
 	if err != nil {
-		return 0
+		return false, fmt.Errorf("Invalid boolean value %s=%s.", name, val)
 	}
-	return port
+	return b, nil
+}
+
+// loopbackAddr returns addr, where a listener bound to bindIP accepts, as a
+// host:port string that can be dialed locally. An unspecified host (0.0.0.0
+// or ::) is replaced with the loopback address of the family of bindIP, as
+// a listener bound to 0.0.0.0 may report [::].
+func loopbackAddr(addr net.Addr, bindIP string) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || !tcpAddr.IP.IsUnspecified() {
+		return addr.String()
+	}
+	ip := net.ParseIP(bindIP)
+	if ip == nil {
+		ip = tcpAddr.IP
+	}
+	loopback := "127.0.0.1"
+	if ip.To4() == nil {
+		loopback = "::1"
+	}
+	return net.JoinHostPort(loopback, strconv.Itoa(tcpAddr.Port))
+}
+
+// newUUID returns a random (version 4) UUID in its 16 bytes binary form.
+func newUUID() []byte {
+	uuid := make([]byte, 16)
+	rand.Read(uuid)
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return uuid
 }